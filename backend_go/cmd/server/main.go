@@ -2,14 +2,30 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"google.golang.org/grpc"
+
 	"backend_go/internal/config"
+	"backend_go/internal/domain"
 	"backend_go/internal/httpserver"
 	"backend_go/internal/security"
 	"backend_go/internal/store/postgres"
@@ -36,6 +52,13 @@ import (
 // @name Authorization
 
 func main() {
+	// "ca" subcommands bootstrap and manage the mTLS trust chain for service
+	// accounts; everything else falls through to the usual server startup.
+	if len(os.Args) > 1 && os.Args[1] == "ca" {
+		runCA(os.Args[2:])
+		return
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -53,21 +76,66 @@ func main() {
 		log.Fatalf("failed to run migrations: %v", err)
 	}
 
+	if cfg.BootstrapAdminUsername != "" {
+		if err := bootstrapAdmin(context.Background(), db, cfg.BootstrapAdminUsername); err != nil {
+			log.Fatalf("failed to bootstrap admin: %v", err)
+		}
+	}
+
 	// Security components
-	tokenSvc := security.NewTokenService(cfg.JWTSecret, time.Duration(cfg.AccessTokenMinutes)*time.Minute)
-	passwordHasher := security.NewPasswordHasher(0)
+	jwtKeys, err := security.LoadOrGenerateKeySet(cfg.JWTSigningAlg, cfg.JWTKeyID, cfg.JWTPrivateKeyPath)
+	if err != nil {
+		log.Fatalf("failed to initialize JWT key set: %v", err)
+	}
+	tokenSvc := security.NewTokenService(jwtKeys, time.Duration(cfg.AccessTokenMinutes)*time.Minute)
+	passwordHasher := security.NewPasswordWrapper(security.Argon2Params{})
 
-	encryptor, err := security.NewEncryptor([]byte(cfg.EncryptKey))
+	encryptor, err := security.NewEncryptor([]byte(cfg.EncryptKey), nil)
 	if err != nil {
 		log.Fatalf("failed to initialize encryptor: %v", err)
 	}
 
+	// Tracing: OTEL_EXPORTER_OTLP_ENDPOINT set means export spans over OTLP
+	// gRPC to a collector; left empty, otel's no-op global tracer is used
+	// and the decorators in internal/service/middleware record nothing.
+	if cfg.OTELExporterEndpoint != "" {
+		tp, err := newTracerProvider(cfg.OTELExporterEndpoint)
+		if err != nil {
+			log.Fatalf("failed to initialize OTLP tracer provider: %v", err)
+		}
+		otel.SetTracerProvider(tp)
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := tp.Shutdown(shutdownCtx); err != nil {
+				log.Printf("tracer provider shutdown: %v", err)
+			}
+		}()
+	}
+
+	// Broker fans ws broadcasts and presence out across replicas; with no
+	// REDIS_URL configured every instance just runs its own in-memory hub.
+	var broker ws.Broker
+	if cfg.RedisURL != "" {
+		opts, err := redis.ParseURL(cfg.RedisURL)
+		if err != nil {
+			log.Fatalf("invalid REDIS_URL: %v", err)
+		}
+		broker = ws.NewRedisBroker(redis.NewClient(opts))
+	} else {
+		broker = ws.NewMemoryBroker()
+	}
+
 	// Initialize WebSocket hub
-	hub := ws.NewHub()
-	go hub.Run()
+	instanceID := uuid.New().String()
+	presenceTTL := time.Duration(cfg.PresenceTTLSeconds) * time.Second
+	hub := ws.NewHub(broker, instanceID, presenceTTL)
+	hubCtx, cancelHub := context.WithCancel(context.Background())
+	defer cancelHub()
+	go hub.Run(hubCtx)
 
-	// Build HTTP router
-	router := httpserver.NewRouter(cfg, db, hub, tokenSvc, passwordHasher, encryptor)
+	// Build HTTP router (and, if GRPCEnabled, the gRPC server that mirrors it)
+	router, grpcSrv := httpserver.NewRouter(cfg, db, hub, tokenSvc, passwordHasher, encryptor)
 
 	srv := &http.Server{
 		Addr:         cfg.HTTPAddr(),
@@ -77,14 +145,54 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
+	// TLSClientCAPath, if set, turns on optional mTLS: a verified client
+	// certificate flows through to AuthMiddleware's service-account path,
+	// but its absence doesn't reject the connection (human browsers have no
+	// client cert and keep authenticating with Bearer tokens).
+	if cfg.TLSClientCAPath != "" {
+		pool := x509.NewCertPool()
+		caPEM, err := os.ReadFile(cfg.TLSClientCAPath)
+		if err != nil {
+			log.Fatalf("failed to read TLS_CLIENT_CA_PATH: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(caPEM) {
+			log.Fatalf("no certificates found in TLS_CLIENT_CA_PATH %s", cfg.TLSClientCAPath)
+		}
+		srv.TLSConfig = &tls.Config{
+			ClientAuth: tls.VerifyClientCertIfGiven,
+			ClientCAs:  pool,
+		}
+	}
+
 	// Start server in background
 	go func() {
 		log.Printf("Starting zChat Go server on %s\n", cfg.HTTPAddr())
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("server error: %v", err)
+		var serveErr error
+		if cfg.TLSCertPath != "" && cfg.TLSKeyPath != "" {
+			serveErr = srv.ListenAndServeTLS(cfg.TLSCertPath, cfg.TLSKeyPath)
+		} else {
+			serveErr = srv.ListenAndServe()
+		}
+		if serveErr != nil && serveErr != http.ErrServerClosed {
+			log.Fatalf("server error: %v", serveErr)
 		}
 	}()
 
+	if grpcSrv != nil {
+		lis, err := net.Listen("tcp", cfg.GRPCAddr())
+		if err != nil {
+			log.Fatalf("failed to listen on GRPC_PORT: %v", err)
+		}
+		go func() {
+			log.Printf("Starting zChat gRPC server on %s\n", cfg.GRPCAddr())
+			// Serve returns (non-nil) grpc.ErrServerStopped once GracefulStop
+			// runs during shutdown below; that's expected, not a failure.
+			if err := grpcSrv.Serve(lis); err != nil && err != grpc.ErrServerStopped {
+				log.Fatalf("grpc server error: %v", err)
+			}
+		}()
+	}
+
 	// Graceful shutdown
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
@@ -97,4 +205,178 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Printf("graceful shutdown failed: %v", err)
 	}
+	if grpcSrv != nil {
+		grpcSrv.GracefulStop()
+	}
+}
+
+
+// bootstrapAdmin promotes username to domain.GlobalRoleAdmin if no admin
+// exists yet, so a fresh deployment has a way to reach the admin API without
+// a human ever touching the database directly. It's a no-op once any admin
+// exists, so setting BOOTSTRAP_ADMIN_USERNAME permanently is harmless.
+func bootstrapAdmin(ctx context.Context, db *sql.DB, username string) error {
+	userRepo := postgres.NewUserRepo(db)
+
+	count, err := userRepo.CountByRole(ctx, domain.GlobalRoleAdmin)
+	if err != nil {
+		return fmt.Errorf("count admins: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	user, err := userRepo.GetByUsername(ctx, username)
+	if err != nil {
+		return fmt.Errorf("get bootstrap admin user: %w", err)
+	}
+	if user == nil {
+		log.Printf("bootstrap admin: user %q does not exist yet, skipping", username)
+		return nil
+	}
+
+	if err := userRepo.SetRole(ctx, user.ID, domain.GlobalRoleAdmin); err != nil {
+		return fmt.Errorf("set bootstrap admin role: %w", err)
+	}
+	log.Printf("bootstrap admin: promoted %q to admin", username)
+	return nil
+}
+
+// newTracerProvider builds a TracerProvider that batches spans to an OTLP
+// gRPC collector at endpoint (host:port, no scheme).
+func newTracerProvider(endpoint string) (*sdktrace.TracerProvider, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	exporter, err := otlptracegrpc.New(ctx,
+		otlptracegrpc.WithEndpoint(endpoint),
+		otlptracegrpc.WithInsecure(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.New(ctx, resource.WithAttributes(
+		semconv.ServiceName("zchat-backend"),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	), nil
+}
+
+// runCA dispatches the "ca" subcommands used to bootstrap and manage the
+// mTLS client-certificate trust chain for service accounts: init generates
+// a new CA, sign-agent issues a per-agent client certificate embedding its
+// identity claim, and revoke adds a certificate's serial number to the
+// Postgres revocation list AuthMiddleware consults on every mTLS request.
+func runCA(args []string) {
+	if len(args) < 1 {
+		log.Fatal("usage: zchat-server ca <init|sign-agent|revoke> [flags]")
+	}
+	switch args[0] {
+	case "init":
+		runCAInit(args[1:])
+	case "sign-agent":
+		runCASignAgent(args[1:])
+	case "revoke":
+		runCARevoke(args[1:])
+	default:
+		log.Fatalf("unknown ca subcommand %q", args[0])
+	}
+}
+
+func runCAInit(args []string) {
+	fs := flag.NewFlagSet("ca init", flag.ExitOnError)
+	cn := fs.String("cn", "zchat mTLS CA", "CA certificate common name")
+	days := fs.Int("days", 3650, "validity in days")
+	certOut := fs.String("cert-out", "ca-cert.pem", "path to write the CA certificate")
+	keyOut := fs.String("key-out", "ca-key.pem", "path to write the CA private key")
+	fs.Parse(args)
+
+	certPEM, keyPEM, err := security.GenerateCA(*cn, time.Duration(*days)*24*time.Hour)
+	if err != nil {
+		log.Fatalf("generate CA: %v", err)
+	}
+	if err := os.WriteFile(*certOut, certPEM, 0o644); err != nil {
+		log.Fatalf("write CA certificate: %v", err)
+	}
+	if err := os.WriteFile(*keyOut, keyPEM, 0o600); err != nil {
+		log.Fatalf("write CA key: %v", err)
+	}
+	log.Printf("wrote CA certificate to %s and key to %s", *certOut, *keyOut)
+}
+
+func runCASignAgent(args []string) {
+	fs := flag.NewFlagSet("ca sign-agent", flag.ExitOnError)
+	name := fs.String("name", "", "agent username; must match an existing IsServiceAccount user")
+	days := fs.Int("days", 365, "validity in days")
+	caCertPath := fs.String("ca-cert", "ca-cert.pem", "path to the CA certificate")
+	caKeyPath := fs.String("ca-key", "ca-key.pem", "path to the CA private key")
+	certOut := fs.String("cert-out", "", "path to write the agent certificate (default <name>-cert.pem)")
+	keyOut := fs.String("key-out", "", "path to write the agent private key (default <name>-key.pem)")
+	fs.Parse(args)
+
+	if *name == "" {
+		log.Fatal("-name is required")
+	}
+	if *certOut == "" {
+		*certOut = *name + "-cert.pem"
+	}
+	if *keyOut == "" {
+		*keyOut = *name + "-key.pem"
+	}
+
+	caCertPEM, err := os.ReadFile(*caCertPath)
+	if err != nil {
+		log.Fatalf("read CA certificate: %v", err)
+	}
+	caKeyPEM, err := os.ReadFile(*caKeyPath)
+	if err != nil {
+		log.Fatalf("read CA key: %v", err)
+	}
+
+	certPEM, keyPEM, serialHex, err := security.SignAgentCert(caCertPEM, caKeyPEM, *name, time.Duration(*days)*24*time.Hour)
+	if err != nil {
+		log.Fatalf("sign agent certificate: %v", err)
+	}
+	if err := os.WriteFile(*certOut, certPEM, 0o644); err != nil {
+		log.Fatalf("write agent certificate: %v", err)
+	}
+	if err := os.WriteFile(*keyOut, keyPEM, 0o600); err != nil {
+		log.Fatalf("write agent key: %v", err)
+	}
+	log.Printf("wrote agent certificate to %s (serial %s) and key to %s", *certOut, serialHex, *keyOut)
+}
+
+func runCARevoke(args []string) {
+	fs := flag.NewFlagSet("ca revoke", flag.ExitOnError)
+	serial := fs.String("serial", "", "certificate serial number in hex, as printed by ca sign-agent")
+	subject := fs.String("subject", "", "agent username the certificate was issued to")
+	reason := fs.String("reason", "", "free-text revocation reason")
+	fs.Parse(args)
+
+	if *serial == "" {
+		log.Fatal("-serial is required")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	db, err := postgres.Open(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	certRevocations := postgres.NewCertRevocationRepo(db)
+	if err := certRevocations.Revoke(context.Background(), *serial, *subject, *reason); err != nil {
+		log.Fatalf("revoke certificate: %v", err)
+	}
+	log.Printf("revoked certificate with serial %s", *serial)
 }
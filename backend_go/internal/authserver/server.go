@@ -0,0 +1,333 @@
+// Package authserver implements the authorization-code (+ PKCE) half of an
+// OIDC provider, letting third-party applications registered in
+// domain.OAuthClientRepository request zchat-issued access and ID tokens for
+// a zchat user — the mirror image of internal/auth/oidc, which lets zchat
+// act as a relying party to upstream IdPs instead.
+package authserver
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"backend_go/internal/auth/oidc"
+	"backend_go/internal/domain"
+	"backend_go/internal/security"
+)
+
+var (
+	ErrInvalidClient     = errors.New("unknown oauth client")
+	ErrInvalidRedirect   = errors.New("redirect_uri is not registered for this client")
+	ErrPKCERequired      = errors.New("public clients must present a code_challenge using S256")
+	ErrUnsupportedGrant  = errors.New("unsupported response_type or grant_type")
+	ErrInvalidGrant      = errors.New("authorization code is invalid, expired, or already used")
+	ErrInvalidClientAuth = errors.New("client authentication failed")
+	ErrInvalidToken      = errors.New("access token was not issued by this authorization server")
+)
+
+// codeTTL bounds how long an authorization code is redeemable, per RFC 6749
+// §4.1.2's "short lived" recommendation.
+const codeTTL = 5 * time.Minute
+
+// supportedScopes are the OIDC scopes this authorization server will ever
+// grant; anything else requested is silently dropped, per the spec, rather
+// than rejected.
+var supportedScopes = map[string]bool{"openid": true, "profile": true, "email": true}
+
+// Server issues authorization codes and tokens for third-party OAuth
+// clients on behalf of already-authenticated zchat users.
+type Server struct {
+	clients      domain.OAuthClientRepository
+	authRequests domain.AuthRequestRepository
+	users        domain.UserRepository
+	tokens       *security.TokenService
+	issuer       string
+	tokenTTL     time.Duration
+}
+
+// NewServer builds a Server. issuer is this zchat instance's public base
+// URL, used both as the OIDC "iss" and to build the endpoint URLs in the
+// discovery document. tokenTTL bounds the lifetime of the access and ID
+// tokens minted by Exchange.
+func NewServer(clients domain.OAuthClientRepository, authRequests domain.AuthRequestRepository, users domain.UserRepository, tokens *security.TokenService, issuer string, tokenTTL time.Duration) *Server {
+	return &Server{
+		clients:      clients,
+		authRequests: authRequests,
+		users:        users,
+		tokens:       tokens,
+		issuer:       issuer,
+		tokenTTL:     tokenTTL,
+	}
+}
+
+// AuthorizeRequest is the parsed query string of a GET /oauth/authorize call.
+type AuthorizeRequest struct {
+	ClientID            string
+	RedirectURI         string
+	ResponseType        string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// Authorize validates req against its registered client and mints a
+// single-use authorization code for userID, the zchat user who has already
+// logged in and is approving the grant. It returns the URL the caller
+// should redirect the browser to next.
+func (s *Server) Authorize(ctx context.Context, req AuthorizeRequest, userID int64) (string, error) {
+	if req.ResponseType != "code" {
+		return "", ErrUnsupportedGrant
+	}
+	client, err := s.clients.GetByID(ctx, req.ClientID)
+	if err != nil {
+		return "", fmt.Errorf("look up client: %w", err)
+	}
+	if client == nil {
+		return "", ErrInvalidClient
+	}
+	if !containsString(client.RedirectURIs, req.RedirectURI) {
+		return "", ErrInvalidRedirect
+	}
+
+	isPublic := client.ClientSecret == ""
+	if isPublic && (req.CodeChallenge == "" || req.CodeChallengeMethod != "S256") {
+		return "", ErrPKCERequired
+	}
+
+	code, err := randomToken(32)
+	if err != nil {
+		return "", fmt.Errorf("generate authorization code: %w", err)
+	}
+	authReq := &domain.AuthRequest{
+		Code:                code,
+		ClientID:            client.ClientID,
+		UserID:              userID,
+		RedirectURI:         req.RedirectURI,
+		Scope:               filterScopes(req.Scope, client.AllowedScopes),
+		State:               req.State,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		ExpiresAt:           time.Now().Add(codeTTL),
+	}
+	if err := s.authRequests.Create(ctx, authReq); err != nil {
+		return "", fmt.Errorf("persist authorization code: %w", err)
+	}
+
+	redirect, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		return "", fmt.Errorf("parse redirect_uri: %w", err)
+	}
+	q := redirect.Query()
+	q.Set("code", code)
+	if req.State != "" {
+		q.Set("state", req.State)
+	}
+	redirect.RawQuery = q.Encode()
+	return redirect.String(), nil
+}
+
+// TokenResult is the standard OAuth2 token response body for the
+// authorization_code grant.
+type TokenResult struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token,omitempty"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// Exchange redeems a single-use authorization code for an access token
+// (and, when the openid scope was granted, an ID token), per RFC 6749
+// §4.1.3 and OIDC Core §3.1.3.3.
+func (s *Server) Exchange(ctx context.Context, clientID, clientSecret, code, redirectURI, codeVerifier string) (*TokenResult, error) {
+	client, err := s.clients.GetByID(ctx, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("look up client: %w", err)
+	}
+	if client == nil {
+		return nil, ErrInvalidClient
+	}
+	if client.ClientSecret != "" && subtle.ConstantTimeCompare([]byte(client.ClientSecret), []byte(clientSecret)) != 1 {
+		return nil, ErrInvalidClientAuth
+	}
+
+	authReq, err := s.authRequests.GetByCode(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("look up authorization code: %w", err)
+	}
+	if authReq == nil || authReq.Used || authReq.ClientID != clientID || authReq.RedirectURI != redirectURI || time.Now().After(authReq.ExpiresAt) {
+		return nil, ErrInvalidGrant
+	}
+	if authReq.CodeChallenge != "" && oidc.CodeChallenge(codeVerifier) != authReq.CodeChallenge {
+		return nil, ErrInvalidGrant
+	}
+	if err := s.authRequests.MarkUsed(ctx, code); err != nil {
+		return nil, fmt.Errorf("mark authorization code used: %w", err)
+	}
+
+	user, err := s.users.GetByID(ctx, authReq.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("look up user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrInvalidGrant
+	}
+
+	// amr:"oauth" marks this as a third-party-client token scoped to
+	// authReq.Scope, not a zchat login session — security.AuthenticateBearer
+	// rejects it outright, so it can only ever be used at /oauth/userinfo.
+	accessToken, err := s.tokens.CreateWithExtra(user.Username, s.tokenTTL, jwt.MapClaims{
+		"aud":   clientID,
+		"scope": authReq.Scope,
+		"amr":   []string{"oauth"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("mint access token: %w", err)
+	}
+
+	result := &TokenResult{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.tokenTTL.Seconds()),
+		Scope:       authReq.Scope,
+	}
+
+	if scopeContains(authReq.Scope, "openid") {
+		idClaims := jwt.MapClaims{"aud": clientID}
+		if scopeContains(authReq.Scope, "profile") {
+			idClaims["preferred_username"] = user.Username
+		}
+		if scopeContains(authReq.Scope, "email") && user.Email != nil {
+			idClaims["email"] = *user.Email
+		}
+		idToken, err := s.tokens.CreateWithExtra(user.Username, s.tokenTTL, idClaims)
+		if err != nil {
+			return nil, fmt.Errorf("mint id token: %w", err)
+		}
+		result.IDToken = idToken
+	}
+	return result, nil
+}
+
+// UserInfo validates an access token minted by Exchange and returns the
+// subset of the user's claims its granted scope allows, per the OIDC
+// UserInfo endpoint contract.
+func (s *Server) UserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error) {
+	claims, err := s.tokens.Parse(accessToken)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	clientID, _ := claims["aud"].(string)
+	if clientID == "" {
+		return nil, ErrInvalidToken
+	}
+	client, err := s.clients.GetByID(ctx, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("look up client: %w", err)
+	}
+	if client == nil {
+		return nil, ErrInvalidToken
+	}
+
+	username, _ := claims["sub"].(string)
+	user, err := s.users.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("look up user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrInvalidToken
+	}
+
+	scope, _ := claims["scope"].(string)
+	info := map[string]interface{}{"sub": username}
+	if scopeContains(scope, "profile") {
+		info["preferred_username"] = user.Username
+	}
+	if scopeContains(scope, "email") && user.Email != nil {
+		info["email"] = *user.Email
+	}
+	return info, nil
+}
+
+// DiscoveryDocument is the JSON body served at
+// /.well-known/openid-configuration.
+type DiscoveryDocument struct {
+	Issuer                           string   `json:"issuer"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint"`
+	TokenEndpoint                    string   `json:"token_endpoint"`
+	UserInfoEndpoint                 string   `json:"userinfo_endpoint"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	ResponseTypesSupported           []string `json:"response_types_supported"`
+	SubjectTypesSupported            []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                  []string `json:"scopes_supported"`
+	GrantTypesSupported              []string `json:"grant_types_supported"`
+	CodeChallengeMethodsSupported    []string `json:"code_challenge_methods_supported"`
+}
+
+// Discovery builds the OIDC discovery document advertising this server's
+// endpoints. jwks_uri points at the same key set every other zchat-issued
+// token already publishes at /.well-known/jwks.json — this package mints no
+// keys of its own.
+func (s *Server) Discovery(signingAlg string) DiscoveryDocument {
+	return DiscoveryDocument{
+		Issuer:                           s.issuer,
+		AuthorizationEndpoint:            s.issuer + "/oauth/authorize",
+		TokenEndpoint:                    s.issuer + "/oauth/token",
+		UserInfoEndpoint:                 s.issuer + "/oauth/userinfo",
+		JWKSURI:                          s.issuer + "/.well-known/jwks.json",
+		ResponseTypesSupported:           []string{"code"},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: []string{signingAlg},
+		ScopesSupported:                  []string{"openid", "profile", "email"},
+		GrantTypesSupported:              []string{"authorization_code"},
+		CodeChallengeMethodsSupported:    []string{"S256"},
+	}
+}
+
+func containsString(list []string, want string) bool {
+	for _, v := range list {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+// filterScopes intersects the requested scope string with both the scopes
+// this server grants at all and the ones client is allowed to request.
+func filterScopes(requested string, allowed []string) string {
+	var kept []string
+	for _, scope := range strings.Fields(requested) {
+		if supportedScopes[scope] && containsString(allowed, scope) {
+			kept = append(kept, scope)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+func scopeContains(scope, want string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate random bytes: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,30 @@
+// Package authz makes site-wide authorization decisions based on a user's
+// domain.GlobalRole, complementing internal/policies, which makes
+// per-conversation decisions based on domain.ConversationRole. A global
+// moderator/admin bypasses policies.Authorizer entirely: they can act on a
+// conversation regardless of their (possibly nonexistent) standing in it.
+package authz
+
+import "backend_go/internal/domain"
+
+// rank orders domain.GlobalRole so Atleast is a single integer compare.
+var rank = map[domain.GlobalRole]int{
+	domain.GlobalRoleUser:      0,
+	domain.GlobalRoleModerator: 1,
+	domain.GlobalRoleAdmin:     2,
+}
+
+// Atleast reports whether role meets or exceeds min; an unrecognized role on
+// either side satisfies nothing, so a stale or empty Role never grants
+// access by accident.
+func Atleast(role, min domain.GlobalRole) bool {
+	r, ok := rank[role]
+	if !ok {
+		return false
+	}
+	m, ok := rank[min]
+	if !ok {
+		return false
+	}
+	return r >= m
+}
@@ -0,0 +1,27 @@
+// Package call implements WebRTC signaling for voice/video calls,
+// multiplexed over ws.Hub. It never touches media itself: a Service only
+// relays SDP/ICE frames between the two participants of a call and mints
+// short-lived TURN credentials for the peer connection to use.
+package call
+
+// Frame type constants for the signaling messages relayed over ws.Hub.
+const (
+	FrameInvite = "call.invite"
+	FrameAccept = "call.accept"
+	FrameReject = "call.reject"
+	FrameSDP    = "call.sdp"
+	FrameICE    = "call.ice"
+	FrameHangup = "call.hangup"
+)
+
+// Frame is the typed envelope every call-signaling WS message shares.
+// Payload carries the frame-specific data (an SDP description, an ICE
+// candidate, ...) and is omitted for frames that don't need one.
+type Frame struct {
+	Type           string `json:"type"`
+	ConversationID int64  `json:"conversation_id"`
+	FromUser       int64  `json:"from_user"`
+	ToUser         int64  `json:"to_user"`
+	CallID         string `json:"call_id"`
+	Payload        any    `json:"payload,omitempty"`
+}
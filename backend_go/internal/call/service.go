@@ -0,0 +1,144 @@
+package call
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"backend_go/internal/domain"
+	"backend_go/internal/rtc"
+)
+
+// Outcome values recorded once a call ends.
+const (
+	OutcomeOngoing  = "ongoing"
+	OutcomeAccepted = "accepted"
+	OutcomeRejected = "rejected"
+	OutcomeMissed   = "missed"
+	OutcomeEnded    = "ended"
+)
+
+// Broadcaster delivers a signaling frame to specific users. ws.Hub
+// satisfies this structurally, so this package doesn't need to import ws
+// (which would create an import cycle, since ws.MakeHandler dispatches
+// incoming frames back into Service.Relay).
+type Broadcaster interface {
+	BroadcastToUsers(userIDs []int64, payload any)
+}
+
+// ErrTURNUnconfigured is returned by CreateCall when turnRequired is set but
+// no turnSecret was provided, so a deployment that forgot to set
+// TURN_SHARED_SECRET fails loudly instead of handing out calls whose
+// participants can never traverse a symmetric NAT.
+var ErrTURNUnconfigured = errors.New("TURN is required but not configured")
+
+// Service creates and ends calls, mints TURN credentials, and relays
+// signaling frames between the two participants of a call. The Go server
+// never handles media — only this bookkeeping.
+type Service struct {
+	calls        domain.CallRepository
+	participants domain.ParticipantRepository
+	hub          Broadcaster
+	turnSecret   []byte
+	turnTTL      time.Duration
+	turnRequired bool
+}
+
+func NewService(calls domain.CallRepository, participants domain.ParticipantRepository, hub Broadcaster, turnSecret []byte, turnTTL time.Duration, turnRequired bool) *Service {
+	return &Service{calls: calls, participants: participants, hub: hub, turnSecret: turnSecret, turnTTL: turnTTL, turnRequired: turnRequired}
+}
+
+// TURNCredential is a short-lived username/password pair for a TURN server,
+// derived per the RFC 5766 "TURN REST API" convention: username is
+// "<expiry-unix-seconds>:<user-id>" and password is
+// base64(HMAC-SHA1(sharedSecret, username)). Any TURN server configured
+// with the same shared secret (e.g. coturn's use-auth-secret) can validate
+// it without a round trip to this service.
+type TURNCredential struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	TTL      int64  `json:"ttl"`
+}
+
+func (s *Service) mintTURNCredential(userID int64) TURNCredential {
+	return TURNCredential(rtc.Mint(s.turnSecret, userID, s.turnTTL))
+}
+
+// CreateCall validates that both the caller and callee are participants of
+// the conversation, persists a new call log row, notifies the callee with
+// a call.invite frame, and mints the caller's TURN credential.
+func (s *Service) CreateCall(ctx context.Context, conversationID, callerID, calleeID int64) (*domain.Call, TURNCredential, error) {
+	if s.turnRequired && len(s.turnSecret) == 0 {
+		return nil, TURNCredential{}, ErrTURNUnconfigured
+	}
+
+	for _, uid := range []int64{callerID, calleeID} {
+		ok, err := s.participants.IsParticipant(ctx, conversationID, uid)
+		if err != nil {
+			return nil, TURNCredential{}, fmt.Errorf("check call participant: %w", err)
+		}
+		if !ok {
+			return nil, TURNCredential{}, domain.ErrForbidden
+		}
+	}
+
+	c := &domain.Call{
+		ID:             uuid.New().String(),
+		ConversationID: conversationID,
+		CallerID:       callerID,
+		CalleeID:       calleeID,
+		Outcome:        OutcomeOngoing,
+	}
+	if err := s.calls.Create(ctx, c); err != nil {
+		return nil, TURNCredential{}, fmt.Errorf("create call: %w", err)
+	}
+
+	s.Relay(Frame{
+		Type:           FrameInvite,
+		ConversationID: conversationID,
+		FromUser:       callerID,
+		ToUser:         calleeID,
+		CallID:         c.ID,
+	})
+
+	return c, s.mintTURNCredential(callerID), nil
+}
+
+// EndCall records the outcome of a finished call and notifies the other
+// participant with a call.hangup frame. Either participant may end a call.
+func (s *Service) EndCall(ctx context.Context, callID string, userID int64, outcome string) error {
+	c, err := s.calls.GetByID(ctx, callID)
+	if err != nil {
+		return err
+	}
+	if c.CallerID != userID && c.CalleeID != userID {
+		return domain.ErrForbidden
+	}
+	if outcome == "" {
+		outcome = OutcomeEnded
+	}
+	if err := s.calls.End(ctx, callID, time.Now(), outcome); err != nil {
+		return fmt.Errorf("end call: %w", err)
+	}
+
+	other := c.CalleeID
+	if userID == c.CalleeID {
+		other = c.CallerID
+	}
+	s.Relay(Frame{
+		Type:           FrameHangup,
+		ConversationID: c.ConversationID,
+		FromUser:       userID,
+		ToUser:         other,
+		CallID:         callID,
+	})
+	return nil
+}
+
+// Relay forwards a signaling frame to its intended recipient only.
+func (s *Service) Relay(f Frame) {
+	s.hub.BroadcastToUsers([]int64{f.ToUser}, f)
+}
@@ -6,6 +6,8 @@ import (
 	"os"
 	"strconv"
 	"strings"
+
+	"backend_go/internal/auth/oidc"
 )
 
 type Config struct {
@@ -15,7 +17,14 @@ type Config struct {
 	Port        int
 	DatabaseURL string
 
-	JWTSecret          string
+	// JWTSigningAlg selects the TokenService KeySet's algorithm: "RS256",
+	// "ES256", or "EdDSA". JWTKeyID identifies the active key in the JWKS
+	// published at /.well-known/jwks.json. JWTPrivateKeyPath points at a
+	// PKCS8 PEM file holding that key; left empty, a fresh one is generated
+	// at startup (fine for development, but it won't survive a restart).
+	JWTSigningAlg      string
+	JWTKeyID           string
+	JWTPrivateKeyPath  string
 	AccessTokenMinutes int
 	RememberMeDays     int
 	EncryptKey         string
@@ -24,6 +33,131 @@ type Config struct {
 	CORSOrigins                []string
 	Debug                      bool
 	MaxMessagesPerConversation int
+
+	// RetentionSweepIntervalSeconds controls how often the retention worker
+	// checks every conversation against its keep_last_n/retention_seconds
+	// policy. DefaultRetentionMaxAgeSeconds is the max_age fallback used for
+	// conversations that haven't set their own retention_seconds; zero
+	// disables it. MaxMessagesPerConversation above doubles as the fallback
+	// keep_last_n for conversations without their own.
+	RetentionSweepIntervalSeconds int
+	DefaultRetentionMaxAgeSeconds int
+
+	// Per-category upload size caps, enforced against the client-declared
+	// size before a presigned upload URL is minted.
+	MaxImageUploadBytes    int64
+	MaxVideoUploadBytes    int64
+	MaxDocumentUploadBytes int64
+	MaxFileUploadBytes     int64
+
+	// ScannerAddr is the clamd TCP address (host:port) used to scan
+	// uploads for malware. Empty disables scanning (scan.NoopScanner).
+	ScannerAddr string
+
+	// TurnSharedSecret and TurnCredentialTTLSeconds configure the
+	// RFC 5766 REST API-style TURN credentials minted for calls; any TURN
+	// server configured with the same shared secret can validate them.
+	// TurnURIs and StunURIs are handed to clients verbatim as the "urls" of
+	// their respective ICE server entries (e.g. "turn:turn.example.com:3478",
+	// "stun:stun.example.com:3478"). TurnRequired, if set, makes
+	// call.Service.CreateCall fail with call.ErrTURNUnconfigured instead of
+	// silently minting an empty credential when TurnSharedSecret is unset —
+	// for deployments where calls are useless without a relay.
+	TurnSharedSecret         string
+	TurnCredentialTTLSeconds int
+	TurnURIs                 []string
+	StunURIs                 []string
+	TurnRequired             bool
+
+	StorageBackend      string // "local" | "s3" | "minio" | "s3_compatible"
+	StorageBucket       string
+	StorageRegion       string
+	StorageEndpoint     string
+	StorageAccessKey    string
+	StorageSecretKey    string
+	StorageUseSSL       bool
+	StorageUsePathStyle bool
+	PublicBaseURL       string
+
+	// RedisURL selects the ws.Hub's cluster broker: empty runs every
+	// instance's hub in-memory (single-node only); set it to fan
+	// broadcasts and presence out over Redis Pub/Sub for horizontal scale.
+	RedisURL           string
+	PresenceTTLSeconds int
+
+	// WSMaxMessageBytes caps the size of a single incoming WebSocket frame;
+	// ws.Client applies it via conn.SetReadLimit so one oversized frame
+	// can't run up memory for a connection.
+	WSMaxMessageBytes int64
+
+	// EventsEnabled turns on the domain-event publisher (message and auth
+	// lifecycle events) over Redis Streams, reusing RedisURL; off by
+	// default, a no-op publisher is used instead. EventsStreamMaxLen caps
+	// each stream's length (approximate trim via XADD MAXLEN ~).
+	EventsEnabled      bool
+	EventsStreamMaxLen int64
+
+	OIDCProviders []oidc.ProviderConfig
+
+	// TLSCertPath/TLSKeyPath, if both set, make the server terminate TLS
+	// itself via ListenAndServeTLS instead of plain HTTP; leave both empty
+	// to keep terminating TLS upstream (e.g. nginx), the default. TLSClientCAPath,
+	// if also set, turns on optional client-certificate auth (VerifyClientCertIfGiven)
+	// against that CA pool, which is what AuthMiddleware's service-account
+	// path relies on — without it, r.TLS.PeerCertificates is always empty.
+	TLSCertPath     string
+	TLSKeyPath      string
+	TLSClientCAPath string
+
+	// OTELExporterEndpoint, if set, points the tracing middleware (see
+	// internal/service/middleware) at an OTLP gRPC collector; left empty, a
+	// no-op TracerProvider is used and spans are discarded. MetricsEnabled
+	// gates mounting the Prometheus /metrics endpoint.
+	OTELExporterEndpoint string
+	MetricsEnabled       bool
+
+	// SMTPHost, if set, makes AuthService send verification and
+	// password-reset email through internal/email.SMTPEmailer; left empty,
+	// internal/email.NoopEmailer is used instead and
+	// EmailVerificationRequired is ignored, so a deployment without SMTP
+	// keeps today's immediately-active registration flow.
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// EmailVerificationRequired gates whether AuthService.Register creates
+	// a user with an email address as inactive pending SendVerification.
+	EmailVerificationRequired bool
+
+	// FederationEnabled turns on internal/federation's gossip bridge to
+	// other zchat servers; off by default so a standalone deployment never
+	// opens a libp2p listener. FederationServerHost is this server's half
+	// of a federated handle ("alice@FederationServerHost"). FederationListenAddr
+	// is the libp2p multiaddr the bridge listens on. FederationServerKeyPath
+	// is a PEM file holding this server's long-lived Ed25519 identity; left
+	// empty, a fresh one is generated on every start (fine for dev, but
+	// peers won't recognize this server across restarts). FederationBootstrapPeers
+	// are libp2p multiaddrs (including peer ID) dialed once at startup.
+	FederationEnabled        bool
+	FederationServerHost     string
+	FederationListenAddr     string
+	FederationServerKeyPath  string
+	FederationBootstrapPeers []string
+
+	// GRPCEnabled starts internal/grpcserver alongside the HTTP API on
+	// GRPCPort, for non-browser clients that want typed RPCs and a single
+	// server-streaming Subscribe instead of the WS event loop. Off by
+	// default — most deployments only ever talk to httpserver.
+	GRPCEnabled bool
+	GRPCPort    int
+
+	// BootstrapAdminUsername, if set, is promoted to domain.GlobalRoleAdmin
+	// at startup if the username exists and no admin does yet — the only
+	// way to reach the admin API on a fresh deployment, since nothing else
+	// can grant the first admin role.
+	BootstrapAdminUsername string
 }
 
 func Load() (*Config, error) {
@@ -49,7 +183,9 @@ func Load() (*Config, error) {
 		Port:        getEnvAsInt("HTTP_PORT", 8000),
 		DatabaseURL: dbURL,
 
-		JWTSecret:          os.Getenv("JWT_SECRET"),
+		JWTSigningAlg:      getEnv("JWT_SIGNING_ALG", "ES256"),
+		JWTKeyID:           getEnv("JWT_KEY_ID", "default"),
+		JWTPrivateKeyPath:  getEnv("JWT_PRIVATE_KEY_PATH", ""),
 		AccessTokenMinutes: getEnvAsInt("ACCESS_TOKEN_EXPIRE_MINUTES", 60*24),
 		RememberMeDays:     getEnvAsInt("REMEMBER_ME_TOKEN_EXPIRE_DAYS", 30),
 		EncryptKey:         os.Getenv("ENCRYPTION_KEY"),
@@ -57,6 +193,73 @@ func Load() (*Config, error) {
 		UploadDir:                  getEnv("UPLOAD_DIR", "uploads"),
 		Debug:                      getEnvAsBool("DEBUG", true),
 		MaxMessagesPerConversation: getEnvAsInt("MAX_MESSAGES_PER_CONVERSATION", 1000),
+
+		RetentionSweepIntervalSeconds: getEnvAsInt("RETENTION_SWEEP_INTERVAL_SECONDS", 300),
+		DefaultRetentionMaxAgeSeconds: getEnvAsInt("DEFAULT_RETENTION_MAX_AGE_SECONDS", 0),
+
+		MaxImageUploadBytes:    getEnvAsInt64("MAX_IMAGE_UPLOAD_BYTES", 10<<20),
+		MaxVideoUploadBytes:    getEnvAsInt64("MAX_VIDEO_UPLOAD_BYTES", 200<<20),
+		MaxDocumentUploadBytes: getEnvAsInt64("MAX_DOCUMENT_UPLOAD_BYTES", 25<<20),
+		MaxFileUploadBytes:     getEnvAsInt64("MAX_FILE_UPLOAD_BYTES", 50<<20),
+		ScannerAddr:            getEnv("CLAMAV_ADDR", ""),
+
+		TurnSharedSecret:         os.Getenv("TURN_SHARED_SECRET"),
+		TurnCredentialTTLSeconds: getEnvAsInt("TURN_CREDENTIAL_TTL_SECONDS", 3600),
+		TurnURIs:                 getEnvAsSlice("TURN_URIS", nil),
+		StunURIs:                 getEnvAsSlice("STUN_URIS", nil),
+		TurnRequired:             getEnvAsBool("TURN_REQUIRED", false),
+
+		StorageBackend:      getEnv("STORAGE_BACKEND", "local"),
+		StorageBucket:       getEnv("STORAGE_BUCKET", ""),
+		StorageRegion:       getEnv("STORAGE_REGION", ""),
+		StorageEndpoint:     getEnv("STORAGE_ENDPOINT", ""),
+		StorageAccessKey:    getEnv("STORAGE_ACCESS_KEY", ""),
+		StorageSecretKey:    getEnv("STORAGE_SECRET_KEY", ""),
+		StorageUseSSL:       getEnvAsBool("STORAGE_USE_SSL", true),
+		StorageUsePathStyle: getEnvAsBool("STORAGE_USE_PATH_STYLE", false),
+		PublicBaseURL:       getEnv("PUBLIC_BASE_URL", "http://localhost:8000"),
+
+		RedisURL:           getEnv("REDIS_URL", ""),
+		PresenceTTLSeconds: getEnvAsInt("PRESENCE_TTL_SECONDS", 90),
+		WSMaxMessageBytes:  getEnvAsInt64("WS_MAX_MESSAGE_BYTES", 64<<10),
+
+		EventsEnabled:      getEnvAsBool("EVENTS_ENABLED", false),
+		EventsStreamMaxLen: getEnvAsInt64("EVENTS_STREAM_MAXLEN", 10000),
+
+		TLSCertPath:     getEnv("TLS_CERT_PATH", ""),
+		TLSKeyPath:      getEnv("TLS_KEY_PATH", ""),
+		TLSClientCAPath: getEnv("TLS_CLIENT_CA_PATH", ""),
+
+		OTELExporterEndpoint: getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		MetricsEnabled:       getEnvAsBool("METRICS_ENABLED", true),
+
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:     getEnv("SMTP_FROM", "no-reply@zchat.local"),
+
+		EmailVerificationRequired: getEnvAsBool("EMAIL_VERIFICATION_REQUIRED", false),
+
+		FederationEnabled:       getEnvAsBool("FEDERATION_ENABLED", false),
+		FederationServerHost:    getEnv("FEDERATION_SERVER_HOST", ""),
+		FederationListenAddr:    getEnv("FEDERATION_LISTEN_ADDR", "/ip4/0.0.0.0/tcp/4001"),
+		FederationServerKeyPath: getEnv("FEDERATION_SERVER_KEY_PATH", ""),
+
+		GRPCEnabled: getEnvAsBool("GRPC_ENABLED", false),
+		GRPCPort:    getEnvAsInt("GRPC_PORT", 9090),
+
+		BootstrapAdminUsername: getEnv("BOOTSTRAP_ADMIN_USERNAME", ""),
+	}
+
+	cfg.OIDCProviders = loadOIDCProviders()
+
+	if peers := getEnv("FEDERATION_BOOTSTRAP_PEERS", ""); peers != "" {
+		parts := strings.Split(peers, ",")
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		cfg.FederationBootstrapPeers = parts
 	}
 
 	cors := getEnv("CORS_ORIGINS", "")
@@ -70,8 +273,10 @@ func Load() (*Config, error) {
 		cfg.CORSOrigins = []string{"http://localhost:3000", "http://localhost:5173"}
 	}
 
-	if cfg.JWTSecret == "" {
-		return nil, fmt.Errorf("JWT_SECRET is required")
+	switch cfg.JWTSigningAlg {
+	case "RS256", "ES256", "EdDSA":
+	default:
+		return nil, fmt.Errorf("JWT_SIGNING_ALG must be one of RS256, ES256, EdDSA, got %q", cfg.JWTSigningAlg)
 	}
 	if cfg.EncryptKey == "" {
 		return nil, fmt.Errorf("ENCRYPTION_KEY is required")
@@ -88,6 +293,45 @@ func (c *Config) HTTPAddr() string {
 	return fmt.Sprintf("%s:%d", c.Host, c.Port)
 }
 
+func (c *Config) GRPCAddr() string {
+	return fmt.Sprintf("%s:%d", c.Host, c.GRPCPort)
+}
+
+// loadOIDCProviders reads OIDC_PROVIDERS (a comma-separated list of provider
+// names, e.g. "google,github") and, for each one, its own OIDC_<NAME>_*
+// settings.
+func loadOIDCProviders() []oidc.ProviderConfig {
+	names := getEnv("OIDC_PROVIDERS", "")
+	if names == "" {
+		return nil
+	}
+
+	var providers []oidc.ProviderConfig
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		scopes := strings.Split(getEnv(prefix+"SCOPES", "openid,email,profile"), ",")
+		for i := range scopes {
+			scopes[i] = strings.TrimSpace(scopes[i])
+		}
+
+		providers = append(providers, oidc.ProviderConfig{
+			Name:         name,
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			AuthURL:      getEnv(prefix+"AUTH_URL", ""),
+			TokenURL:     getEnv(prefix+"TOKEN_URL", ""),
+			UserInfoURL:  getEnv(prefix+"USERINFO_URL", ""),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", ""),
+			Scopes:       scopes,
+		})
+	}
+	return providers
+}
+
 func getEnv(key, def string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -104,6 +348,15 @@ func getEnvAsInt(key string, def int) int {
 	return def
 }
 
+func getEnvAsInt64(key string, def int64) int64 {
+	if v := os.Getenv(key); v != "" {
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return i
+		}
+	}
+	return def
+}
+
 func getEnvAsBool(key string, def bool) bool {
 	if v := os.Getenv(key); v != "" {
 		if b, err := strconv.ParseBool(v); err == nil {
@@ -112,3 +365,17 @@ func getEnvAsBool(key string, def bool) bool {
 	}
 	return def
 }
+
+// getEnvAsSlice splits a comma-separated env var into trimmed parts,
+// returning def if the var is unset or empty.
+func getEnvAsSlice(key string, def []string) []string {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	parts := strings.Split(v, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
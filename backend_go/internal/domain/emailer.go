@@ -0,0 +1,14 @@
+package domain
+
+import "context"
+
+// Emailer sends the transactional email behind account lifecycle flows.
+// AuthService.Register queues a SendVerification when email verification is
+// required and the new user supplied an address; AuthService.RequestPasswordReset
+// queues a SendPasswordReset only for a registered user with an address, but
+// returns the same result either way, so its response can't be used to
+// enumerate accounts.
+type Emailer interface {
+	SendVerification(ctx context.Context, to, token string) error
+	SendPasswordReset(ctx context.Context, to, token string) error
+}
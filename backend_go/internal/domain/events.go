@@ -0,0 +1,140 @@
+package domain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// EventPublisher fans domain events out to downstream consumers (search
+// indexing, push notifications, analytics) without coupling them to the
+// database. MessageService and AuthService each hold one and call Publish
+// right after the write that the event describes succeeds; a publish
+// failure is logged by the caller and never fails the request it
+// accompanies.
+type EventPublisher interface {
+	Publish(ctx context.Context, event interface{}) error
+}
+
+// eventBase carries the fields every event shares: a deterministic ID (so a
+// downstream consumer can dedupe a redelivered event), the actor who caused
+// it, and when it happened.
+type eventBase struct {
+	EventID   string    `json:"event_id"`
+	ActorID   int64     `json:"actor_id"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// newEventID derives a deterministic ID from an event's own identifying
+// fields (kind, entity id, and the instant it occurred) rather than random
+// bits, so redelivering the exact same event always reproduces the same ID
+// and downstream consumers can dedupe on it.
+func newEventID(kind string, entityID int64, ts time.Time) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%d", kind, entityID, ts.UnixNano())))
+	return hex.EncodeToString(sum[:])
+}
+
+type MessageCreatedEvent struct {
+	eventBase
+	MessageID      int64 `json:"message_id"`
+	ConversationID int64 `json:"conversation_id"`
+}
+
+func NewMessageCreatedEvent(actorID, messageID, conversationID int64) MessageCreatedEvent {
+	ts := time.Now().UTC()
+	return MessageCreatedEvent{
+		eventBase:      eventBase{EventID: newEventID("message.created", messageID, ts), ActorID: actorID, Timestamp: ts},
+		MessageID:      messageID,
+		ConversationID: conversationID,
+	}
+}
+
+type MessageEditedEvent struct {
+	eventBase
+	MessageID      int64 `json:"message_id"`
+	ConversationID int64 `json:"conversation_id"`
+}
+
+func NewMessageEditedEvent(actorID, messageID, conversationID int64) MessageEditedEvent {
+	ts := time.Now().UTC()
+	return MessageEditedEvent{
+		eventBase:      eventBase{EventID: newEventID("message.edited", messageID, ts), ActorID: actorID, Timestamp: ts},
+		MessageID:      messageID,
+		ConversationID: conversationID,
+	}
+}
+
+type MessageDeletedEvent struct {
+	eventBase
+	MessageID      int64  `json:"message_id"`
+	ConversationID int64  `json:"conversation_id"`
+	DeleteType     string `json:"delete_type"` // "for_me" | "for_everyone"
+}
+
+func NewMessageDeletedEvent(actorID, messageID, conversationID int64, deleteType string) MessageDeletedEvent {
+	ts := time.Now().UTC()
+	return MessageDeletedEvent{
+		eventBase:      eventBase{EventID: newEventID("message.deleted", messageID, ts), ActorID: actorID, Timestamp: ts},
+		MessageID:      messageID,
+		ConversationID: conversationID,
+		DeleteType:     deleteType,
+	}
+}
+
+type MessageReadEvent struct {
+	eventBase
+	ConversationID int64 `json:"conversation_id"`
+}
+
+func NewMessageReadEvent(actorID, conversationID int64) MessageReadEvent {
+	ts := time.Now().UTC()
+	return MessageReadEvent{
+		eventBase:      eventBase{EventID: newEventID("message.read", conversationID, ts), ActorID: actorID, Timestamp: ts},
+		ConversationID: conversationID,
+	}
+}
+
+type UserRegisteredEvent struct {
+	eventBase
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+}
+
+func NewUserRegisteredEvent(userID int64, username string) UserRegisteredEvent {
+	ts := time.Now().UTC()
+	return UserRegisteredEvent{
+		eventBase: eventBase{EventID: newEventID("user.registered", userID, ts), ActorID: userID, Timestamp: ts},
+		UserID:    userID,
+		Username:  username,
+	}
+}
+
+type UserLoggedInEvent struct {
+	eventBase
+	UserID     int64 `json:"user_id"`
+	RememberMe bool  `json:"remember_me"`
+}
+
+func NewUserLoggedInEvent(userID int64, rememberMe bool) UserLoggedInEvent {
+	ts := time.Now().UTC()
+	return UserLoggedInEvent{
+		eventBase:  eventBase{EventID: newEventID("user.logged_in", userID, ts), ActorID: userID, Timestamp: ts},
+		UserID:     userID,
+		RememberMe: rememberMe,
+	}
+}
+
+type UserLoggedOutEvent struct {
+	eventBase
+	UserID int64 `json:"user_id"`
+}
+
+func NewUserLoggedOutEvent(userID int64) UserLoggedOutEvent {
+	ts := time.Now().UTC()
+	return UserLoggedOutEvent{
+		eventBase: eventBase{EventID: newEventID("user.logged_out", userID, ts), ActorID: userID, Timestamp: ts},
+		UserID:    userID,
+	}
+}
@@ -4,14 +4,48 @@ import "time"
 
 // User represents an application user.
 type User struct {
-	ID             int64     `db:"id" json:"id"`
-	Username       string    `db:"username" json:"username"`
-	Email          *string   `db:"email" json:"email,omitempty"`
-	HashedPassword string    `db:"hashed_password" json:"-"`
-	IsActive       bool      `db:"is_active" json:"is_active"`
-	IsOnline       bool      `db:"is_online" json:"is_online"`
-	CreatedAt      time.Time `db:"created_at" json:"created_at"`
-	LastSeen       time.Time `db:"last_seen" json:"last_seen"`
+	ID             int64   `db:"id" json:"id"`
+	Username       string  `db:"username" json:"username"`
+	Email          *string `db:"email" json:"email,omitempty"`
+	HashedPassword string  `db:"hashed_password" json:"-"`
+	IsActive       bool    `db:"is_active" json:"is_active"`
+	IsOnline       bool    `db:"is_online" json:"is_online"`
+	// IsServiceAccount marks a user minted for a bot or scripted integration
+	// rather than a human: it authenticates via a zchat-issued client
+	// certificate (see httpserver's mTLS middleware) instead of a password,
+	// but is otherwise an ordinary participant so existing handlers don't
+	// need to special-case it.
+	IsServiceAccount bool      `db:"is_service_account" json:"is_service_account"`
+	CreatedAt        time.Time `db:"created_at" json:"created_at"`
+	LastSeen         time.Time `db:"last_seen" json:"last_seen"`
+
+	// Role is the user's site-wide standing, consulted by internal/authz for
+	// capabilities that cut across every conversation (force-deleting a
+	// message or locking a conversation regardless of the caller's
+	// ConversationRole in it). It has nothing to do with ConversationRole,
+	// which only governs one conversation at a time.
+	Role GlobalRole `db:"role" json:"role"`
+
+	// RemoteHandle is set for a "ghost" user that represents a participant
+	// joining from another zchat server (e.g. "alice@host.example") rather
+	// than a local account: internal/federation creates one the first time
+	// a remote handle posts into a conversation, so the rest of the codebase
+	// can keep treating a federated message's sender as an ordinary User.
+	RemoteHandle *string `db:"remote_handle" json:"remote_handle,omitempty"`
+
+	// PasswordChangedAt is stamped on every password change. A bearer token
+	// whose iat predates it is rejected by security.AuthenticateBearer, so
+	// resetting a password (or changing it any other way) signs out every
+	// session that was issued before the change.
+	PasswordChangedAt time.Time `db:"password_changed_at" json:"-"`
+
+	// SSOOnly marks an account OIDCService provisioned for a first-time
+	// external login: its HashedPassword is a random value the user never
+	// saw, so it isn't a fallback a handler can fall back on. UnlinkIdentity
+	// callers must refuse to remove a provider's identity from an SSOOnly
+	// user when it's their last one, or the account would become
+	// unauthenticatable.
+	SSOOnly bool `db:"sso_only" json:"-"`
 }
 
 // Conversation represents a chat conversation (direct or group).
@@ -21,29 +55,115 @@ type Conversation struct {
 	IsGroup   bool      `db:"is_group" json:"is_group"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+
+	// RetentionSeconds, when set, is the janitor-enforced lifetime of every
+	// message in this conversation: a message older than CreatedAt+this is
+	// deleted server-side regardless of any per-message ExpiresAt.
+	RetentionSeconds *int `db:"retention_seconds" json:"retention_seconds,omitempty"`
+	// KeepLastN, when set, caps how many of this conversation's messages the
+	// retention worker keeps: anything beyond the N most recent is pruned on
+	// the next sweep, same as RetentionSeconds but counting messages instead
+	// of measuring their age.
+	KeepLastN *int `db:"keep_last_n" json:"keep_last_n,omitempty"`
+
+	// IsLocked marks a conversation read-only: MessageService.CreateMessage
+	// refuses new messages while it's set, regardless of who's sending.
+	IsLocked bool `db:"is_locked" json:"is_locked"`
 }
 
+// ConversationRole is a participant's standing within one conversation: what
+// internal/policies consults to decide whether they may moderate it.
+type ConversationRole string
+
+const (
+	// RoleOwner is granted to a conversation's creator and can do anything
+	// RoleAdmin can.
+	RoleOwner ConversationRole = "owner"
+	// RoleAdmin may rename the conversation, manage its participants, and
+	// for_everyone-delete other members' messages.
+	RoleAdmin ConversationRole = "admin"
+	// RoleMember has no rights beyond their own messages and membership.
+	RoleMember ConversationRole = "member"
+)
+
+// GlobalRole is a user's site-wide standing: unlike ConversationRole, it
+// isn't scoped to any one conversation. internal/authz consults it to grant
+// a moderator/admin capabilities (force-delete, lock, kick/ban) in any
+// conversation, including ones they aren't even a participant in.
+type GlobalRole string
+
+const (
+	// GlobalRoleUser is the default for every new account.
+	GlobalRoleUser GlobalRole = "user"
+	// GlobalRoleModerator may force-delete messages, lock conversations, and
+	// kick/ban participants in any conversation.
+	GlobalRoleModerator GlobalRole = "moderator"
+	// GlobalRoleAdmin can do anything GlobalRoleModerator can, plus whatever
+	// future site-wide capability internal/authz adds above moderator.
+	GlobalRoleAdmin GlobalRole = "admin"
+)
+
 // ConversationParticipant represents the membership of a user in a conversation.
 type ConversationParticipant struct {
-	UserID         int64      `db:"user_id"`
-	ConversationID int64      `db:"conversation_id"`
-	LastReadAt     *time.Time `db:"last_read_at"`
-	JoinedAt       *time.Time `db:"joined_at"`
+	UserID         int64            `db:"user_id"`
+	ConversationID int64            `db:"conversation_id"`
+	Role           ConversationRole `db:"role"`
+	LastReadAt     *time.Time       `db:"last_read_at"`
+	JoinedAt       *time.Time       `db:"joined_at"`
 }
 
 // Message represents a single chat message.
 type Message struct {
-	ID             int64      `db:"id"`
-	Content        string     `db:"content"` // encrypted at rest
-	ConversationID int64      `db:"conversation_id"`
-	SenderID       int64      `db:"sender_id"`
-	CreatedAt      time.Time  `db:"created_at"`
-	FilePath       *string    `db:"file_path"`
-	FileType       *string    `db:"file_type"`
-	FullyReadAt    *time.Time `db:"fully_read_at"`
-	IsDeleted      bool       `db:"is_deleted"`
-	IsEdited       bool       `db:"is_edited"`
-	IsRead         bool       `db:"is_read"`
+	ID             int64     `db:"id"`
+	Content        string    `db:"content"` // encrypted at rest
+	ConversationID int64     `db:"conversation_id"`
+	SenderID       int64     `db:"sender_id"`
+	CreatedAt      time.Time `db:"created_at"`
+	// FilePath holds the referenced Attachment's id (as a decimal string)
+	// for messages created after AttachmentService.Upload existed; FileType
+	// mirrors that attachment's ContentType. Older rows may still hold a
+	// raw, client-supplied path instead.
+	FilePath    *string    `db:"file_path"`
+	FileType    *string    `db:"file_type"`
+	FullyReadAt *time.Time `db:"fully_read_at"`
+	IsDeleted   bool       `db:"is_deleted"`
+	IsEdited    bool       `db:"is_edited"`
+	// EditedAt is set alongside IsEdited, so MessageRepository.ChangesSince
+	// can tell a reconnecting client about an edit to a message it already
+	// has cached, independent of the message's original CreatedAt.
+	EditedAt *time.Time `db:"edited_at"`
+	IsRead   bool       `db:"is_read"`
+
+	// ExpiresAt, when set, is the per-message TTL deadline: the janitor
+	// deletes the row once CURRENT_TIMESTAMP passes it.
+	ExpiresAt *time.Time `db:"expires_at"`
+	// ViewOnce marks a message for immediate deletion once FullyReadAt is
+	// set, instead of waiting for ExpiresAt or the conversation's retention.
+	ViewOnce bool `db:"view_once"`
+
+	// SearchText mirrors the plaintext Content at write time, so the store's
+	// full-text index (a Postgres tsvector trigger / sqlite FTS5 table) can
+	// be built without ever holding the encryption key. This only works
+	// because security.Encryptor is reversible server-side; if Content were
+	// ever switched to a one-way scheme, this would need to become a
+	// client-supplied blind-index token instead of a plaintext mirror.
+	SearchText *string `db:"search_text"`
+
+	// OriginServer is nil for messages created locally, and the sending
+	// server's host (e.g. "host.example") for messages relayed in by
+	// internal/federation from a remote zchat instance. It lets a
+	// federated conversation survive a restart and lets the bridge dedupe
+	// an envelope it has already applied without re-checking the seen-cache.
+	OriginServer *string `db:"origin_server"`
+}
+
+// PrunedMessage is a minimal record of a message the retention worker or the
+// per-conversation cap in MessageService.CreateMessage has just deleted,
+// returned so the caller can remove its attachment blob and tell connected
+// clients to drop it from their local cache.
+type PrunedMessage struct {
+	ID       int64
+	FilePath *string
 }
 
 // UserDeletedMessage tracks per-user "delete for me" deletions.
@@ -53,6 +173,161 @@ type UserDeletedMessage struct {
 	DeletedAt time.Time `db:"deleted_at"`
 }
 
+// MessageProgress is one device's delivery/read state for a single message,
+// the per-device replacement for Message.IsRead: a user reading on their
+// phone no longer silently marks a message read on their desktop too, and a
+// client can report a partial Percentage for a long message or attachment
+// it hasn't finished viewing.
+type MessageProgress struct {
+	UserID      int64      `db:"user_id"`
+	DeviceID    string     `db:"device_id"`
+	MessageID   int64      `db:"message_id"`
+	DeliveredAt *time.Time `db:"delivered_at"`
+	ReadAt      *time.Time `db:"read_at"`
+	Percentage  int        `db:"percentage"`
+}
+
+// DeviceProgress is one (user, device) pair's aggregated progress through a
+// conversation: the furthest message it has read, and when. It's the shape
+// ProgressRepository.ListForConversation returns, one row per device that
+// has reported any progress.
+type DeviceProgress struct {
+	UserID            int64      `json:"user_id"`
+	DeviceID          string     `json:"device_id"`
+	LastReadMessageID int64      `json:"last_read_message_id,omitempty"`
+	ReadAt            *time.Time `json:"read_at,omitempty"`
+	DeliveredAt       *time.Time `json:"delivered_at,omitempty"`
+}
+
+// Direction is which side of a Cursor MessageRepository.ListPage walks: the
+// page of messages older than the cursor, or the page newer than it.
+type Direction int
+
+const (
+	// Backward walks toward older messages: (created_at, id) < cursor,
+	// ordered created_at DESC, id DESC. This is the "load more history"
+	// direction used by infinite scroll.
+	Backward Direction = iota
+	// Forward walks toward newer messages: (created_at, id) > cursor,
+	// ordered created_at ASC, id ASC.
+	Forward
+)
+
+// Cursor is a stable keyset position in a conversation's message history:
+// the (created_at, id) pair a ListPage query compares against. Ordering by
+// this pair instead of an offset keeps pagination correct even as new
+// messages keep arriving between page fetches.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+// Attachment represents an uploaded object that a message can reference by
+// id. The object bytes themselves live in the configured storage backend
+// under Key, content-addressed by SHA256 so identical uploads share one
+// blob; this row carries the metadata needed to authorize, decrypt, and
+// present it. When WrappedKey is set, the blob at Key was written by
+// AttachmentService.Upload as a sequence of AES-GCM frames sealed under the
+// per-attachment key it wraps (see security.EncryptChunked); a nil
+// WrappedKey marks an older attachment uploaded before encryption, still
+// served via a presigned URL straight from the backend.
+type Attachment struct {
+	ID          int64     `db:"id" json:"id"`
+	OwnerID     int64     `db:"owner_id" json:"owner_id"`
+	Key         string    `db:"key" json:"-"`
+	ContentType string    `db:"content_type" json:"content_type"`
+	Size        int64     `db:"size" json:"size"`
+	SHA256      string    `db:"sha256" json:"sha256"`
+	WrappedKey  []byte    `db:"wrapped_key" json:"-"`
+	KEKID       uint32    `db:"kek_id" json:"-"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
+
+// UserOTP holds a user's TOTP enrollment. Secret is encrypted at rest using
+// the same key material as message content.
+type UserOTP struct {
+	UserID    int64     `db:"user_id"`
+	Secret    []byte    `db:"secret"`
+	Confirmed bool      `db:"confirmed"`
+	CreatedAt time.Time `db:"created_at"`
+
+	// LastCounter is the HOTP counter of the most recently accepted code,
+	// used to reject replays of a code already consumed within the same
+	// (or an earlier) drift window.
+	LastCounter int64 `db:"last_counter"`
+}
+
+// RecoveryCode is a single-use fallback credential issued when a user
+// confirms TOTP enrollment. Only its hash is ever persisted.
+type RecoveryCode struct {
+	UserID   int64      `db:"user_id"`
+	CodeHash string     `db:"code_hash"`
+	UsedAt   *time.Time `db:"used_at"`
+}
+
+// UserIdentity links a local user to a subject at an external OIDC/OAuth2
+// provider, so a later login from that provider resolves back to the same
+// account without re-matching on email.
+type UserIdentity struct {
+	UserID    int64     `db:"user_id"`
+	Provider  string    `db:"provider"`
+	Subject   string    `db:"subject"`
+	Email     *string   `db:"email"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// TusUpload tracks an in-progress resumable (tus.io-style) upload. Rows are
+// created on the initial POST and deleted once the upload completes (its
+// bytes move into Key via the storage backend) or it is garbage-collected
+// after ExpiresAt.
+type TusUpload struct {
+	ID          string    `db:"id" json:"id"`
+	OwnerID     int64     `db:"owner_id" json:"owner_id"`
+	Key         string    `db:"key" json:"-"`
+	ContentType string    `db:"content_type" json:"content_type"`
+	Size        int64     `db:"size" json:"size"`
+	Offset      int64     `db:"offset" json:"offset"`
+	Metadata    string    `db:"metadata" json:"-"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	ExpiresAt   time.Time `db:"expires_at" json:"-"`
+}
+
+// Call records a single voice/video call's signaling lifecycle for history
+// UI. The Go server only brokers signaling and mints TURN credentials for
+// it; media never passes through this process.
+type Call struct {
+	ID             string     `db:"id" json:"id"`
+	ConversationID int64      `db:"conversation_id" json:"conversation_id"`
+	CallerID       int64      `db:"caller_id" json:"caller_id"`
+	CalleeID       int64      `db:"callee_id" json:"callee_id"`
+	StartedAt      time.Time  `db:"started_at" json:"started_at"`
+	EndedAt        *time.Time `db:"ended_at" json:"ended_at,omitempty"`
+	Outcome        string     `db:"outcome" json:"outcome"`
+}
+
+// ConversationKeyStatus tracks whether a wrapped data-encryption-key (DEK)
+// is still used to encrypt new messages (active) or kept only to decrypt
+// messages already sealed under it (retired).
+type ConversationKeyStatus string
+
+const (
+	ConversationKeyActive  ConversationKeyStatus = "active"
+	ConversationKeyRetired ConversationKeyStatus = "retired"
+)
+
+// ConversationKey is a per-conversation data-encryption-key (DEK) used to
+// envelope-encrypt that conversation's messages. The DEK itself is never
+// stored: WrappedDEK is AES-GCM(KEK, DEK) under security.Encryptor's
+// key-encryption-key identified by KEKID.
+type ConversationKey struct {
+	ID             int64                 `db:"id" json:"id"`
+	ConversationID int64                 `db:"conversation_id" json:"conversation_id"`
+	KEKID          uint32                `db:"kek_id" json:"kek_id"`
+	WrappedDEK     []byte                `db:"wrapped_dek" json:"-"`
+	Status         ConversationKeyStatus `db:"status" json:"status"`
+	CreatedAt      time.Time             `db:"created_at" json:"created_at"`
+}
+
 // ConversationResponse is the rich DTO returned by conversation endpoints.
 type ConversationResponse struct {
 	*Conversation
@@ -60,3 +335,70 @@ type ConversationResponse struct {
 	LastMessage  interface{} `json:"last_message"` // *service.MessageResponse, typed as any to avoid import cycle
 	UnreadCount  int         `json:"unread_count"`
 }
+
+// OAuthClient is a third-party application registered to request
+// zchat-issued tokens through internal/authserver's authorization-code
+// flow. ClientSecret is empty for a public client (mobile/SPA), which must
+// authenticate the flow with PKCE instead.
+type OAuthClient struct {
+	ClientID      string    `db:"client_id" json:"client_id"`
+	ClientSecret  string    `db:"client_secret" json:"-"`
+	Name          string    `db:"name" json:"name"`
+	RedirectURIs  []string  `db:"-" json:"redirect_uris"`
+	AllowedScopes []string  `db:"-" json:"allowed_scopes"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
+
+// AuthRequest is the server-side state of a single in-flight
+// authorization-code grant: created when /authorize redirects back to the
+// client with a code, consumed once by the matching /token exchange.
+type AuthRequest struct {
+	Code                string    `db:"code" json:"-"`
+	ClientID            string    `db:"client_id" json:"client_id"`
+	UserID              int64     `db:"user_id" json:"user_id"`
+	RedirectURI         string    `db:"redirect_uri" json:"redirect_uri"`
+	Scope               string    `db:"scope" json:"scope"`
+	State               string    `db:"state" json:"state"`
+	CodeChallenge       string    `db:"code_challenge" json:"-"`
+	CodeChallengeMethod string    `db:"code_challenge_method" json:"-"`
+	ExpiresAt           time.Time `db:"expires_at" json:"-"`
+	Used                bool      `db:"used" json:"-"`
+}
+
+// Verification token purposes. A single table serves both the
+// email-verification and password-reset flows, distinguished by Purpose, so
+// a token from one flow can never be redeemed by the other's endpoint.
+const (
+	VerificationPurposeEmail         = "verify_email"
+	VerificationPurposePasswordReset = "password_reset"
+)
+
+// VerificationToken is a single-use, time-limited token emailed to a user to
+// prove control of their address: either to activate a freshly registered
+// account (VerificationPurposeEmail) or to authorize a password reset
+// (VerificationPurposePasswordReset).
+type VerificationToken struct {
+	Token     string    `db:"token" json:"-"`
+	UserID    int64     `db:"user_id" json:"-"`
+	Purpose   string    `db:"purpose" json:"-"`
+	ExpiresAt time.Time `db:"expires_at" json:"-"`
+	Used      bool      `db:"used" json:"-"`
+	CreatedAt time.Time `db:"created_at" json:"-"`
+}
+
+// RefreshToken is a long-lived credential issued alongside an access token
+// at login and exchanged at /auth/refresh for a fresh pair. Only its SHA-256
+// hash (TokenHash) is ever persisted, so a database leak alone doesn't yield
+// a usable token. AuthService.Refresh revokes RevokedAt on rotation; a
+// second presentation of an already-revoked token is treated as a sign the
+// raw token leaked, and every other token belonging to UserID is revoked too.
+type RefreshToken struct {
+	ID        int64      `db:"id" json:"-"`
+	UserID    int64      `db:"user_id" json:"-"`
+	TokenHash string     `db:"token_hash" json:"-"`
+	ExpiresAt time.Time  `db:"expires_at" json:"-"`
+	RevokedAt *time.Time `db:"revoked_at" json:"-"`
+	UserAgent string     `db:"user_agent" json:"-"`
+	IP        string     `db:"ip" json:"-"`
+	CreatedAt time.Time  `db:"created_at" json:"-"`
+}
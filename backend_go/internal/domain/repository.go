@@ -1,43 +1,297 @@
-package domain
-
-import (
-	"context"
-)
-
-// UserRepository defines persistence operations for users.
-type UserRepository interface {
-	Create(ctx context.Context, u *User) error
-	GetByID(ctx context.Context, id int64) (*User, error)
-	GetByUsername(ctx context.Context, username string) (*User, error)
-	GetByEmail(ctx context.Context, email string) (*User, error)
-	ListActive(ctx context.Context, offset, limit int) ([]*User, error)
-	ListOnline(ctx context.Context) ([]*User, error)
-	Update(ctx context.Context, u *User) error
-	SoftDelete(ctx context.Context, id int64) error
-	SetOnlineStatus(ctx context.Context, id int64, isOnline bool) error
-}
-
-// ConversationRepository defines persistence operations for conversations.
-type ConversationRepository interface {
-	Create(ctx context.Context, c *Conversation, participantIDs []int64) error
-	GetByID(ctx context.Context, id int64) (*Conversation, error)
-	ListForUser(ctx context.Context, userID int64) ([]*Conversation, error)
-	MarkAsRead(ctx context.Context, conversationID, userID int64) error
-	GetUnreadCount(ctx context.Context, conversationID, userID int64) (int, error)
-	FindExistingDirect(ctx context.Context, participantIDs []int64) (*Conversation, error)
-	FindExistingGroup(ctx context.Context, participantIDs []int64) (*Conversation, error)
-}
-
-// MessageRepository defines persistence operations for messages.
-type MessageRepository interface {
-	Create(ctx context.Context, m *Message) error
-	ListForConversation(ctx context.Context, conversationID int64, limit int) ([]*Message, error)
-	PruneOld(ctx context.Context, conversationID int64, keepLimit int) error
-}
-
-// ParticipantRepository defines operations around conversation participants.
-type ParticipantRepository interface {
-	ListParticipants(ctx context.Context, conversationID int64) ([]*User, error)
-	IsParticipant(ctx context.Context, conversationID, userID int64) (bool, error)
-}
-
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// UserRepository defines persistence operations for users.
+type UserRepository interface {
+	Create(ctx context.Context, u *User) error
+	GetByID(ctx context.Context, id int64) (*User, error)
+	GetByUsername(ctx context.Context, username string) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	ListActive(ctx context.Context, offset, limit int) ([]*User, error)
+	ListOnline(ctx context.Context) ([]*User, error)
+	Update(ctx context.Context, u *User) error
+	SoftDelete(ctx context.Context, id int64) error
+	SetOnlineStatus(ctx context.Context, id int64, isOnline bool) error
+	// SetRole changes a user's site-wide GlobalRole.
+	SetRole(ctx context.Context, id int64, role GlobalRole) error
+	// CountByRole reports how many users hold role, so main.go's bootstrap
+	// admin logic can tell whether one already exists.
+	CountByRole(ctx context.Context, role GlobalRole) (int, error)
+
+	// GetByIdentity looks up the user linked to an external OIDC/OAuth2
+	// identity, if any.
+	GetByIdentity(ctx context.Context, provider, subject string) (*User, error)
+	// LinkIdentity records that userID owns subject at provider, so future
+	// logins from that provider resolve directly to the user.
+	LinkIdentity(ctx context.Context, userID int64, provider, subject string, email *string) error
+	// UnlinkIdentity removes userID's identity at provider, if any.
+	UnlinkIdentity(ctx context.Context, userID int64, provider string) error
+	// CountIdentities reports how many external identities userID has linked,
+	// so a caller can refuse to unlink the last one from an SSOOnly account.
+	CountIdentities(ctx context.Context, userID int64) (int, error)
+
+	// GetByRemoteHandle looks up the ghost user standing in for a remote
+	// federation participant, if one has already been created for handle.
+	GetByRemoteHandle(ctx context.Context, handle string) (*User, error)
+}
+
+// ConversationRepository defines persistence operations for conversations.
+type ConversationRepository interface {
+	Create(ctx context.Context, c *Conversation, participantIDs []int64) error
+	GetByID(ctx context.Context, id int64) (*Conversation, error)
+	ListForUser(ctx context.Context, userID int64) ([]*Conversation, error)
+	MarkAsRead(ctx context.Context, conversationID, userID int64) error
+	GetUnreadCount(ctx context.Context, conversationID, userID int64) (int, error)
+	FindExistingDirect(ctx context.Context, participantIDs []int64) (*Conversation, error)
+	FindExistingGroup(ctx context.Context, participantIDs []int64) (*Conversation, error)
+
+	// SetRetention configures the retention worker's per-conversation policy:
+	// retentionSeconds caps a message's age, keepLastN caps how many recent
+	// messages survive. Either may be nil to fall back to the global default.
+	SetRetention(ctx context.Context, conversationID int64, retentionSeconds *int, keepLastN *int) error
+	// Rename changes a group conversation's display name.
+	Rename(ctx context.Context, conversationID int64, name string) error
+	// SetLocked marks conversationID read-only (true) or reopens it (false).
+	SetLocked(ctx context.Context, conversationID int64, locked bool) error
+	// ListAllIDs returns every conversation's ID, for admin-triggered
+	// operations (like a manual prune sweep) that act across all of them.
+	ListAllIDs(ctx context.Context) ([]int64, error)
+}
+
+// MessageRepository defines persistence operations for messages.
+type MessageRepository interface {
+	Create(ctx context.Context, m *Message) error
+	GetByID(ctx context.Context, id int64) (*Message, error)
+	Update(ctx context.Context, m *Message) error
+	SoftDeleteForEveryone(ctx context.Context, id int64) error
+	ListForConversation(ctx context.Context, conversationID int64, limit int) ([]*Message, error)
+	// ListForConversationForUser is like ListForConversation but excludes
+	// messages the given user has soft-deleted via "delete for me".
+	ListForConversationForUser(ctx context.Context, conversationID, userID int64, limit int) ([]*Message, error)
+	// MarkAllReadInConversation marks every unread message from another
+	// sender as read on behalf of callerID.
+	MarkAllReadInConversation(ctx context.Context, conversationID, callerID int64) error
+
+	// ListPage performs keyset pagination: the limit messages on the cur/dir
+	// side of the cursor that callerID is allowed to see, ordered so the
+	// first returned message is the one closest to the cursor. Pass a zero
+	// Cursor to start from the most recent message.
+	ListPage(ctx context.Context, conversationID, userID int64, cur Cursor, dir Direction, limit int) ([]*Message, error)
+	// ChangesSince returns everything a reconnecting client needs to catch
+	// up without refetching a full window: messages created or edited after
+	// since (upserts), and the ids of messages deleted after since.
+	ChangesSince(ctx context.Context, conversationID, userID int64, since time.Time) (upserts []*Message, deletedIDs []int64, err error)
+
+	// PruneOld deletes every message in conversationID beyond the keepLimit
+	// most recent, returning the ones it removed so the caller can clean up
+	// their attachment blobs and notify participants.
+	PruneOld(ctx context.Context, conversationID int64, keepLimit int) ([]PrunedMessage, error)
+	// PruneOlderThan deletes every message in conversationID created before
+	// cutoff, returning the ones it removed.
+	PruneOlderThan(ctx context.Context, conversationID int64, cutoff time.Time) ([]PrunedMessage, error)
+}
+
+// UserDeletedMessageRepository tracks per-user "delete for me" deletions:
+// rows here are excluded from that user's MessageRepository.ListForConversationForUser
+// without touching the underlying message, so every other participant still sees it.
+type UserDeletedMessageRepository interface {
+	Create(ctx context.Context, userID, messageID int64) error
+}
+
+// ProgressRepository tracks per-device delivery/read progress through a
+// conversation's messages (see MessageProgress). Message.IsRead is now
+// derived from these rows too (read on at least one device), rather than
+// being the only record of a message's read state.
+type ProgressRepository interface {
+	// MarkReadUpTo records deviceID, on behalf of userID, as having read
+	// every message in conversationID up to and including
+	// lastReadMessageID, at readAt. It never moves a message backward to an
+	// earlier read time.
+	MarkReadUpTo(ctx context.Context, conversationID, userID int64, deviceID string, lastReadMessageID int64, readAt time.Time) error
+	// ListForConversation returns the furthest-read state of every device
+	// that has reported progress in conversationID.
+	ListForConversation(ctx context.Context, conversationID int64) ([]*DeviceProgress, error)
+}
+
+// MessageSearchRepository performs full-text search over message content. It
+// is kept separate from MessageRepository because the underlying mechanism
+// is inherently store-specific (a tsvector column and a GIN index on
+// Postgres, an FTS5 virtual table on sqlite) and the two don't share a query
+// syntax beyond "these conversations, this query string".
+type MessageSearchRepository interface {
+	Search(ctx context.Context, conversationIDs []int64, query string, limit int) ([]*Message, error)
+}
+
+// ParticipantRepository defines operations around conversation participants.
+type ParticipantRepository interface {
+	ListParticipants(ctx context.Context, conversationID int64) ([]*User, error)
+	IsParticipant(ctx context.Context, conversationID, userID int64) (bool, error)
+
+	// GetRole returns the caller's role in conversationID, or "" (not
+	// ErrNotFound) if they aren't a participant.
+	GetRole(ctx context.Context, conversationID, userID int64) (ConversationRole, error)
+	// SetRole changes an existing participant's role.
+	SetRole(ctx context.Context, conversationID, userID int64, role ConversationRole) error
+	// AddParticipant joins userID to conversationID with the given role.
+	AddParticipant(ctx context.Context, conversationID, userID int64, role ConversationRole) error
+	// RemoveParticipant removes userID's membership in conversationID.
+	RemoveParticipant(ctx context.Context, conversationID, userID int64) error
+	// BanParticipant removes userID's membership in conversationID and
+	// records the ban, so AddParticipant refuses to let them rejoin.
+	BanParticipant(ctx context.Context, conversationID, userID int64) error
+	// IsBanned reports whether userID has been banned from conversationID.
+	IsBanned(ctx context.Context, conversationID, userID int64) (bool, error)
+}
+
+// AttachmentRepository defines persistence operations for uploaded attachment metadata.
+type AttachmentRepository interface {
+	Create(ctx context.Context, a *Attachment) error
+	GetByID(ctx context.Context, id int64) (*Attachment, error)
+	Delete(ctx context.Context, id int64) error
+	// FindBySHA256 looks up an attachment already stored under the given
+	// plaintext SHA-256, for content-addressed dedup on upload. It returns
+	// (nil, nil), not ErrNotFound, when no match exists.
+	FindBySHA256(ctx context.Context, sha256 string) (*Attachment, error)
+}
+
+// TusUploadRepository defines persistence operations for in-progress
+// resumable uploads.
+type TusUploadRepository interface {
+	Create(ctx context.Context, u *TusUpload) error
+	GetByID(ctx context.Context, id string) (*TusUpload, error)
+	UpdateOffset(ctx context.Context, id string, offset int64) error
+	Delete(ctx context.Context, id string) error
+	// ListExpired returns every upload whose ExpiresAt has passed, for the
+	// background garbage collector to sweep.
+	ListExpired(ctx context.Context, before time.Time) ([]*TusUpload, error)
+}
+
+// CallRepository defines persistence operations for the voice/video call
+// history log.
+type CallRepository interface {
+	Create(ctx context.Context, c *Call) error
+	GetByID(ctx context.Context, id string) (*Call, error)
+	// End records when and how a call finished.
+	End(ctx context.Context, id string, endedAt time.Time, outcome string) error
+	ListForConversation(ctx context.Context, conversationID int64, limit int) ([]*Call, error)
+}
+
+// CertRevocationRepository tracks revoked mTLS client certificates by serial
+// number, consulted by httpserver's mTLS middleware on every request so a
+// compromised agent certificate can be shut out without waiting for it to
+// expire.
+type CertRevocationRepository interface {
+	IsRevoked(ctx context.Context, serialHex string) (bool, error)
+	Revoke(ctx context.Context, serialHex string, subject string, reason string) error
+}
+
+// ConversationKeyRepository persists wrapped per-conversation
+// data-encryption-keys (see ConversationKey). GetActive is used on every
+// message encrypt; GetByID on every decrypt, keyed by the dek_id embedded
+// in the ciphertext's envelope header.
+type ConversationKeyRepository interface {
+	GetActive(ctx context.Context, conversationID int64) (*ConversationKey, error)
+	GetByID(ctx context.Context, id int64) (*ConversationKey, error)
+	Create(ctx context.Context, key *ConversationKey) error
+	Retire(ctx context.Context, id int64) error
+	// Rewrap persists a DEK re-wrapped under a new KEK during master key
+	// rotation; it does not change the key's active/retired status.
+	Rewrap(ctx context.Context, id int64, kekID uint32, wrappedDEK []byte) error
+	ListAll(ctx context.Context) ([]*ConversationKey, error)
+}
+
+// OAuthClientRepository defines persistence operations for third-party
+// applications registered to use internal/authserver's authorization-code
+// flow.
+type OAuthClientRepository interface {
+	GetByID(ctx context.Context, clientID string) (*OAuthClient, error)
+	Create(ctx context.Context, c *OAuthClient) error
+}
+
+// AuthRequestRepository persists the in-flight authorization-code grants
+// internal/authserver's /authorize endpoint creates and its /token endpoint
+// consumes.
+type AuthRequestRepository interface {
+	Create(ctx context.Context, req *AuthRequest) error
+	// GetByCode looks up an authorization code. It returns (nil, nil), not
+	// ErrNotFound, when no such code exists.
+	GetByCode(ctx context.Context, code string) (*AuthRequest, error)
+	// MarkUsed flags a code as redeemed so a replayed /token call is
+	// rejected, per RFC 6749 §4.1.2.
+	MarkUsed(ctx context.Context, code string) error
+	// DeleteExpired removes every request whose ExpiresAt is before cutoff.
+	DeleteExpired(ctx context.Context, cutoff time.Time) error
+}
+
+// VerificationTokenRepository persists the single-use tokens behind the
+// email-verification and password-reset flows (see VerificationToken).
+type VerificationTokenRepository interface {
+	Create(ctx context.Context, t *VerificationToken) error
+	// GetByToken returns (nil, nil), not ErrNotFound, when no such token
+	// exists.
+	GetByToken(ctx context.Context, token string) (*VerificationToken, error)
+	MarkUsed(ctx context.Context, token string) error
+	// DeleteExpired removes every token whose ExpiresAt is before cutoff.
+	DeleteExpired(ctx context.Context, cutoff time.Time) error
+}
+
+// RefreshTokenRepository persists the rotating refresh tokens issued by
+// AuthService.Login and consumed/rotated by AuthService.Refresh (see
+// RefreshToken).
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, t *RefreshToken) error
+	// GetByHash returns (nil, nil), not ErrNotFound, when no such token
+	// exists.
+	GetByHash(ctx context.Context, tokenHash string) (*RefreshToken, error)
+	// Revoke marks a single token, by ID, revoked.
+	Revoke(ctx context.Context, id int64) error
+	// RevokeAllForUser marks every token belonging to userID revoked, used
+	// when a revoked token is presented again (see RefreshToken).
+	RevokeAllForUser(ctx context.Context, userID int64) error
+	// DeleteExpired removes every token whose ExpiresAt is before cutoff.
+	DeleteExpired(ctx context.Context, cutoff time.Time) error
+}
+
+// OTPRepository defines persistence operations for TOTP enrollment and its
+// recovery codes.
+type OTPRepository interface {
+	Get(ctx context.Context, userID int64) (*UserOTP, error)
+	Upsert(ctx context.Context, o *UserOTP) error
+	Confirm(ctx context.Context, userID int64) error
+	Delete(ctx context.Context, userID int64) error
+	SetLastCounter(ctx context.Context, userID int64, counter int64) error
+	// CompareAndSetLastCounter atomically records counter as the most
+	// recently accepted HOTP counter iff it is still greater than the one
+	// on file, returning false (and leaving the row untouched) otherwise.
+	// Callers use this instead of Get+SetLastCounter to close the race
+	// where two requests presenting the same code both pass a read-then-write
+	// replay check before either persists the new counter.
+	CompareAndSetLastCounter(ctx context.Context, userID int64, counter int64) (bool, error)
+
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error
+	ListUnusedRecoveryCodes(ctx context.Context, userID int64) ([]RecoveryCode, error)
+	MarkRecoveryCodeUsed(ctx context.Context, userID int64, codeHash string) error
+}
+
+// FederationRepository records which remote handles (e.g.
+// "alice@host.example") a conversation has been federated to, so
+// internal/federation can reject an inbound envelope whose participant list
+// doesn't match what this server already believes the conversation looks
+// like.
+type FederationRepository interface {
+	// RemoteParticipants returns every remote handle federated into
+	// conversationID, in no particular order.
+	RemoteParticipants(ctx context.Context, conversationID int64) ([]string, error)
+	// AddRemoteParticipant is idempotent: adding an already-present handle
+	// is a no-op.
+	AddRemoteParticipant(ctx context.Context, conversationID int64, handle string) error
+	RemoveRemoteParticipant(ctx context.Context, conversationID int64, handle string) error
+	// ListFederatedConversations returns the ID of every conversation with
+	// at least one remote participant, so federation.Bridge can resubscribe
+	// to their gossip topics on startup.
+	ListFederatedConversations(ctx context.Context) ([]int64, error)
+}
@@ -0,0 +1,21 @@
+package email
+
+import (
+	"context"
+
+	"backend_go/internal/domain"
+)
+
+// NoopEmailer discards every message. It's the default when no SMTP server
+// is configured, and what tests construct services with.
+type NoopEmailer struct{}
+
+var _ domain.Emailer = NoopEmailer{}
+
+func (NoopEmailer) SendVerification(ctx context.Context, to, token string) error {
+	return nil
+}
+
+func (NoopEmailer) SendPasswordReset(ctx context.Context, to, token string) error {
+	return nil
+}
@@ -0,0 +1,103 @@
+// Package email sends the transactional email behind zchat's account
+// lifecycle flows: SMTPEmailer is the production backend, NoopEmailer is
+// the default for deployments that haven't configured SMTP.
+package email
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"html/template"
+	"mime"
+	"net/smtp"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"backend_go/internal/domain"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var (
+	htmlTemplates = template.Must(template.ParseFS(templateFS, "templates/*.html.tmpl"))
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt.tmpl"))
+)
+
+// SMTPConfig configures SMTPEmailer.
+type SMTPConfig struct {
+	Host          string
+	Port          int
+	Username      string
+	Password      string
+	From          string
+	AppName       string
+	PublicBaseURL string
+}
+
+// SMTPEmailer sends multipart/alternative (plain-text and HTML) mail through
+// a configured SMTP relay using PLAIN auth.
+type SMTPEmailer struct {
+	cfg SMTPConfig
+}
+
+func NewSMTPEmailer(cfg SMTPConfig) *SMTPEmailer {
+	return &SMTPEmailer{cfg: cfg}
+}
+
+var _ domain.Emailer = (*SMTPEmailer)(nil)
+
+func (e *SMTPEmailer) SendVerification(ctx context.Context, to, token string) error {
+	link := fmt.Sprintf("%s/api/auth/verify?token=%s", e.cfg.PublicBaseURL, token)
+	data := map[string]string{"AppName": e.cfg.AppName, "Link": link}
+	subject := fmt.Sprintf("Verify your %s account", e.cfg.AppName)
+	return e.sendTemplate(to, subject, "verification", data)
+}
+
+func (e *SMTPEmailer) SendPasswordReset(ctx context.Context, to, token string) error {
+	data := map[string]string{"AppName": e.cfg.AppName, "Token": token}
+	subject := fmt.Sprintf("Reset your %s password", e.cfg.AppName)
+	return e.sendTemplate(to, subject, "password_reset", data)
+}
+
+// sendTemplate renders name's text and HTML template pair and sends the
+// result as a multipart/alternative message, so mail clients that render
+// HTML show the formatted version while plain-text clients fall back to the
+// text part.
+func (e *SMTPEmailer) sendTemplate(to, subject, name string, data map[string]string) error {
+	var textBody bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&textBody, name+".txt.tmpl", data); err != nil {
+		return fmt.Errorf("render text template: %w", err)
+	}
+	var htmlBody bytes.Buffer
+	if err := htmlTemplates.ExecuteTemplate(&htmlBody, name+".html.tmpl", data); err != nil {
+		return fmt.Errorf("render html template: %w", err)
+	}
+	return e.send(to, subject, textBody.String(), htmlBody.String())
+}
+
+func (e *SMTPEmailer) send(to, subject, textBody, htmlBody string) error {
+	addr := fmt.Sprintf("%s:%d", e.cfg.Host, e.cfg.Port)
+	var auth smtp.Auth
+	if e.cfg.Username != "" {
+		auth = smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, e.cfg.Host)
+	}
+
+	boundary := fmt.Sprintf("zchat-%d", time.Now().UnixNano())
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", e.cfg.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", boundary)
+	fmt.Fprintf(&buf, "--%s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n\r\n", boundary, strings.TrimSpace(textBody))
+	fmt.Fprintf(&buf, "--%s\r\nContent-Type: text/html; charset=utf-8\r\n\r\n%s\r\n\r\n", boundary, strings.TrimSpace(htmlBody))
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	if err := smtp.SendMail(addr, auth, e.cfg.From, []string{to}, buf.Bytes()); err != nil {
+		return fmt.Errorf("send mail: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package events
+
+import (
+	"context"
+
+	"backend_go/internal/domain"
+)
+
+// NoopPublisher discards every event. It's the default when no event
+// backend is configured, and what tests construct services with.
+type NoopPublisher struct{}
+
+var _ domain.EventPublisher = NoopPublisher{}
+
+func (NoopPublisher) Publish(ctx context.Context, event any) error {
+	return nil
+}
@@ -0,0 +1,79 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+
+	"backend_go/internal/domain"
+)
+
+// streamFor routes an event to its Redis Stream key and tags it with a
+// short type name consumers can switch on without unmarshalling the payload
+// first. Message lifecycle events share one stream, auth lifecycle events
+// share another, mirroring how a consumer actually wants to subscribe
+// (e.g. search indexing only cares about chat.messages).
+func streamFor(event any) (stream, eventType string, err error) {
+	switch event.(type) {
+	case domain.MessageCreatedEvent:
+		return "chat.messages", "message.created", nil
+	case domain.MessageEditedEvent:
+		return "chat.messages", "message.edited", nil
+	case domain.MessageDeletedEvent:
+		return "chat.messages", "message.deleted", nil
+	case domain.MessageReadEvent:
+		return "chat.messages", "message.read", nil
+	case domain.UserRegisteredEvent:
+		return "chat.auth", "user.registered", nil
+	case domain.UserLoggedInEvent:
+		return "chat.auth", "user.logged_in", nil
+	case domain.UserLoggedOutEvent:
+		return "chat.auth", "user.logged_out", nil
+	default:
+		return "", "", fmt.Errorf("events: unrecognized event type %T", event)
+	}
+}
+
+// RedisStreamsPublisher publishes domain events to capped Redis Streams via
+// XADD, so downstream consumers (search indexing, push notifications,
+// analytics) can tail them without the publisher knowing who's listening.
+type RedisStreamsPublisher struct {
+	client *redis.Client
+	maxLen int64
+}
+
+func NewRedisStreamsPublisher(client *redis.Client, maxLen int64) *RedisStreamsPublisher {
+	if maxLen <= 0 {
+		maxLen = 10000
+	}
+	return &RedisStreamsPublisher{client: client, maxLen: maxLen}
+}
+
+var _ domain.EventPublisher = (*RedisStreamsPublisher)(nil)
+
+func (p *RedisStreamsPublisher) Publish(ctx context.Context, event any) error {
+	stream, eventType, err := streamFor(event)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	err = p.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: p.maxLen,
+		Approx: true,
+		Values: map[string]any{
+			"type":    eventType,
+			"payload": payload,
+		},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("xadd %s: %w", stream, err)
+	}
+	return nil
+}
@@ -0,0 +1,437 @@
+package federation
+
+import (
+	"container/list"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"backend_go/internal/domain"
+	"backend_go/internal/security"
+	"backend_go/internal/service"
+)
+
+// Broadcaster delivers a payload to specific local users over their open
+// WebSocket connections. ws.Hub satisfies this structurally, so this
+// package doesn't need to import ws — ws.MakeHandler is the one that
+// imports federation, to call Bridge.PublishMessage/PublishTyping after a
+// local send, which would otherwise be an import cycle (mirrors
+// call.Broadcaster).
+type Broadcaster interface {
+	BroadcastToUsers(userIDs []int64, payload any)
+}
+
+// seenCacheSize bounds the envelope-hash LRU that drops replayed gossip; a
+// few thousand is generous for the handful of envelopes any one topic sees
+// in the coalesce window's neighborhood.
+const seenCacheSize = 4096
+
+// coalesceWindow is how long Bridge batches outbound envelopes for the same
+// topic before flushing, so a chatty group chat doesn't spam one gossip
+// publish per keystroke-adjacent event.
+const coalesceWindow = 200 * time.Millisecond
+
+// messageBody is the EventMessage Payload.Body: just the fields a remote
+// CreateMessage needs, not the full domain.Message (e.g. no local IDs).
+// Attachments aren't federated — the remote server has no way to fetch the
+// bytes from our content-addressed store — so only text content crosses.
+type messageBody struct {
+	Content  string `json:"content"`
+	ViewOnce bool   `json:"view_once,omitempty"`
+}
+
+// Bridge relays local conversation activity to other zchat servers over a
+// Transport, and relays inbound activity from them back onto
+// service.Messages.CreateMessage, status-go-messenger style. See the
+// package doc comment in envelope.go for the trust model.
+type Bridge struct {
+	transport      Transport
+	fedRepo        domain.FederationRepository
+	users          domain.UserRepository
+	messages       service.Messages
+	hub            Broadcaster
+	passwordHasher *security.PasswordWrapper
+
+	serverHost string
+	serverKey  ed25519.PrivateKey
+
+	seenMu  sync.Mutex
+	seen    map[[32]byte]*list.Element
+	seenLRU *list.List
+
+	subMu      sync.Mutex
+	subscribed map[string]bool
+
+	outMu  sync.Mutex
+	outbox map[string]*pendingBatch
+}
+
+type pendingBatch struct {
+	envelopes []*Envelope
+	timer     *time.Timer
+}
+
+// NewBridge builds a Bridge that signs outbound envelopes as serverHost
+// (this server's half of every local handle, e.g. "host.example" in
+// "alice@host.example") using serverKey.
+func NewBridge(transport Transport, fedRepo domain.FederationRepository, users domain.UserRepository, messages service.Messages, hub Broadcaster, passwordHasher *security.PasswordWrapper, serverHost string, serverKey ed25519.PrivateKey) *Bridge {
+	return &Bridge{
+		transport:      transport,
+		fedRepo:        fedRepo,
+		users:          users,
+		messages:       messages,
+		hub:            hub,
+		passwordHasher: passwordHasher,
+		serverHost:     serverHost,
+		serverKey:      serverKey,
+		seen:           make(map[[32]byte]*list.Element),
+		seenLRU:        list.New(),
+		subscribed:     make(map[string]bool),
+		outbox:         make(map[string]*pendingBatch),
+	}
+}
+
+// Start resubscribes to every conversation this server already has remote
+// participants recorded for, so a restart picks gossip back up without
+// waiting for JoinConversation to be called again.
+func (b *Bridge) Start(ctx context.Context) error {
+	convIDs, err := b.fedRepo.ListFederatedConversations(ctx)
+	if err != nil {
+		return fmt.Errorf("list federated conversations: %w", err)
+	}
+	for _, convID := range convIDs {
+		if err := b.subscribe(ctx, ConversationTopic(convID)); err != nil {
+			log.Printf("federation: resubscribe to conversation %d: %v", convID, err)
+		}
+	}
+	return nil
+}
+
+// JoinConversation records remoteHandles as federated participants of
+// convID and (re)subscribes to its gossip topic. It is idempotent: an
+// already-known handle or an already-subscribed topic is a no-op.
+func (b *Bridge) JoinConversation(ctx context.Context, convID int64, remoteHandles ...string) error {
+	for _, h := range remoteHandles {
+		if _, _, ok := ParseHandle(h); !ok {
+			return fmt.Errorf("federation: invalid handle %q", h)
+		}
+		if err := b.fedRepo.AddRemoteParticipant(ctx, convID, h); err != nil {
+			return fmt.Errorf("add remote participant: %w", err)
+		}
+	}
+	return b.subscribe(ctx, ConversationTopic(convID))
+}
+
+func (b *Bridge) subscribe(ctx context.Context, topic string) error {
+	b.subMu.Lock()
+	if b.subscribed[topic] {
+		b.subMu.Unlock()
+		return nil
+	}
+	b.subscribed[topic] = true
+	b.subMu.Unlock()
+
+	ch, err := b.transport.Subscribe(ctx, topic)
+	if err != nil {
+		b.subMu.Lock()
+		delete(b.subscribed, topic)
+		b.subMu.Unlock()
+		return err
+	}
+	go func() {
+		for data := range ch {
+			b.ingest(data)
+		}
+	}()
+	return nil
+}
+
+// PublishMessage signs and gossips a locally-created message, if and only
+// if convID has at least one federated remote participant; a purely local
+// conversation never touches Transport. Errors are the caller's to log,
+// not to fail the local send over — see service.MessageService.publish for
+// the same fire-and-forget philosophy applied to domain events.
+func (b *Bridge) PublishMessage(ctx context.Context, convID, senderID int64, senderUsername string, createdAt time.Time, content string, viewOnce bool) error {
+	remote, err := b.fedRepo.RemoteParticipants(ctx, convID)
+	if err != nil {
+		return fmt.Errorf("check remote participants: %w", err)
+	}
+	if len(remote) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(messageBody{Content: content, ViewOnce: viewOnce})
+	if err != nil {
+		return fmt.Errorf("marshal message body: %w", err)
+	}
+	return b.enqueue(ConversationTopic(convID), Payload{
+		Type:           EventMessage,
+		ConversationID: convID,
+		SenderHandle:   senderUsername + "@" + b.serverHost,
+		CreatedAtUnix:  createdAt.Unix(),
+		Body:           body,
+	}, senderID)
+}
+
+// PublishTyping gossips a typing indicator the same way PublishMessage
+// gossips a message, minus a body — EventTyping carries no payload beyond
+// who and where.
+func (b *Bridge) PublishTyping(ctx context.Context, convID, senderID int64, senderUsername string) error {
+	remote, err := b.fedRepo.RemoteParticipants(ctx, convID)
+	if err != nil {
+		return fmt.Errorf("check remote participants: %w", err)
+	}
+	if len(remote) == 0 {
+		return nil
+	}
+	return b.enqueue(ConversationTopic(convID), Payload{
+		Type:           EventTyping,
+		ConversationID: convID,
+		SenderHandle:   senderUsername + "@" + b.serverHost,
+		CreatedAtUnix:  time.Now().Unix(),
+	}, senderID)
+}
+
+func (b *Bridge) enqueue(topic string, payload Payload, senderID int64) error {
+	env := &Envelope{Payload: payload}
+	if err := env.Sign(b.serverKey, deviceKeyFor(b.serverKey, senderID)); err != nil {
+		return fmt.Errorf("sign envelope: %w", err)
+	}
+
+	b.outMu.Lock()
+	defer b.outMu.Unlock()
+	batch, ok := b.outbox[topic]
+	if !ok {
+		batch = &pendingBatch{}
+		b.outbox[topic] = batch
+	}
+	batch.envelopes = append(batch.envelopes, env)
+	if batch.timer == nil {
+		batch.timer = time.AfterFunc(coalesceWindow, func() { b.flush(topic) })
+	}
+	return nil
+}
+
+func (b *Bridge) flush(topic string) {
+	b.outMu.Lock()
+	batch := b.outbox[topic]
+	delete(b.outbox, topic)
+	b.outMu.Unlock()
+	if batch == nil || len(batch.envelopes) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(batch.envelopes)
+	if err != nil {
+		log.Printf("federation: marshal envelope batch for %s: %v", topic, err)
+		return
+	}
+	// Detached from whatever request context triggered the first envelope
+	// in the batch, since this runs after that request has already
+	// returned.
+	if err := b.transport.Publish(context.Background(), topic, data); err != nil {
+		log.Printf("federation: publish to %s: %v", topic, err)
+	}
+}
+
+// ingest verifies, dedupes, and applies every envelope in a gossiped batch.
+func (b *Bridge) ingest(data []byte) {
+	var envs []*Envelope
+	if err := json.Unmarshal(data, &envs); err != nil {
+		log.Printf("federation: unmarshal envelope batch: %v", err)
+		return
+	}
+	ctx := context.Background()
+	for _, env := range envs {
+		if err := b.apply(ctx, env); err != nil {
+			log.Printf("federation: apply envelope: %v", err)
+		}
+	}
+}
+
+func (b *Bridge) apply(ctx context.Context, env *Envelope) error {
+	if err := env.Verify(); err != nil {
+		return err
+	}
+
+	hash, err := env.Hash()
+	if err != nil {
+		return err
+	}
+	if !b.markSeen(hash) {
+		return nil // already applied this envelope
+	}
+
+	_, host, ok := ParseHandle(env.Payload.SenderHandle)
+	if !ok {
+		return fmt.Errorf("invalid sender handle %q", env.Payload.SenderHandle)
+	}
+	if host == b.serverHost {
+		return nil // our own envelope, echoed back by the overlay
+	}
+
+	remote, err := b.fedRepo.RemoteParticipants(ctx, env.Payload.ConversationID)
+	if err != nil {
+		return fmt.Errorf("list remote participants: %w", err)
+	}
+	if !containsHandle(remote, env.Payload.SenderHandle) {
+		return fmt.Errorf("sender %s is not a known remote participant of conversation %d", env.Payload.SenderHandle, env.Payload.ConversationID)
+	}
+
+	sender, err := b.resolveGhostUser(ctx, env.Payload.SenderHandle)
+	if err != nil {
+		return fmt.Errorf("resolve remote sender: %w", err)
+	}
+
+	switch env.Payload.Type {
+	case EventMessage:
+		return b.applyMessage(ctx, env, sender, host)
+	case EventTyping:
+		return b.applyTyping(ctx, env, sender)
+	default:
+		return fmt.Errorf("unknown event type %q", env.Payload.Type)
+	}
+}
+
+func (b *Bridge) applyMessage(ctx context.Context, env *Envelope, sender *domain.User, host string) error {
+	var body messageBody
+	if err := json.Unmarshal(env.Payload.Body, &body); err != nil {
+		return fmt.Errorf("unmarshal message body: %w", err)
+	}
+
+	msg, err := b.messages.CreateMessage(ctx, service.MessageCreateInput{
+		ConversationID: env.Payload.ConversationID,
+		Content:        body.Content,
+		ViewOnce:       body.ViewOnce,
+		OriginServer:   &host,
+	}, sender.ID)
+	if err != nil {
+		return fmt.Errorf("create remote message: %w", err)
+	}
+
+	resp, err := b.messages.ToResponse(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("render remote message: %w", err)
+	}
+	participantIDs, err := b.messages.GetParticipantIDs(ctx, env.Payload.ConversationID)
+	if err != nil {
+		return fmt.Errorf("get participants: %w", err)
+	}
+	b.hub.BroadcastToUsers(participantIDs, map[string]any{
+		"type":            "message",
+		"conversation_id": resp.ConversationID,
+		"message_id":      resp.ID,
+		"content":         resp.Content,
+		"sender_id":       resp.SenderID,
+		"sender_username": resp.SenderUsername,
+		"timestamp":       resp.CreatedAt,
+		"file_path":       resp.FilePath,
+		"file_type":       resp.FileType,
+		"is_deleted":      resp.IsDeleted,
+		"is_read":         false,
+		"expires_at":      resp.ExpiresAt,
+		"view_once":       resp.ViewOnce,
+	})
+	return nil
+}
+
+func (b *Bridge) applyTyping(ctx context.Context, env *Envelope, sender *domain.User) error {
+	participantIDs, err := b.messages.GetParticipantIDs(ctx, env.Payload.ConversationID)
+	if err != nil {
+		return fmt.Errorf("get participants: %w", err)
+	}
+	b.hub.BroadcastToUsers(participantIDs, map[string]any{
+		"type":            "typing",
+		"conversation_id": env.Payload.ConversationID,
+		"user_id":         sender.ID,
+		"username":        sender.Username,
+	})
+	return nil
+}
+
+// resolveGhostUser returns the local "ghost" User standing in for handle,
+// creating one the first time handle posts into a conversation on this
+// server (see domain.User.RemoteHandle).
+func (b *Bridge) resolveGhostUser(ctx context.Context, handle string) (*domain.User, error) {
+	existing, err := b.users.GetByRemoteHandle(ctx, handle)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+
+	// A ghost user can never log in locally, but users.hashed_password is
+	// NOT NULL; fill it with a hash of random bytes nobody knows, same as
+	// OIDCService does for externally-authenticated accounts.
+	randomPassword := make([]byte, 32)
+	if _, err := rand.Read(randomPassword); err != nil {
+		return nil, fmt.Errorf("generate ghost user password: %w", err)
+	}
+	hashed, err := b.passwordHasher.Hash(hex.EncodeToString(randomPassword))
+	if err != nil {
+		return nil, fmt.Errorf("hash ghost user password: %w", err)
+	}
+
+	u := &domain.User{Username: handle, HashedPassword: hashed, IsActive: true, RemoteHandle: &handle}
+	if err := b.users.Create(ctx, u); err != nil {
+		// Lost a race with another envelope creating the same ghost user
+		// concurrently; the loser just looks the winner up.
+		if existing, getErr := b.users.GetByRemoteHandle(ctx, handle); getErr == nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return u, nil
+}
+
+func (b *Bridge) markSeen(hash [32]byte) bool {
+	b.seenMu.Lock()
+	defer b.seenMu.Unlock()
+	if _, ok := b.seen[hash]; ok {
+		return false
+	}
+	el := b.seenLRU.PushFront(hash)
+	b.seen[hash] = el
+	if b.seenLRU.Len() > seenCacheSize {
+		oldest := b.seenLRU.Back()
+		if oldest != nil {
+			b.seenLRU.Remove(oldest)
+			delete(b.seen, oldest.Value.([32]byte))
+		}
+	}
+	return true
+}
+
+func containsHandle(handles []string, target string) bool {
+	for _, h := range handles {
+		if h == target {
+			return true
+		}
+	}
+	return false
+}
+
+// deviceKeyFor deterministically derives a per-user signing key from the
+// server's own identity key plus userID. This is a placeholder for real
+// per-device E2E identity: zchat doesn't yet give clients a persistent
+// device keypair (see security.Encryptor for the conversation-level
+// encryption it does have), so DeviceSig can't prove a specific device sent
+// a message yet — only that this server is vouching for this user, which
+// ServerSig already does. It exists so the wire format is ready for that
+// once client-side key management lands, without another migration.
+func deviceKeyFor(serverKey ed25519.PrivateKey, userID int64) ed25519.PrivateKey {
+	mac := hmac.New(sha256.New, serverKey.Seed())
+	_ = binary.Write(mac, binary.BigEndian, userID)
+	return ed25519.NewKeyFromSeed(mac.Sum(nil))
+}
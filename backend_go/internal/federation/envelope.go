@@ -0,0 +1,100 @@
+// Package federation bridges zchat's conversations to other zchat servers
+// over a gossip pubsub overlay, status-go-messenger style: each server
+// signs an Envelope with its own long-lived key and relays it over a
+// topic-based Transport, and a receiving server only applies it once it
+// verifies both signatures, checks it hasn't seen the envelope before, and
+// confirms the sender is a remote participant it already knows about.
+package federation
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// EventType identifies what an Envelope's Payload carries.
+type EventType string
+
+const (
+	EventMessage EventType = "message"
+	EventTyping  EventType = "typing"
+)
+
+// Payload is the part of an Envelope that gets content-addressed and
+// signed. Its field order is fixed by this struct's declaration, since
+// encoding/json always marshals struct fields in declaration order — two
+// servers that agree on this struct agree on the canonical bytes of a
+// wire-identical Payload, which is all Hash and Sign/Verify need.
+type Payload struct {
+	Type           EventType `json:"type"`
+	ConversationID int64     `json:"conversation_id"`
+	SenderHandle   string    `json:"sender_handle"`
+	CreatedAtUnix  int64     `json:"created_at_unix"`
+	// Body is the event-specific JSON (a message's content, a typing
+	// ping, ...), kept opaque here so Envelope/Transport never need to
+	// know about every event shape Bridge supports.
+	Body json.RawMessage `json:"body"`
+}
+
+// Envelope is what actually crosses the wire: a Payload plus the two
+// signatures a receiver must verify before accepting it:
+//   - ServerSig, by the origin server's long-lived key, vouches for which
+//     server is relaying the envelope.
+//   - DeviceSig, by a per-user key, is the slot for the sending user's own
+//     signature. Bridge derives that key from the server's own key (see
+//     deviceKeyFor), so it does not prove the server didn't forge a
+//     message on the user's behalf.
+type Envelope struct {
+	Payload      Payload `json:"payload"`
+	ServerPubKey []byte  `json:"server_pub_key"`
+	ServerSig    []byte  `json:"server_sig"`
+	DevicePubKey []byte  `json:"device_pub_key"`
+	DeviceSig    []byte  `json:"device_sig"`
+}
+
+// Hash content-addresses an envelope's Payload as BLAKE2b-256 of its
+// canonical encoding, so Bridge's seen-cache can dedupe a gossiped replay without
+// trusting anything the sender signed.
+func (e *Envelope) Hash() ([32]byte, error) {
+	canonical, err := json.Marshal(e.Payload)
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("canonicalize payload: %w", err)
+	}
+	return blake2b.Sum256(canonical), nil
+}
+
+// Sign fills in ServerSig and DeviceSig (and their matching public keys)
+// over the Payload's canonical encoding.
+func (e *Envelope) Sign(serverKey, deviceKey ed25519.PrivateKey) error {
+	canonical, err := json.Marshal(e.Payload)
+	if err != nil {
+		return fmt.Errorf("canonicalize payload: %w", err)
+	}
+	e.ServerPubKey = serverKey.Public().(ed25519.PublicKey)
+	e.ServerSig = ed25519.Sign(serverKey, canonical)
+	e.DevicePubKey = deviceKey.Public().(ed25519.PublicKey)
+	e.DeviceSig = ed25519.Sign(deviceKey, canonical)
+	return nil
+}
+
+// Verify checks both signatures over the Payload's canonical encoding
+// against the envelope's own embedded public keys. It does not check that
+// ServerPubKey is a server Bridge trusts, or that DevicePubKey belongs to
+// SenderHandle — Bridge.apply checks the sender against the
+// conversation's known remote participants separately.
+func (e *Envelope) Verify() error {
+	canonical, err := json.Marshal(e.Payload)
+	if err != nil {
+		return fmt.Errorf("canonicalize payload: %w", err)
+	}
+	if len(e.ServerPubKey) != ed25519.PublicKeySize || !ed25519.Verify(e.ServerPubKey, canonical, e.ServerSig) {
+		return errors.New("federation: invalid server signature")
+	}
+	if len(e.DevicePubKey) != ed25519.PublicKeySize || !ed25519.Verify(e.DevicePubKey, canonical, e.DeviceSig) {
+		return errors.New("federation: invalid device signature")
+	}
+	return nil
+}
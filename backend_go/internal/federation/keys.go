@@ -0,0 +1,45 @@
+package federation
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// LoadOrGenerateServerKey loads this server's long-lived Ed25519 identity
+// from a PKCS8 PEM file at path, or generates a fresh one if path is empty.
+// A freshly generated key does not survive a restart, which is fine for
+// development but means every deploy mints a new identity; production
+// should set FederationServerKeyPath so the same key (and therefore the
+// same libp2p peer ID, see NewLibp2pTransport) persists across restarts.
+// Mirrors security.LoadOrGenerateKeySet.
+func LoadOrGenerateServerKey(path string) (ed25519.PrivateKey, error) {
+	if path == "" {
+		_, priv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			return nil, fmt.Errorf("generate federation server key: %w", err)
+		}
+		return priv, nil
+	}
+
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read federation server key file: %w", err)
+	}
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("federation: invalid PEM block in server key file")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse PKCS8 federation server key: %w", err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("federation server key is %T, want ed25519.PrivateKey", key)
+	}
+	return priv, nil
+}
@@ -0,0 +1,140 @@
+package federation
+
+import (
+	"context"
+	goed25519 "crypto/ed25519"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/libp2p/go-libp2p"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Libp2pIdentity adapts serverKey — the same Ed25519 key Bridge uses for
+// envelope ServerSig — into the libp2p node identity NewLibp2pTransport
+// needs, so a server has one long-lived key rather than two.
+func Libp2pIdentity(serverKey goed25519.PrivateKey) (crypto.PrivKey, error) {
+	priv, err := crypto.UnmarshalEd25519PrivateKey(serverKey)
+	if err != nil {
+		return nil, fmt.Errorf("adapt federation server key for libp2p: %w", err)
+	}
+	return priv, nil
+}
+
+// Libp2pTransport is the production Transport: a libp2p host running
+// gossipsub, the same relay protocol Waku builds its store/relay nodes
+// on. Bootstrap peers are dialed once at startup; gossipsub's own peer
+// exchange keeps the mesh connected after that.
+type Libp2pTransport struct {
+	host host.Host
+	ps   *pubsub.PubSub
+
+	mu     sync.Mutex
+	topics map[string]*pubsub.Topic
+}
+
+// NewLibp2pTransport starts a libp2p host identified by identityKey,
+// listening on listenAddr (a multiaddr, e.g. "/ip4/0.0.0.0/tcp/4001"),
+// joins gossipsub, and dials bootstrapPeers (multiaddrs including a peer
+// ID, e.g. "/dns4/peer.example/tcp/4001/p2p/Qm...").
+func NewLibp2pTransport(ctx context.Context, listenAddr string, identityKey crypto.PrivKey, bootstrapPeers []string) (*Libp2pTransport, error) {
+	h, err := libp2p.New(
+		libp2p.Identity(identityKey),
+		libp2p.ListenAddrStrings(listenAddr),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create libp2p host: %w", err)
+	}
+
+	ps, err := pubsub.NewGossipSub(ctx, h)
+	if err != nil {
+		h.Close()
+		return nil, fmt.Errorf("start gossipsub: %w", err)
+	}
+
+	t := &Libp2pTransport{host: h, ps: ps, topics: make(map[string]*pubsub.Topic)}
+
+	for _, addr := range bootstrapPeers {
+		if err := t.dial(ctx, addr); err != nil {
+			log.Printf("federation: dial bootstrap peer %s: %v", addr, err)
+		}
+	}
+
+	return t, nil
+}
+
+func (t *Libp2pTransport) dial(ctx context.Context, addr string) error {
+	maddr, err := multiaddr.NewMultiaddr(addr)
+	if err != nil {
+		return fmt.Errorf("parse multiaddr: %w", err)
+	}
+	info, err := peer.AddrInfoFromP2pAddr(maddr)
+	if err != nil {
+		return fmt.Errorf("parse peer info: %w", err)
+	}
+	return t.host.Connect(ctx, *info)
+}
+
+func (t *Libp2pTransport) joinTopic(topic string) (*pubsub.Topic, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if tp, ok := t.topics[topic]; ok {
+		return tp, nil
+	}
+	tp, err := t.ps.Join(topic)
+	if err != nil {
+		return nil, fmt.Errorf("join topic %s: %w", topic, err)
+	}
+	t.topics[topic] = tp
+	return tp, nil
+}
+
+func (t *Libp2pTransport) Publish(ctx context.Context, topic string, data []byte) error {
+	tp, err := t.joinTopic(topic)
+	if err != nil {
+		return err
+	}
+	return tp.Publish(ctx, data)
+}
+
+func (t *Libp2pTransport) Subscribe(ctx context.Context, topic string) (<-chan []byte, error) {
+	tp, err := t.joinTopic(topic)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := tp.Subscribe()
+	if err != nil {
+		return nil, fmt.Errorf("subscribe to topic %s: %w", topic, err)
+	}
+
+	out := make(chan []byte, 32)
+	go func() {
+		defer close(out)
+		defer sub.Cancel()
+		for {
+			msg, err := sub.Next(ctx)
+			if err != nil {
+				return
+			}
+			if msg.ReceivedFrom == t.host.ID() {
+				continue
+			}
+			select {
+			case out <- msg.Data:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (t *Libp2pTransport) Close() error {
+	return t.host.Close()
+}
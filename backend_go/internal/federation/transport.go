@@ -0,0 +1,50 @@
+package federation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Transport moves signed Envelope bytes between zchat servers over a
+// pubsub overlay, one topic per federated conversation. Bridge owns
+// serialization and verification; Transport only ever sees opaque bytes.
+type Transport interface {
+	Publish(ctx context.Context, topic string, data []byte) error
+	// Subscribe delivers every message published to topic from here on,
+	// until ctx is cancelled, at which point the channel is closed.
+	Subscribe(ctx context.Context, topic string) (<-chan []byte, error)
+	Close() error
+}
+
+const (
+	conversationTopicPrefix = "zchat/conversation/"
+	directTopicPrefix       = "zchat/direct/"
+)
+
+// ConversationTopic is the gossip topic for an already-established
+// federated group conversation.
+func ConversationTopic(conversationID int64) string {
+	return fmt.Sprintf("%s%d", conversationTopicPrefix, conversationID)
+}
+
+// DirectTopic is the topic for a one-to-one conversation that hasn't been
+// federated yet (no shared conversation_id on both sides), derived from
+// the two participants' handles sorted so either side names the same
+// topic.
+func DirectTopic(handleA, handleB string) string {
+	if handleA > handleB {
+		handleA, handleB = handleB, handleA
+	}
+	return directTopicPrefix + handleA + "|" + handleB
+}
+
+// ParseHandle splits "alice@host.example" into ("alice", "host.example").
+// ok is false if handle has no '@' or either side is empty.
+func ParseHandle(handle string) (user, host string, ok bool) {
+	i := strings.LastIndex(handle, "@")
+	if i <= 0 || i == len(handle)-1 {
+		return "", "", false
+	}
+	return handle[:i], handle[i+1:], true
+}
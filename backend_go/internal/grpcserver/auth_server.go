@@ -0,0 +1,75 @@
+package grpcserver
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"backend_go/internal/domain"
+	"backend_go/internal/grpcserver/zchatv1"
+	"backend_go/internal/service"
+)
+
+type authServer struct {
+	zchatv1.UnimplementedAuthServiceServer
+	auth service.Auth
+}
+
+func (a *authServer) Register(ctx context.Context, req *zchatv1.RegisterRequest) (*zchatv1.User, error) {
+	u, err := a.auth.Register(ctx, service.RegisterInput{
+		Username: req.GetUsername(),
+		Email:    req.Email,
+		Password: req.GetPassword(),
+	})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return toProtoUser(u), nil
+}
+
+func (a *authServer) Login(ctx context.Context, req *zchatv1.LoginRequest) (*zchatv1.LoginResponse, error) {
+	resp, err := a.auth.Login(ctx, service.LoginInput{
+		Username:   req.GetUsername(),
+		Password:   req.GetPassword(),
+		RememberMe: req.GetRememberMe(),
+	})
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return &zchatv1.LoginResponse{
+		AccessToken:  resp.AccessToken,
+		PreAuthToken: resp.PreAuthToken,
+		RequiresOtp:  resp.RequiresOTP,
+		TokenType:    resp.TokenType,
+		User:         toProtoUser(resp.User),
+	}, nil
+}
+
+func (a *authServer) Logout(ctx context.Context, req *zchatv1.LogoutRequest) (*zchatv1.LogoutResponse, error) {
+	user := currentUser(ctx)
+	if user == nil {
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	if err := a.auth.Logout(ctx, user.ID, ""); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &zchatv1.LogoutResponse{}, nil
+}
+
+func toProtoUser(u *domain.User) *zchatv1.User {
+	if u == nil {
+		return nil
+	}
+	return &zchatv1.User{
+		Id:               u.ID,
+		Username:         u.Username,
+		Email:            u.Email,
+		IsActive:         u.IsActive,
+		IsOnline:         u.IsOnline,
+		IsServiceAccount: u.IsServiceAccount,
+		CreatedAt:        timestamppb.New(u.CreatedAt),
+		RemoteHandle:     u.RemoteHandle,
+	}
+}
@@ -0,0 +1,100 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"backend_go/internal/domain"
+	"backend_go/internal/grpcserver/zchatv1"
+	"backend_go/internal/service"
+)
+
+type conversationServer struct {
+	zchatv1.UnimplementedConversationServiceServer
+	conversations *service.ConversationService
+}
+
+func (c *conversationServer) CreateConversation(ctx context.Context, req *zchatv1.CreateConversationRequest) (*zchatv1.Conversation, error) {
+	user := currentUser(ctx)
+	if user == nil {
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	conv, err := c.conversations.CreateConversation(ctx, service.ConversationCreateInput{
+		Name:           req.Name,
+		IsGroup:        req.GetIsGroup(),
+		ParticipantIDs: req.GetParticipantIds(),
+	}, user.ID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return toProtoConversation(conv.Conversation), nil
+}
+
+func (c *conversationServer) ListConversations(ctx context.Context, req *zchatv1.ListConversationsRequest) (*zchatv1.ListConversationsResponse, error) {
+	user := currentUser(ctx)
+	if user == nil {
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	convs, err := c.conversations.ListForUser(ctx, user.ID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &zchatv1.ListConversationsResponse{Conversations: make([]*zchatv1.Conversation, 0, len(convs))}
+	for _, conv := range convs {
+		resp.Conversations = append(resp.Conversations, toProtoConversation(conv.Conversation))
+	}
+	return resp, nil
+}
+
+func (c *conversationServer) GetConversation(ctx context.Context, req *zchatv1.GetConversationRequest) (*zchatv1.Conversation, error) {
+	user := currentUser(ctx)
+	if user == nil {
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	conv, err := c.conversations.GetConversation(ctx, req.GetConversationId(), user.ID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return toProtoConversation(conv.Conversation), nil
+}
+
+func (c *conversationServer) AddParticipant(ctx context.Context, req *zchatv1.AddParticipantRequest) (*zchatv1.AddParticipantResponse, error) {
+	user := currentUser(ctx)
+	if user == nil {
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	err := c.conversations.AddParticipant(ctx, req.GetConversationId(), user.ID, req.GetUserId())
+	if err != nil {
+		if errors.Is(err, service.ErrForbidden) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &zchatv1.AddParticipantResponse{}, nil
+}
+
+func toProtoConversation(conv *domain.Conversation) *zchatv1.Conversation {
+	if conv == nil {
+		return nil
+	}
+	out := &zchatv1.Conversation{
+		Id:        conv.ID,
+		Name:      conv.Name,
+		IsGroup:   conv.IsGroup,
+		CreatedAt: timestamppb.New(conv.CreatedAt),
+		UpdatedAt: timestamppb.New(conv.UpdatedAt),
+	}
+	if conv.RetentionSeconds != nil {
+		v := int32(*conv.RetentionSeconds)
+		out.RetentionSeconds = &v
+	}
+	if conv.KeepLastN != nil {
+		v := int32(*conv.KeepLastN)
+		out.KeepLastN = &v
+	}
+	return out
+}
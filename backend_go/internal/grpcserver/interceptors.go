@@ -0,0 +1,103 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"backend_go/internal/domain"
+	"backend_go/internal/security"
+)
+
+type ctxKey string
+
+const userCtxKey ctxKey = "grpcserver.currentUser"
+
+// currentUser extracts the user attached by the auth interceptors, if any.
+// Subscribe and every *ServiceServer method read the caller through this
+// instead of re-parsing the token themselves.
+func currentUser(ctx context.Context) *domain.User {
+	if u, ok := ctx.Value(userCtxKey).(*domain.User); ok {
+		return u
+	}
+	return nil
+}
+
+// authenticate pulls the "authorization" metadata value (a bare "Bearer
+// <token>" header, same as the HTTP API) out of ctx and validates it with
+// security.AuthenticateBearer — the same rule httpserver.AuthMiddleware
+// enforces, so a token good enough for REST is good enough for gRPC and
+// vice versa.
+func (s *Server) authenticate(ctx context.Context) (*domain.User, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing metadata")
+	}
+	vals := md.Get("authorization")
+	if len(vals) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing authorization metadata")
+	}
+	tokenStr := strings.TrimSpace(vals[0])
+	tokenStr = strings.TrimPrefix(tokenStr, "Bearer ")
+	tokenStr = strings.TrimPrefix(tokenStr, "bearer ")
+
+	user, err := security.AuthenticateBearer(ctx, s.tokens, s.users, s.otps, tokenStr)
+	if err != nil {
+		if errors.Is(err, security.ErrUnauthenticated) {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		return nil, status.Error(codes.Internal, "authentication failed")
+	}
+	return user, nil
+}
+
+// unauthenticatedMethods are exempt from the auth interceptors below —
+// Register/Login have no caller identity yet; that's the point of calling
+// them.
+var unauthenticatedMethods = map[string]bool{
+	"/zchat.v1.AuthService/Register": true,
+	"/zchat.v1.AuthService/Login":    true,
+}
+
+// UnaryAuthInterceptor authenticates every unary RPC except Register/Login
+// and attaches the resulting user to the handler's context, mirroring
+// httpserver.AuthMiddleware for the unary half of the gRPC surface.
+func (s *Server) UnaryAuthInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if unauthenticatedMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+	user, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(context.WithValue(ctx, userCtxKey, user), req)
+}
+
+// authServerStream wraps a grpc.ServerStream to override Context(), the only
+// way to hand an authenticated user down to a streaming handler (Subscribe)
+// without changing the generated *Server interface.
+type authServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// StreamAuthInterceptor is UnaryAuthInterceptor's streaming-RPC
+// counterpart; StreamService.Subscribe is the only streaming RPC this
+// server has, and it always requires a caller.
+func (s *Server) StreamAuthInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	user, err := s.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	ctx := context.WithValue(ss.Context(), userCtxKey, user)
+	return handler(srv, &authServerStream{ServerStream: ss, ctx: ctx})
+}
@@ -0,0 +1,149 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"backend_go/internal/domain"
+	"backend_go/internal/grpcserver/zchatv1"
+	"backend_go/internal/service"
+)
+
+type messageServer struct {
+	zchatv1.UnimplementedMessageServiceServer
+	messages service.Messages
+}
+
+func (m *messageServer) CreateMessage(ctx context.Context, req *zchatv1.CreateMessageRequest) (*zchatv1.Message, error) {
+	user := currentUser(ctx)
+	if user == nil {
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	msg, err := m.messages.CreateMessage(ctx, service.MessageCreateInput{
+		ConversationID: req.GetConversationId(),
+		Content:        req.GetContent(),
+		AttachmentID:   req.AttachmentId,
+		TTLSeconds:     int(req.GetTtlSeconds()),
+		ViewOnce:       req.GetViewOnce(),
+	}, user.ID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	resp, err := m.messages.ToResponse(ctx, msg)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return toProtoMessage(resp), nil
+}
+
+func (m *messageServer) ListMessages(ctx context.Context, req *zchatv1.ListMessagesRequest) (*zchatv1.ListMessagesResponse, error) {
+	user := currentUser(ctx)
+	if user == nil {
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	dir := domain.Backward
+	cur := domain.Cursor{}
+	var err error
+	switch {
+	case req.GetBeforeCursor() != "":
+		cur, err = domain.ParseCursor(req.GetBeforeCursor())
+	case req.GetAfterCursor() != "":
+		dir = domain.Forward
+		cur, err = domain.ParseCursor(req.GetAfterCursor())
+	}
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid cursor")
+	}
+
+	page, err := m.messages.ListMessagesPage(ctx, req.GetConversationId(), user.ID, cur, dir, int(req.GetLimit()))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	responses, err := m.messages.ToResponses(ctx, page.Messages)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &zchatv1.ListMessagesResponse{
+		Messages:   make([]*zchatv1.Message, 0, len(responses)),
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+		HasMore:    page.HasMore,
+	}
+	for _, r := range responses {
+		resp.Messages = append(resp.Messages, toProtoMessage(r))
+	}
+	return resp, nil
+}
+
+func (m *messageServer) EditMessage(ctx context.Context, req *zchatv1.EditMessageRequest) (*zchatv1.Message, error) {
+	user := currentUser(ctx)
+	if user == nil {
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	msg, err := m.messages.EditMessage(ctx, user.ID, req.GetMessageId(), req.GetContent())
+	if err != nil {
+		return nil, toProtoErr(err)
+	}
+	resp, err := m.messages.ToResponse(ctx, msg)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return toProtoMessage(resp), nil
+}
+
+func (m *messageServer) DeleteMessage(ctx context.Context, req *zchatv1.DeleteMessageRequest) (*zchatv1.Message, error) {
+	user := currentUser(ctx)
+	if user == nil {
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	msg, err := m.messages.DeleteMessage(ctx, user.ID, req.GetMessageId(), req.GetDeleteType())
+	if err != nil {
+		return nil, toProtoErr(err)
+	}
+	resp, err := m.messages.ToResponse(ctx, msg)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return toProtoMessage(resp), nil
+}
+
+// toProtoErr maps the service.Messages sentinel errors to the gRPC status
+// codes a client would expect instead of a blanket InvalidArgument.
+func toProtoErr(err error) error {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, service.ErrMessageDeleted):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+}
+
+func toProtoMessage(r *service.MessageResponse) *zchatv1.Message {
+	if r == nil {
+		return nil
+	}
+	out := &zchatv1.Message{
+		Id:             r.ID,
+		Content:        r.Content,
+		ConversationId: r.ConversationID,
+		SenderId:       r.SenderID,
+		SenderUsername: r.SenderUsername,
+		CreatedAt:      timestamppb.New(r.CreatedAt),
+		IsDeleted:      r.IsDeleted,
+		IsEdited:       r.IsEdited,
+		IsRead:         r.IsRead,
+		ViewOnce:       r.ViewOnce,
+	}
+	if r.ExpiresAt != nil {
+		out.ExpiresAt = timestamppb.New(*r.ExpiresAt)
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+// Package grpcserver exposes the same operations httpserver.NewRouter wires
+// up over REST/WS — auth, conversations, messages, upload metadata — as a
+// gRPC API for non-browser clients, plus a StreamService.Subscribe RPC that
+// replaces the WS event loop with one server-streaming call. It reuses the
+// service.* layer directly so none of that business logic is duplicated;
+// see internal/grpcserver/zchatv1 (generated by `buf generate` from
+// backend_go/proto, not committed — see its .gitignore) for the request/
+// response types and *Server interfaces implemented here.
+package grpcserver
+
+import (
+	"google.golang.org/grpc"
+
+	"backend_go/internal/grpcserver/zchatv1"
+	"backend_go/internal/security"
+	"backend_go/internal/service"
+	"backend_go/internal/ws"
+
+	"backend_go/internal/domain"
+)
+
+// Server holds every dependency grpcserver needs: the service.* instances
+// its RPC handlers call into, and the pieces UnaryAuthInterceptor/
+// StreamAuthInterceptor need to authenticate a caller the same way
+// httpserver.AuthMiddleware does.
+type Server struct {
+	auth          service.Auth
+	conversations *service.ConversationService
+	messages      service.Messages
+	attachments   *service.AttachmentService
+	hub           *ws.Hub
+
+	tokens *security.TokenService
+	users  domain.UserRepository
+	otps   domain.OTPRepository
+}
+
+// NewServer builds a Server. The caller (httpserver.NewRouter's sibling in
+// cmd/server) is expected to reuse the exact same service instances passed
+// to httpserver.NewRouter, so REST and gRPC clients observe one consistent
+// view of the data.
+func NewServer(
+	auth service.Auth,
+	conversations *service.ConversationService,
+	messages service.Messages,
+	attachments *service.AttachmentService,
+	hub *ws.Hub,
+	tokens *security.TokenService,
+	users domain.UserRepository,
+	otps domain.OTPRepository,
+) *Server {
+	return &Server{
+		auth:          auth,
+		conversations: conversations,
+		messages:      messages,
+		attachments:   attachments,
+		hub:           hub,
+		tokens:        tokens,
+		users:         users,
+		otps:          otps,
+	}
+}
+
+// NewGRPCServer builds a *grpc.Server with s's auth interceptors installed
+// and every service registered on it. cmd/server just needs to call
+// Serve(listener) on the result.
+func (s *Server) NewGRPCServer() *grpc.Server {
+	gs := grpc.NewServer(
+		grpc.UnaryInterceptor(s.UnaryAuthInterceptor),
+		grpc.StreamInterceptor(s.StreamAuthInterceptor),
+	)
+	zchatv1.RegisterAuthServiceServer(gs, &authServer{auth: s.auth})
+	zchatv1.RegisterConversationServiceServer(gs, &conversationServer{conversations: s.conversations})
+	zchatv1.RegisterMessageServiceServer(gs, &messageServer{messages: s.messages})
+	zchatv1.RegisterUploadServiceServer(gs, &uploadServer{attachments: s.attachments})
+	zchatv1.RegisterStreamServiceServer(gs, &streamServer{hub: s.hub})
+	return gs
+}
@@ -0,0 +1,140 @@
+package grpcserver
+
+import (
+	"encoding/json"
+	"log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"backend_go/internal/grpcserver/zchatv1"
+	"backend_go/internal/ws"
+)
+
+type streamServer struct {
+	zchatv1.UnimplementedStreamServiceServer
+	hub *ws.Hub
+}
+
+func (s *streamServer) Subscribe(req *zchatv1.SubscribeRequest, stream zchatv1.StreamService_SubscribeServer) error {
+	user := currentUser(stream.Context())
+	if user == nil {
+		return status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	sink := &grpcSink{stream: stream}
+	s.hub.Register(user.ID, sink)
+	defer s.hub.Unregister(user.ID, sink)
+
+	<-stream.Context().Done()
+	return stream.Context().Err()
+}
+
+// grpcSink adapts a StreamService_SubscribeServer into a ws.Sink, so
+// ws.Hub can deliver to a gRPC Subscribe caller exactly the way it delivers
+// to a WS ws.Client — it only ever calls WriteEvent and, on error, Close.
+type grpcSink struct {
+	stream zchatv1.StreamService_SubscribeServer
+}
+
+// WriteEvent decodes v — always a ws.Envelope wrapping one of the
+// map[string]any payloads ws/handler.go and internal/call broadcast — into
+// the matching Event oneof case and sends it. An envelope whose "type"
+// doesn't map to a known case (e.g. the presence-only "user_online") is
+// dropped rather than failing the whole stream, since the gRPC surface has
+// no equivalent of those yet.
+func (g *grpcSink) WriteEvent(v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var envelope struct {
+		Event map[string]any `json:"event"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return err
+	}
+
+	event, ok := decodeEvent(envelope.Event)
+	if !ok {
+		return nil
+	}
+	return g.stream.Send(event)
+}
+
+func (g *grpcSink) Close() error {
+	return nil
+}
+
+func decodeEvent(payload map[string]any) (*zchatv1.Event, bool) {
+	t, _ := payload["type"].(string)
+	switch t {
+	case "message":
+		return &zchatv1.Event{Payload: &zchatv1.Event_Message{Message: &zchatv1.NewMessageEvent{
+			Message: &zchatv1.Message{
+				Id:             asInt64(payload["message_id"]),
+				Content:        asString(payload["content"]),
+				ConversationId: asInt64(payload["conversation_id"]),
+				SenderId:       asInt64(payload["sender_id"]),
+				SenderUsername: asString(payload["sender_username"]),
+				IsDeleted:      asBool(payload["is_deleted"]),
+				IsRead:         asBool(payload["is_read"]),
+				ViewOnce:       asBool(payload["view_once"]),
+			},
+		}}}, true
+	case "messages_read":
+		return &zchatv1.Event{Payload: &zchatv1.Event_MessagesRead{MessagesRead: &zchatv1.MessagesReadEvent{
+			ConversationId: asInt64(payload["conversation_id"]),
+			UserId:         asInt64(payload["user_id"]),
+		}}}, true
+	case "typing":
+		return &zchatv1.Event{Payload: &zchatv1.Event_Typing{Typing: &zchatv1.TypingEvent{
+			ConversationId: asInt64(payload["conversation_id"]),
+			UserId:         asInt64(payload["user_id"]),
+			Username:       asString(payload["username"]),
+		}}}, true
+	case "message_edited":
+		return &zchatv1.Event{Payload: &zchatv1.Event_MessageEdited{MessageEdited: &zchatv1.MessageEditedEvent{
+			Message: &zchatv1.Message{
+				Id:             asInt64(payload["message_id"]),
+				ConversationId: asInt64(payload["conversation_id"]),
+				Content:        asString(payload["content"]),
+				IsEdited:       asBool(payload["is_edited"]),
+			},
+		}}}, true
+	case "message_deleted":
+		return &zchatv1.Event{Payload: &zchatv1.Event_MessageDeleted{MessageDeleted: &zchatv1.MessageDeletedEvent{
+			MessageId:      asInt64(payload["message_id"]),
+			ConversationId: asInt64(payload["conversation_id"]),
+			DeleteType:     asString(payload["delete_type"]),
+		}}}, true
+	case "call.accept", "call.reject", "call.sdp", "call.ice", "call.hangup", "call.invite":
+		signalJSON, err := json.Marshal(payload["payload"])
+		if err != nil {
+			log.Printf("grpcserver: marshal call signal payload: %v", err)
+			signalJSON = []byte("null")
+		}
+		return &zchatv1.Event{Payload: &zchatv1.Event_CallSignal{CallSignal: &zchatv1.CallSignalEvent{
+			CallId:     asString(payload["call_id"]),
+			FromUserId: asInt64(payload["from_user"]),
+			SignalJson: string(signalJSON),
+		}}}, true
+	default:
+		return nil, false
+	}
+}
+
+func asInt64(v any) int64 {
+	f, _ := v.(float64)
+	return int64(f)
+}
+
+func asString(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+func asBool(v any) bool {
+	b, _ := v.(bool)
+	return b
+}
@@ -0,0 +1,37 @@
+package grpcserver
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"backend_go/internal/grpcserver/zchatv1"
+	"backend_go/internal/service"
+)
+
+type uploadServer struct {
+	zchatv1.UnimplementedUploadServiceServer
+	attachments *service.AttachmentService
+}
+
+func (u *uploadServer) PresignUpload(ctx context.Context, req *zchatv1.PresignUploadRequest) (*zchatv1.PresignUploadResponse, error) {
+	user := currentUser(ctx)
+	if user == nil {
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	result, err := u.attachments.PresignUpload(ctx, user.ID, service.PresignUploadInput{
+		ContentType: req.GetContentType(),
+		Size:        req.GetSize(),
+	})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &zchatv1.PresignUploadResponse{
+		AttachmentId:  result.AttachmentID,
+		Key:           result.Key,
+		UploadUrl:     result.Upload.URL,
+		UploadMethod:  result.Upload.Method,
+		UploadHeaders: result.Upload.Headers,
+	}, nil
+}
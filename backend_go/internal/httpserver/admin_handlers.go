@@ -0,0 +1,174 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"backend_go/internal/domain"
+	"backend_go/internal/service"
+)
+
+type adminSetRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type adminPruneRequest struct {
+	KeepLastN int `json:"keep_last_n"`
+}
+
+// validGlobalRoles are the only roles handleAdminSetRole accepts; rejecting
+// anything else keeps a typo'd role from silently becoming "the lowest
+// rank" per authz.Atleast's unrecognized-role handling.
+var validGlobalRoles = map[string]domain.GlobalRole{
+	string(domain.GlobalRoleUser):      domain.GlobalRoleUser,
+	string(domain.GlobalRoleModerator): domain.GlobalRoleModerator,
+	string(domain.GlobalRoleAdmin):     domain.GlobalRoleAdmin,
+}
+
+// @Summary      List active users
+// @Description  Paginated list of active users, for the admin console
+// @Tags         admin
+// @Security     BearerAuth
+// @Produce      json
+// @Param        offset query int false "Offset"
+// @Param        limit  query int false "Limit (default 50)"
+// @Success      200  {array}  domain.User
+// @Router       /admin/users [get]
+func handleAdminListUsers(adminSvc *service.AdminService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 {
+			limit = 50
+		}
+
+		users, err := adminSvc.ListUsers(r.Context(), offset, limit)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, users)
+	}
+}
+
+// @Summary      Deactivate a user
+// @Description  Soft-deletes a user account
+// @Tags         admin
+// @Security     BearerAuth
+// @Param        id path int true "User ID"
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/users/{id}/deactivate [post]
+func handleAdminDeactivateUser(adminSvc *service.AdminService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+			return
+		}
+		if err := adminSvc.Deactivate(r.Context(), id); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// @Summary      Grant a user a site-wide role
+// @Description  Sets a user's GlobalRole to "user", "moderator", or "admin"
+// @Tags         admin
+// @Security     BearerAuth
+// @Accept       json
+// @Param        id    path int                   true "User ID"
+// @Param        input body adminSetRoleRequest true "Role to grant"
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/users/{id}/roles [post]
+func handleAdminSetRole(adminSvc *service.AdminService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+			return
+		}
+		var req adminSetRoleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		role, ok := validGlobalRoles[req.Role]
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unrecognized role"})
+			return
+		}
+		if err := adminSvc.SetRole(r.Context(), id, role); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// @Summary      Revoke a user's site-wide role
+// @Description  Reverts a user to the default "user" role, if they currently hold the named one
+// @Tags         admin
+// @Security     BearerAuth
+// @Param        id   path int    true "User ID"
+// @Param        role path string true "Role to revoke"
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/users/{id}/roles/{role} [delete]
+func handleAdminRemoveRole(adminSvc *service.AdminService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+			return
+		}
+		role, ok := validGlobalRoles[chi.URLParam(r, "role")]
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unrecognized role"})
+			return
+		}
+		if err := adminSvc.RemoveRole(r.Context(), id, role); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// @Summary      Manually prune old messages across every conversation
+// @Description  Applies the count-based retention cap (default: MAX_MESSAGES_PER_CONVERSATION) to every conversation immediately, instead of waiting for the retention worker's next sweep
+// @Tags         admin
+// @Security     BearerAuth
+// @Accept       json
+// @Param        input body adminPruneRequest false "Optional override for how many recent messages to keep per conversation"
+// @Success      200  {object}  service.PruneResult
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/messages/prune [post]
+func handleAdminPruneMessages(adminSvc *service.AdminService, defaultKeepLastN int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req adminPruneRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+				return
+			}
+		}
+		keepLastN := req.KeepLastN
+		if keepLastN <= 0 {
+			keepLastN = defaultKeepLastN
+		}
+
+		result, err := adminSvc.PruneAll(r.Context(), keepLastN)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
@@ -0,0 +1,314 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"backend_go/internal/domain"
+	"backend_go/internal/scan"
+	"backend_go/internal/service"
+	"backend_go/internal/storage"
+)
+
+// createAtPath creates dst (and any missing parent directories) for writing.
+func createAtPath(dst string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return nil, err
+	}
+	return os.Create(dst)
+}
+
+type presignAttachmentRequest struct {
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+// @Summary      Presign an attachment upload
+// @Description  Returns a direct-upload URL and the attachment id to reference from a message
+// @Tags         attachments
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        input body presignAttachmentRequest true "Presign input"
+// @Success      200  {object}  service.PresignUploadResult
+// @Failure      400  {object}  map[string]string
+// @Router       /attachments/presign [post]
+func handlePresignAttachment(attSvc *service.AttachmentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		var req presignAttachmentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		result, err := attSvc.PresignUpload(r.Context(), currentUser.ID, service.PresignUploadInput{
+			ContentType: req.ContentType,
+			Size:        req.Size,
+		})
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// @Summary      Upload an attachment
+// @Description  Streams, encrypts, and content-addresses the request body; the returned id is used as messageCreateRequest.attachment_id
+// @Tags         attachments
+// @Security     BearerAuth
+// @Accept       application/octet-stream
+// @Produce      json
+// @Success      200  {object}  domain.Attachment
+// @Failure      400  {object}  map[string]string
+// @Router       /attachments [post]
+func handleUploadAttachment(attSvc *service.AttachmentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		contentType := r.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		att, err := attSvc.Upload(r.Context(), currentUser.ID, contentType, r.Body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, att)
+	}
+}
+
+// @Summary      Download an attachment
+// @Description  Streams and decrypts the attachment, honoring a single-range Range header; falls back to a presigned redirect for attachments uploaded before encryption existed
+// @Tags         attachments
+// @Security     BearerAuth
+// @Param        id path int true "Attachment ID"
+// @Success      200
+// @Success      206
+// @Success      302
+// @Failure      404  {object}  map[string]string
+// @Router       /attachments/{id} [get]
+func handleGetAttachment(attSvc *service.AttachmentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if CurrentUser(r) == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid attachment id"})
+			return
+		}
+
+		start, end, err := parseRangeHeader(r.Header.Get("Range"))
+		if err != nil {
+			writeJSON(w, http.StatusRequestedRangeNotSatisfiable, map[string]string{"error": err.Error()})
+			return
+		}
+
+		att, body, err := attSvc.OpenDecrypted(r.Context(), id, start, end)
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "attachment not found"})
+			return
+		case errors.Is(err, service.ErrAttachmentNotEncrypted):
+			url, err := attSvc.DownloadURL(r.Context(), id)
+			if err != nil {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+				return
+			}
+			http.Redirect(w, r, url, http.StatusFound)
+			return
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		defer body.Close()
+
+		w.Header().Set("Content-Type", att.ContentType)
+		w.Header().Set("Accept-Ranges", "bytes")
+		if end >= 0 || start > 0 {
+			rangeEnd := end
+			if rangeEnd < 0 {
+				rangeEnd = att.Size - 1
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, rangeEnd, att.Size))
+			w.Header().Set("Content-Length", strconv.FormatInt(rangeEnd-start+1, 10))
+			w.WriteHeader(http.StatusPartialContent)
+		} else {
+			w.Header().Set("Content-Length", strconv.FormatInt(att.Size, 10))
+		}
+		io.Copy(w, body)
+	}
+}
+
+// parseRangeHeader parses a single-range "bytes=start-end" Range header,
+// returning start=0, end=-1 ("through EOF") when h is empty. Multi-range
+// and suffix ("bytes=-500") requests are rejected rather than supported,
+// since attachment downloads essentially never need them.
+func parseRangeHeader(h string) (start, end int64, err error) {
+	if h == "" {
+		return 0, -1, nil
+	}
+	h = strings.TrimPrefix(h, "bytes=")
+	if strings.Contains(h, ",") {
+		return 0, 0, fmt.Errorf("multi-range requests are not supported")
+	}
+	parts := strings.SplitN(h, "-", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return 0, 0, fmt.Errorf("invalid or unsupported range header")
+	}
+	start, err = strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, fmt.Errorf("invalid range start")
+	}
+	if parts[1] == "" {
+		return start, -1, nil
+	}
+	end, err = strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || end < start {
+		return 0, 0, fmt.Errorf("invalid range end")
+	}
+	return start, end, nil
+}
+
+// LocalAttachmentUploadRoute returns a handler for the local-disk backend's
+// signed direct-upload endpoint (mounted at /api/attachments/local/{key}).
+// It is only wired when cfg.StorageBackend is "local", since other backends
+// accept the PUT directly against their own presigned URL. Because this is
+// the only path where uploaded bytes actually pass through this process, it
+// is also where content-based validation and malware scanning happen: the
+// PUT body is buffered to a ".part" file, sniffed and scanned, and only
+// renamed into place (making it visible to the GET case below) once clean.
+func LocalAttachmentUploadRoute(backend *storage.LocalBackend, scanner scan.Scanner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		encodedKey := chi.URLParam(r, "key")
+		key, err := storage.DecodeKey(encodedKey)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid key"})
+			return
+		}
+		exp, err := storage.ParseExpiry(r.URL.Query().Get("exp"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid expiry"})
+			return
+		}
+		sig := r.URL.Query().Get("sig")
+		if !backend.VerifySignature(key, r.Method, sig, exp) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "invalid or expired signature"})
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPut:
+			finalPath := backend.Path(key)
+			tmpPath := finalPath + ".part"
+			dst, err := createAtPath(tmpPath)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "could not create file"})
+				return
+			}
+			if _, err := io.Copy(dst, r.Body); err != nil {
+				dst.Close()
+				os.Remove(tmpPath)
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "could not save file"})
+				return
+			}
+			dst.Close()
+
+			if err := validateUploadContent(key, tmpPath); err != nil {
+				os.Remove(tmpPath)
+				writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+				return
+			}
+			if err := scanUpload(r.Context(), scanner, tmpPath); err != nil {
+				os.Remove(tmpPath)
+				writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+				return
+			}
+
+			if err := os.Rename(tmpPath, finalPath); err != nil {
+				os.Remove(tmpPath)
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "could not finalize file"})
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case http.MethodGet:
+			http.ServeFile(w, r, backend.Path(key))
+		default:
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	}
+}
+
+// validateUploadContent sniffs the first 512 bytes of the uploaded file and
+// rejects it if the real content type conflicts with the one implied by the
+// key's extension. Sniffed results of "application/octet-stream" are
+// treated as inconclusive (most documents and archives sniff that way) and
+// never trigger a rejection on their own.
+func validateUploadContent(key, path string) error {
+	expected := mime.TypeByExtension(strings.ToLower(filepath.Ext(key)))
+	if expected == "" {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open upload for inspection: %w", err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return fmt.Errorf("read upload for inspection: %w", err)
+	}
+
+	sniffed := http.DetectContentType(buf[:n])
+	if sniffed == "application/octet-stream" {
+		return nil
+	}
+	if primaryMimeType(sniffed) != primaryMimeType(expected) {
+		return fmt.Errorf("file content (%s) does not match its extension", sniffed)
+	}
+	return nil
+}
+
+// scanUpload streams the buffered upload through scanner before it is
+// allowed to become visible via the GET case.
+func scanUpload(ctx context.Context, scanner scan.Scanner, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open upload for scan: %w", err)
+	}
+	defer f.Close()
+
+	result, err := scanner.Scan(ctx, f)
+	if err != nil {
+		return fmt.Errorf("scan upload: %w", err)
+	}
+	if !result.Clean {
+		return fmt.Errorf("upload quarantined: %s", result.Signature)
+	}
+	return nil
+}
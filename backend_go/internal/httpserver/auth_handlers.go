@@ -1,148 +1,307 @@
-package httpserver
-
-import (
-	"encoding/json"
-	"net/http"
-
-	"backend_go/internal/service"
-)
-
-type registerRequest struct {
-	Username string  `json:"username"`
-	Email    *string `json:"email"`
-	Password string  `json:"password"`
-}
-
-type loginRequest struct {
-	Username   string `json:"username"`
-	Password   string `json:"password"`
-	RememberMe bool   `json:"remember_me"`
-}
-
-// tokenResponse mirrors the Python Token schema: access_token, token_type, user.
-type tokenResponse struct {
-	AccessToken string      `json:"access_token"`
-	TokenType   string      `json:"token_type"`
-	User        interface{} `json:"user"`
-}
-
-// @Summary      Register a new user
-// @Description  Register a new user and return an access token
-// @Tags         auth
-// @Accept       json
-// @Produce      json
-// @Param        input body registerRequest true "Register input"
-// @Success      201  {object}  tokenResponse
-// @Failure      400  {object}  map[string]string
-// @Router       /auth/register [post]
-func handleRegister(authSvc *service.AuthService, userSvc *service.UserService) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		var req registerRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
-			return
-		}
-
-		user, err := authSvc.Register(r.Context(), service.RegisterInput{
-			Username: req.Username,
-			Email:    req.Email,
-			Password: req.Password,
-		})
-		if err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
-			return
-		}
-
-		// Auto-login after registration
-		resp, err := authSvc.Login(r.Context(), service.LoginInput{
-			Username: req.Username,
-			Password: req.Password,
-		})
-		if err != nil {
-			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to login after registration"})
-			return
-		}
-		// Ensure user in response is the created one
-		writeJSON(w, http.StatusCreated, tokenResponse{
-			AccessToken: resp.AccessToken,
-			TokenType:   "bearer",
-			User:        user,
-		})
-	}
-}
-
-// @Summary      Login
-// @Description  Login with username and password
-// @Tags         auth
-// @Accept       json
-// @Produce      json
-// @Param        input body loginRequest true "Login input"
-// @Success      200  {object}  tokenResponse
-// @Failure      400  {object}  map[string]string
-// @Failure      401  {object}  map[string]string
-// @Router       /auth/login [post]
-func handleLogin(authSvc *service.AuthService) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		var req loginRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
-			return
-		}
-
-		resp, err := authSvc.Login(r.Context(), service.LoginInput{
-			Username:   req.Username,
-			Password:   req.Password,
-			RememberMe: req.RememberMe,
-		})
-		if err != nil {
-			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
-			return
-		}
-		writeJSON(w, http.StatusOK, tokenResponse{
-			AccessToken: resp.AccessToken,
-			TokenType:   "bearer",
-			User:        resp.User,
-		})
-	}
-}
-
-// @Summary      Logout
-// @Description  Logout user
-// @Tags         auth
-// @Security     BearerAuth
-// @Success      204
-// @Failure      401  {object}  map[string]string
-// @Router       /auth/logout [post]
-func handleLogout(authSvc *service.AuthService) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		user := CurrentUser(r)
-		if user == nil {
-			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
-			return
-		}
-		if err := authSvc.Logout(r.Context(), user.ID); err != nil {
-			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
-			return
-		}
-		w.WriteHeader(http.StatusNoContent)
-	}
-}
-
-// @Summary      Get Current User
-// @Description  Get currently logged in user details
-// @Tags         auth
-// @Security     BearerAuth
-// @Produce      json
-// @Success      200  {object}  domain.User
-// @Failure      401  {object}  map[string]string
-// @Router       /auth/me [get]
-func handleMe() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		user := CurrentUser(r)
-		if user == nil {
-			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
-			return
-		}
-		writeJSON(w, http.StatusOK, user)
-	}
-}
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"backend_go/internal/service"
+)
+
+type registerRequest struct {
+	Username string  `json:"username"`
+	Email    *string `json:"email"`
+	Password string  `json:"password"`
+}
+
+type loginRequest struct {
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	RememberMe bool   `json:"remember_me"`
+}
+
+// tokenResponse mirrors the Python Token schema: access_token, token_type, user.
+// When the account has confirmed TOTP enrollment, login instead returns
+// pre_auth_token and requires_otp; the client must complete /auth/otp/verify
+// to obtain an access_token. RequiresVerification is set instead of any
+// token when registration created the account inactive pending
+// AuthService.VerifyEmail. RefreshToken and ExpiresIn are present whenever
+// AccessToken is: the client exchanges RefreshToken at POST /auth/refresh
+// once AccessToken, valid for ExpiresIn seconds, expires.
+type tokenResponse struct {
+	AccessToken          string      `json:"access_token,omitempty"`
+	RefreshToken         string      `json:"refresh_token,omitempty"`
+	ExpiresIn            int64       `json:"expires_in,omitempty"`
+	PreAuthToken         string      `json:"pre_auth_token,omitempty"`
+	RequiresOTP          bool        `json:"requires_otp,omitempty"`
+	RequiresVerification bool        `json:"requires_verification,omitempty"`
+	TokenType            string      `json:"token_type,omitempty"`
+	User                 interface{} `json:"user"`
+}
+
+// @Summary      Register a new user
+// @Description  Register a new user and return an access token; an account created inactive pending email verification gets requires_verification and no token
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        input body registerRequest true "Register input"
+// @Success      201  {object}  tokenResponse
+// @Failure      400  {object}  map[string]string
+// @Router       /auth/register [post]
+func handleRegister(authSvc service.Auth, userSvc *service.UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req registerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+
+		user, err := authSvc.Register(r.Context(), service.RegisterInput{
+			Username: req.Username,
+			Email:    req.Email,
+			Password: req.Password,
+		})
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		if !user.IsActive {
+			writeJSON(w, http.StatusCreated, tokenResponse{
+				RequiresVerification: true,
+				User:                 user,
+			})
+			return
+		}
+
+		// Auto-login after registration
+		resp, err := authSvc.Login(r.Context(), service.LoginInput{
+			Username:  req.Username,
+			Password:  req.Password,
+			UserAgent: r.UserAgent(),
+			IP:        r.RemoteAddr,
+		})
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to login after registration"})
+			return
+		}
+		// Ensure user in response is the created one
+		writeJSON(w, http.StatusCreated, tokenResponse{
+			AccessToken:  resp.AccessToken,
+			RefreshToken: resp.RefreshToken,
+			ExpiresIn:    resp.ExpiresIn,
+			TokenType:    "bearer",
+			User:         user,
+		})
+	}
+}
+
+// @Summary      Login
+// @Description  Login with username and password
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        input body loginRequest true "Login input"
+// @Success      200  {object}  tokenResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Router       /auth/login [post]
+func handleLogin(authSvc service.Auth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req loginRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+
+		resp, err := authSvc.Login(r.Context(), service.LoginInput{
+			Username:   req.Username,
+			Password:   req.Password,
+			RememberMe: req.RememberMe,
+			UserAgent:  r.UserAgent(),
+			IP:         r.RemoteAddr,
+		})
+		if err != nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, tokenResponse{
+			AccessToken:  resp.AccessToken,
+			RefreshToken: resp.RefreshToken,
+			ExpiresIn:    resp.ExpiresIn,
+			PreAuthToken: resp.PreAuthToken,
+			RequiresOTP:  resp.RequiresOTP,
+			TokenType:    "bearer",
+			User:         resp.User,
+		})
+	}
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// @Summary      Refresh an access token
+// @Description  Exchanges a refresh token for a new access/refresh token pair, revoking the one presented. Presenting an already-revoked token is treated as a sign of token theft and revokes every other token belonging to its owner.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        input body refreshRequest true "Refresh token"
+// @Success      200  {object}  tokenResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Router       /auth/refresh [post]
+func handleRefresh(authSvc service.Auth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req refreshRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		if req.RefreshToken == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "refresh_token is required"})
+			return
+		}
+
+		resp, err := authSvc.Refresh(r.Context(), req.RefreshToken, r.UserAgent(), r.RemoteAddr)
+		if err != nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, tokenResponse{
+			AccessToken:  resp.AccessToken,
+			RefreshToken: resp.RefreshToken,
+			ExpiresIn:    resp.ExpiresIn,
+			TokenType:    "bearer",
+			User:         resp.User,
+		})
+	}
+}
+
+type logoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// @Summary      Logout
+// @Description  Logout user. An optional refresh_token in the body is revoked along with ending the session.
+// @Tags         auth
+// @Security     BearerAuth
+// @Accept       json
+// @Param        input body logoutRequest false "Refresh token to revoke"
+// @Success      204
+// @Failure      401  {object}  map[string]string
+// @Router       /auth/logout [post]
+func handleLogout(authSvc service.Auth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := CurrentUser(r)
+		if user == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		var req logoutRequest
+		if r.ContentLength != 0 {
+			_ = json.NewDecoder(r.Body).Decode(&req)
+		}
+		if err := authSvc.Logout(r.Context(), user.ID, req.RefreshToken); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// @Summary      Get Current User
+// @Description  Get currently logged in user details
+// @Tags         auth
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {object}  domain.User
+// @Failure      401  {object}  map[string]string
+// @Router       /auth/me [get]
+func handleMe() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := CurrentUser(r)
+		if user == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		writeJSON(w, http.StatusOK, user)
+	}
+}
+
+// @Summary      Verify email
+// @Description  Activate an account created with a pending email verification
+// @Tags         auth
+// @Produce      json
+// @Param        token query string true "Verification token"
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Router       /auth/verify [get]
+func handleVerifyEmail(authSvc service.Auth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "token is required"})
+			return
+		}
+		if err := authSvc.VerifyEmail(r.Context(), token); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "verified"})
+	}
+}
+
+type passwordResetRequestRequest struct {
+	Email string `json:"email"`
+}
+
+// @Summary      Request a password reset
+// @Description  Queues a password-reset email if the address is registered; always succeeds
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        input body passwordResetRequestRequest true "Email to send the reset token to"
+// @Success      200  {object}  map[string]string
+// @Router       /auth/reset/request [post]
+func handleRequestPasswordReset(authSvc service.Auth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req passwordResetRequestRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		// Always reports success, whether or not req.Email is registered, so
+		// the response can't be used to enumerate accounts.
+		_ = authSvc.RequestPasswordReset(r.Context(), req.Email)
+		writeJSON(w, http.StatusOK, map[string]string{"status": "if that email is registered, a reset code has been sent"})
+	}
+}
+
+type passwordResetConfirmRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// @Summary      Confirm a password reset
+// @Description  Redeems a password-reset token and sets a new password
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        input body passwordResetConfirmRequest true "Reset token and new password"
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Router       /auth/reset/confirm [post]
+func handleResetPassword(authSvc service.Auth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req passwordResetConfirmRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		if err := authSvc.ResetPassword(r.Context(), req.Token, req.NewPassword); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "password reset"})
+	}
+}
@@ -2,10 +2,12 @@ package httpserver
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
 
+	"backend_go/internal/authz"
 	"backend_go/internal/domain"
 	"backend_go/internal/security"
 )
@@ -29,10 +31,25 @@ func CurrentUser(r *http.Request) *domain.User {
 	return nil
 }
 
-// AuthMiddleware validates the Bearer token and attaches the user to the context.
-func AuthMiddleware(tokens *security.TokenService, users domain.UserRepository) func(http.Handler) http.Handler {
+// AuthMiddleware validates the caller's credentials and attaches the user to
+// the context. Two credential forms are accepted: a Bearer token (the usual
+// human login path, checked against amr/2FA below), or, if the request
+// arrived over mTLS with a verified client certificate, the certificate's
+// embedded service identity — this is the only path available to
+// IsServiceAccount users such as bots and scripted integrations, which have
+// no password to bear a token for.
+//
+// Users who have confirmed TOTP enrollment must present a token whose amr
+// claim includes "otp"; pre-auth tokens (password verified, 2FA still owed)
+// are always rejected here regardless of amr.
+func AuthMiddleware(tokens *security.TokenService, users domain.UserRepository, otps domain.OTPRepository, certRevocations domain.CertRevocationRepository) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+				serveServiceAccount(w, r, next, users, certRevocations)
+				return
+			}
+
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" || !strings.HasPrefix(strings.ToLower(authHeader), "bearer ") {
 				http.Error(w, "missing or invalid Authorization header", http.StatusUnauthorized)
@@ -40,37 +57,75 @@ func AuthMiddleware(tokens *security.TokenService, users domain.UserRepository)
 			}
 			tokenStr := strings.TrimSpace(authHeader[len("Bearer "):])
 
-			claims, err := tokens.Parse(tokenStr)
+			user, err := security.AuthenticateBearer(r.Context(), tokens, users, otps, tokenStr)
 			if err != nil {
-				http.Error(w, "invalid token", http.StatusUnauthorized)
+				if errors.Is(err, security.ErrUnauthenticated) {
+					http.Error(w, "invalid token", http.StatusUnauthorized)
+					return
+				}
+				log.Printf("AuthMiddleware: authenticate bearer token: %v", err)
+				http.Error(w, "internal server error", http.StatusInternalServerError)
 				return
 			}
 
-			sub, _ := claims["sub"].(string)
-			if sub == "" {
-				http.Error(w, "invalid token subject", http.StatusUnauthorized)
-				return
-			}
+			ctx := WithUser(r.Context(), user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
 
-			user, err := users.GetByUsername(r.Context(), sub)
-			if err != nil {
-				log.Printf("AuthMiddleware: GetByUsername error for sub '%s': %v", sub, err)
-				http.Error(w, "user not found", http.StatusUnauthorized)
-				return
-			}
-			if user == nil {
-				log.Printf("AuthMiddleware: user nil for sub '%s'", sub)
-				http.Error(w, "user not found", http.StatusUnauthorized)
-				return
-			}
-			if !user.IsActive {
-				log.Printf("AuthMiddleware: user inactive for sub '%s'", sub)
-				http.Error(w, "user not found", http.StatusUnauthorized)
+// serveServiceAccount authenticates a request that presented a verified
+// client certificate: it maps the certificate's embedded identity to a
+// domain.User with IsServiceAccount set, after checking the certificate
+// hasn't been revoked, then hands off to next exactly like the Bearer-token
+// path so downstream handlers don't need to know which path was taken.
+func serveServiceAccount(w http.ResponseWriter, r *http.Request, next http.Handler, users domain.UserRepository, certRevocations domain.CertRevocationRepository) {
+	identity := security.IdentityFromCert(r.TLS.PeerCertificates[0])
+	if identity.Username == "" {
+		http.Error(w, "client certificate missing identity", http.StatusUnauthorized)
+		return
+	}
+
+	revoked, err := certRevocations.IsRevoked(r.Context(), identity.SerialHex)
+	if err != nil {
+		log.Printf("AuthMiddleware: revocation lookup error for serial '%s': %v", identity.SerialHex, err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if revoked {
+		http.Error(w, "certificate has been revoked", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := users.GetByUsername(r.Context(), identity.Username)
+	if err != nil {
+		log.Printf("AuthMiddleware: GetByUsername error for service account '%s': %v", identity.Username, err)
+		http.Error(w, "user not found", http.StatusUnauthorized)
+		return
+	}
+	if user == nil || !user.IsActive || !user.IsServiceAccount {
+		http.Error(w, "user not found", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := WithUser(r.Context(), user)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// RequireRole returns middleware that rejects any request whose current user
+// doesn't hold at least min, per internal/authz. AuthMiddleware re-fetches
+// the user from the database on every request rather than trusting the
+// token, so a role change (or downgrade) takes effect on the very next
+// request, not just the next login.
+func RequireRole(min domain.GlobalRole) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := CurrentUser(r)
+			if user == nil || !authz.Atleast(user.Role, min) {
+				http.Error(w, "forbidden", http.StatusForbidden)
 				return
 			}
-
-			ctx := WithUser(r.Context(), user)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			next.ServeHTTP(w, r)
 		})
 	}
 }
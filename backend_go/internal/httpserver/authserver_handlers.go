@@ -0,0 +1,101 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"backend_go/internal/authserver"
+)
+
+// handleOAuthAuthorize handles GET /oauth/authorize. It runs behind
+// AuthMiddleware, so the caller has already completed zchat's own login
+// (including 2FA); this endpoint only asks them to approve the grant.
+func handleOAuthAuthorize(authSvc *authserver.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+
+		q := r.URL.Query()
+		req := authserver.AuthorizeRequest{
+			ClientID:            q.Get("client_id"),
+			RedirectURI:         q.Get("redirect_uri"),
+			ResponseType:        q.Get("response_type"),
+			Scope:               q.Get("scope"),
+			State:               q.Get("state"),
+			CodeChallenge:       q.Get("code_challenge"),
+			CodeChallengeMethod: q.Get("code_challenge_method"),
+		}
+
+		redirect, err := authSvc.Authorize(r.Context(), req, currentUser.ID)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		http.Redirect(w, r, redirect, http.StatusFound)
+	}
+}
+
+// handleOAuthToken handles POST /oauth/token for the authorization_code
+// grant, the only grant type this authorization server supports.
+func handleOAuthToken(authSvc *authserver.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid form body"})
+			return
+		}
+		if r.PostForm.Get("grant_type") != "authorization_code" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+			return
+		}
+
+		clientID := r.PostForm.Get("client_id")
+		clientSecret := r.PostForm.Get("client_secret")
+		result, err := authSvc.Exchange(r.Context(), clientID, clientSecret,
+			r.PostForm.Get("code"), r.PostForm.Get("redirect_uri"), r.PostForm.Get("code_verifier"))
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, authserver.ErrInvalidClientAuth) {
+				status = http.StatusUnauthorized
+			}
+			writeJSON(w, status, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// handleOAuthUserInfo handles GET /oauth/userinfo. The Bearer token here is
+// one this authorization server minted for a third-party client, not a
+// zchat login token, so it is validated directly rather than through
+// AuthMiddleware.
+func handleOAuthUserInfo(authSvc *authserver.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" || !strings.HasPrefix(strings.ToLower(authHeader), "bearer ") {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid Authorization header"})
+			return
+		}
+		accessToken := strings.TrimSpace(authHeader[len("Bearer "):])
+
+		info, err := authSvc.UserInfo(r.Context(), accessToken)
+		if err != nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, info)
+	}
+}
+
+// handleOIDCDiscovery handles GET /.well-known/openid-configuration.
+func handleOIDCDiscovery(authSvc *authserver.Server, signingAlg string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		doc := authSvc.Discovery(signingAlg)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(doc)
+	}
+}
@@ -1,19 +1,30 @@
 package httpserver
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/playwright-community/playwright-go"
 )
 
-const maxProxiedHTMLBytes = 2 * 1024 * 1024
+const (
+	maxProxiedHTMLBytes = 2 * 1024 * 1024
+	proxyDialTimeout    = 10 * time.Second
+	proxyRequestTimeout = 15 * time.Second
+	maxProxyRedirects   = 10
+)
 
+// isBlockedIP reports whether ip falls in a private, loopback, or otherwise
+// non-routable range that /proxy must never be allowed to reach.
 func isBlockedIP(ip net.IP) bool {
 	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
 		return true
@@ -26,6 +37,11 @@ func isBlockedIP(ip net.IP) bool {
 	return false
 }
 
+// validateProxyURL performs the cheap, syntactic checks on a would-be target:
+// scheme and obviously-disallowed hostnames. It intentionally does NOT
+// resolve DNS — that happens exactly once, inside the guarded dialer, so a
+// hostname can't resolve to an allowed IP here and a private one by the time
+// the real connection is made (DNS rebinding).
 func validateProxyURL(targetURL string) error {
 	parsedURL, err := url.Parse(targetURL)
 	if err != nil {
@@ -38,20 +54,61 @@ func validateProxyURL(targetURL string) error {
 	if hostname == "" || hostname == "localhost" || strings.HasSuffix(hostname, ".localhost") || strings.HasSuffix(hostname, ".local") {
 		return fmt.Errorf("target host is not allowed")
 	}
+	return nil
+}
 
-	ips, err := net.LookupIP(hostname)
+// guardedDialContext resolves addr's host exactly once, rejects it if any
+// candidate IP is blocked, and dials the literal IP. The transport still
+// performs its TLS handshake (and sets the Host header) using the original
+// hostname from addr, so the connection cannot be redirected into a private
+// range by a second DNS answer mid-handshake.
+func guardedDialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
 	if err != nil {
-		return fmt.Errorf("failed to resolve host")
+		return nil, fmt.Errorf("invalid address: %w", err)
 	}
-	if len(ips) == 0 {
-		return fmt.Errorf("host has no addresses")
+	host = strings.ToLower(host)
+	if host == "" || host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".local") {
+		return nil, fmt.Errorf("target host is not allowed")
 	}
+
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve host")
+	}
+
+	var dialIP net.IP
 	for _, ip := range ips {
 		if isBlockedIP(ip) {
-			return fmt.Errorf("target host is not allowed")
+			return nil, fmt.Errorf("target host is not allowed")
+		}
+		if dialIP == nil {
+			dialIP = ip
 		}
 	}
-	return nil
+	if dialIP == nil {
+		return nil, fmt.Errorf("host has no addresses")
+	}
+
+	dialer := &net.Dialer{Timeout: proxyDialTimeout}
+	return dialer.DialContext(ctx, network, net.JoinHostPort(dialIP.String(), port))
+}
+
+// newProxyHTTPClient returns a client whose every dial — including ones made
+// while following redirects — goes through guardedDialContext.
+func newProxyHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: guardedDialContext,
+		},
+		Timeout: proxyRequestTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxProxyRedirects {
+				return fmt.Errorf("too many redirects")
+			}
+			return validateProxyURL(req.URL.String())
+		},
+	}
 }
 
 func RegisterBrowserRoutes(r chi.Router) {
@@ -64,13 +121,120 @@ func handleBrowserProxy(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "missing url parameter", http.StatusBadRequest)
 		return
 	}
-
 	if err := validateProxyURL(targetURL); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Initialize Playwright
+	if r.URL.Query().Get("render") == "js" {
+		renderWithBrowser(w, targetURL)
+		return
+	}
+	renderWithHTTPClient(w, r, targetURL)
+}
+
+// renderWithHTTPClient is the default mode: a plain Go HTTP request through
+// the guarded dialer, with no JavaScript execution.
+func renderWithHTTPClient(w http.ResponseWriter, r *http.Request, targetURL string) {
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, targetURL, nil)
+	if err != nil {
+		http.Error(w, "invalid url", http.StatusBadRequest)
+		return
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+
+	resp, err := newProxyHTTPClient().Do(req)
+	if err != nil {
+		log.Printf("proxy fetch failed for %s: %v", targetURL, err)
+		http.Error(w, "failed to load page", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProxiedHTMLBytes+1))
+	if err != nil {
+		http.Error(w, "failed to read content", http.StatusInternalServerError)
+		return
+	}
+	if len(body) > maxProxiedHTMLBytes {
+		http.Error(w, "response too large", http.StatusBadGateway)
+		return
+	}
+
+	// The final URL (after any redirects) is what relative links must
+	// resolve against — never the user-supplied one.
+	finalURL := targetURL
+	if resp.Request != nil && resp.Request.URL != nil {
+		finalURL = resp.Request.URL.String()
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(injectBaseTag(string(body), finalURL)))
+}
+
+// fulfillThroughGuardedClient intercepts every request Playwright's page
+// makes — including the initial navigation — and serves it from a response
+// fetched through newProxyHTTPClient() instead of letting Chromium make its
+// own connection. Chromium does its own independent DNS resolution, so a
+// check-then-route.Continue() guard (resolve, confirm the IP is allowed,
+// then let the browser connect) is still vulnerable to DNS rebinding: a
+// second lookup made by Chromium a moment later can return a different,
+// private IP. Routing the fetch itself through the guarded dialer — which
+// resolves once and dials the literal IP it resolved — closes that gap the
+// same way it's closed for the non-JS render path.
+func fulfillThroughGuardedClient(route playwright.Route) {
+	req := route.Request()
+	if err := validateProxyURL(req.URL()); err != nil {
+		_ = route.Abort()
+		return
+	}
+
+	var body io.Reader
+	if data, err := req.PostDataBuffer(); err == nil && len(data) > 0 {
+		body = bytes.NewReader(data)
+	}
+	httpReq, err := http.NewRequest(req.Method(), req.URL(), body)
+	if err != nil {
+		_ = route.Abort()
+		return
+	}
+	for k, v := range req.Headers() {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := newProxyHTTPClient().Do(httpReq)
+	if err != nil {
+		log.Printf("guarded fetch failed for %s: %v", req.URL(), err)
+		_ = route.Abort()
+		return
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxProxiedHTMLBytes+1))
+	if err != nil || len(respBody) > maxProxiedHTMLBytes {
+		_ = route.Abort()
+		return
+	}
+
+	headers := make(map[string]string, len(resp.Header))
+	for k := range resp.Header {
+		headers[k] = resp.Header.Get(k)
+	}
+	status := resp.StatusCode
+	if err := route.Fulfill(playwright.RouteFulfillOptions{
+		Status:    &status,
+		Headers:   headers,
+		BodyBytes: respBody,
+	}); err != nil {
+		log.Printf("could not fulfill route for %s: %v", req.URL(), err)
+	}
+}
+
+// renderWithBrowser is the opt-in render=js mode. Every subresource request
+// the page makes — including the initial navigation — is fetched through
+// the guarded HTTP client and fulfilled from that response, so Chromium
+// never makes its own connection to the target.
+func renderWithBrowser(w http.ResponseWriter, targetURL string) {
 	pw, err := playwright.Run()
 	if err != nil {
 		log.Printf("could not start playwright: %v", err)
@@ -89,7 +253,7 @@ func handleBrowserProxy(w http.ResponseWriter, r *http.Request) {
 	}
 	defer browser.Close()
 
-	context, err := browser.NewContext(playwright.BrowserNewContextOptions{
+	browserCtx, err := browser.NewContext(playwright.BrowserNewContextOptions{
 		UserAgent: playwright.String("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"),
 	})
 	if err != nil {
@@ -97,15 +261,21 @@ func handleBrowserProxy(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "browser context failed", http.StatusInternalServerError)
 		return
 	}
+	defer browserCtx.Close()
 
-	page, err := context.NewPage()
+	if err := browserCtx.Route("**/*", fulfillThroughGuardedClient); err != nil {
+		log.Printf("could not register route guard: %v", err)
+		http.Error(w, "browser context failed", http.StatusInternalServerError)
+		return
+	}
+
+	page, err := browserCtx.NewPage()
 	if err != nil {
 		log.Printf("could not create page: %v", err)
 		http.Error(w, "browser page failed", http.StatusInternalServerError)
 		return
 	}
 
-	// Go to URL
 	if _, err = page.Goto(targetURL, playwright.PageGotoOptions{
 		WaitUntil: playwright.WaitUntilStateNetworkidle,
 		Timeout:   playwright.Float(30000),
@@ -126,14 +296,23 @@ func handleBrowserProxy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Inject <base> tag to fix relative links
-	baseTag := `<base href="` + targetURL + `">`
-	if strings.Contains(content, "<head>") {
-		content = strings.Replace(content, "<head>", "<head>"+baseTag, 1)
-	} else {
-		content = baseTag + content
+	// The final URL (after any client-side redirects) is what relative
+	// links must resolve against — never the user-supplied one.
+	finalURL := page.URL()
+	if finalURL == "" {
+		finalURL = targetURL
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.Write([]byte(content))
+	w.Write([]byte(injectBaseTag(content, finalURL)))
+}
+
+// injectBaseTag adds a <base> tag so the page's relative links resolve
+// against baseURL instead of our own origin.
+func injectBaseTag(content, baseURL string) string {
+	baseTag := `<base href="` + baseURL + `">`
+	if strings.Contains(content, "<head>") {
+		return strings.Replace(content, "<head>", "<head>"+baseTag, 1)
+	}
+	return baseTag + content
 }
@@ -0,0 +1,82 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"backend_go/internal/call"
+)
+
+type callCreateRequest struct {
+	ToUser int64 `json:"to_user"`
+}
+
+type callCreateResponse struct {
+	CallID string              `json:"call_id"`
+	TURN   call.TURNCredential `json:"turn"`
+}
+
+// handleCreateCall handles POST /conversations/{conversationID}/calls: it
+// starts a new call log entry, sends the callee a call.invite frame over
+// the socket, and returns the caller's TURN credential.
+func handleCreateCall(callSvc *call.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+
+		convID, err := strconv.ParseInt(chi.URLParam(r, "conversationID"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid conversation id"})
+			return
+		}
+
+		var req callCreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ToUser == 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "to_user is required"})
+			return
+		}
+
+		c, turn, err := callSvc.CreateCall(r.Context(), convID, currentUser.ID, req.ToUser)
+		if err != nil {
+			if errors.Is(err, call.ErrTURNUnconfigured) {
+				writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusCreated, callCreateResponse{CallID: c.ID, TURN: turn})
+	}
+}
+
+type callEndRequest struct {
+	Outcome string `json:"outcome"`
+}
+
+// handleEndCall handles POST /calls/{callID}/end: it closes out the call
+// log entry and notifies the other participant with a call.hangup frame.
+func handleEndCall(callSvc *call.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+
+		var req callEndRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		if err := callSvc.EndCall(r.Context(), chi.URLParam(r, "callID"), currentUser.ID, req.Outcome); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+	}
+}
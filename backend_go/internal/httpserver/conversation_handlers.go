@@ -2,11 +2,13 @@ package httpserver
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
 
+	"backend_go/internal/domain"
 	"backend_go/internal/service"
 )
 
@@ -80,6 +82,40 @@ func handleGetConversation(convSvc *service.ConversationService) http.HandlerFun
 	}
 }
 
+type conversationRetentionRequest struct {
+	RetentionSeconds *int `json:"retention_seconds"`
+	KeepLastN        *int `json:"keep_last_n"`
+}
+
+// handleSetConversationRetention handles PATCH /conversations/{id}/retention.
+// Retention is enforced server-side by the store's background retention
+// worker, not just advertised to clients.
+func handleSetConversationRetention(convSvc *service.ConversationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		idStr := chi.URLParam(r, "conversationID")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid conversation id"})
+			return
+		}
+		var req conversationRetentionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		if err := convSvc.SetRetention(r.Context(), id, currentUser.ID, req.RetentionSeconds, req.KeepLastN); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+	}
+}
+
 func handleMarkConversationRead(convSvc *service.ConversationService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		currentUser := CurrentUser(r)
@@ -101,3 +137,229 @@ func handleMarkConversationRead(convSvc *service.ConversationService) http.Handl
 		writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
 	}
 }
+
+type conversationRenameRequest struct {
+	Name string `json:"name"`
+}
+
+// handleRenameConversation handles PATCH /conversations/{id}/name. Only an
+// owner/admin of a group conversation may rename it.
+func handleRenameConversation(convSvc *service.ConversationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.ParseInt(chi.URLParam(r, "conversationID"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid conversation id"})
+			return
+		}
+		var req conversationRenameRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		if err := convSvc.RenameConversation(r.Context(), id, currentUser.ID, req.Name); err != nil {
+			writeForbiddenOrBadRequest(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+	}
+}
+
+type addParticipantRequest struct {
+	UserID int64 `json:"user_id"`
+}
+
+// handleAddParticipant handles POST /conversations/{id}/participants. Only
+// an owner/admin may add participants.
+func handleAddParticipant(convSvc *service.ConversationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.ParseInt(chi.URLParam(r, "conversationID"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid conversation id"})
+			return
+		}
+		var req addParticipantRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		if err := convSvc.AddParticipant(r.Context(), id, currentUser.ID, req.UserID); err != nil {
+			writeForbiddenOrBadRequest(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+	}
+}
+
+// handleRemoveParticipant handles DELETE
+// /conversations/{id}/participants/{userID}. Only an owner/admin may remove
+// participants.
+func handleRemoveParticipant(convSvc *service.ConversationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.ParseInt(chi.URLParam(r, "conversationID"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid conversation id"})
+			return
+		}
+		userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+			return
+		}
+		if err := convSvc.RemoveParticipant(r.Context(), id, currentUser.ID, userID); err != nil {
+			writeForbiddenOrBadRequest(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+	}
+}
+
+type changeRoleRequest struct {
+	Role domain.ConversationRole `json:"role"`
+}
+
+// handleChangeParticipantRole handles PATCH
+// /conversations/{id}/participants/{userID}/role. Only an owner/admin may
+// change another participant's role.
+func handleChangeParticipantRole(convSvc *service.ConversationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.ParseInt(chi.URLParam(r, "conversationID"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid conversation id"})
+			return
+		}
+		userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+			return
+		}
+		var req changeRoleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		if err := convSvc.ChangeRole(r.Context(), id, currentUser.ID, userID, req.Role); err != nil {
+			writeForbiddenOrBadRequest(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+	}
+}
+
+// handleKickParticipant handles POST
+// /conversations/{id}/participants/{userID}/kick. An owner/admin of the
+// conversation, or a global moderator/admin (internal/authz), may kick a
+// participant without banning them — they may be re-added later.
+func handleKickParticipant(convSvc *service.ConversationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.ParseInt(chi.URLParam(r, "conversationID"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid conversation id"})
+			return
+		}
+		userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+			return
+		}
+		if err := convSvc.KickParticipant(r.Context(), id, currentUser.ID, userID); err != nil {
+			writeForbiddenOrBadRequest(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+	}
+}
+
+// handleBanParticipant handles POST
+// /conversations/{id}/participants/{userID}/ban. Same authorization as
+// handleKickParticipant, but the user may not be re-added until unbanned.
+func handleBanParticipant(convSvc *service.ConversationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.ParseInt(chi.URLParam(r, "conversationID"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid conversation id"})
+			return
+		}
+		userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+			return
+		}
+		if err := convSvc.BanParticipant(r.Context(), id, currentUser.ID, userID); err != nil {
+			writeForbiddenOrBadRequest(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+	}
+}
+
+type lockConversationRequest struct {
+	Locked bool `json:"locked"`
+}
+
+// handleLockConversation handles PATCH /conversations/{id}/lock. Same
+// authorization as handleKickParticipant; while locked,
+// MessageService.CreateMessage refuses new messages from anyone.
+func handleLockConversation(convSvc *service.ConversationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.ParseInt(chi.URLParam(r, "conversationID"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid conversation id"})
+			return
+		}
+		var req lockConversationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		if err := convSvc.SetLocked(r.Context(), id, currentUser.ID, req.Locked); err != nil {
+			writeForbiddenOrBadRequest(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+	}
+}
+
+// writeForbiddenOrBadRequest maps service.ErrForbidden to 403, same as
+// message_handlers.go, and everything else to 400.
+func writeForbiddenOrBadRequest(w http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrForbidden) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,54 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"backend_go/internal/federation"
+	"backend_go/internal/policies"
+)
+
+type addFederatedParticipantRequest struct {
+	Handle string `json:"handle"`
+}
+
+// handleAddFederatedParticipant handles POST
+// /conversations/{id}/federation/participants, federating the conversation
+// to a remote zchat user (e.g. "alice@host.example") if it isn't already.
+// Only an owner/admin may do this, same as handleAddParticipant.
+func handleAddFederatedParticipant(bridge *federation.Bridge, authz *policies.Authorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.ParseInt(chi.URLParam(r, "conversationID"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid conversation id"})
+			return
+		}
+		var req addFederatedParticipantRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Handle == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		if err := authz.Authorize(r.Context(), currentUser.ID, id, policies.ActionManageParticipants); err != nil {
+			if errors.Is(err, policies.ErrForbidden) {
+				writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := bridge.JoinConversation(r.Context(), id, req.Handle); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+	}
+}
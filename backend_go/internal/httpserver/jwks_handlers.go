@@ -0,0 +1,21 @@
+package httpserver
+
+import (
+	"net/http"
+
+	"backend_go/internal/security"
+)
+
+// handleJWKS handles GET /.well-known/jwks.json, publishing every key in
+// keys (the active signing key plus any still within its rotation grace
+// window) in standard JWK Set form.
+func handleJWKS(keys *security.KeySet) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jwks, err := keys.JWKS()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, jwks)
+	}
+}
@@ -5,23 +5,26 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	"backend_go/internal/domain"
 	"backend_go/internal/service"
 )
 
 type messageCreateRequest struct {
-	Content  string  `json:"content"`
-	FilePath *string `json:"file_path"`
-	FileType *string `json:"file_type"`
+	Content      string `json:"content"`
+	AttachmentID *int64 `json:"attachment_id"`
+	TTLSeconds   int    `json:"ttl_seconds"`
+	ViewOnce     bool   `json:"view_once"`
 }
 
 type messageEditRequest struct {
 	Content string `json:"content"`
 }
 
-func handleCreateMessage(msgSvc *service.MessageService) http.HandlerFunc {
+func handleCreateMessage(msgSvc service.Messages) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		currentUser := CurrentUser(r)
 		if currentUser == nil {
@@ -43,8 +46,9 @@ func handleCreateMessage(msgSvc *service.MessageService) http.HandlerFunc {
 		msg, err := msgSvc.CreateMessage(r.Context(), service.MessageCreateInput{
 			ConversationID: convID,
 			Content:        req.Content,
-			FilePath:       req.FilePath,
-			FileType:       req.FileType,
+			AttachmentID:   req.AttachmentID,
+			TTLSeconds:     req.TTLSeconds,
+			ViewOnce:       req.ViewOnce,
 		}, currentUser.ID)
 		if err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
@@ -60,7 +64,19 @@ func handleCreateMessage(msgSvc *service.MessageService) http.HandlerFunc {
 	}
 }
 
-func handleListMessages(msgSvc *service.MessageService) http.HandlerFunc {
+// messagePageResponse is the envelope for GET .../messages: a page of
+// messages plus the opaque cursors to fetch the next/previous page, or
+// (when since is given) the set of changes a reconnecting client needs to
+// reconcile its cache.
+type messagePageResponse struct {
+	Messages   []*service.MessageResponse `json:"messages"`
+	NextCursor string                     `json:"next_cursor,omitempty"`
+	PrevCursor string                     `json:"prev_cursor,omitempty"`
+	HasMore    bool                       `json:"has_more"`
+	DeletedIDs []int64                    `json:"deleted_ids,omitempty"`
+}
+
+func handleListMessages(msgSvc service.Messages) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		currentUser := CurrentUser(r)
 		if currentUser == nil {
@@ -74,29 +90,77 @@ func handleListMessages(msgSvc *service.MessageService) http.HandlerFunc {
 			return
 		}
 
+		query := r.URL.Query()
+
 		limit := 0
-		if s := r.URL.Query().Get("limit"); s != "" {
+		if s := query.Get("limit"); s != "" {
 			if v, err := strconv.Atoi(s); err == nil {
 				limit = v
 			}
 		}
 
-		msgs, err := msgSvc.ListMessages(r.Context(), convID, currentUser.ID, limit)
+		if sinceStr := query.Get("since"); sinceStr != "" {
+			since, err := time.Parse(time.RFC3339Nano, sinceStr)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid since timestamp"})
+				return
+			}
+			changes, err := msgSvc.ChangesSince(r.Context(), convID, currentUser.ID, since)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			responses, err := msgSvc.ToResponses(r.Context(), changes.Upserts)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, messagePageResponse{
+				Messages:   responses,
+				DeletedIDs: changes.DeletedIDs,
+			})
+			return
+		}
+
+		dir := domain.Backward
+		cur := domain.Cursor{}
+		switch {
+		case query.Get("before_id") != "":
+			cur, err = domain.ParseCursor(query.Get("before_id"))
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid before_id"})
+				return
+			}
+		case query.Get("after_id") != "":
+			dir = domain.Forward
+			cur, err = domain.ParseCursor(query.Get("after_id"))
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid after_id"})
+				return
+			}
+		}
+
+		page, err := msgSvc.ListMessagesPage(r.Context(), convID, currentUser.ID, cur, dir, limit)
 		if err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 			return
 		}
 
-		responses, err := msgSvc.ToResponses(r.Context(), msgs)
+		responses, err := msgSvc.ToResponses(r.Context(), page.Messages)
 		if err != nil {
 			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 			return
 		}
-		writeJSON(w, http.StatusOK, responses)
+		writeJSON(w, http.StatusOK, messagePageResponse{
+			Messages:   responses,
+			NextCursor: page.NextCursor,
+			PrevCursor: page.PrevCursor,
+			HasMore:    page.HasMore,
+		})
 	}
 }
 
-func handleEditMessage(msgSvc *service.MessageService) http.HandlerFunc {
+func handleEditMessage(msgSvc service.Messages) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		currentUser := CurrentUser(r)
 		if currentUser == nil {
@@ -137,7 +201,7 @@ func handleEditMessage(msgSvc *service.MessageService) http.HandlerFunc {
 	}
 }
 
-func handleDeleteMessage(msgSvc *service.MessageService) http.HandlerFunc {
+func handleDeleteMessage(msgSvc service.Messages) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		currentUser := CurrentUser(r)
 		if currentUser == nil {
@@ -174,3 +238,38 @@ func handleDeleteMessage(msgSvc *service.MessageService) http.HandlerFunc {
 		writeJSON(w, http.StatusOK, resp)
 	}
 }
+
+// handleForceDeleteMessage handles DELETE /moderation/messages/{messageID}.
+// Route is gated by RequireRole(domain.GlobalRoleModerator); MessageService
+// re-checks the caller's role itself since the WS path reaches it directly.
+func handleForceDeleteMessage(msgSvc service.Messages) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		msgID, err := strconv.ParseInt(chi.URLParam(r, "messageID"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid message id"})
+			return
+		}
+
+		msg, err := msgSvc.ForceDeleteMessage(r.Context(), currentUser.ID, msgID)
+		if err != nil {
+			if errors.Is(err, service.ErrForbidden) {
+				writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+			} else {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+			return
+		}
+
+		resp, err := msgSvc.ToResponse(r.Context(), msg)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
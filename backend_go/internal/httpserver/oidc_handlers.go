@@ -0,0 +1,200 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"backend_go/internal/auth/oidc"
+	"backend_go/internal/config"
+	"backend_go/internal/service"
+)
+
+// oidcStateCookieTTL bounds how long a start leg's state/PKCE verifier may
+// sit in the browser before the callback must complete.
+const oidcStateCookieTTL = 10 * time.Minute
+
+type oidcLinkRequest struct {
+	Code         string `json:"code"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+func oidcStateCookieName(provider string) string {
+	return "oidc_state_" + provider
+}
+
+// @Summary      Start external OIDC/OAuth2 login
+// @Description  Redirects to the named provider's authorization endpoint, with a PKCE challenge and a CSRF state cookie
+// @Tags         auth
+// @Param        provider path string true "Provider name, e.g. google"
+// @Success      302
+// @Failure      404  {object}  map[string]string
+// @Router       /auth/oidc/{provider}/start [get]
+func handleOIDCStart(registry *oidc.Registry, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provider, ok := registry.Get(chi.URLParam(r, "provider"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown oidc provider"})
+			return
+		}
+
+		state, err := oidc.GenerateState()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to start oidc login"})
+			return
+		}
+		verifier, err := oidc.GenerateCodeVerifier()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to start oidc login"})
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcStateCookieName(provider.Name()),
+			Value:    state + "." + verifier,
+			Path:     "/api/auth/oidc",
+			MaxAge:   int(oidcStateCookieTTL.Seconds()),
+			HttpOnly: true,
+			Secure:   cfg.Env == "production",
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		http.Redirect(w, r, provider.AuthCodeURL(state, oidc.CodeChallenge(verifier)), http.StatusFound)
+	}
+}
+
+// @Summary      Complete external OIDC/OAuth2 login
+// @Description  Exchanges the authorization code for tokens, resolves the local user, and returns a session token
+// @Tags         auth
+// @Produce      json
+// @Param        provider path string true "Provider name, e.g. google"
+// @Success      200  {object}  tokenResponse
+// @Failure      400  {object}  map[string]string
+// @Router       /auth/oidc/{provider}/callback [get]
+func handleOIDCCallback(registry *oidc.Registry, oidcSvc *service.OIDCService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provider, ok := registry.Get(chi.URLParam(r, "provider"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown oidc provider"})
+			return
+		}
+
+		cookieName := oidcStateCookieName(provider.Name())
+		cookie, err := r.Cookie(cookieName)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing or expired oidc state"})
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: cookieName, Value: "", Path: "/api/auth/oidc", MaxAge: -1})
+
+		state, verifier, ok := strings.Cut(cookie.Value, ".")
+		if !ok || state == "" || verifier == "" || state != r.URL.Query().Get("state") {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid oidc state"})
+			return
+		}
+
+		code := r.URL.Query().Get("code")
+		if code == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing authorization code"})
+			return
+		}
+
+		info, err := provider.Exchange(r.Context(), code, verifier)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+			return
+		}
+
+		resp, err := oidcSvc.LoginWithIdentity(r.Context(), provider.Name(), info, r.UserAgent(), r.RemoteAddr)
+		if err != nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, tokenResponse{
+			AccessToken:  resp.AccessToken,
+			RefreshToken: resp.RefreshToken,
+			ExpiresIn:    resp.ExpiresIn,
+			PreAuthToken: resp.PreAuthToken,
+			RequiresOTP:  resp.RequiresOTP,
+			TokenType:    "bearer",
+			User:         resp.User,
+		})
+	}
+}
+
+// @Summary      Link an external OIDC/OAuth2 identity to the current account
+// @Description  Exchanges an authorization code already obtained for the named provider and attaches it to the caller's account
+// @Tags         auth
+// @Security     BearerAuth
+// @Accept       json
+// @Param        provider path string true "Provider name, e.g. google"
+// @Param        input body oidcLinkRequest true "Authorization code and PKCE verifier"
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Router       /auth/link/{provider} [post]
+func handleOIDCLink(registry *oidc.Registry, oidcSvc *service.OIDCService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := CurrentUser(r)
+		if user == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+
+		provider, ok := registry.Get(chi.URLParam(r, "provider"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown oidc provider"})
+			return
+		}
+
+		var req oidcLinkRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+
+		info, err := provider.Exchange(r.Context(), req.Code, req.CodeVerifier)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+			return
+		}
+
+		if err := oidcSvc.LinkIdentity(r.Context(), user.ID, provider.Name(), info); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// @Summary      Unlink an external OIDC/OAuth2 identity from the current account
+// @Description  Removes the named provider's identity, refusing if it would leave the account with no way to authenticate
+// @Tags         auth
+// @Security     BearerAuth
+// @Param        provider path string true "Provider name, e.g. google"
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Router       /auth/link/{provider} [delete]
+func handleOIDCUnlink(registry *oidc.Registry, oidcSvc *service.OIDCService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := CurrentUser(r)
+		if user == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+
+		provider, ok := registry.Get(chi.URLParam(r, "provider"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown oidc provider"})
+			return
+		}
+
+		if err := oidcSvc.UnlinkIdentity(r.Context(), user.ID, provider.Name()); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,136 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"backend_go/internal/service"
+)
+
+type otpConfirmRequest struct {
+	Code string `json:"code"`
+}
+
+type otpVerifyRequest struct {
+	PreAuthToken string `json:"pre_auth_token"`
+	Code         string `json:"code"`
+}
+
+type otpDisableRequest struct {
+	Password string `json:"password"`
+}
+
+// @Summary      Enroll in TOTP two-factor authentication
+// @Description  Generates a new TOTP secret and returns an otpauth:// URI and QR code PNG
+// @Tags         auth
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {object}  service.EnrollResult
+// @Failure      401  {object}  map[string]string
+// @Router       /auth/otp/enroll [post]
+func handleOTPEnroll(otpSvc *service.OTPService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := CurrentUser(r)
+		if user == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		result, err := otpSvc.Enroll(r.Context(), user.ID, user.Username)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// @Summary      Confirm TOTP enrollment
+// @Description  Verifies the first TOTP code, confirms enrollment, and returns recovery codes
+// @Tags         auth
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        input body otpConfirmRequest true "Confirm input"
+// @Success      200  {object}  service.ConfirmResult
+// @Failure      400  {object}  map[string]string
+// @Router       /auth/otp/confirm [post]
+func handleOTPConfirm(otpSvc *service.OTPService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := CurrentUser(r)
+		if user == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		var req otpConfirmRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		result, err := otpSvc.Confirm(r.Context(), user.ID, req.Code)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// @Summary      Verify TOTP during login
+// @Description  Exchanges a pre-auth token and TOTP (or recovery) code for a session token
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        input body otpVerifyRequest true "Verify input"
+// @Success      200  {object}  tokenResponse
+// @Failure      401  {object}  map[string]string
+// @Router       /auth/otp/verify [post]
+func handleOTPVerify(otpSvc *service.OTPService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req otpVerifyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		resp, err := otpSvc.VerifyLogin(r.Context(), req.PreAuthToken, req.Code, r.UserAgent(), r.RemoteAddr)
+		if err != nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, tokenResponse{
+			AccessToken:  resp.AccessToken,
+			RefreshToken: resp.RefreshToken,
+			ExpiresIn:    resp.ExpiresIn,
+			TokenType:    "bearer",
+			User:         resp.User,
+		})
+	}
+}
+
+// @Summary      Disable TOTP two-factor authentication
+// @Description  Removes TOTP enrollment and recovery codes after re-verifying the password
+// @Tags         auth
+// @Security     BearerAuth
+// @Accept       json
+// @Param        input body otpDisableRequest true "Disable input"
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Router       /auth/otp/disable [post]
+func handleOTPDisable(otpSvc *service.OTPService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := CurrentUser(r)
+		if user == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		var req otpDisableRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		if err := otpSvc.Disable(r.Context(), user.ID, req.Password); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
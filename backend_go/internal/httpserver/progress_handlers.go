@@ -0,0 +1,89 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"backend_go/internal/service"
+)
+
+type progressRequest struct {
+	DeviceID          string    `json:"device_id"`
+	LastReadMessageID int64     `json:"last_read_message_id"`
+	Timestamp         time.Time `json:"timestamp"`
+}
+
+// handleRecordProgress handles POST /conversations/{id}/progress: a device
+// reporting it has read everything up to a given message.
+func handleRecordProgress(progressSvc *service.ProgressService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		idStr := chi.URLParam(r, "conversationID")
+		convID, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid conversation id"})
+			return
+		}
+		var req progressRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		if req.DeviceID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "device_id is required"})
+			return
+		}
+		ts := req.Timestamp
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+
+		err = progressSvc.RecordProgress(r.Context(), convID, currentUser.ID, req.DeviceID, req.LastReadMessageID, ts)
+		if err != nil {
+			if errors.Is(err, service.ErrForbidden) {
+				writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+			} else {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+	}
+}
+
+// handleGetProgress handles GET /conversations/{id}/progress: the
+// aggregated per-device read state of a conversation.
+func handleGetProgress(progressSvc *service.ProgressService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		idStr := chi.URLParam(r, "conversationID")
+		convID, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid conversation id"})
+			return
+		}
+		progress, err := progressSvc.ListProgress(r.Context(), convID, currentUser.ID)
+		if err != nil {
+			if errors.Is(err, service.ErrForbidden) {
+				writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+			} else {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+			return
+		}
+		writeJSON(w, http.StatusOK, progress)
+	}
+}
@@ -1,143 +1,389 @@
-package httpserver
-
-import (
-	"database/sql"
-	"encoding/json"
-	"net/http"
-	"time"
-
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
-
-	"backend_go/internal/config"
-	"backend_go/internal/security"
-	"backend_go/internal/service"
-	"backend_go/internal/store/postgres"
-	"backend_go/internal/ws"
-
-	_ "backend_go/docs"
-
-	httpSwagger "github.com/swaggo/http-swagger"
-)
-
-// NewRouter constructs the main HTTP router and wires routes, services, and middleware.
-func NewRouter(cfg *config.Config, db *sql.DB, hub *ws.Hub, tokenSvc *security.TokenService, passwordHasher *security.PasswordHasher, encryptor *security.Encryptor) http.Handler {
-	r := chi.NewRouter()
-
-	// Middlewares
-	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
-
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   cfg.CORSOrigins,
-		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	}))
-
-	// Repositories
-	userRepo := postgres.NewUserRepo(db)
-	convRepo := postgres.NewConversationRepo(db)
-	msgRepo := postgres.NewMessageRepo(db)
-	partRepo := postgres.NewParticipantRepo(db)
-	deletedMsgRepo := postgres.NewUserDeletedMessageRepo(db)
-
-	// Services
-	defaultTTL := time.Duration(cfg.AccessTokenMinutes) * time.Minute
-	rememberMeTTL := time.Duration(cfg.RememberMeDays) * 24 * time.Hour
-
-	authSvc := service.NewAuthService(userRepo, tokenSvc, passwordHasher, defaultTTL, rememberMeTTL)
-	userSvc := service.NewUserService(userRepo)
-	convSvc := service.NewConversationService(convRepo, partRepo, msgRepo)
-	msgSvc := service.NewMessageService(convRepo, partRepo, msgRepo, deletedMsgRepo, userRepo, encryptor, cfg.MaxMessagesPerConversation)
-	// wire circular reference
-	convSvc.SetMessageService(msgSvc)
-
-	// Static endpoints
-	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"message":"zChat Go Application API","version":"1.0.0","docs":"/docs"}`))
-	})
-
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"healthy"}`))
-	})
-
-	r.Get("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/plain")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("User-agent: *\nDisallow: /"))
-	})
-
-	// Swagger documentation
-	r.Get("/docs/*", httpSwagger.Handler(
-		httpSwagger.URL("/docs/doc.json"),
-	))
-
-	// API routes
-	r.Route("/api", func(r chi.Router) {
-		// Auth routes (no auth required)
-		r.Route("/auth", func(r chi.Router) {
-			r.Post("/register", handleRegister(authSvc, userSvc))
-			r.Post("/login", handleLogin(authSvc))
-		})
-
-		// Authenticated routes
-		r.Group(func(r chi.Router) {
-			r.Use(AuthMiddleware(tokenSvc, userRepo))
-
-			// Authenticated auth endpoints
-			r.Post("/auth/logout", handleLogout(authSvc))
-			r.Get("/auth/me", handleMe())
-
-			// Users
-			r.Route("/users", func(r chi.Router) {
-				r.Get("/", handleListUsers(userSvc))
-				r.Get("/online", handleListOnlineUsers(userSvc))
-				r.Get("/{userID}", handleGetUser(userSvc))
-			})
-
-			// Conversations and messages
-			r.Route("/conversations", func(r chi.Router) {
-				r.Post("/", handleCreateConversation(convSvc))
-				r.Get("/", handleListConversations(convSvc))
-				r.Get("/{conversationID}", handleGetConversation(convSvc))
-				r.Post("/{conversationID}/read", handleMarkConversationRead(convSvc))
-				r.Get("/{conversationID}/messages", handleListMessages(msgSvc))
-				r.Post("/{conversationID}/messages", handleCreateMessage(msgSvc))
-			})
-
-			// Message edit / delete
-			r.Route("/messages", func(r chi.Router) {
-				r.Put("/{messageID}", handleEditMessage(msgSvc))
-				r.Delete("/{messageID}", handleDeleteMessage(msgSvc))
-			})
-
-			// Uploads (auth enforced inside for download via token param)
-			r.Mount("/uploads", UploadRoutes(cfg, tokenSvc))
-		})
-	})
-
-	// WebSocket endpoint
-	r.Get("/ws", ws.MakeHandler(hub, tokenSvc, userRepo, convRepo, msgSvc, encryptor))
-
-	return r
-}
-
-// writeJSON is a small helper to send JSON responses.
-func writeJSON(w http.ResponseWriter, status int, v any) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	if v != nil {
-		_ = json.NewEncoder(w).Encode(v)
-	}
-}
+package httpserver
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+
+	"backend_go/internal/auth/oidc"
+	"backend_go/internal/authserver"
+	"backend_go/internal/call"
+	"backend_go/internal/config"
+	"backend_go/internal/domain"
+	"backend_go/internal/email"
+	"backend_go/internal/events"
+	"backend_go/internal/federation"
+	"backend_go/internal/grpcserver"
+	"backend_go/internal/policies"
+	"backend_go/internal/scan"
+	"backend_go/internal/security"
+	"backend_go/internal/service"
+	svcmiddleware "backend_go/internal/service/middleware"
+	"backend_go/internal/storage"
+	"backend_go/internal/store/postgres"
+	"backend_go/internal/ws"
+
+	_ "backend_go/docs"
+
+	httpSwagger "github.com/swaggo/http-swagger"
+)
+
+// NewRouter builds the REST/WS API and, if GRPCEnabled, the gRPC server
+// that mirrors it (internal/grpcserver) over the same service.* instances —
+// cmd/server runs both, cfg.HTTPAddr and cfg.GRPCAddr, from one process.
+// grpcServer is nil when GRPCEnabled is false.
+func NewRouter(cfg *config.Config, db *sql.DB, hub *ws.Hub, tokenSvc *security.TokenService, passwordHasher *security.PasswordWrapper, encryptor *security.Encryptor) (router http.Handler, grpcServer *grpc.Server) {
+	r := chi.NewRouter()
+
+	// Middlewares
+	r.Use(middleware.RequestID)
+	r.Use(middleware.RealIP)
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.Timeout(60 * time.Second))
+
+	r.Use(cors.Handler(cors.Options{
+		AllowedOrigins:   cfg.CORSOrigins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		ExposedHeaders:   []string{"Link"},
+		AllowCredentials: true,
+		MaxAge:           300,
+	}))
+
+	// Repositories
+	userRepo := postgres.NewUserRepo(db)
+	convRepo := postgres.NewConversationRepo(db)
+	msgRepo := postgres.NewMessageRepo(db)
+	partRepo := postgres.NewParticipantRepo(db)
+	deletedMsgRepo := postgres.NewUserDeletedMessageRepo(db)
+	attachmentRepo := postgres.NewAttachmentRepo(db)
+	otpRepo := postgres.NewOTPRepo(db)
+	tusUploadRepo := postgres.NewTusUploadRepo(db)
+	callRepo := postgres.NewCallRepo(db)
+	searchRepo := postgres.NewSearchRepo(db)
+	certRevRepo := postgres.NewCertRevocationRepo(db)
+	convKeyRepo := postgres.NewConversationKeyRepo(db)
+	progressRepo := postgres.NewProgressRepo(db)
+	oauthClientRepo := postgres.NewOAuthClientRepo(db)
+	authRequestRepo := postgres.NewAuthRequestRepo(db)
+	verificationTokenRepo := postgres.NewVerificationTokenRepo(db)
+	refreshTokenRepo := postgres.NewRefreshTokenRepo(db)
+	fedRepo := postgres.NewFederationRepo(db)
+
+	// Object storage backend for message attachments.
+	storageBackend, err := storage.New(context.Background(), storage.Settings{
+		Backend:      cfg.StorageBackend,
+		LocalDir:     cfg.UploadDir,
+		PublicURL:    cfg.PublicBaseURL + "/api/attachments/local",
+		SignSecret:   []byte(cfg.EncryptKey),
+		Bucket:       cfg.StorageBucket,
+		Region:       cfg.StorageRegion,
+		Endpoint:     cfg.StorageEndpoint,
+		AccessKey:    cfg.StorageAccessKey,
+		SecretKey:    cfg.StorageSecretKey,
+		UseSSL:       cfg.StorageUseSSL,
+		UsePathStyle: cfg.StorageUsePathStyle,
+	})
+	if err != nil {
+		log.Fatalf("failed to initialize storage backend: %v", err)
+	}
+
+	// Malware scanner for uploads that pass through this process (the local
+	// storage backend); defaults to a no-op when no clamd is configured.
+	var scanner scan.Scanner = scan.NoopScanner{}
+	if cfg.ScannerAddr != "" {
+		scanner = scan.NewClamAVScanner(cfg.ScannerAddr)
+	}
+
+	// Event publisher for message/auth lifecycle events (search indexing,
+	// push notifications, analytics); a no-op until EVENTS_ENABLED and
+	// REDIS_URL are both set, so existing deployments are unaffected.
+	var eventPublisher domain.EventPublisher = events.NoopPublisher{}
+	if cfg.EventsEnabled && cfg.RedisURL != "" {
+		opts, err := redis.ParseURL(cfg.RedisURL)
+		if err != nil {
+			log.Fatalf("invalid REDIS_URL: %v", err)
+		}
+		eventPublisher = events.NewRedisStreamsPublisher(redis.NewClient(opts), cfg.EventsStreamMaxLen)
+	}
+
+	// Services
+	defaultTTL := time.Duration(cfg.AccessTokenMinutes) * time.Minute
+	rememberMeTTL := time.Duration(cfg.RememberMeDays) * 24 * time.Hour
+
+	// Emailer backing the verification / password-reset flows: a no-op
+	// unless SMTP_HOST is set, so deployments without SMTP are unaffected.
+	var emailer domain.Emailer = email.NoopEmailer{}
+	if cfg.SMTPHost != "" {
+		emailer = email.NewSMTPEmailer(email.SMTPConfig{
+			Host:          cfg.SMTPHost,
+			Port:          cfg.SMTPPort,
+			Username:      cfg.SMTPUsername,
+			Password:      cfg.SMTPPassword,
+			From:          cfg.SMTPFrom,
+			AppName:       cfg.AppName,
+			PublicBaseURL: cfg.PublicBaseURL,
+		})
+	}
+
+	realAuthSvc := service.NewAuthService(userRepo, otpRepo, verificationTokenRepo, refreshTokenRepo, tokenSvc, passwordHasher, eventPublisher, emailer, defaultTTL, rememberMeTTL, cfg.EmailVerificationRequired)
+	userSvc := service.NewUserService(userRepo, hub.Broker(), hub.InstanceID(), time.Duration(cfg.PresenceTTLSeconds)*time.Second)
+	authz := policies.NewAuthorizer(partRepo)
+	convSvc := service.NewConversationService(convRepo, partRepo, msgRepo, userRepo, authz)
+	convKeySvc := service.NewConversationKeyService(convKeyRepo, encryptor)
+	realMsgSvc := service.NewMessageService(convRepo, partRepo, msgRepo, deletedMsgRepo, userRepo, attachmentRepo, convKeySvc, eventPublisher, authz, cfg.MaxMessagesPerConversation)
+	progressSvc := service.NewProgressService(partRepo, progressRepo)
+
+	// Wrap the real implementations with the logging/metrics/tracing
+	// decorator chain so every call site (handlers, ws.Handler, and other
+	// services below) gets observability for free instead of each one
+	// logging/instrumenting itself ad hoc.
+	authSvc := svcmiddleware.NewTracingAuth(svcmiddleware.NewMetricsAuth(svcmiddleware.NewLoggingAuth(realAuthSvc, nil)))
+	msgSvc := svcmiddleware.NewTracingMessages(svcmiddleware.NewMetricsMessages(svcmiddleware.NewLoggingMessages(realMsgSvc, nil)))
+
+	// wire circular reference
+	convSvc.SetMessageService(msgSvc)
+
+	attSvc := service.NewAttachmentService(attachmentRepo, storageBackend, encryptor, 15*time.Minute)
+
+	tusSvc, err := service.NewTusService(tusUploadRepo, storageBackend, scanner, filepath.Join(cfg.UploadDir, "tus"), 24*time.Hour)
+	if err != nil {
+		log.Fatalf("failed to initialize resumable upload service: %v", err)
+	}
+	go tusSvc.RunGC(context.Background(), 10*time.Minute)
+	otpSvc := service.NewOTPService(userRepo, otpRepo, refreshTokenRepo, security.NewTOTP(cfg.AppName), encryptor, passwordHasher, tokenSvc, defaultTTL, rememberMeTTL)
+	oidcRegistry := oidc.NewRegistry(cfg.OIDCProviders)
+	oidcSvc := service.NewOIDCService(userRepo, otpRepo, refreshTokenRepo, passwordHasher, tokenSvc, defaultTTL)
+	adminSvc := service.NewAdminService(userRepo, convRepo, msgRepo)
+	callSvc := call.NewService(callRepo, partRepo, hub, []byte(cfg.TurnSharedSecret), time.Duration(cfg.TurnCredentialTTLSeconds)*time.Second, cfg.TurnRequired)
+	searchSvc := service.NewSearchService(convRepo, partRepo, searchRepo, msgSvc)
+	authServerSvc := authserver.NewServer(oauthClientRepo, authRequestRepo, userRepo, tokenSvc, cfg.PublicBaseURL, defaultTTL)
+
+	retentionWorker := postgres.NewRetentionWorker(
+		db, msgRepo, storageBackend, hub,
+		time.Duration(cfg.RetentionSweepIntervalSeconds)*time.Second,
+		cfg.MaxMessagesPerConversation,
+		time.Duration(cfg.DefaultRetentionMaxAgeSeconds)*time.Second,
+	)
+	go retentionWorker.Run(context.Background())
+
+	// Federation bridge to other zchat servers over a gossip overlay; nil
+	// (and therefore a no-op everywhere it's consulted) unless
+	// FEDERATION_ENABLED is set, so a standalone deployment never opens a
+	// libp2p listener.
+	var fedBridge *federation.Bridge
+	if cfg.FederationEnabled {
+		fedKey, err := federation.LoadOrGenerateServerKey(cfg.FederationServerKeyPath)
+		if err != nil {
+			log.Fatalf("failed to initialize federation server key: %v", err)
+		}
+		fedIdentity, err := federation.Libp2pIdentity(fedKey)
+		if err != nil {
+			log.Fatalf("failed to adapt federation server key: %v", err)
+		}
+		fedTransport, err := federation.NewLibp2pTransport(context.Background(), cfg.FederationListenAddr, fedIdentity, cfg.FederationBootstrapPeers)
+		if err != nil {
+			log.Fatalf("failed to start federation transport: %v", err)
+		}
+		fedBridge = federation.NewBridge(fedTransport, fedRepo, userRepo, msgSvc, hub, passwordHasher, cfg.FederationServerHost, fedKey)
+		if err := fedBridge.Start(context.Background()); err != nil {
+			log.Fatalf("failed to start federation bridge: %v", err)
+		}
+	}
+
+	// Static endpoints
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message":"zChat Go Application API","version":"1.0.0","docs":"/docs"}`))
+	})
+
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"healthy"}`))
+	})
+
+	r.Get("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("User-agent: *\nDisallow: /"))
+	})
+
+	// Prometheus scrape endpoint for the service call counters/histograms
+	// recorded by internal/service/middleware.
+	if cfg.MetricsEnabled {
+		r.Handle("/metrics", promhttp.Handler())
+	}
+
+	// JWKS endpoint: lets external services (mobile clients, other internal
+	// services) verify zchat-issued tokens without holding a signing key.
+	r.Get("/.well-known/jwks.json", handleJWKS(tokenSvc.KeySet()))
+
+	// OIDC authorization server for third-party applications: discovery and
+	// token exchange are public, but /oauth/authorize requires the caller to
+	// already hold a zchat login session (it only asks them to approve the
+	// grant), so it runs behind the same AuthMiddleware as the rest of /api.
+	r.Get("/.well-known/openid-configuration", handleOIDCDiscovery(authServerSvc, cfg.JWTSigningAlg))
+	r.With(AuthMiddleware(tokenSvc, userRepo, otpRepo, certRevRepo)).Get("/oauth/authorize", handleOAuthAuthorize(authServerSvc))
+	r.Post("/oauth/token", handleOAuthToken(authServerSvc))
+	r.Get("/oauth/userinfo", handleOAuthUserInfo(authServerSvc))
+
+	// Swagger documentation
+	r.Get("/docs/*", httpSwagger.Handler(
+		httpSwagger.URL("/docs/doc.json"),
+	))
+
+	// API routes
+	r.Route("/api", func(r chi.Router) {
+		// Auth routes (no auth required)
+		r.Route("/auth", func(r chi.Router) {
+			r.Post("/register", handleRegister(authSvc, userSvc))
+			r.Post("/login", handleLogin(authSvc))
+			r.Post("/refresh", handleRefresh(authSvc))
+			r.Post("/otp/verify", handleOTPVerify(otpSvc))
+			r.Get("/verify", handleVerifyEmail(authSvc))
+			r.Post("/reset/request", handleRequestPasswordReset(authSvc))
+			r.Post("/reset/confirm", handleResetPassword(authSvc))
+
+			r.Route("/oidc/{provider}", func(r chi.Router) {
+				r.Get("/start", handleOIDCStart(oidcRegistry, cfg))
+				r.Get("/callback", handleOIDCCallback(oidcRegistry, oidcSvc))
+			})
+		})
+
+		// Authenticated routes
+		r.Group(func(r chi.Router) {
+			r.Use(AuthMiddleware(tokenSvc, userRepo, otpRepo, certRevRepo))
+
+			// Authenticated auth endpoints
+			r.Post("/auth/logout", handleLogout(authSvc))
+			r.Get("/auth/me", handleMe())
+			r.Post("/auth/link/{provider}", handleOIDCLink(oidcRegistry, oidcSvc))
+			r.Delete("/auth/link/{provider}", handleOIDCUnlink(oidcRegistry, oidcSvc))
+
+			// Users
+			r.Route("/users", func(r chi.Router) {
+				r.Get("/", handleListUsers(userSvc))
+				r.Get("/online", handleListOnlineUsers(userSvc))
+				r.Get("/{userID}", handleGetUser(userSvc))
+			})
+
+			// Conversations and messages
+			r.Route("/conversations", func(r chi.Router) {
+				r.Post("/", handleCreateConversation(convSvc))
+				r.Get("/", handleListConversations(convSvc))
+				r.Get("/{conversationID}", handleGetConversation(convSvc))
+				r.Post("/{conversationID}/read", handleMarkConversationRead(convSvc))
+				r.Patch("/{conversationID}/retention", handleSetConversationRetention(convSvc))
+				r.Patch("/{conversationID}/name", handleRenameConversation(convSvc))
+				r.Post("/{conversationID}/participants", handleAddParticipant(convSvc))
+				r.Delete("/{conversationID}/participants/{userID}", handleRemoveParticipant(convSvc))
+				r.Patch("/{conversationID}/participants/{userID}/role", handleChangeParticipantRole(convSvc))
+				r.Post("/{conversationID}/participants/{userID}/kick", handleKickParticipant(convSvc))
+				r.Post("/{conversationID}/participants/{userID}/ban", handleBanParticipant(convSvc))
+				r.Patch("/{conversationID}/lock", handleLockConversation(convSvc))
+				r.Post("/{conversationID}/progress", handleRecordProgress(progressSvc))
+				r.Get("/{conversationID}/progress", handleGetProgress(progressSvc))
+				r.Get("/{conversationID}/messages", handleListMessages(msgSvc))
+				r.Post("/{conversationID}/messages", handleCreateMessage(msgSvc))
+				r.Post("/{conversationID}/calls", handleCreateCall(callSvc))
+				if fedBridge != nil {
+					r.Post("/{conversationID}/federation/participants", handleAddFederatedParticipant(fedBridge, authz))
+				}
+			})
+
+			// Message edit / delete
+			r.Route("/messages", func(r chi.Router) {
+				r.Put("/{messageID}", handleEditMessage(msgSvc))
+				r.Delete("/{messageID}", handleDeleteMessage(msgSvc))
+			})
+
+			// Site-wide moderation: force-delete any message regardless of
+			// conversation membership. Kick/ban/lock live under
+			// /conversations instead, since they also accept an in-conversation
+			// owner/admin, not just a global moderator/admin.
+			r.Route("/moderation", func(r chi.Router) {
+				r.Use(RequireRole(domain.GlobalRoleModerator))
+				r.Delete("/messages/{messageID}", handleForceDeleteMessage(msgSvc))
+			})
+
+			// Admin console: user management and a manual retention sweep.
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(RequireRole(domain.GlobalRoleAdmin))
+				r.Get("/users", handleAdminListUsers(adminSvc))
+				r.Post("/users/{id}/deactivate", handleAdminDeactivateUser(adminSvc))
+				r.Post("/users/{id}/roles", handleAdminSetRole(adminSvc))
+				r.Delete("/users/{id}/roles/{role}", handleAdminRemoveRole(adminSvc))
+				r.Post("/messages/prune", handleAdminPruneMessages(adminSvc, cfg.MaxMessagesPerConversation))
+			})
+
+			// Call signaling REST (WS carries the rest of the protocol)
+			r.Post("/calls/{callID}/end", handleEndCall(callSvc))
+
+			// ICE server discovery: STUN/TURN URIs plus a freshly minted
+			// TURN credential for the caller, for clients that want it ahead
+			// of (or independent of) starting a call.
+			r.Get("/rtc/ice-servers", handleICEServers(cfg))
+
+			// Full-text search over the caller's own conversations
+			r.Route("/search", func(r chi.Router) {
+				r.Get("/messages", handleSearchMessages(searchSvc))
+			})
+
+			// Uploads (auth enforced inside for download via token param)
+			r.Mount("/uploads", UploadRoutes(cfg, tokenSvc, storageBackend, tusSvc))
+
+			// Attachments (presigned upload/download for message attachments)
+			r.Route("/attachments", func(r chi.Router) {
+				r.Post("/", handleUploadAttachment(attSvc))
+				r.Post("/presign", handlePresignAttachment(attSvc))
+				r.Get("/{id}", handleGetAttachment(attSvc))
+			})
+
+			// TOTP two-factor authentication management
+			r.Route("/auth/otp", func(r chi.Router) {
+				r.Post("/enroll", handleOTPEnroll(otpSvc))
+				r.Post("/confirm", handleOTPConfirm(otpSvc))
+				r.Post("/disable", handleOTPDisable(otpSvc))
+			})
+		})
+
+		// Local storage backend's signed direct-upload endpoint. It is outside
+		// the bearer-auth group because it is instead protected by its own HMAC
+		// signature scheme, same as the /uploads routes.
+		if localBackend, ok := storageBackend.(*storage.LocalBackend); ok {
+			r.HandleFunc("/attachments/local/{key}", LocalAttachmentUploadRoute(localBackend, scanner))
+		}
+	})
+
+	// WebSocket endpoint
+	r.Get("/ws", ws.MakeHandler(hub, tokenSvc, userRepo, convRepo, msgSvc, encryptor, callSvc, fedBridge, cfg.CORSOrigins, cfg.WSMaxMessageBytes))
+
+	if cfg.GRPCEnabled {
+		grpcServer = grpcserver.NewServer(authSvc, convSvc, msgSvc, attSvc, hub, tokenSvc, userRepo, otpRepo).NewGRPCServer()
+	}
+
+	return r, grpcServer
+}
+
+// writeJSON is a small helper to send JSON responses.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if v != nil {
+		_ = json.NewEncoder(w).Encode(v)
+	}
+}
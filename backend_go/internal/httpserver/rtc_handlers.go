@@ -0,0 +1,38 @@
+package httpserver
+
+import (
+	"net/http"
+	"time"
+
+	"backend_go/internal/config"
+	"backend_go/internal/rtc"
+)
+
+type iceServersResponse struct {
+	IceServers []rtc.ICEServer `json:"iceServers"`
+}
+
+// handleICEServers handles GET /api/rtc/ice-servers: it returns the
+// STUN/TURN URIs from config plus a TURN credential freshly minted for the
+// caller, in the "iceServers" shape RTCPeerConnection's constructor expects
+// directly. Unlike call.Service.CreateCall, this never fails when TURN is
+// unconfigured — it just omits the TURN entry, since merely listing ICE
+// servers (unlike placing a call) isn't useless without one.
+func handleICEServers(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+
+		servers := rtc.BuildICEServers(
+			[]byte(cfg.TurnSharedSecret),
+			cfg.StunURIs,
+			cfg.TurnURIs,
+			currentUser.ID,
+			time.Duration(cfg.TurnCredentialTTLSeconds)*time.Second,
+		)
+		writeJSON(w, http.StatusOK, iceServersResponse{IceServers: servers})
+	}
+}
@@ -0,0 +1,55 @@
+package httpserver
+
+import (
+	"net/http"
+	"strconv"
+
+	"backend_go/internal/service"
+)
+
+// handleSearchMessages handles GET /search/messages?q=...&conversation_id=...,
+// returning decrypted message snippets from conversations the caller
+// participates in.
+func handleSearchMessages(searchSvc *service.SearchService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+
+		q := r.URL.Query().Get("q")
+		if q == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "q is required"})
+			return
+		}
+
+		var conversationID *int64
+		if s := r.URL.Query().Get("conversation_id"); s != "" {
+			id, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid conversation_id"})
+				return
+			}
+			conversationID = &id
+		}
+
+		limit := 20
+		if s := r.URL.Query().Get("limit"); s != "" {
+			if v, err := strconv.Atoi(s); err == nil {
+				limit = v
+			}
+		}
+
+		results, err := searchSvc.Search(r.Context(), currentUser.ID, q, conversationID, limit)
+		if err != nil {
+			if err == service.ErrForbidden {
+				writeJSON(w, http.StatusForbidden, map[string]string{"error": "not allowed for this conversation"})
+				return
+			}
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, results)
+	}
+}
@@ -0,0 +1,181 @@
+package httpserver
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"backend_go/internal/config"
+	"backend_go/internal/domain"
+	"backend_go/internal/service"
+)
+
+// tusResumableVersion is the tus.io protocol version this handler speaks.
+const tusResumableVersion = "1.0.0"
+
+// parseUploadMetadata decodes a tus Upload-Metadata header: a comma
+// separated list of "key base64(value)" pairs (a bare key with no value is
+// also valid per the spec and decodes to an empty string).
+func parseUploadMetadata(header string) map[string]string {
+	meta := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, " ", 2)
+		if parts[0] == "" {
+			continue
+		}
+		if len(parts) == 1 {
+			meta[parts[0]] = ""
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			continue
+		}
+		meta[parts[0]] = string(decoded)
+	}
+	return meta
+}
+
+// handleTusCreate handles the tus.io upload-creation POST: it is reached
+// from UploadRoutes' POST "/" handler whenever the request carries an
+// Upload-Length header.
+func handleTusCreate(cfg *config.Config, tusSvc *service.TusService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+
+		size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+		if err != nil || size <= 0 {
+			http.Error(w, "invalid Upload-Length", http.StatusBadRequest)
+			return
+		}
+
+		rawMetadata := r.Header.Get("Upload-Metadata")
+		meta := parseUploadMetadata(rawMetadata)
+		ext := strings.ToLower(filepath.Ext(meta["filename"]))
+		if ext == "" {
+			http.Error(w, "Upload-Metadata must include a filename with an extension", http.StatusBadRequest)
+			return
+		}
+		if _, forbidden := forbiddenExtensions[ext]; forbidden {
+			http.Error(w, "file type not allowed", http.StatusBadRequest)
+			return
+		}
+
+		contentType := meta["content_type"]
+		if contentType == "" {
+			contentType = mime.TypeByExtension(ext)
+		}
+
+		fileType := categoriseFileType(ext)
+		if limit := maxUploadBytes(cfg, fileType); size > limit {
+			http.Error(w, fmt.Sprintf("%s uploads are limited to %d bytes", fileType, limit), http.StatusBadRequest)
+			return
+		}
+
+		key := uuid.New().String() + ext
+		upload, err := tusSvc.CreateUpload(r.Context(), currentUser.ID, key, contentType, size, rawMetadata)
+		if err != nil {
+			http.Error(w, "could not create upload", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Tus-Resumable", tusResumableVersion)
+		w.Header().Set("Location", "/api/uploads/"+upload.ID)
+		w.Header().Set("Upload-Offset", "0")
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// handleTusHead reports how many bytes of a resumable upload have landed
+// so far, letting the client resume a PATCH stream from the right offset.
+func handleTusHead(tusSvc *service.TusService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+
+		upload, err := tusSvc.GetUpload(r.Context(), chi.URLParam(r, "id"))
+		if err != nil || upload.OwnerID != currentUser.ID {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Tus-Resumable", tusResumableVersion)
+		w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+		w.Header().Set("Upload-Length", strconv.FormatInt(upload.Size, 10))
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// handleTusPatch appends one chunk of a resumable upload at Upload-Offset.
+// The storage layer rejects (409) an offset that doesn't match what it has
+// on disk, which is how the client detects and recovers from a dropped
+// connection that only partially delivered the previous chunk.
+func handleTusPatch(tusSvc *service.TusService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currentUser := CurrentUser(r)
+		if currentUser == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/offset+octet-stream" {
+			http.Error(w, "Content-Type must be application/offset+octet-stream", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		id := chi.URLParam(r, "id")
+		upload, err := tusSvc.GetUpload(r.Context(), id)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if upload.OwnerID != currentUser.ID {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid Upload-Offset", http.StatusBadRequest)
+			return
+		}
+
+		newOffset, completed, err := tusSvc.AppendChunk(r.Context(), id, offset, r.Body)
+		if err == domain.ErrConflict {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+			return
+		}
+
+		w.Header().Set("Tus-Resumable", tusResumableVersion)
+		w.Header().Set("Upload-Offset", strconv.FormatInt(newOffset, 10))
+		if completed {
+			// The key chosen at creation time doubles as the final
+			// file_path clients reference from a message once the upload
+			// has moved into the storage backend.
+			w.Header().Set("X-File-Path", upload.Key)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
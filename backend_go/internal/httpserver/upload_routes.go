@@ -1,18 +1,21 @@
 package httpserver
 
 import (
-	"io"
+	"encoding/json"
+	"fmt"
 	"mime"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 
 	"backend_go/internal/config"
 	"backend_go/internal/security"
+	"backend_go/internal/service"
+	"backend_go/internal/storage"
 )
 
 // forbiddenExtensions are rejected on upload.
@@ -65,23 +68,81 @@ func categoriseFileType(ext string) string {
 	}
 }
 
-// UploadRoutes returns a sub-router mounted at /api/uploads.
-func UploadRoutes(cfg *config.Config, tokenSvc *security.TokenService) chi.Router {
+// primaryMimeType returns the part of a MIME type before the "/", e.g.
+// "image" for "image/png". Used to cross-check a client-declared
+// content-type against the one implied by the file extension.
+func primaryMimeType(mtype string) string {
+	if i := strings.IndexByte(mtype, '/'); i != -1 {
+		return mtype[:i]
+	}
+	return mtype
+}
+
+// maxUploadBytes returns the configured size cap for a file category.
+func maxUploadBytes(cfg *config.Config, category string) int64 {
+	switch category {
+	case "image":
+		return cfg.MaxImageUploadBytes
+	case "video":
+		return cfg.MaxVideoUploadBytes
+	case "document":
+		return cfg.MaxDocumentUploadBytes
+	default:
+		return cfg.MaxFileUploadBytes
+	}
+}
+
+type presignUploadRequest struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+type presignUploadResponse struct {
+	FilePath   string                  `json:"file_path"`
+	FileType   string                  `json:"file_type"`
+	ScanStatus string                  `json:"scan_status"`
+	Upload     storage.PresignedUpload `json:"upload"`
+}
+
+// downloadURLTTL is how long a presigned GET redirect target stays valid.
+const downloadURLTTL = 15 * time.Minute
+
+// UploadRoutes returns a sub-router mounted at /api/uploads. Persistence is
+// delegated to the configured storage.Backend rather than the local disk:
+// POST mints a short-lived presigned PUT URL so the client uploads bytes
+// directly to the backend (bypassing this process for large files), and GET
+// 302-redirects the authenticated caller to a presigned GET URL instead of
+// streaming the object through http.ServeFile.
+//
+// A POST carrying an Upload-Length header is instead treated as a tus.io
+// 1.0.0 resumable-upload creation (see tus_handlers.go): HEAD and PATCH on
+// the returned id then drive the rest of that protocol, for clients (e.g.
+// mobile apps sending video) that need to resume after a dropped connection.
+func UploadRoutes(cfg *config.Config, tokenSvc *security.TokenService, backend storage.Backend, tusSvc *service.TusService) chi.Router {
 	r := chi.NewRouter()
 
 	r.Post("/", func(w http.ResponseWriter, r *http.Request) {
-		if err := r.ParseMultipartForm(50 << 20); err != nil {
-			http.Error(w, "failed to parse multipart form", http.StatusBadRequest)
+		if r.Header.Get("Upload-Length") != "" {
+			handleTusCreate(cfg, tusSvc)(w, r)
 			return
 		}
-		file, header, err := r.FormFile("file")
-		if err != nil {
-			http.Error(w, "missing file", http.StatusBadRequest)
+
+		var req presignUploadRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		if req.Filename == "" {
+			http.Error(w, "filename is required", http.StatusBadRequest)
+			return
+		}
+		if req.Size <= 0 {
+			http.Error(w, "size must be positive", http.StatusBadRequest)
 			return
 		}
-		defer file.Close()
 
-		ext := strings.ToLower(filepath.Ext(header.Filename))
+		ext := strings.ToLower(filepath.Ext(req.Filename))
 		if ext == "" {
 			http.Error(w, "file must have an extension", http.StatusBadRequest)
 			return
@@ -91,31 +152,42 @@ func UploadRoutes(cfg *config.Config, tokenSvc *security.TokenService) chi.Route
 			return
 		}
 
-		filename := uuid.New().String() + ext
-		destPath := filepath.Join(cfg.UploadDir, filename)
+		expectedMime := mime.TypeByExtension(ext)
+		contentType := req.ContentType
+		if contentType == "" {
+			contentType = expectedMime
+		} else if expectedMime != "" && primaryMimeType(contentType) != primaryMimeType(expectedMime) {
+			http.Error(w, "content type does not match file extension", http.StatusBadRequest)
+			return
+		}
 
-		if err := os.MkdirAll(cfg.UploadDir, 0755); err != nil {
-			http.Error(w, "could not create upload directory", http.StatusInternalServerError)
+		fileType := categoriseFileType(ext)
+		if limit := maxUploadBytes(cfg, fileType); req.Size > limit {
+			http.Error(w, fmt.Sprintf("%s uploads are limited to %d bytes", fileType, limit), http.StatusBadRequest)
 			return
 		}
 
-		out, err := os.Create(destPath)
+		filePath := uuid.New().String() + ext
+		upload, err := backend.PresignPut(r.Context(), filePath, contentType, req.Size)
 		if err != nil {
-			http.Error(w, "could not create file", http.StatusInternalServerError)
+			http.Error(w, "could not presign upload", http.StatusInternalServerError)
 			return
 		}
-		defer out.Close()
 
-		if _, err := io.Copy(out, file); err != nil {
-			os.Remove(destPath)
-			http.Error(w, "could not save file", http.StatusInternalServerError)
-			return
+		// Only the local backend proxies bytes through this process (see
+		// LocalAttachmentUploadRoute), so it is the only case where a
+		// malware scan can run before the object is ever readable. Other
+		// backends receive the upload directly and are reported unscanned.
+		scanStatus := "unscanned"
+		if _, ok := backend.(*storage.LocalBackend); ok {
+			scanStatus = "pending"
 		}
 
-		writeJSON(w, http.StatusOK, map[string]any{
-			"file_path": "uploads/" + filename,
-			"file_type": categoriseFileType(ext),
-			"filename":  filename,
+		writeJSON(w, http.StatusOK, presignUploadResponse{
+			FilePath:   filePath,
+			FileType:   fileType,
+			ScanStatus: scanStatus,
+			Upload:     upload,
 		})
 	})
 
@@ -143,8 +215,17 @@ func UploadRoutes(cfg *config.Config, tokenSvc *security.TokenService) chi.Route
 			http.Error(w, "invalid filename", http.StatusBadRequest)
 			return
 		}
-		http.ServeFile(w, r, filepath.Join(cfg.UploadDir, filename))
+
+		url, err := backend.PresignGet(r.Context(), filename, downloadURLTTL)
+		if err != nil {
+			http.Error(w, "could not resolve download url", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, url, http.StatusFound)
 	})
 
+	r.Head("/{id}", handleTusHead(tusSvc))
+	r.Patch("/{id}", handleTusPatch(tusSvc))
+
 	return r
 }
@@ -0,0 +1,70 @@
+package policies_test
+
+import (
+	"context"
+	"testing"
+
+	"backend_go/internal/domain"
+	"backend_go/internal/policies"
+)
+
+// fakeParticipants is a minimal in-memory domain.ParticipantRepository
+// stand-in: Authorize only ever calls GetRole.
+type fakeParticipants struct {
+	domain.ParticipantRepository
+	roles map[int64]domain.ConversationRole
+}
+
+func (f *fakeParticipants) GetRole(ctx context.Context, conversationID, userID int64) (domain.ConversationRole, error) {
+	return f.roles[userID], nil
+}
+
+const conversationID = int64(1)
+
+func TestAuthorize(t *testing.T) {
+	const (
+		owner    int64 = 1
+		admin    int64 = 2
+		member   int64 = 3
+		stranger int64 = 4
+	)
+	authz := policies.NewAuthorizer(&fakeParticipants{roles: map[int64]domain.ConversationRole{
+		owner:  domain.RoleOwner,
+		admin:  domain.RoleAdmin,
+		member: domain.RoleMember,
+	}})
+
+	actions := []policies.Action{
+		policies.ActionReadMessages,
+		policies.ActionMarkRead,
+		policies.ActionSendMessage,
+		policies.ActionDeleteAnyMessage,
+		policies.ActionRenameConversation,
+		policies.ActionManageParticipants,
+		policies.ActionChangeRole,
+		policies.ActionLockConversation,
+	}
+	elevated := map[policies.Action]bool{
+		policies.ActionDeleteAnyMessage:   true,
+		policies.ActionRenameConversation: true,
+		policies.ActionManageParticipants: true,
+		policies.ActionChangeRole:         true,
+		policies.ActionLockConversation:   true,
+	}
+
+	for _, action := range actions {
+		for _, caller := range []int64{owner, admin, member, stranger} {
+			wantAllowed := caller != stranger && (!elevated[action] || caller == owner || caller == admin)
+
+			err := authz.Authorize(context.Background(), caller, conversationID, action)
+			gotAllowed := err == nil
+
+			if gotAllowed != wantAllowed {
+				t.Errorf("action %d, caller %d: Authorize() allowed=%v, want %v", action, caller, gotAllowed, wantAllowed)
+			}
+			if !gotAllowed && err != policies.ErrForbidden {
+				t.Errorf("action %d, caller %d: err = %v, want ErrForbidden", action, caller, err)
+			}
+		}
+	}
+}
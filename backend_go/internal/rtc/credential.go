@@ -0,0 +1,70 @@
+// Package rtc mints TURN REST API credentials and assembles the ICE server
+// list a WebRTC client needs to reach a relay. internal/call used to carry
+// its own copy of this math for the TURN credential it hands back from
+// CreateCall; this package is now the one implementation both it and the
+// standalone /api/rtc/ice-servers endpoint call into.
+package rtc
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// Credential is a short-lived username/password pair for a TURN server,
+// derived per the RFC 5766 "TURN REST API" convention: username is
+// "<expiry-unix-seconds>:<user-id>" and password is
+// base64(HMAC-SHA1(sharedSecret, username)). Any TURN server configured
+// with the same shared secret (e.g. coturn's use-auth-secret) can validate
+// it without a round trip to this service.
+type Credential struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	TTL      int64  `json:"ttl"`
+}
+
+// Mint derives a Credential for userID valid for ttl, signed with secret.
+// ttl <= 0 falls back to one hour.
+func Mint(secret []byte, userID int64, ttl time.Duration) Credential {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	username := fmt.Sprintf("%d:%d", time.Now().Add(ttl).Unix(), userID)
+	mac := hmac.New(sha1.New, secret)
+	mac.Write([]byte(username))
+	password := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return Credential{Username: username, Password: password, TTL: int64(ttl.Seconds())}
+}
+
+// ICEServer is one entry of the "iceServers" array an RTCPeerConnection
+// constructor expects: a STUN entry carries no credentials, a TURN entry
+// carries the Credential minted for the requesting user.
+type ICEServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// BuildICEServers assembles the ICE server list for userID: one entry per
+// configured STUN URI (no auth needed) followed by one TURN entry, carrying
+// a freshly minted Credential, if any TURN URIs are configured. It returns
+// an empty slice, not an error, when neither is configured — callers that
+// require TURN specifically (e.g. call creation) check secret/turnURIs
+// themselves before relying on this.
+func BuildICEServers(secret []byte, stunURIs, turnURIs []string, userID int64, ttl time.Duration) []ICEServer {
+	servers := make([]ICEServer, 0, 2)
+	if len(stunURIs) > 0 {
+		servers = append(servers, ICEServer{URLs: stunURIs})
+	}
+	if len(turnURIs) > 0 {
+		cred := Mint(secret, userID, ttl)
+		servers = append(servers, ICEServer{
+			URLs:       turnURIs,
+			Username:   cred.Username,
+			Credential: cred.Password,
+		})
+	}
+	return servers
+}
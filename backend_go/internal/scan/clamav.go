@@ -0,0 +1,95 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamChunkSize is the maximum number of bytes clamd's INSTREAM command
+// accepts per length-prefixed chunk.
+const clamChunkSize = 64 * 1024
+
+// ClamAVScanner streams a candidate file to a clamd daemon's INSTREAM
+// command over TCP and reports whether it came back clean.
+//
+// See https://docs.clamav.net/manual/Usage/Scanning.html#idsession for the
+// wire protocol: a "zINSTREAM\0" command, followed by 4-byte big-endian
+// length-prefixed chunks, terminated by a zero-length chunk.
+type ClamAVScanner struct {
+	Addr    string
+	Timeout time.Duration
+}
+
+// NewClamAVScanner builds a Scanner that talks to a clamd instance at addr
+// (host:port).
+func NewClamAVScanner(addr string) *ClamAVScanner {
+	return &ClamAVScanner{Addr: addr, Timeout: 30 * time.Second}
+}
+
+var _ Scanner = (*ClamAVScanner)(nil)
+
+func (s *ClamAVScanner) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	dialer := net.Dialer{Timeout: s.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return Result{}, fmt.Errorf("clamd: dial %s: %w", s.Addr, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(s.Timeout))
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return Result{}, fmt.Errorf("clamd: send command: %w", err)
+	}
+
+	buf := make([]byte, clamChunkSize)
+	lenPrefix := make([]byte, 4)
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(lenPrefix, uint32(n))
+			if _, err := conn.Write(lenPrefix); err != nil {
+				return Result{}, fmt.Errorf("clamd: write chunk length: %w", err)
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return Result{}, fmt.Errorf("clamd: write chunk: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return Result{}, fmt.Errorf("clamd: read upload: %w", readErr)
+		}
+	}
+	binary.BigEndian.PutUint32(lenPrefix, 0)
+	if _, err := conn.Write(lenPrefix); err != nil {
+		return Result{}, fmt.Errorf("clamd: write terminating chunk: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString('\x00')
+	if err != nil && err != io.EOF {
+		return Result{}, fmt.Errorf("clamd: read reply: %w", err)
+	}
+	reply = strings.TrimRight(reply, "\x00\r\n")
+
+	if strings.HasSuffix(reply, "OK") {
+		return Result{Clean: true}, nil
+	}
+	if idx := strings.Index(reply, "FOUND"); idx != -1 {
+		sig := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+		sig = strings.TrimSuffix(sig, "FOUND")
+		return Result{Clean: false, Signature: strings.TrimSpace(sig)}, nil
+	}
+	return Result{}, fmt.Errorf("clamd: unexpected reply %q", reply)
+}
@@ -0,0 +1,35 @@
+// Package scan inspects uploaded content for malware before it is made
+// visible to other users.
+package scan
+
+import (
+	"context"
+	"io"
+)
+
+// Result is the outcome of scanning a single object.
+type Result struct {
+	// Clean is false if the scanner identified malicious content.
+	Clean bool
+	// Signature names the matched signature, if Clean is false.
+	Signature string
+}
+
+// Scanner inspects a stream of bytes and reports whether it is safe to
+// serve. Implementations must fully consume r.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+// NoopScanner accepts every upload unscanned. It is the default when no
+// scan backend is configured, e.g. in local development.
+type NoopScanner struct{}
+
+func (NoopScanner) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return Result{}, err
+	}
+	return Result{Clean: true}, nil
+}
+
+var _ Scanner = NoopScanner{}
@@ -0,0 +1,57 @@
+package security
+
+import (
+	"context"
+	"errors"
+
+	"backend_go/internal/domain"
+)
+
+// ErrUnauthenticated is returned by AuthenticateBearer for any failure that
+// should surface to the caller as "not authenticated" (missing/invalid
+// token, 2FA owed, unknown or inactive user) without leaking which one —
+// httpserver.AuthMiddleware and grpcserver's auth interceptor both map it to
+// their transport's "unauthenticated" status.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// AuthenticateBearer validates a raw (no "Bearer " prefix) access token and
+// returns the user it names, applying the same rules AuthMiddleware has
+// always enforced: the token must parse, must not be a pre-auth token (see
+// PreAuthScope) or an OAuth-client token (see IsOAuthClientToken), must name
+// an active user, must have been issued no earlier than the user's last
+// password change, and — if that user has confirmed TOTP enrollment — must
+// carry the "otp" amr entry. It is transport-agnostic so both the HTTP and
+// gRPC front ends share one definition of "logged in".
+func AuthenticateBearer(ctx context.Context, tokens *TokenService, users domain.UserRepository, otps domain.OTPRepository, tokenStr string) (*domain.User, error) {
+	claims, err := tokens.Parse(tokenStr)
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+	if IsPreAuth(claims) || IsOAuthClientToken(claims) {
+		return nil, ErrUnauthenticated
+	}
+
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	user, err := users.GetByUsername(ctx, sub)
+	if err != nil || user == nil || !user.IsActive {
+		return nil, ErrUnauthenticated
+	}
+
+	if iat, ok := claims["iat"].(float64); ok && !user.PasswordChangedAt.IsZero() && int64(iat) < user.PasswordChangedAt.Unix() {
+		return nil, ErrUnauthenticated
+	}
+
+	otp, err := otps.Get(ctx, user.ID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+	if otp != nil && otp.Confirmed && !HasAMR(claims, "otp") {
+		return nil, ErrUnauthenticated
+	}
+
+	return user, nil
+}
@@ -0,0 +1,138 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// attachmentChunkSize is the plaintext frame size EncryptChunked/
+// DecryptChunked operate on, so a large attachment can be streamed through
+// AES-GCM instead of held in memory whole.
+const attachmentChunkSize = 64 * 1024
+
+// EncryptChunked streams src through dek (a fresh key generated for this
+// attachment alone, never reused across blobs), writing dst as a sequence
+// of independently-sealed AES-GCM frames — each a 4-byte big-endian
+// ciphertext length, its own 12-byte nonce, then the sealed frame — so the
+// plaintext never needs to be held whole. It returns the hex-encoded
+// SHA-256 of the plaintext and its length, for content-addressed dedup.
+func EncryptChunked(dek []byte, src io.Reader, dst io.Writer) (sha256Hex string, plainSize int64, err error) {
+	block, err := aes.NewCipher(dek)
+	if err != nil {
+		return "", 0, fmt.Errorf("encrypt chunked: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", 0, fmt.Errorf("encrypt chunked: %w", err)
+	}
+
+	hasher := sha256.New()
+	buf := make([]byte, attachmentChunkSize)
+	var total int64
+
+	for {
+		n, readErr := io.ReadFull(src, buf)
+		if n > 0 {
+			hasher.Write(buf[:n])
+			total += int64(n)
+
+			nonce := make([]byte, aead.NonceSize())
+			if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+				return "", 0, fmt.Errorf("generate chunk nonce: %w", err)
+			}
+			sealed := aead.Seal(nil, nonce, buf[:n], nil)
+
+			var lenPrefix [4]byte
+			binary.BigEndian.PutUint32(lenPrefix[:], uint32(len(sealed)))
+			if _, err := dst.Write(lenPrefix[:]); err != nil {
+				return "", 0, fmt.Errorf("write chunk length: %w", err)
+			}
+			if _, err := dst.Write(nonce); err != nil {
+				return "", 0, fmt.Errorf("write chunk nonce: %w", err)
+			}
+			if _, err := dst.Write(sealed); err != nil {
+				return "", 0, fmt.Errorf("write chunk: %w", err)
+			}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return "", 0, fmt.Errorf("read plaintext: %w", readErr)
+		}
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), total, nil
+}
+
+// DecryptChunked reverses EncryptChunked, writing each frame's verified
+// plaintext to dst as it goes. skipPlaintextBytes discards that many
+// leading plaintext bytes — whole frames where possible, trimming only the
+// frame the cut falls inside — before writing anything, and writeLimit, if
+// >= 0, stops once that many plaintext bytes have been written. Together
+// these let a caller serve an HTTP Range request without buffering the
+// decrypted attachment to satisfy it.
+func DecryptChunked(dek []byte, src io.Reader, dst io.Writer, skipPlaintextBytes, writeLimit int64) error {
+	block, err := aes.NewCipher(dek)
+	if err != nil {
+		return fmt.Errorf("decrypt chunked: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("decrypt chunked: %w", err)
+	}
+
+	nonceSize := aead.NonceSize()
+	var lenPrefix [4]byte
+	var written int64
+
+	for {
+		if writeLimit >= 0 && written >= writeLimit {
+			return nil
+		}
+
+		if _, err := io.ReadFull(src, lenPrefix[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("read chunk length: %w", err)
+		}
+		frameLen := binary.BigEndian.Uint32(lenPrefix[:])
+
+		nonce := make([]byte, nonceSize)
+		if _, err := io.ReadFull(src, nonce); err != nil {
+			return fmt.Errorf("read chunk nonce: %w", err)
+		}
+		sealed := make([]byte, frameLen)
+		if _, err := io.ReadFull(src, sealed); err != nil {
+			return fmt.Errorf("read chunk: %w", err)
+		}
+
+		plain, err := aead.Open(nil, nonce, sealed, nil)
+		if err != nil {
+			return fmt.Errorf("decrypt chunk: %w", err)
+		}
+
+		if skipPlaintextBytes >= int64(len(plain)) {
+			skipPlaintextBytes -= int64(len(plain))
+			continue
+		}
+		if skipPlaintextBytes > 0 {
+			plain = plain[skipPlaintextBytes:]
+			skipPlaintextBytes = 0
+		}
+		if writeLimit >= 0 && written+int64(len(plain)) > writeLimit {
+			plain = plain[:writeLimit-written]
+		}
+		if _, err := dst.Write(plain); err != nil {
+			return fmt.Errorf("write plaintext: %w", err)
+		}
+		written += int64(len(plain))
+	}
+}
@@ -6,39 +6,57 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fernet/fernet-go"
 )
 
-// Encryptor provides symmetric encryption for message content.
-// It uses AES-GCM with a 32-byte key, roughly mirroring the security
-// guarantees of the Python Fernet-based implementation.
+// envelopeVersion is the only format EncryptWithDEK/ParseHeader/
+// DecryptWithDEK understand: version(1) || kek_id(4) || dek_id(8) ||
+// nonce(12) || ciphertext+tag.
+const envelopeVersion = 1
+
+const envelopeHeaderLen = 1 + 4 + 8 + 12
+
+// Encryptor provides envelope encryption for message content: a master
+// key-encryption-key (KEK), derived from the configured secret, wraps a
+// fresh data-encryption-key (DEK) per conversation; the DEK does the actual
+// AES-GCM sealing of message content via EncryptWithDEK/DecryptWithDEK.
+// Encryptor never persists a DEK's plaintext anywhere — that's
+// service.ConversationKeyService's job, using WrapDEK/UnwrapDEK here.
+//
+// Encrypt/Decrypt remain a flat (non-enveloped) AES-GCM path directly under
+// the active KEK, for callers with no per-conversation key of their own
+// (e.g. OTP secrets). Decrypt falls back through any retired KEKs kept
+// around by RotateMasterKey, then the legacy Fernet keys, for ciphertexts
+// that predate this KEK.
 type Encryptor struct {
-	aead       cipher.AEAD
+	mu         sync.RWMutex
+	active     *kek
+	retired    map[uint32]*kek
 	fernetKeys []*fernet.Key
 }
 
+type kek struct {
+	id   uint32
+	aead cipher.AEAD
+}
+
 func NewEncryptor(key []byte, legacyKeys []string) (*Encryptor, error) {
-	// Derive a fixed-size 32-byte key from the provided bytes using SHA-256.
-	// This allows using arbitrary-length secrets (e.g. from existing .env files)
-	// while ensuring AES-256 compatibility.
 	if len(key) == 0 {
 		return nil, errors.New("encryption key must not be empty")
 	}
-	sum := sha256.Sum256(key)
-	k := sum[:]
-	block, err := aes.NewCipher(k)
-	if err != nil {
-		return nil, err
-	}
-	aead, err := cipher.NewGCM(block)
+	active, err := deriveKEK(key)
 	if err != nil {
 		return nil, err
 	}
+
 	fernetKeys := make([]*fernet.Key, 0, len(legacyKeys)+1)
 	if fk := parseFernetKey(string(key)); fk != nil {
 		fernetKeys = append(fernetKeys, fk)
@@ -49,7 +67,24 @@ func NewEncryptor(key []byte, legacyKeys []string) (*Encryptor, error) {
 		}
 	}
 
-	return &Encryptor{aead: aead, fernetKeys: fernetKeys}, nil
+	return &Encryptor{active: active, retired: make(map[uint32]*kek), fernetKeys: fernetKeys}, nil
+}
+
+// deriveKEK turns an arbitrary-length secret (e.g. from an existing .env
+// file) into a fixed-size 32-byte AES-256 key via SHA-256; the hash's first
+// 4 bytes double as the key's id, so a wrapped DEK can always name exactly
+// which KEK wrapped it.
+func deriveKEK(secret []byte) (*kek, error) {
+	sum := sha256.Sum256(secret)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &kek{id: binary.BigEndian.Uint32(sum[:4]), aead: aead}, nil
 }
 
 func parseFernetKey(raw string) *fernet.Key {
@@ -65,34 +100,227 @@ func parseFernetKey(raw string) *fernet.Key {
 	return key
 }
 
+// Encrypt seals plain directly under the active KEK, with no
+// per-conversation DEK involved; used for values that aren't tied to a
+// conversation, such as OTP secrets.
 func (e *Encryptor) Encrypt(plain string) (string, error) {
-	nonce := make([]byte, e.aead.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", err
-	}
-	ciphertext := e.aead.Seal(nonce, nonce, []byte(plain), nil)
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	e.mu.RLock()
+	active := e.active
+	e.mu.RUnlock()
+	return sealAEAD(active.aead, plain)
 }
 
+// Decrypt opens a ciphertext produced by Encrypt: it tries the active KEK,
+// then any retired KEKs kept around by RotateMasterKey, then the legacy
+// Fernet keys, for ciphertexts from before this KEK existed.
 func (e *Encryptor) Decrypt(enc string) (string, error) {
-	raw, err := base64.StdEncoding.DecodeString(enc)
-	if err == nil {
-		if len(raw) < e.aead.NonceSize() {
-			return "", errors.New("ciphertext too short")
-		}
-		nonce := raw[:e.aead.NonceSize()]
-		ciphertext := raw[e.aead.NonceSize():]
-		plain, openErr := e.aead.Open(nil, nonce, ciphertext, nil)
-		if openErr == nil {
-			return string(plain), nil
+	e.mu.RLock()
+	keks := make([]*kek, 0, len(e.retired)+1)
+	keks = append(keks, e.active)
+	for _, k := range e.retired {
+		keks = append(keks, k)
+	}
+	fernetKeys := e.fernetKeys
+	e.mu.RUnlock()
+
+	for _, k := range keks {
+		if plain, err := openAEAD(k.aead, enc); err == nil {
+			return plain, nil
 		}
 	}
 
-	if len(e.fernetKeys) > 0 {
-		if plain := fernet.VerifyAndDecrypt([]byte(enc), 0*time.Second, e.fernetKeys); plain != nil {
+	if len(fernetKeys) > 0 {
+		if plain := fernet.VerifyAndDecrypt([]byte(enc), 0*time.Second, fernetKeys); plain != nil {
 			return string(plain), nil
 		}
 	}
 
 	return "", errors.New("failed to decrypt message payload")
 }
+
+func sealAEAD(aead cipher.AEAD, plain string) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	ciphertext := aead.Seal(nonce, nonce, []byte(plain), nil)
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+func openAEAD(aead cipher.AEAD, enc string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil {
+		return "", err
+	}
+	if len(raw) < aead.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+	nonce := raw[:aead.NonceSize()]
+	ciphertext := raw[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// GenerateDEK creates a fresh random 256-bit data-encryption-key for a
+// conversation.
+func (e *Encryptor) GenerateDEK() ([]byte, error) {
+	dek := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return nil, fmt.Errorf("generate DEK: %w", err)
+	}
+	return dek, nil
+}
+
+// WrapDEK seals dek under the active KEK, for storage in conversation_keys.
+func (e *Encryptor) WrapDEK(dek []byte) (wrapped []byte, kekID uint32, err error) {
+	e.mu.RLock()
+	active := e.active
+	e.mu.RUnlock()
+
+	nonce := make([]byte, active.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, 0, fmt.Errorf("generate DEK wrap nonce: %w", err)
+	}
+	wrapped = active.aead.Seal(nonce, nonce, dek, nil)
+	return wrapped, active.id, nil
+}
+
+// UnwrapDEK opens a wrapped DEK sealed under the KEK identified by kekID —
+// the active KEK, or a retired one kept around since RotateMasterKey.
+func (e *Encryptor) UnwrapDEK(wrapped []byte, kekID uint32) ([]byte, error) {
+	e.mu.RLock()
+	k := e.kekByID(kekID)
+	e.mu.RUnlock()
+	if k == nil {
+		return nil, fmt.Errorf("unwrap DEK: unknown kek_id %d", kekID)
+	}
+
+	if len(wrapped) < k.aead.NonceSize() {
+		return nil, errors.New("wrapped DEK too short")
+	}
+	nonce := wrapped[:k.aead.NonceSize()]
+	ciphertext := wrapped[k.aead.NonceSize():]
+	dek, err := k.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap DEK: %w", err)
+	}
+	return dek, nil
+}
+
+// kekByID must be called with e.mu held.
+func (e *Encryptor) kekByID(id uint32) *kek {
+	if e.active.id == id {
+		return e.active
+	}
+	return e.retired[id]
+}
+
+// RotateMasterKey installs a KEK derived from newKEKSecret as the active
+// key, keeping the previous one around (retired, unwrap-only) so DEKs still
+// wrapped under it keep decrypting. The caller is responsible for
+// re-wrapping every stored DEK under the new key (see
+// service.ConversationKeyService.RotateMasterKey); Encryptor itself keeps
+// every retired KEK in memory for the life of the process regardless of
+// whether anything still references it.
+func (e *Encryptor) RotateMasterKey(newKEKSecret []byte) error {
+	next, err := deriveKEK(newKEKSecret)
+	if err != nil {
+		return fmt.Errorf("rotate master key: %w", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.active.id != next.id {
+		e.retired[e.active.id] = e.active
+	}
+	e.active = next
+	return nil
+}
+
+// EncryptedHeader is the parsed metadata prefix of a ciphertext produced by
+// EncryptWithDEK: which wrapped DEK (and KEK) decrypts it, so the caller
+// can fetch exactly that key instead of trying every conversation's DEK.
+type EncryptedHeader struct {
+	Version uint8
+	KEKID   uint32
+	DEKID   int64
+}
+
+// ParseHeader reads the header off ciphertext produced by EncryptWithDEK.
+func ParseHeader(enc string) (EncryptedHeader, error) {
+	raw, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil {
+		return EncryptedHeader{}, fmt.Errorf("decode envelope: %w", err)
+	}
+	if len(raw) < envelopeHeaderLen {
+		return EncryptedHeader{}, errors.New("envelope header too short")
+	}
+	if raw[0] != envelopeVersion {
+		return EncryptedHeader{}, fmt.Errorf("unsupported envelope version %d", raw[0])
+	}
+	return EncryptedHeader{
+		Version: raw[0],
+		KEKID:   binary.BigEndian.Uint32(raw[1:5]),
+		DEKID:   int64(binary.BigEndian.Uint64(raw[5:13])),
+	}, nil
+}
+
+// EncryptWithDEK seals plain under dek (an already-unwrapped
+// per-conversation key), stamping the envelope header with kekID/dekID so
+// ParseHeader can later find the right key without trial-decrypting
+// against every DEK.
+func EncryptWithDEK(dekID int64, kekID uint32, dek []byte, plain string) (string, error) {
+	block, err := aes.NewCipher(dek)
+	if err != nil {
+		return "", fmt.Errorf("encrypt with DEK: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", fmt.Errorf("encrypt with DEK: %w", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("generate envelope nonce: %w", err)
+	}
+
+	header := make([]byte, 0, envelopeHeaderLen)
+	header = append(header, envelopeVersion)
+	header = binary.BigEndian.AppendUint32(header, kekID)
+	header = binary.BigEndian.AppendUint64(header, uint64(dekID))
+	header = append(header, nonce...)
+
+	sealed := aead.Seal(header, nonce, []byte(plain), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptWithDEK opens ciphertext produced by EncryptWithDEK using dek, the
+// already-unwrapped key the caller looked up via ParseHeader + UnwrapDEK.
+func DecryptWithDEK(dek []byte, enc string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil {
+		return "", fmt.Errorf("decrypt with DEK: %w", err)
+	}
+	if len(raw) < envelopeHeaderLen {
+		return "", errors.New("envelope header too short")
+	}
+
+	block, err := aes.NewCipher(dek)
+	if err != nil {
+		return "", fmt.Errorf("decrypt with DEK: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", fmt.Errorf("decrypt with DEK: %w", err)
+	}
+
+	nonce := raw[13:envelopeHeaderLen]
+	ciphertext := raw[envelopeHeaderLen:]
+	plain, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt with DEK: %w", err)
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,237 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is one key in a KeySet: a public key JWKS can publish, always
+// present, plus the private half needed to sign with it. Private is nil for
+// keys that have been rotated out of the active slot and kept around only
+// to verify tokens minted before the rotation.
+type SigningKey struct {
+	Kid     string
+	Alg     string // "RS256", "ES256", or "EdDSA"
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeySet holds the JWT signing key TokenService currently mints tokens
+// with, plus every key (including the active one) it will accept for
+// verification. This is what lets external services validate zchat-issued
+// tokens against /.well-known/jwks.json without ever holding a signing key.
+type KeySet struct {
+	mu     sync.RWMutex
+	active *SigningKey
+	verify map[string]*SigningKey
+}
+
+// NewKeySet builds a KeySet whose active key is active, additionally
+// accepting verifyOnly for validation (e.g. a key rotated out previously but
+// still within its grace window).
+func NewKeySet(active *SigningKey, verifyOnly ...*SigningKey) *KeySet {
+	ks := &KeySet{
+		active: active,
+		verify: map[string]*SigningKey{active.Kid: active},
+	}
+	for _, k := range verifyOnly {
+		ks.verify[k.Kid] = k
+	}
+	return ks
+}
+
+// Active returns the key TokenService signs new tokens with.
+func (ks *KeySet) Active() *SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.active
+}
+
+// Lookup returns the key registered under kid, for verifying a token's
+// signature.
+func (ks *KeySet) Lookup(kid string) (*SigningKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	k, ok := ks.verify[kid]
+	return k, ok
+}
+
+// Rotate promotes next to active and demotes the previous active key to
+// verify-only (its private half is discarded, so only next can sign from
+// here on). The old key stays in Verify for grace so tokens minted under it
+// keep validating through a deploy; once grace elapses it is dropped
+// entirely. grace <= 0 keeps the old key around indefinitely.
+func (ks *KeySet) Rotate(next *SigningKey, grace time.Duration) {
+	ks.mu.Lock()
+	prevKid := ks.active.Kid
+	ks.verify[prevKid] = &SigningKey{Kid: prevKid, Alg: ks.active.Alg, Public: ks.active.Public}
+	ks.active = next
+	ks.verify[next.Kid] = next
+	ks.mu.Unlock()
+
+	if grace > 0 {
+		time.AfterFunc(grace, func() {
+			ks.mu.Lock()
+			delete(ks.verify, prevKid)
+			ks.mu.Unlock()
+		})
+	}
+}
+
+// JWKS renders every key's public half in standard JWK Set form
+// (RFC 7517/7518), for GET /.well-known/jwks.json.
+func (ks *KeySet) JWKS() (JWKSet, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	keys := make([]JWK, 0, len(ks.verify))
+	for _, k := range ks.verify {
+		jwk, err := toJWK(k)
+		if err != nil {
+			return JWKSet{}, err
+		}
+		keys = append(keys, jwk)
+	}
+	return JWKSet{Keys: keys}, nil
+}
+
+// JWK is one entry of a JSON Web Key Set.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet is the top-level document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func toJWK(k *SigningKey) (JWK, error) {
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA", Use: "sig", Kid: k.Kid, Alg: k.Alg,
+			N: base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		return JWK{
+			Kty: "EC", Use: "sig", Kid: k.Kid, Alg: k.Alg, Crv: "P-256",
+			X: base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size))),
+			Y: base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size))),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP", Use: "sig", Kid: k.Kid, Alg: k.Alg, Crv: "Ed25519",
+			X: base64.RawURLEncoding.EncodeToString(pub),
+		}, nil
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T", k.Public)
+	}
+}
+
+func signingMethod(alg string) (jwt.SigningMethod, error) {
+	switch alg {
+	case "RS256":
+		return jwt.SigningMethodRS256, nil
+	case "ES256":
+		return jwt.SigningMethodES256, nil
+	case "EdDSA":
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported JWT signing algorithm %q", alg)
+	}
+}
+
+// GenerateSigningKey creates a fresh key pair for alg ("RS256", "ES256", or
+// "EdDSA"), identified by kid.
+func GenerateSigningKey(kid, alg string) (*SigningKey, error) {
+	switch alg {
+	case "RS256":
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, fmt.Errorf("generate RSA key: %w", err)
+		}
+		return &SigningKey{Kid: kid, Alg: alg, Private: priv, Public: &priv.PublicKey}, nil
+	case "ES256":
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generate EC key: %w", err)
+		}
+		return &SigningKey{Kid: kid, Alg: alg, Private: priv, Public: &priv.PublicKey}, nil
+	case "EdDSA":
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generate Ed25519 key: %w", err)
+		}
+		return &SigningKey{Kid: kid, Alg: alg, Private: priv, Public: pub}, nil
+	default:
+		return nil, fmt.Errorf("unsupported JWT signing algorithm %q", alg)
+	}
+}
+
+// ParseSigningKeyPEM loads a PKCS8-encoded private key (RSA, EC, or Ed25519)
+// from PEM bytes and pairs it with its public half.
+func ParseSigningKeyPEM(kid, alg string, pemBytes []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("invalid PEM block")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse PKCS8 private key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("key type %T does not support signing", key)
+	}
+	return &SigningKey{Kid: kid, Alg: alg, Private: signer, Public: signer.Public()}, nil
+}
+
+// LoadOrGenerateKeySet loads the active signing key from a PKCS8 PEM file at
+// path, or generates a fresh one of the given algorithm if path is empty.
+// A freshly generated key does not survive a restart, which is fine for
+// development but means every deploy rotates it; production should set
+// path so the same key (and kid) persists across restarts.
+func LoadOrGenerateKeySet(alg, kid, path string) (*KeySet, error) {
+	if path == "" {
+		active, err := GenerateSigningKey(kid, alg)
+		if err != nil {
+			return nil, err
+		}
+		return NewKeySet(active), nil
+	}
+
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read JWT private key file: %w", err)
+	}
+	active, err := ParseSigningKeyPEM(kid, alg, pemBytes)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(active), nil
+}
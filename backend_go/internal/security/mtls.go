@@ -0,0 +1,142 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// ServiceIdentity is the zchat agent identity embedded in a client
+// certificate's Common Name, extracted from a verified peer certificate by
+// httpserver's mTLS auth path.
+type ServiceIdentity struct {
+	// Username matches a domain.User row with IsServiceAccount = true.
+	Username string
+	// SerialHex is the certificate's serial number in lowercase hex, the key
+	// CertRevocationRepository checks revocation against.
+	SerialHex string
+}
+
+// IdentityFromCert extracts the service identity zchat embeds in a client
+// certificate: its Common Name (the agent's username, the identity claim
+// SignAgentCert wrote) and its serial number.
+func IdentityFromCert(cert *x509.Certificate) ServiceIdentity {
+	return ServiceIdentity{
+		Username:  cert.Subject.CommonName,
+		SerialHex: fmt.Sprintf("%x", cert.SerialNumber),
+	}
+}
+
+// GenerateCA creates a self-signed CA certificate and key pair, used to sign
+// per-agent client certificates with SignAgentCert.
+func GenerateCA(commonName string, validity time.Duration) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate CA key: %w", err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create CA certificate: %w", err)
+	}
+	return encodeCertAndKey(der, key)
+}
+
+// SignAgentCert signs a client certificate for agentUsername, embedding it
+// as the certificate's Common Name so IdentityFromCert can read it back out
+// of every request that presents this certificate. caCertPEM/caKeyPEM are
+// the CA material GenerateCA produced.
+func SignAgentCert(caCertPEM, caKeyPEM []byte, agentUsername string, validity time.Duration) (certPEM, keyPEM []byte, serialHex string, err error) {
+	caCert, caKey, err := parseCA(caCertPEM, caKeyPEM)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("generate agent key: %w", err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: agentUsername},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("sign agent certificate: %w", err)
+	}
+	certPEM, keyPEM, err = encodeCertAndKey(der, key)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return certPEM, keyPEM, fmt.Sprintf("%x", serial), nil
+}
+
+func randomSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("generate certificate serial: %w", err)
+	}
+	return serial, nil
+}
+
+func encodeCertAndKey(der []byte, key *ecdsa.PrivateKey) (certPEM, keyPEM []byte, err error) {
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshal private key: %w", err)
+	}
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+func parseCA(certPEM, keyPEM []byte) (*x509.Certificate, crypto.Signer, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, nil, errors.New("invalid CA certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse CA certificate: %w", err)
+	}
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, nil, errors.New("invalid CA key PEM")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse CA key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, errors.New("CA key does not support signing")
+	}
+	return cert, signer, nil
+}
@@ -1,28 +1,135 @@
-package security
-
-import "golang.org/x/crypto/bcrypt"
-
-// PasswordHasher wraps bcrypt hashing and verification.
-type PasswordHasher struct {
-	cost int
-}
-
-func NewPasswordHasher(cost int) *PasswordHasher {
-	if cost == 0 {
-		cost = bcrypt.DefaultCost
-	}
-	return &PasswordHasher{cost: cost}
-}
-
-func (h *PasswordHasher) Hash(plain string) (string, error) {
-	b, err := bcrypt.GenerateFromPassword([]byte(plain), h.cost)
-	if err != nil {
-		return "", err
-	}
-	return string(b), nil
-}
-
-func (h *PasswordHasher) Verify(plain, hashed string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(plain))
-}
-
+package security
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Argon2Params configures the argon2id parameters PasswordWrapper hashes
+// new passwords with. The zero value is replaced by argon2DefaultParams.
+type Argon2Params struct {
+	Time      uint32 // number of passes over memory
+	MemoryKiB uint32 // memory cost in KiB
+	Threads   uint8
+	SaltLen   uint32
+	KeyLen    uint32
+}
+
+// argon2DefaultParams matches the parameters OWASP recommends as a
+// reasonable minimum for argon2id: 2 passes, 64MB, 4 threads, a 16-byte
+// salt, and a 32-byte derived key.
+var argon2DefaultParams = Argon2Params{
+	Time:      2,
+	MemoryKiB: 64 * 1024,
+	Threads:   4,
+	SaltLen:   16,
+	KeyLen:    32,
+}
+
+// PasswordWrapper hashes new passwords with argon2id and verifies existing
+// ones against whichever algorithm produced them, recognized by prefix:
+// "$2a$"/"$2b$"/"$2y$" is bcrypt (zchat's hash format before this wrapper),
+// "$argon2id$" is argon2id. This lets a deployment move off bcrypt (or onto
+// stronger argon2id parameters) without forcing every user to reset their
+// password: Verify reports needsRehash whenever the stored hash isn't what
+// Hash would produce today, and the caller — AuthService.Login — re-hashes
+// and persists it once it has the plaintext in hand.
+type PasswordWrapper struct {
+	params Argon2Params
+}
+
+// NewPasswordWrapper returns a PasswordWrapper. A zero-value params uses
+// argon2DefaultParams.
+func NewPasswordWrapper(params Argon2Params) *PasswordWrapper {
+	if params == (Argon2Params{}) {
+		params = argon2DefaultParams
+	}
+	return &PasswordWrapper{params: params}
+}
+
+// Hash produces an argon2id hash of plain using the wrapper's configured
+// parameters, PHC-formatted as "$argon2id$v=...$m=...,t=...,p=...$salt$key".
+func (h *PasswordWrapper) Hash(plain string) (string, error) {
+	salt := make([]byte, h.params.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(plain), salt, h.params.Time, h.params.MemoryKiB, h.params.Threads, h.params.KeyLen)
+	return encodeArgon2id(h.params, salt, key), nil
+}
+
+// Verify reports whether plain matches hashed, and whether hashed should be
+// re-hashed: true if it was produced by a weaker algorithm (bcrypt) or by
+// argon2id with parameters other than the wrapper's current ones.
+func (h *PasswordWrapper) Verify(plain, hashed string) (needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(hashed, "$2a$"), strings.HasPrefix(hashed, "$2b$"), strings.HasPrefix(hashed, "$2y$"):
+		if err := bcrypt.CompareHashAndPassword([]byte(hashed), []byte(plain)); err != nil {
+			return false, err
+		}
+		return true, nil
+
+	case strings.HasPrefix(hashed, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(hashed)
+		if err != nil {
+			return false, err
+		}
+		candidate := argon2.IDKey([]byte(plain), salt, params.Time, params.MemoryKiB, params.Threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(candidate, key) != 1 {
+			return false, errors.New("password mismatch")
+		}
+		return params != h.params, nil
+
+	default:
+		return false, fmt.Errorf("unrecognized password hash format")
+	}
+}
+
+func encodeArgon2id(p Argon2Params, salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.MemoryKiB, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key))
+}
+
+func decodeArgon2id(hashed string) (Argon2Params, []byte, []byte, error) {
+	// hashed is "$argon2id$v=..$m=..,t=..,p=..$salt$key"; splitting on '$'
+	// yields ["", "argon2id", "v=..", "m=..,t=..,p=..", salt, key].
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, errors.New("malformed argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("parse argon2id version: %w", err)
+	}
+	if version != argon2.Version {
+		return Argon2Params{}, nil, nil, fmt.Errorf("unsupported argon2id version %d", version)
+	}
+
+	var p Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.MemoryKiB, &p.Time, &p.Threads); err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("parse argon2id params: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("decode argon2id salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("decode argon2id key: %w", err)
+	}
+	p.SaltLen = uint32(len(salt))
+	p.KeyLen = uint32(len(key))
+
+	return p, salt, key, nil
+}
@@ -6,43 +6,175 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// TokenService wraps JWT creation and validation.
+// PreAuthScope marks a token minted mid-login, after password verification
+// but before a required second factor has been presented. Such tokens carry
+// only the "pwd" amr entry and must never be accepted by AuthMiddleware.
+const PreAuthScope = "otp_preauth"
+
+// TokenService wraps JWT creation and validation. It signs with the active
+// key of a KeySet (RS256, ES256, or EdDSA) and verifies by looking the
+// token's kid header up in that same KeySet, so a rotation never invalidates
+// tokens minted under the previous key until its grace window passes.
 type TokenService struct {
-	secret    []byte
+	keys      *KeySet
 	expiresIn time.Duration
 }
 
-func NewTokenService(secret string, expiresIn time.Duration) *TokenService {
+func NewTokenService(keys *KeySet, expiresIn time.Duration) *TokenService {
 	return &TokenService{
-		secret:    []byte(secret),
+		keys:      keys,
 		expiresIn: expiresIn,
 	}
 }
 
+// KeySet returns the underlying key set, for publishing at
+// /.well-known/jwks.json.
+func (t *TokenService) KeySet() *KeySet {
+	return t.keys
+}
+
 // CreateForUser creates a JWT for the given username using the default TTL.
 func (t *TokenService) CreateForUser(username string) (string, error) {
 	return t.CreateWithTTL(username, t.expiresIn)
 }
 
 // CreateWithTTL creates a JWT for the given username with an explicit TTL.
+// The resulting token carries a single "pwd" amr entry.
 func (t *TokenService) CreateWithTTL(username string, ttl time.Duration) (string, error) {
+	return t.CreateWithAMR(username, ttl, []string{"pwd"}, nil)
+}
+
+// CreateWithAMR creates a JWT for the given username with an explicit TTL and
+// amr (Authentication Methods References) claim, e.g. ["pwd","otp"] once a
+// user has completed two-factor login. roles, if non-empty, is carried as
+// the "roles" claim (e.g. the caller's domain.GlobalRole) — advisory only,
+// since AuthMiddleware re-fetches the user from the database on every
+// request rather than trusting it, so a role change never waits for the
+// token to expire.
+func (t *TokenService) CreateWithAMR(username string, ttl time.Duration, amr []string, roles []string) (string, error) {
 	now := time.Now()
 	claims := jwt.MapClaims{
 		"sub": username,
 		"iat": now.Unix(),
 		"exp": now.Add(ttl).Unix(),
+		"amr": amr,
+	}
+	if len(roles) > 0 {
+		claims["roles"] = roles
+	}
+	return t.sign(claims)
+}
+
+// CreatePreAuth creates a short-lived token for a user who has passed
+// password verification but still owes a TOTP code. It is only ever accepted
+// by the /auth/otp/verify endpoint, never by AuthMiddleware.
+func (t *TokenService) CreatePreAuth(username string, ttl time.Duration, rememberMe bool) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":         username,
+		"iat":         now.Unix(),
+		"exp":         now.Add(ttl).Unix(),
+		"amr":         []string{"pwd"},
+		"scope":       PreAuthScope,
+		"remember_me": rememberMe,
+	}
+	return t.sign(claims)
+}
+
+// CreateWithExtra creates a JWT for subject with an explicit TTL, merging
+// extra into the claim set on top of the usual sub/iat/exp — e.g. "aud" and
+// "scope" for a token internal/authserver mints on behalf of a third-party
+// OAuth client. Callers must not set sub, iat, or exp in extra; those are
+// always derived from subject and ttl.
+func (t *TokenService) CreateWithExtra(subject string, ttl time.Duration, extra jwt.MapClaims) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub": subject,
+		"iat": now.Unix(),
+		"exp": now.Add(ttl).Unix(),
+	}
+	for k, v := range extra {
+		claims[k] = v
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(t.secret)
+	return t.sign(claims)
 }
 
-// Parse validates a token and returns its claims.
+// sign mints a token with the KeySet's active key, stamping its kid into the
+// header so Parse (here or in another service sharing the same JWKS) knows
+// which key to verify against.
+func (t *TokenService) sign(claims jwt.MapClaims) (string, error) {
+	active := t.keys.Active()
+	method, err := signingMethod(active.Alg)
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = active.Kid
+	return token.SignedString(active.Private)
+}
+
+// HasAMR reports whether claims carries method in its amr array.
+func HasAMR(claims jwt.MapClaims, method string) bool {
+	raw, ok := claims["amr"]
+	if !ok {
+		return false
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return false
+	}
+	for _, item := range list {
+		if s, ok := item.(string); ok && s == method {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPreAuth reports whether claims belong to a pre-auth (password-only, 2FA
+// still owed) token.
+func IsPreAuth(claims jwt.MapClaims) bool {
+	scope, _ := claims["scope"].(string)
+	return scope == PreAuthScope
+}
+
+// IsOAuthClientToken reports whether claims belong to an access token minted
+// by internal/authserver for a third-party OAuth client rather than a zchat
+// login session. Such tokens carry the "oauth" amr entry and must never be
+// accepted by AuthMiddleware or the gRPC interceptor: they are scoped to
+// whatever the client requested (e.g. openid/profile for the OIDC UserInfo
+// endpoint), not to full account access.
+func IsOAuthClientToken(claims jwt.MapClaims) bool {
+	return HasAMR(claims, "oauth")
+}
+
+// Parse validates a token and returns its claims. The verification key is
+// selected by the token's kid header, so tokens minted under a since-rotated
+// key still validate as long as it's within its grace window.
 func (t *TokenService) Parse(tokenStr string) (jwt.MapClaims, error) {
 	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := t.keys.Lookup(kid)
+		if !ok {
+			return nil, jwt.ErrTokenUnverifiable
+		}
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA:
+			if key.Alg != "RS256" {
+				return nil, jwt.ErrSignatureInvalid
+			}
+		case *jwt.SigningMethodECDSA:
+			if key.Alg != "ES256" {
+				return nil, jwt.ErrSignatureInvalid
+			}
+		case *jwt.SigningMethodEd25519:
+			if key.Alg != "EdDSA" {
+				return nil, jwt.ErrSignatureInvalid
+			}
+		default:
 			return nil, jwt.ErrSignatureInvalid
 		}
-		return t.secret, nil
+		return key.Public, nil
 	})
 	if err != nil {
 		return nil, err
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"backend_go/internal/domain"
+)
+
+// AdminService implements the operations behind httpserver's /admin API:
+// listing and deactivating users, changing a user's site-wide GlobalRole,
+// and manually triggering a prune sweep across every conversation.
+type AdminService struct {
+	users         domain.UserRepository
+	conversations domain.ConversationRepository
+	messages      domain.MessageRepository
+}
+
+func NewAdminService(users domain.UserRepository, conversations domain.ConversationRepository, messages domain.MessageRepository) *AdminService {
+	return &AdminService{users: users, conversations: conversations, messages: messages}
+}
+
+// ListUsers returns a page of active users, oldest first.
+func (s *AdminService) ListUsers(ctx context.Context, offset, limit int) ([]*domain.User, error) {
+	return s.users.ListActive(ctx, offset, limit)
+}
+
+// Deactivate soft-deletes userID.
+func (s *AdminService) Deactivate(ctx context.Context, userID int64) error {
+	return s.users.SoftDelete(ctx, userID)
+}
+
+// SetRole assigns userID's site-wide GlobalRole.
+func (s *AdminService) SetRole(ctx context.Context, userID int64, role domain.GlobalRole) error {
+	return s.users.SetRole(ctx, userID, role)
+}
+
+// RemoveRole reverts userID to the default GlobalRoleUser, but only if they
+// currently hold role — this lets the DELETE endpoint report a clear error
+// instead of silently doing nothing when the caller's view of the user's
+// role is stale.
+func (s *AdminService) RemoveRole(ctx context.Context, userID int64, role domain.GlobalRole) error {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+	if user == nil {
+		return domain.ErrNotFound
+	}
+	if user.Role != role {
+		return fmt.Errorf("user does not hold role %q", role)
+	}
+	return s.users.SetRole(ctx, userID, domain.GlobalRoleUser)
+}
+
+// PruneResult summarizes a manual prune sweep.
+type PruneResult struct {
+	ConversationsSwept int `json:"conversations_swept"`
+	MessagesPruned     int `json:"messages_pruned"`
+}
+
+// PruneAll applies MessageRepo.PruneOld's count-based cap to every
+// conversation, the on-demand counterpart to postgres.RetentionWorker's
+// timer-driven sweep.
+func (s *AdminService) PruneAll(ctx context.Context, keepLastN int) (*PruneResult, error) {
+	ids, err := s.conversations.ListAllIDs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list conversations: %w", err)
+	}
+
+	result := &PruneResult{ConversationsSwept: len(ids)}
+	for _, id := range ids {
+		pruned, err := s.messages.PruneOld(ctx, id, keepLastN)
+		if err != nil {
+			return nil, fmt.Errorf("prune conversation %d: %w", id, err)
+		}
+		result.MessagesPruned += len(pruned)
+	}
+	return result, nil
+}
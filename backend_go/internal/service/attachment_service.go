@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"backend_go/internal/domain"
+	"backend_go/internal/security"
+	"backend_go/internal/storage"
+)
+
+// ErrAttachmentNotEncrypted is returned by OpenDecrypted for an attachment
+// uploaded before encryption existed (no WrappedKey); the caller should
+// fall back to DownloadURL's presigned redirect instead.
+var ErrAttachmentNotEncrypted = errors.New("attachment is not encrypted")
+
+// AttachmentService manages upload metadata and mints presigned URLs for the
+// configured storage backend.
+type AttachmentService struct {
+	attachments domain.AttachmentRepository
+	backend     storage.Backend
+	encryptor   *security.Encryptor
+	getTTL      time.Duration
+}
+
+func NewAttachmentService(attachments domain.AttachmentRepository, backend storage.Backend, encryptor *security.Encryptor, getTTL time.Duration) *AttachmentService {
+	if getTTL <= 0 {
+		getTTL = 15 * time.Minute
+	}
+	return &AttachmentService{attachments: attachments, backend: backend, encryptor: encryptor, getTTL: getTTL}
+}
+
+type PresignUploadInput struct {
+	ContentType string
+	Size        int64
+}
+
+type PresignUploadResult struct {
+	AttachmentID int64                   `json:"attachment_id"`
+	Key          string                  `json:"key"`
+	Upload       storage.PresignedUpload `json:"upload"`
+}
+
+// PresignUpload mints a direct-upload URL and records a pending attachment
+// row owned by the caller. The object key embeds the attachment's eventual
+// owner so the storage layer never needs to know about users.
+func (s *AttachmentService) PresignUpload(ctx context.Context, ownerID int64, in PresignUploadInput) (*PresignUploadResult, error) {
+	if in.ContentType == "" {
+		return nil, fmt.Errorf("content type is required")
+	}
+	if in.Size <= 0 {
+		return nil, fmt.Errorf("size must be positive")
+	}
+
+	key := fmt.Sprintf("attachments/%d/%s", ownerID, uuid.New().String())
+
+	upload, err := s.backend.PresignPut(ctx, key, in.ContentType, in.Size)
+	if err != nil {
+		return nil, fmt.Errorf("presign upload: %w", err)
+	}
+
+	att := &domain.Attachment{
+		OwnerID:     ownerID,
+		Key:         key,
+		ContentType: in.ContentType,
+		Size:        in.Size,
+	}
+	if err := s.attachments.Create(ctx, att); err != nil {
+		return nil, fmt.Errorf("create attachment: %w", err)
+	}
+
+	return &PresignUploadResult{
+		AttachmentID: att.ID,
+		Key:          key,
+		Upload:       upload,
+	}, nil
+}
+
+// DownloadURL returns a short-lived presigned GET URL for an attachment.
+func (s *AttachmentService) DownloadURL(ctx context.Context, id int64) (string, error) {
+	att, err := s.attachments.GetByID(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("get attachment: %w", err)
+	}
+	if att == nil {
+		return "", domain.ErrNotFound
+	}
+	return s.backend.PresignGet(ctx, att.Key, s.getTTL)
+}
+
+// Upload streams r through a fresh per-attachment key, encrypting it into
+// 64 KiB AES-GCM frames as it goes (see security.EncryptChunked) so the
+// plaintext is never buffered whole, then content-addresses the result by
+// the plaintext's SHA-256: a second upload of the same bytes, by anyone,
+// returns the existing attachment instead of storing a duplicate blob.
+func (s *AttachmentService) Upload(ctx context.Context, ownerID int64, contentType string, r io.Reader) (*domain.Attachment, error) {
+	dek, err := s.encryptor.GenerateDEK()
+	if err != nil {
+		return nil, fmt.Errorf("generate attachment key: %w", err)
+	}
+
+	tmp, err := os.CreateTemp("", "attachment-upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("create upload scratch file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	sum, plainSize, err := security.EncryptChunked(dek, r, tmp)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt attachment: %w", err)
+	}
+
+	if existing, err := s.attachments.FindBySHA256(ctx, sum); err != nil {
+		return nil, fmt.Errorf("check existing attachment: %w", err)
+	} else if existing != nil {
+		return existing, nil
+	}
+
+	encryptedSize, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("measure encrypted attachment: %w", err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("rewind encrypted attachment: %w", err)
+	}
+
+	wrappedKey, kekID, err := s.encryptor.WrapDEK(dek)
+	if err != nil {
+		return nil, fmt.Errorf("wrap attachment key: %w", err)
+	}
+
+	key := fmt.Sprintf("attachments/sha256/%s", sum)
+	if err := s.backend.Put(ctx, key, "application/octet-stream", encryptedSize, tmp); err != nil {
+		return nil, fmt.Errorf("store attachment: %w", err)
+	}
+
+	att := &domain.Attachment{
+		OwnerID:     ownerID,
+		Key:         key,
+		ContentType: contentType,
+		Size:        plainSize,
+		SHA256:      sum,
+		WrappedKey:  wrappedKey,
+		KEKID:       kekID,
+	}
+	if err := s.attachments.Create(ctx, att); err != nil {
+		return nil, fmt.Errorf("create attachment: %w", err)
+	}
+	return att, nil
+}
+
+// OpenDecrypted streams the decrypted bytes of attachment id, restricted to
+// the plaintext range [start, end] inclusive (end < 0 means "through the
+// end of the file"), for a handler to serve an HTTP Range request without
+// ever writing the whole decrypted blob to disk. It returns
+// ErrAttachmentNotEncrypted for an attachment uploaded before Upload
+// existed, so the caller can fall back to DownloadURL.
+func (s *AttachmentService) OpenDecrypted(ctx context.Context, id int64, start, end int64) (*domain.Attachment, io.ReadCloser, error) {
+	att, err := s.attachments.GetByID(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get attachment: %w", err)
+	}
+	if att == nil {
+		return nil, nil, domain.ErrNotFound
+	}
+	if len(att.WrappedKey) == 0 {
+		return nil, nil, ErrAttachmentNotEncrypted
+	}
+
+	dek, err := s.encryptor.UnwrapDEK(att.WrappedKey, att.KEKID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unwrap attachment key: %w", err)
+	}
+
+	src, err := s.backend.Get(ctx, att.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open attachment: %w", err)
+	}
+
+	limit := int64(-1)
+	if end >= 0 {
+		limit = end - start + 1
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		decryptErr := security.DecryptChunked(dek, src, pw, start, limit)
+		src.Close()
+		pw.CloseWithError(decryptErr)
+	}()
+
+	return att, pr, nil
+}
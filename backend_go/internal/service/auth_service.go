@@ -1,183 +1,534 @@
-package service
-
-import (
-	"context"
-	"errors"
-	"fmt"
-	"regexp"
-	"strings"
-	"time"
-	"unicode"
-
-	"backend_go/internal/domain"
-	"backend_go/internal/security"
-)
-
-var (
-	usernameRe = regexp.MustCompile(`^[a-z0-9_-]+$`)
-	specialRe  = regexp.MustCompile(`[!@#$%^&*()\,\.?":{}|<>]`)
-)
-
-// AuthService handles registration, login, and logout.
-type AuthService struct {
-	users         domain.UserRepository
-	tokens        *security.TokenService
-	hash          *security.PasswordHasher
-	defaultTTL    time.Duration
-	rememberMeTTL time.Duration
-}
-
-func NewAuthService(
-	users domain.UserRepository,
-	tokens *security.TokenService,
-	hash *security.PasswordHasher,
-	defaultTTL time.Duration,
-	rememberMeTTL time.Duration,
-) *AuthService {
-	return &AuthService{
-		users:         users,
-		tokens:        tokens,
-		hash:          hash,
-		defaultTTL:    defaultTTL,
-		rememberMeTTL: rememberMeTTL,
-	}
-}
-
-type RegisterInput struct {
-	Username string
-	Email    *string
-	Password string
-}
-
-type LoginInput struct {
-	Username   string
-	Password   string
-	RememberMe bool
-}
-
-type TokenResponse struct {
-	AccessToken string
-	TokenType   string
-	User        *domain.User
-}
-
-func validateUsername(username string) error {
-	username = strings.ToLower(username)
-	if len(username) < 3 || len(username) > 50 {
-		return errors.New("username must be 3–50 characters")
-	}
-	if !usernameRe.MatchString(username) {
-		return errors.New("username may only contain letters, digits, underscores and hyphens")
-	}
-	return nil
-}
-
-func validatePassword(password string) error {
-	if len(password) < 10 {
-		return errors.New("password must be at least 10 characters")
-	}
-	var hasUpper, hasLower, hasDigit bool
-	for _, ch := range password {
-		switch {
-		case unicode.IsUpper(ch):
-			hasUpper = true
-		case unicode.IsLower(ch):
-			hasLower = true
-		case unicode.IsDigit(ch):
-			hasDigit = true
-		}
-	}
-	if !hasUpper {
-		return errors.New("password must contain at least one uppercase letter")
-	}
-	if !hasLower {
-		return errors.New("password must contain at least one lowercase letter")
-	}
-	if !hasDigit {
-		return errors.New("password must contain at least one digit")
-	}
-	if !specialRe.MatchString(password) {
-		return errors.New(`password must contain at least one special character (!@#$%^&*()\,\.?":{}|<>)`)
-	}
-	return nil
-}
-
-func (s *AuthService) Register(ctx context.Context, in RegisterInput) (*domain.User, error) {
-	// Normalise and validate
-	in.Username = strings.ToLower(strings.TrimSpace(in.Username))
-	if err := validateUsername(in.Username); err != nil {
-		return nil, err
-	}
-	if err := validatePassword(in.Password); err != nil {
-		return nil, err
-	}
-
-	if existing, err := s.users.GetByUsername(ctx, in.Username); err != nil {
-		return nil, fmt.Errorf("check username: %w", err)
-	} else if existing != nil {
-		return nil, errors.New("username already registered")
-	}
-
-	if in.Email != nil && *in.Email != "" {
-		if existing, err := s.users.GetByEmail(ctx, *in.Email); err != nil {
-			return nil, fmt.Errorf("check email: %w", err)
-		} else if existing != nil {
-			return nil, errors.New("email already registered")
-		}
-	}
-
-	hashed, err := s.hash.Hash(in.Password)
-	if err != nil {
-		return nil, fmt.Errorf("hash password: %w", err)
-	}
-
-	user := &domain.User{
-		Username:       in.Username,
-		Email:          in.Email,
-		HashedPassword: hashed,
-		IsActive:       true,
-		IsOnline:       false,
-	}
-	if err := s.users.Create(ctx, user); err != nil {
-		return nil, err
-	}
-	return user, nil
-}
-
-func (s *AuthService) Login(ctx context.Context, in LoginInput) (*TokenResponse, error) {
-	user, err := s.users.GetByUsername(ctx, strings.ToLower(in.Username))
-	if err != nil {
-		return nil, fmt.Errorf("get user: %w", err)
-	}
-	if user == nil {
-		return nil, errors.New("incorrect username or password")
-	}
-	if !user.IsActive {
-		return nil, errors.New("user account is inactive")
-	}
-	if err := s.hash.Verify(in.Password, user.HashedPassword); err != nil {
-		return nil, errors.New("incorrect username or password")
-	}
-	if err := s.users.SetOnlineStatus(ctx, user.ID, true); err != nil {
-		return nil, fmt.Errorf("set online: %w", err)
-	}
-
-	ttl := s.defaultTTL
-	if in.RememberMe {
-		ttl = s.rememberMeTTL
-	}
-	token, err := s.tokens.CreateWithTTL(user.Username, ttl)
-	if err != nil {
-		return nil, fmt.Errorf("create token: %w", err)
-	}
-
-	return &TokenResponse{
-		AccessToken: token,
-		TokenType:   "bearer",
-		User:        user,
-	}, nil
-}
-
-func (s *AuthService) Logout(ctx context.Context, userID int64) error {
-	return s.users.SetOnlineStatus(ctx, userID, false)
-}
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"backend_go/internal/domain"
+	"backend_go/internal/security"
+)
+
+var (
+	usernameRe = regexp.MustCompile(`^[a-z0-9_-]+$`)
+	specialRe  = regexp.MustCompile(`[!@#$%^&*()\,\.?":{}|<>]`)
+)
+
+// otpPreAuthTTL bounds how long a pre-auth token (password verified, TOTP
+// code still owed) remains exchangeable at /auth/otp/verify.
+const otpPreAuthTTL = 5 * time.Minute
+
+// emailVerificationTTL and passwordResetTTL bound how long their respective
+// VerificationToken rows stay redeemable.
+const (
+	emailVerificationTTL = 24 * time.Hour
+	passwordResetTTL     = 1 * time.Hour
+)
+
+// refreshTokenTTL and refreshTokenRememberMeTTL bound how long a refresh
+// token minted by Login stays exchangeable at /auth/refresh, mirroring the
+// RememberMe split Login already applies to the access token's own TTL.
+const (
+	refreshTokenTTL           = 8 * time.Hour
+	refreshTokenRememberMeTTL = 30 * 24 * time.Hour
+)
+
+// AuthService handles registration, login, logout, and the email
+// verification / password reset flows.
+type AuthService struct {
+	users                    domain.UserRepository
+	otps                     domain.OTPRepository
+	verificationTokens       domain.VerificationTokenRepository
+	refreshTokens            domain.RefreshTokenRepository
+	tokens                   *security.TokenService
+	hash                     *security.PasswordWrapper
+	events                   domain.EventPublisher
+	emailer                  domain.Emailer
+	defaultTTL               time.Duration
+	rememberMeTTL            time.Duration
+	requireEmailVerification bool
+}
+
+func NewAuthService(
+	users domain.UserRepository,
+	otps domain.OTPRepository,
+	verificationTokens domain.VerificationTokenRepository,
+	refreshTokens domain.RefreshTokenRepository,
+	tokens *security.TokenService,
+	hash *security.PasswordWrapper,
+	events domain.EventPublisher,
+	emailer domain.Emailer,
+	defaultTTL time.Duration,
+	rememberMeTTL time.Duration,
+	requireEmailVerification bool,
+) *AuthService {
+	return &AuthService{
+		users:                    users,
+		otps:                     otps,
+		verificationTokens:       verificationTokens,
+		refreshTokens:            refreshTokens,
+		tokens:                   tokens,
+		hash:                     hash,
+		events:                   events,
+		emailer:                  emailer,
+		defaultTTL:               defaultTTL,
+		rememberMeTTL:            rememberMeTTL,
+		requireEmailVerification: requireEmailVerification,
+	}
+}
+
+// publish fires event to s.events and logs, rather than returns, any
+// failure: a downstream consumer missing an event must never fail the
+// request that produced it.
+func (s *AuthService) publish(ctx context.Context, event interface{}) {
+	if s.events == nil {
+		return
+	}
+	if err := s.events.Publish(ctx, event); err != nil {
+		log.Printf("publish event: %v", err)
+	}
+}
+
+// issueVerificationToken generates a token, persists it with the given
+// purpose and TTL, and returns it for the caller to email out.
+func (s *AuthService) issueVerificationToken(ctx context.Context, userID int64, purpose string, ttl time.Duration) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", fmt.Errorf("generate verification token: %w", err)
+	}
+	vt := &domain.VerificationToken{
+		Token:     token,
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.verificationTokens.Create(ctx, vt); err != nil {
+		return "", fmt.Errorf("persist verification token: %w", err)
+	}
+	return token, nil
+}
+
+// redeemVerificationToken looks up token, checks it matches purpose and is
+// still unused and unexpired, and marks it used. It returns the owning
+// user's ID.
+func (s *AuthService) redeemVerificationToken(ctx context.Context, token, purpose string) (int64, error) {
+	vt, err := s.verificationTokens.GetByToken(ctx, token)
+	if err != nil {
+		return 0, fmt.Errorf("look up verification token: %w", err)
+	}
+	if vt == nil || vt.Used || vt.Purpose != purpose || time.Now().After(vt.ExpiresAt) {
+		return 0, errors.New("invalid or expired token")
+	}
+	if err := s.verificationTokens.MarkUsed(ctx, token); err != nil {
+		return 0, fmt.Errorf("mark verification token used: %w", err)
+	}
+	return vt.UserID, nil
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hex-encoded SHA-256 digest of a raw refresh token,
+// the only form ever persisted — so a database leak alone doesn't hand an
+// attacker a usable token.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type RegisterInput struct {
+	Username string
+	Email    *string
+	Password string
+}
+
+type LoginInput struct {
+	Username   string
+	Password   string
+	RememberMe bool
+	// UserAgent and IP are recorded on the issued refresh token for the
+	// user's own audit trail; neither is required for Refresh to succeed.
+	UserAgent string
+	IP        string
+}
+
+type TokenResponse struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64
+	PreAuthToken string
+	RequiresOTP  bool
+	TokenType    string
+	User         *domain.User
+}
+
+func validateUsername(username string) error {
+	username = strings.ToLower(username)
+	if len(username) < 3 || len(username) > 50 {
+		return errors.New("username must be 3–50 characters")
+	}
+	if !usernameRe.MatchString(username) {
+		return errors.New("username may only contain letters, digits, underscores and hyphens")
+	}
+	return nil
+}
+
+func validatePassword(password string) error {
+	if len(password) < 10 {
+		return errors.New("password must be at least 10 characters")
+	}
+	var hasUpper, hasLower, hasDigit bool
+	for _, ch := range password {
+		switch {
+		case unicode.IsUpper(ch):
+			hasUpper = true
+		case unicode.IsLower(ch):
+			hasLower = true
+		case unicode.IsDigit(ch):
+			hasDigit = true
+		}
+	}
+	if !hasUpper {
+		return errors.New("password must contain at least one uppercase letter")
+	}
+	if !hasLower {
+		return errors.New("password must contain at least one lowercase letter")
+	}
+	if !hasDigit {
+		return errors.New("password must contain at least one digit")
+	}
+	if !specialRe.MatchString(password) {
+		return errors.New(`password must contain at least one special character (!@#$%^&*()\,\.?":{}|<>)`)
+	}
+	return nil
+}
+
+func (s *AuthService) Register(ctx context.Context, in RegisterInput) (*domain.User, error) {
+	// Normalise and validate
+	in.Username = strings.ToLower(strings.TrimSpace(in.Username))
+	if err := validateUsername(in.Username); err != nil {
+		return nil, err
+	}
+	if err := validatePassword(in.Password); err != nil {
+		return nil, err
+	}
+
+	if existing, err := s.users.GetByUsername(ctx, in.Username); err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, fmt.Errorf("check username: %w", err)
+	} else if existing != nil {
+		return nil, errors.New("username already registered")
+	}
+
+	if in.Email != nil && *in.Email != "" {
+		if existing, err := s.users.GetByEmail(ctx, *in.Email); err != nil && !errors.Is(err, domain.ErrNotFound) {
+			return nil, fmt.Errorf("check email: %w", err)
+		} else if existing != nil {
+			return nil, errors.New("email already registered")
+		}
+	}
+
+	hashed, err := s.hash.Hash(in.Password)
+	if err != nil {
+		return nil, fmt.Errorf("hash password: %w", err)
+	}
+
+	needsVerification := s.requireEmailVerification && in.Email != nil && *in.Email != ""
+
+	user := &domain.User{
+		Username:       in.Username,
+		Email:          in.Email,
+		HashedPassword: hashed,
+		IsActive:       !needsVerification,
+		IsOnline:       false,
+	}
+	if err := s.users.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	s.publish(ctx, domain.NewUserRegisteredEvent(user.ID, user.Username))
+
+	if needsVerification {
+		token, err := s.issueVerificationToken(ctx, user.ID, domain.VerificationPurposeEmail, emailVerificationTTL)
+		if err != nil {
+			log.Printf("issue verification token: %v", err)
+		} else if err := s.emailer.SendVerification(ctx, *in.Email, token); err != nil {
+			log.Printf("send verification email: %v", err)
+		}
+	}
+	return user, nil
+}
+
+// VerifyEmail redeems a token minted by Register and activates the account
+// it belongs to.
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
+	userID, err := s.redeemVerificationToken(ctx, token, domain.VerificationPurposeEmail)
+	if err != nil {
+		return err
+	}
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+	if user == nil {
+		return errors.New("invalid or expired token")
+	}
+	user.IsActive = true
+	return s.users.Update(ctx, user)
+}
+
+// RequestPasswordReset queues a password-reset email when email matches a
+// registered user. It always returns nil, whether or not a match was found,
+// so the response can't be used to enumerate registered addresses.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.users.GetByEmail(ctx, email)
+	if err != nil {
+		log.Printf("look up user by email: %v", err)
+		return nil
+	}
+	if user == nil {
+		return nil
+	}
+	token, err := s.issueVerificationToken(ctx, user.ID, domain.VerificationPurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		log.Printf("issue password reset token: %v", err)
+		return nil
+	}
+	if user.Email == nil {
+		return nil
+	}
+	if err := s.emailer.SendPasswordReset(ctx, *user.Email, token); err != nil {
+		log.Printf("send password reset email: %v", err)
+	}
+	return nil
+}
+
+// ResetPassword redeems a token minted by RequestPasswordReset and sets
+// newPassword as the account's new password, subject to the same rules
+// Register enforces.
+func (s *AuthService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	if err := validatePassword(newPassword); err != nil {
+		return err
+	}
+	userID, err := s.redeemVerificationToken(ctx, token, domain.VerificationPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+	if user == nil {
+		return errors.New("invalid or expired token")
+	}
+	hashed, err := s.hash.Hash(newPassword)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+	user.HashedPassword = hashed
+	// Stamping this signs every outstanding session out: AuthenticateBearer
+	// rejects any bearer token whose iat predates it.
+	user.PasswordChangedAt = time.Now()
+	return s.users.Update(ctx, user)
+}
+
+func (s *AuthService) Login(ctx context.Context, in LoginInput) (*TokenResponse, error) {
+	user, err := s.users.GetByUsername(ctx, strings.ToLower(in.Username))
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	if user == nil {
+		return nil, errors.New("incorrect username or password")
+	}
+	if !user.IsActive {
+		return nil, errors.New("user account is inactive")
+	}
+	needsRehash, err := s.hash.Verify(in.Password, user.HashedPassword)
+	if err != nil {
+		return nil, errors.New("incorrect username or password")
+	}
+	if needsRehash {
+		s.rehashPassword(ctx, user, in.Password)
+	}
+
+	otp, err := s.otps.Get(ctx, user.ID)
+	if err != nil && err != domain.ErrNotFound {
+		return nil, fmt.Errorf("get otp enrollment: %w", err)
+	}
+	if otp != nil && otp.Confirmed {
+		preAuthToken, err := s.tokens.CreatePreAuth(user.Username, otpPreAuthTTL, in.RememberMe)
+		if err != nil {
+			return nil, fmt.Errorf("create pre-auth token: %w", err)
+		}
+		return &TokenResponse{
+			PreAuthToken: preAuthToken,
+			RequiresOTP:  true,
+			TokenType:    "bearer",
+			User:         user,
+		}, nil
+	}
+
+	if err := s.users.SetOnlineStatus(ctx, user.ID, true); err != nil {
+		return nil, fmt.Errorf("set online: %w", err)
+	}
+
+	ttl := s.defaultTTL
+	if in.RememberMe {
+		ttl = s.rememberMeTTL
+	}
+	token, err := s.tokens.CreateWithAMR(user.Username, ttl, []string{"pwd"}, []string{string(user.Role)})
+	if err != nil {
+		return nil, fmt.Errorf("create token: %w", err)
+	}
+
+	refreshToken, err := issueRefreshToken(ctx, s.refreshTokens, user.ID, in.RememberMe, in.UserAgent, in.IP)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publish(ctx, domain.NewUserLoggedInEvent(user.ID, in.RememberMe))
+
+	return &TokenResponse{
+		AccessToken:  token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(ttl.Seconds()),
+		TokenType:    "bearer",
+		User:         user,
+	}, nil
+}
+
+// issueRefreshToken mints and persists a new refresh token for userID in
+// repo, returning the raw token to hand back to the client — only its hash
+// is ever stored (see hashToken). Shared by every login path that issues a
+// session (AuthService, OTPService, OIDCService) rather than duplicated per
+// service.
+func issueRefreshToken(ctx context.Context, repo domain.RefreshTokenRepository, userID int64, rememberMe bool, userAgent, ip string) (string, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", fmt.Errorf("generate refresh token: %w", err)
+	}
+	ttl := refreshTokenTTL
+	if rememberMe {
+		ttl = refreshTokenRememberMeTTL
+	}
+	rt := &domain.RefreshToken{
+		UserID:    userID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(ttl),
+		UserAgent: userAgent,
+		IP:        ip,
+	}
+	if err := repo.Create(ctx, rt); err != nil {
+		return "", fmt.Errorf("persist refresh token: %w", err)
+	}
+	return raw, nil
+}
+
+// Refresh rotates rawToken: it's looked up by hash, revoked, and a fresh
+// access/refresh pair is issued in its place, preserving the same
+// remember-me-driven TTL the original token was minted with. Presenting a
+// token that's already revoked is treated as evidence the raw token leaked
+// and is being replayed after a legitimate rotation, so every other token
+// belonging to its owner is revoked too, forcing them to log in again
+// everywhere.
+func (s *AuthService) Refresh(ctx context.Context, rawToken, userAgent, ip string) (*TokenResponse, error) {
+	rt, err := s.refreshTokens.GetByHash(ctx, hashToken(rawToken))
+	if err != nil {
+		return nil, fmt.Errorf("look up refresh token: %w", err)
+	}
+	if rt == nil || time.Now().After(rt.ExpiresAt) {
+		return nil, errors.New("invalid or expired refresh token")
+	}
+	if rt.RevokedAt != nil {
+		if err := s.refreshTokens.RevokeAllForUser(ctx, rt.UserID); err != nil {
+			log.Printf("revoke all refresh tokens for user %d after reuse detected: %v", rt.UserID, err)
+		}
+		return nil, errors.New("refresh token already used")
+	}
+	if err := s.refreshTokens.Revoke(ctx, rt.ID); err != nil {
+		return nil, fmt.Errorf("revoke refresh token: %w", err)
+	}
+
+	user, err := s.users.GetByID(ctx, rt.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	if user == nil || !user.IsActive {
+		return nil, errors.New("user account is inactive")
+	}
+
+	rememberMe := rt.ExpiresAt.Sub(rt.CreatedAt) > refreshTokenTTL
+
+	ttl := s.defaultTTL
+	if rememberMe {
+		ttl = s.rememberMeTTL
+	}
+	token, err := s.tokens.CreateWithAMR(user.Username, ttl, []string{"pwd"}, []string{string(user.Role)})
+	if err != nil {
+		return nil, fmt.Errorf("create token: %w", err)
+	}
+
+	newRefreshToken, err := issueRefreshToken(ctx, s.refreshTokens, user.ID, rememberMe, userAgent, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		AccessToken:  token,
+		RefreshToken: newRefreshToken,
+		ExpiresIn:    int64(ttl.Seconds()),
+		TokenType:    "bearer",
+		User:         user,
+	}, nil
+}
+
+// rehashPassword re-hashes plain under the wrapper's current algorithm and
+// parameters and persists it, so a login is the only chance to upgrade a
+// password hash minted under an older scheme — there's no plaintext to do
+// it with afterwards. Failures are logged, not returned: a stale hash isn't
+// worth failing an otherwise-successful login over.
+func (s *AuthService) rehashPassword(ctx context.Context, user *domain.User, plain string) {
+	hashed, err := s.hash.Hash(plain)
+	if err != nil {
+		log.Printf("rehash password for user %d: %v", user.ID, err)
+		return
+	}
+	user.HashedPassword = hashed
+	if err := s.users.Update(ctx, user); err != nil {
+		log.Printf("persist rehashed password for user %d: %v", user.ID, err)
+	}
+}
+
+// Logout marks userID offline and, if refreshToken is non-empty, revokes it
+// so it can't be exchanged at /auth/refresh after this session ends. An
+// unrecognized or already-revoked refreshToken is not an error: logout
+// always succeeds for the caller's online status regardless.
+func (s *AuthService) Logout(ctx context.Context, userID int64, refreshToken string) error {
+	if err := s.users.SetOnlineStatus(ctx, userID, false); err != nil {
+		return err
+	}
+	if refreshToken != "" {
+		rt, err := s.refreshTokens.GetByHash(ctx, hashToken(refreshToken))
+		if err != nil {
+			log.Printf("look up refresh token at logout for user %d: %v", userID, err)
+		} else if rt != nil && rt.UserID == userID && rt.RevokedAt == nil {
+			if err := s.refreshTokens.Revoke(ctx, rt.ID); err != nil {
+				log.Printf("revoke refresh token at logout for user %d: %v", userID, err)
+			}
+		}
+	}
+	s.publish(ctx, domain.NewUserLoggedOutEvent(userID))
+	return nil
+}
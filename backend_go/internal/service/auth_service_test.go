@@ -1,109 +1,236 @@
-package service_test
-
-import (
-	"context"
-	"testing"
-	"time"
-
-	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
-
-	"backend_go/internal/domain"
-	"backend_go/internal/security"
-	"backend_go/internal/service"
-)
-
-// Mock mocks
-type MockUserRepo struct {
-	mock.Mock
-}
-
-func (m *MockUserRepo) Create(ctx context.Context, u *domain.User) error {
-	args := m.Called(ctx, u)
-	return args.Error(0)
-}
-
-func (m *MockUserRepo) GetByID(ctx context.Context, id int64) (*domain.User, error) {
-	args := m.Called(ctx, id)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*domain.User), args.Error(1)
-}
-
-func (m *MockUserRepo) GetByUsername(ctx context.Context, username string) (*domain.User, error) {
-	args := m.Called(ctx, username)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*domain.User), args.Error(1)
-}
-
-func (m *MockUserRepo) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
-	args := m.Called(ctx, email)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*domain.User), args.Error(1)
-}
-
-func (m *MockUserRepo) ListActive(ctx context.Context, offset, limit int) ([]*domain.User, error) {
-	return nil, nil // Not used in auth tests
-}
-
-func (m *MockUserRepo) ListOnline(ctx context.Context) ([]*domain.User, error) {
-	return nil, nil
-}
-
-func (m *MockUserRepo) Update(ctx context.Context, u *domain.User) error {
-	return nil
-}
-
-func (m *MockUserRepo) SoftDelete(ctx context.Context, id int64) error {
-	return nil
-}
-
-func (m *MockUserRepo) SetOnlineStatus(ctx context.Context, userID int64, isOnline bool) error {
-	args := m.Called(ctx, userID, isOnline)
-	return args.Error(0)
-}
-
-func TestRegister(t *testing.T) {
-	mockRepo := new(MockUserRepo)
-	tokenSvc := security.NewTokenService("secret", time.Hour)
-	hasher := security.NewPasswordHasher(10) // low cost for tests
-
-	svc := service.NewAuthService(mockRepo, tokenSvc, hasher, time.Hour, 24*time.Hour)
-
-	t.Run("Success", func(t *testing.T) {
-		input := service.RegisterInput{
-			Username: "newuser",
-			Password: "Password1!",
-		}
-
-		mockRepo.On("GetByUsername", mock.Anything, "newuser").Return(nil, domain.ErrNotFound)
-		mockRepo.On("Create", mock.Anything, mock.MatchedBy(func(u *domain.User) bool {
-			return u.Username == "newuser"
-		})).Return(nil)
-
-		user, err := svc.Register(context.Background(), input)
-		assert.NoError(t, err)
-		assert.NotNil(t, user)
-		assert.Equal(t, "newuser", user.Username)
-	})
-
-	t.Run("UsernameTaken", func(t *testing.T) {
-		input := service.RegisterInput{
-			Username: "existing",
-			Password: "Password1!",
-		}
-
-		existing := &domain.User{Username: "existing"}
-		mockRepo.On("GetByUsername", mock.Anything, "existing").Return(existing, nil)
-
-		user, err := svc.Register(context.Background(), input)
-		assert.Error(t, err)
-		assert.Nil(t, user)
-		assert.Equal(t, domain.ErrConflict, err)
-	})
-}
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"backend_go/internal/domain"
+	"backend_go/internal/email"
+	"backend_go/internal/security"
+	"backend_go/internal/service"
+)
+
+// Mock mocks
+type MockUserRepo struct {
+	mock.Mock
+}
+
+func (m *MockUserRepo) Create(ctx context.Context, u *domain.User) error {
+	args := m.Called(ctx, u)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) GetByID(ctx context.Context, id int64) (*domain.User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func (m *MockUserRepo) GetByUsername(ctx context.Context, username string) (*domain.User, error) {
+	args := m.Called(ctx, username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func (m *MockUserRepo) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	args := m.Called(ctx, email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func (m *MockUserRepo) ListActive(ctx context.Context, offset, limit int) ([]*domain.User, error) {
+	return nil, nil // Not used in auth tests
+}
+
+func (m *MockUserRepo) ListOnline(ctx context.Context) ([]*domain.User, error) {
+	return nil, nil
+}
+
+func (m *MockUserRepo) Update(ctx context.Context, u *domain.User) error {
+	return nil
+}
+
+func (m *MockUserRepo) SoftDelete(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *MockUserRepo) SetOnlineStatus(ctx context.Context, userID int64, isOnline bool) error {
+	args := m.Called(ctx, userID, isOnline)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) CountByRole(ctx context.Context, role domain.GlobalRole) (int, error) {
+	return 0, nil
+}
+
+func (m *MockUserRepo) SetRole(ctx context.Context, id int64, role domain.GlobalRole) error {
+	return nil
+}
+
+func (m *MockUserRepo) GetByIdentity(ctx context.Context, provider, subject string) (*domain.User, error) {
+	return nil, nil // Not used in auth tests
+}
+
+func (m *MockUserRepo) LinkIdentity(ctx context.Context, userID int64, provider, subject string, email *string) error {
+	return nil
+}
+
+func (m *MockUserRepo) UnlinkIdentity(ctx context.Context, userID int64, provider string) error {
+	return nil
+}
+
+func (m *MockUserRepo) CountIdentities(ctx context.Context, userID int64) (int, error) {
+	return 0, nil
+}
+
+func (m *MockUserRepo) GetByRemoteHandle(ctx context.Context, handle string) (*domain.User, error) {
+	return nil, nil // Not used in auth tests
+}
+
+// MockOTPRepo mocks domain.OTPRepository.
+type MockOTPRepo struct {
+	mock.Mock
+}
+
+func (m *MockOTPRepo) Get(ctx context.Context, userID int64) (*domain.UserOTP, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.UserOTP), args.Error(1)
+}
+
+func (m *MockOTPRepo) Upsert(ctx context.Context, o *domain.UserOTP) error {
+	return nil
+}
+
+func (m *MockOTPRepo) Confirm(ctx context.Context, userID int64) error {
+	return nil
+}
+
+func (m *MockOTPRepo) Delete(ctx context.Context, userID int64) error {
+	return nil
+}
+
+func (m *MockOTPRepo) SetLastCounter(ctx context.Context, userID int64, counter int64) error {
+	return nil
+}
+
+func (m *MockOTPRepo) CompareAndSetLastCounter(ctx context.Context, userID int64, counter int64) (bool, error) {
+	return true, nil
+}
+
+func (m *MockOTPRepo) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	return nil
+}
+
+func (m *MockOTPRepo) ListUnusedRecoveryCodes(ctx context.Context, userID int64) ([]domain.RecoveryCode, error) {
+	return nil, nil
+}
+
+func (m *MockOTPRepo) MarkRecoveryCodeUsed(ctx context.Context, userID int64, codeHash string) error {
+	return nil
+}
+
+// MockVerificationTokenRepo mocks domain.VerificationTokenRepository.
+type MockVerificationTokenRepo struct {
+	mock.Mock
+}
+
+func (m *MockVerificationTokenRepo) Create(ctx context.Context, t *domain.VerificationToken) error {
+	return nil
+}
+
+func (m *MockVerificationTokenRepo) GetByToken(ctx context.Context, token string) (*domain.VerificationToken, error) {
+	return nil, nil
+}
+
+func (m *MockVerificationTokenRepo) MarkUsed(ctx context.Context, token string) error {
+	return nil
+}
+
+func (m *MockVerificationTokenRepo) DeleteExpired(ctx context.Context, cutoff time.Time) error {
+	return nil
+}
+
+// MockRefreshTokenRepo mocks domain.RefreshTokenRepository.
+type MockRefreshTokenRepo struct {
+	mock.Mock
+}
+
+func (m *MockRefreshTokenRepo) Create(ctx context.Context, t *domain.RefreshToken) error {
+	return nil
+}
+
+func (m *MockRefreshTokenRepo) GetByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	return nil, nil
+}
+
+func (m *MockRefreshTokenRepo) Revoke(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *MockRefreshTokenRepo) RevokeAllForUser(ctx context.Context, userID int64) error {
+	return nil
+}
+
+func (m *MockRefreshTokenRepo) DeleteExpired(ctx context.Context, cutoff time.Time) error {
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	mockRepo := new(MockUserRepo)
+	mockOTPRepo := new(MockOTPRepo)
+	mockVerificationTokenRepo := new(MockVerificationTokenRepo)
+	mockRefreshTokenRepo := new(MockRefreshTokenRepo)
+	testKey, err := security.GenerateSigningKey("test", "ES256")
+	if err != nil {
+		t.Fatalf("generate test signing key: %v", err)
+	}
+	tokenSvc := security.NewTokenService(security.NewKeySet(testKey), time.Hour)
+	hasher := security.NewPasswordWrapper(security.Argon2Params{})
+
+	svc := service.NewAuthService(mockRepo, mockOTPRepo, mockVerificationTokenRepo, mockRefreshTokenRepo, tokenSvc, hasher, nil, email.NoopEmailer{}, time.Hour, 24*time.Hour, false)
+
+	t.Run("Success", func(t *testing.T) {
+		input := service.RegisterInput{
+			Username: "newuser",
+			Password: "Password1!",
+		}
+
+		mockRepo.On("GetByUsername", mock.Anything, "newuser").Return(nil, domain.ErrNotFound)
+		mockRepo.On("Create", mock.Anything, mock.MatchedBy(func(u *domain.User) bool {
+			return u.Username == "newuser"
+		})).Return(nil)
+
+		user, err := svc.Register(context.Background(), input)
+		assert.NoError(t, err)
+		assert.NotNil(t, user)
+		assert.Equal(t, "newuser", user.Username)
+	})
+
+	t.Run("UsernameTaken", func(t *testing.T) {
+		input := service.RegisterInput{
+			Username: "existing",
+			Password: "Password1!",
+		}
+
+		existing := &domain.User{Username: "existing"}
+		mockRepo.On("GetByUsername", mock.Anything, "existing").Return(existing, nil)
+
+		user, err := svc.Register(context.Background(), input)
+		assert.Error(t, err)
+		assert.Nil(t, user)
+		assert.Equal(t, domain.ErrConflict, err)
+	})
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"backend_go/internal/domain"
+	"backend_go/internal/security"
+)
+
+// ConversationKeyService implements envelope encryption for message
+// content: each conversation gets its own data-encryption-key (DEK),
+// generated on first use and stored wrapped under security.Encryptor's
+// active key-encryption-key (KEK) via ConversationKeyRepository.
+// MessageService calls Encrypt/Decrypt here instead of talking to
+// security.Encryptor directly, so it never sees a DEK in the clear.
+type ConversationKeyService struct {
+	keys domain.ConversationKeyRepository
+	enc  *security.Encryptor
+
+	mu       sync.Mutex
+	dekCache map[int64]cachedDEK // dek_id -> unwrapped DEK
+	active   map[int64]int64     // conversation_id -> active dek_id
+}
+
+// cachedDEK pairs an unwrapped DEK with the kek_id it's currently wrapped
+// under, so activeDEK's cache hit path doesn't need a repository round trip
+// to stamp an envelope header.
+type cachedDEK struct {
+	dek   []byte
+	kekID uint32
+}
+
+func NewConversationKeyService(keys domain.ConversationKeyRepository, enc *security.Encryptor) *ConversationKeyService {
+	return &ConversationKeyService{
+		keys:     keys,
+		enc:      enc,
+		dekCache: make(map[int64]cachedDEK),
+		active:   make(map[int64]int64),
+	}
+}
+
+// Encrypt seals plain under conversationID's active DEK, generating one on
+// first use.
+func (s *ConversationKeyService) Encrypt(ctx context.Context, conversationID int64, plain string) (string, error) {
+	dekID, dek, kekID, err := s.activeDEK(ctx, conversationID)
+	if err != nil {
+		return "", err
+	}
+	enc, err := security.EncryptWithDEK(dekID, kekID, dek, plain)
+	if err != nil {
+		return "", fmt.Errorf("encrypt message: %w", err)
+	}
+	return enc, nil
+}
+
+// Decrypt opens an enveloped ciphertext produced by Encrypt, looking up the
+// exact DEK named in its header instead of trying every conversation's key.
+func (s *ConversationKeyService) Decrypt(ctx context.Context, enc string) (string, error) {
+	header, err := security.ParseHeader(enc)
+	if err != nil {
+		return "", err
+	}
+	dek, err := s.dekByID(ctx, header.DEKID)
+	if err != nil {
+		return "", err
+	}
+	return security.DecryptWithDEK(dek, enc)
+}
+
+// activeDEK returns conversationID's active DEK in the clear (unwrapping or
+// generating+wrapping one as needed), plus the ids EncryptWithDEK needs to
+// stamp into the envelope header.
+func (s *ConversationKeyService) activeDEK(ctx context.Context, conversationID int64) (dekID int64, dek []byte, kekID uint32, err error) {
+	s.mu.Lock()
+	if id, ok := s.active[conversationID]; ok {
+		if cached, ok := s.dekCache[id]; ok {
+			s.mu.Unlock()
+			return id, cached.dek, cached.kekID, nil
+		}
+	}
+	s.mu.Unlock()
+
+	row, err := s.keys.GetActive(ctx, conversationID)
+	if err != nil {
+		return 0, nil, 0, fmt.Errorf("get active conversation key: %w", err)
+	}
+	if row == nil {
+		row, err = s.createKey(ctx, conversationID)
+		if err != nil {
+			return 0, nil, 0, err
+		}
+	}
+
+	clear, err := s.enc.UnwrapDEK(row.WrappedDEK, row.KEKID)
+	if err != nil {
+		return 0, nil, 0, fmt.Errorf("unwrap conversation key: %w", err)
+	}
+
+	s.mu.Lock()
+	s.dekCache[row.ID] = cachedDEK{dek: clear, kekID: row.KEKID}
+	s.active[conversationID] = row.ID
+	s.mu.Unlock()
+
+	return row.ID, clear, row.KEKID, nil
+}
+
+// dekByID returns the unwrapped DEK for a specific dek_id, as named in an
+// envelope header, fetching and caching it on a cold lookup.
+func (s *ConversationKeyService) dekByID(ctx context.Context, dekID int64) ([]byte, error) {
+	s.mu.Lock()
+	if cached, ok := s.dekCache[dekID]; ok {
+		s.mu.Unlock()
+		return cached.dek, nil
+	}
+	s.mu.Unlock()
+
+	row, err := s.keys.GetByID(ctx, dekID)
+	if err != nil {
+		return nil, fmt.Errorf("get conversation key %d: %w", dekID, err)
+	}
+	if row == nil {
+		return nil, fmt.Errorf("unknown conversation key %d", dekID)
+	}
+	clear, err := s.enc.UnwrapDEK(row.WrappedDEK, row.KEKID)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap conversation key %d: %w", dekID, err)
+	}
+
+	s.mu.Lock()
+	s.dekCache[dekID] = cachedDEK{dek: clear, kekID: row.KEKID}
+	s.mu.Unlock()
+	return clear, nil
+}
+
+func (s *ConversationKeyService) createKey(ctx context.Context, conversationID int64) (*domain.ConversationKey, error) {
+	dek, err := s.enc.GenerateDEK()
+	if err != nil {
+		return nil, fmt.Errorf("generate conversation key: %w", err)
+	}
+	wrapped, kekID, err := s.enc.WrapDEK(dek)
+	if err != nil {
+		return nil, fmt.Errorf("wrap conversation key: %w", err)
+	}
+	row := &domain.ConversationKey{
+		ConversationID: conversationID,
+		KEKID:          kekID,
+		WrappedDEK:     wrapped,
+		Status:         domain.ConversationKeyActive,
+	}
+	if err := s.keys.Create(ctx, row); err != nil {
+		return nil, fmt.Errorf("create conversation key: %w", err)
+	}
+	return row, nil
+}
+
+// RotateConversationKey generates a fresh DEK for conversationID and wraps
+// it under the active KEK, then retires the previous one: existing
+// messages keep decrypting against the dek_id embedded in their own
+// envelope header, but new messages are sealed under the new key.
+func (s *ConversationKeyService) RotateConversationKey(ctx context.Context, conversationID int64) error {
+	old, err := s.keys.GetActive(ctx, conversationID)
+	if err != nil {
+		return fmt.Errorf("get active conversation key: %w", err)
+	}
+
+	if _, err := s.createKey(ctx, conversationID); err != nil {
+		return err
+	}
+
+	if old != nil {
+		if err := s.keys.Retire(ctx, old.ID); err != nil {
+			return fmt.Errorf("retire conversation key %d: %w", old.ID, err)
+		}
+	}
+
+	s.mu.Lock()
+	delete(s.active, conversationID)
+	s.mu.Unlock()
+	return nil
+}
+
+// RotateMasterKey installs newKEKSecret as security.Encryptor's active
+// key-encryption-key, then re-wraps every stored conversation DEK under it
+// in the background: the previous KEK keeps unwrapping in the meantime, so
+// nothing here blocks new messages from being encrypted under the new key.
+func (s *ConversationKeyService) RotateMasterKey(ctx context.Context, newKEKSecret []byte) error {
+	if err := s.enc.RotateMasterKey(newKEKSecret); err != nil {
+		return fmt.Errorf("rotate master key: %w", err)
+	}
+	go s.rewrapAll(context.Background())
+	return nil
+}
+
+func (s *ConversationKeyService) rewrapAll(ctx context.Context) {
+	rows, err := s.keys.ListAll(ctx)
+	if err != nil {
+		log.Printf("ConversationKeyService: list conversation keys for master key rotation: %v", err)
+		return
+	}
+
+	rewrapped := 0
+	for _, row := range rows {
+		dek, err := s.enc.UnwrapDEK(row.WrappedDEK, row.KEKID)
+		if err != nil {
+			log.Printf("ConversationKeyService: unwrap conversation key %d during rotation: %v", row.ID, err)
+			continue
+		}
+		wrapped, kekID, err := s.enc.WrapDEK(dek)
+		if err != nil {
+			log.Printf("ConversationKeyService: rewrap conversation key %d during rotation: %v", row.ID, err)
+			continue
+		}
+		if err := s.keys.Rewrap(ctx, row.ID, kekID, wrapped); err != nil {
+			log.Printf("ConversationKeyService: persist rewrapped conversation key %d: %v", row.ID, err)
+			continue
+		}
+		rewrapped++
+	}
+	log.Printf("ConversationKeyService: master key rotation re-wrapped %d/%d conversation keys", rewrapped, len(rows))
+}
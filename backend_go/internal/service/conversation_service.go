@@ -1,176 +1,332 @@
-package service
-
-import (
-	"context"
-	"errors"
-	"fmt"
-
-	"backend_go/internal/domain"
-)
-
-type ConversationService struct {
-	conversations domain.ConversationRepository
-	participants  domain.ParticipantRepository
-	messages      domain.MessageRepository
-	msgSvc        *MessageService // used only in toResponse to decrypt last_message
-}
-
-func NewConversationService(
-	conversations domain.ConversationRepository,
-	participants domain.ParticipantRepository,
-	messages domain.MessageRepository,
-) *ConversationService {
-	return &ConversationService{
-		conversations: conversations,
-		participants:  participants,
-		messages:      messages,
-	}
-}
-
-// SetMessageService injects MessageService after construction (avoids circular init).
-func (s *ConversationService) SetMessageService(msgSvc *MessageService) {
-	s.msgSvc = msgSvc
-}
-
-type ConversationCreateInput struct {
-	Name           *string
-	IsGroup        bool
-	ParticipantIDs []int64
-}
-
-// ConversationResponse is the rich response DTO including participants, last message and unread count.
-type ConversationResponse struct {
-	*domain.Conversation
-	Participants []domain.User    `json:"participants"`
-	LastMessage  *MessageResponse `json:"last_message"`
-	UnreadCount  int              `json:"unread_count"`
-}
-
-func (s *ConversationService) CreateConversation(
-	ctx context.Context,
-	in ConversationCreateInput,
-	creatorID int64,
-) (*ConversationResponse, error) {
-	// Deduplicate + include creator
-	uniqueIDs := make([]int64, 0, len(in.ParticipantIDs)+1)
-	seen := map[int64]struct{}{creatorID: {}}
-	uniqueIDs = append(uniqueIDs, creatorID)
-	for _, id := range in.ParticipantIDs {
-		if _, ok := seen[id]; ok {
-			continue
-		}
-		seen[id] = struct{}{}
-		uniqueIDs = append(uniqueIDs, id)
-	}
-
-	// Validation: direct → exactly 1 other; group → at least 2 others
-	otherCount := len(uniqueIDs) - 1 // exclude creator
-	if !in.IsGroup && otherCount != 1 {
-		return nil, errors.New("a direct conversation requires exactly one other participant")
-	}
-	if in.IsGroup && otherCount < 2 {
-		return nil, errors.New("a group conversation requires at least two other participants")
-	}
-
-	// Idempotency check
-	var existing *domain.Conversation
-	var err error
-	if !in.IsGroup && len(uniqueIDs) == 2 {
-		existing, err = s.conversations.FindExistingDirect(ctx, uniqueIDs)
-	} else if in.IsGroup {
-		existing, err = s.conversations.FindExistingGroup(ctx, uniqueIDs)
-	}
-	if err != nil {
-		return nil, fmt.Errorf("find existing conversation: %w", err)
-	}
-	if existing != nil {
-		return s.toResponse(ctx, existing, creatorID)
-	}
-
-	conv := &domain.Conversation{
-		Name:    in.Name,
-		IsGroup: in.IsGroup,
-	}
-	if err := s.conversations.Create(ctx, conv, uniqueIDs); err != nil {
-		return nil, err
-	}
-	return s.toResponse(ctx, conv, creatorID)
-}
-
-func (s *ConversationService) ListForUser(ctx context.Context, userID int64) ([]*ConversationResponse, error) {
-	convs, err := s.conversations.ListForUser(ctx, userID)
-	if err != nil {
-		return nil, err
-	}
-	res := make([]*ConversationResponse, 0, len(convs))
-	for _, c := range convs {
-		r, err := s.toResponse(ctx, c, userID)
-		if err != nil {
-			return nil, err
-		}
-		res = append(res, r)
-	}
-	return res, nil
-}
-
-func (s *ConversationService) GetConversation(
-	ctx context.Context,
-	conversationID int64,
-	userID int64,
-) (*ConversationResponse, error) {
-	conv, err := s.conversations.GetByID(ctx, conversationID)
-	if err != nil {
-		return nil, err
-	}
-	if conv == nil {
-		return nil, errors.New("conversation not found")
-	}
-	isParticipant, err := s.participants.IsParticipant(ctx, conversationID, userID)
-	if err != nil {
-		return nil, err
-	}
-	if !isParticipant {
-		return nil, errors.New("not a participant in this conversation")
-	}
-	return s.toResponse(ctx, conv, userID)
-}
-
-func (s *ConversationService) MarkAsRead(
-	ctx context.Context,
-	conversationID int64,
-	userID int64,
-) error {
-	return s.conversations.MarkAsRead(ctx, conversationID, userID)
-}
-
-// toResponse enriches a bare Conversation with participants, last message and unread count.
-func (s *ConversationService) toResponse(ctx context.Context, conv *domain.Conversation, userID int64) (*ConversationResponse, error) {
-	users, err := s.participants.ListParticipants(ctx, conv.ID)
-	if err != nil {
-		return nil, fmt.Errorf("list participants: %w", err)
-	}
-	participants := make([]domain.User, len(users))
-	for i, u := range users {
-		participants[i] = *u
-	}
-
-	unread, err := s.conversations.GetUnreadCount(ctx, conv.ID, userID)
-	if err != nil {
-		unread = 0 // non-fatal
-	}
-
-	var lastMsg *MessageResponse
-	if s.msgSvc != nil {
-		msgs, err := s.messages.ListForConversationForUser(ctx, conv.ID, userID, 1)
-		if err == nil && len(msgs) > 0 {
-			lastMsg, _ = s.msgSvc.ToResponse(ctx, msgs[0])
-		}
-	}
-
-	return &ConversationResponse{
-		Conversation: conv,
-		Participants: participants,
-		LastMessage:  lastMsg,
-		UnreadCount:  unread,
-	}, nil
-}
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"backend_go/internal/authz"
+	"backend_go/internal/domain"
+	"backend_go/internal/policies"
+)
+
+// ErrBanned is returned when a banned user (or the caller on their behalf)
+// attempts to rejoin a conversation they were kicked and banned from.
+var ErrBanned = errors.New("user is banned from this conversation")
+
+type ConversationService struct {
+	conversations domain.ConversationRepository
+	participants  domain.ParticipantRepository
+	messages      domain.MessageRepository
+	users         domain.UserRepository
+	policies      *policies.Authorizer
+	msgSvc        Messages // used only in toResponse to decrypt last_message
+}
+
+func NewConversationService(
+	conversations domain.ConversationRepository,
+	participants domain.ParticipantRepository,
+	messages domain.MessageRepository,
+	users domain.UserRepository,
+	authz *policies.Authorizer,
+) *ConversationService {
+	return &ConversationService{
+		conversations: conversations,
+		participants:  participants,
+		messages:      messages,
+		users:         users,
+		policies:      authz,
+	}
+}
+
+// SetMessageService injects Messages after construction (avoids circular init).
+func (s *ConversationService) SetMessageService(msgSvc Messages) {
+	s.msgSvc = msgSvc
+}
+
+type ConversationCreateInput struct {
+	Name           *string
+	IsGroup        bool
+	ParticipantIDs []int64
+}
+
+// ConversationResponse is the rich response DTO including participants, last message and unread count.
+type ConversationResponse struct {
+	*domain.Conversation
+	Participants []domain.User    `json:"participants"`
+	LastMessage  *MessageResponse `json:"last_message"`
+	UnreadCount  int              `json:"unread_count"`
+}
+
+func (s *ConversationService) CreateConversation(
+	ctx context.Context,
+	in ConversationCreateInput,
+	creatorID int64,
+) (*ConversationResponse, error) {
+	// Deduplicate + include creator
+	uniqueIDs := make([]int64, 0, len(in.ParticipantIDs)+1)
+	seen := map[int64]struct{}{creatorID: {}}
+	uniqueIDs = append(uniqueIDs, creatorID)
+	for _, id := range in.ParticipantIDs {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		uniqueIDs = append(uniqueIDs, id)
+	}
+
+	// Validation: direct → exactly 1 other; group → at least 2 others
+	otherCount := len(uniqueIDs) - 1 // exclude creator
+	if !in.IsGroup && otherCount != 1 {
+		return nil, errors.New("a direct conversation requires exactly one other participant")
+	}
+	if in.IsGroup && otherCount < 2 {
+		return nil, errors.New("a group conversation requires at least two other participants")
+	}
+
+	// Idempotency check
+	var existing *domain.Conversation
+	var err error
+	if !in.IsGroup && len(uniqueIDs) == 2 {
+		existing, err = s.conversations.FindExistingDirect(ctx, uniqueIDs)
+	} else if in.IsGroup {
+		existing, err = s.conversations.FindExistingGroup(ctx, uniqueIDs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find existing conversation: %w", err)
+	}
+	if existing != nil {
+		return s.toResponse(ctx, existing, creatorID)
+	}
+
+	conv := &domain.Conversation{
+		Name:    in.Name,
+		IsGroup: in.IsGroup,
+	}
+	if err := s.conversations.Create(ctx, conv, uniqueIDs); err != nil {
+		return nil, err
+	}
+	return s.toResponse(ctx, conv, creatorID)
+}
+
+func (s *ConversationService) ListForUser(ctx context.Context, userID int64) ([]*ConversationResponse, error) {
+	convs, err := s.conversations.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*ConversationResponse, 0, len(convs))
+	for _, c := range convs {
+		r, err := s.toResponse(ctx, c, userID)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+func (s *ConversationService) GetConversation(
+	ctx context.Context,
+	conversationID int64,
+	userID int64,
+) (*ConversationResponse, error) {
+	conv, err := s.conversations.GetByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if conv == nil {
+		return nil, errors.New("conversation not found")
+	}
+	isParticipant, err := s.participants.IsParticipant(ctx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !isParticipant {
+		return nil, errors.New("not a participant in this conversation")
+	}
+	return s.toResponse(ctx, conv, userID)
+}
+
+func (s *ConversationService) MarkAsRead(
+	ctx context.Context,
+	conversationID int64,
+	userID int64,
+) error {
+	return s.conversations.MarkAsRead(ctx, conversationID, userID)
+}
+
+// SetRetention configures how long the retention worker keeps messages in a
+// conversation, and how many of the most recent ones it keeps regardless of
+// age. Any participant may set it for a direct chat; a group conversation
+// restricts it to an owner/admin, the same as renaming it.
+func (s *ConversationService) SetRetention(
+	ctx context.Context,
+	conversationID int64,
+	callerID int64,
+	retentionSeconds *int,
+	keepLastN *int,
+) error {
+	if retentionSeconds != nil && *retentionSeconds <= 0 {
+		return errors.New("retention_seconds must be positive")
+	}
+	if keepLastN != nil && *keepLastN <= 0 {
+		return errors.New("keep_last_n must be positive")
+	}
+	conv, err := s.conversations.GetByID(ctx, conversationID)
+	if err != nil {
+		return fmt.Errorf("get conversation: %w", err)
+	}
+	if conv == nil {
+		return errors.New("conversation not found")
+	}
+	if conv.IsGroup {
+		if err := s.policies.Authorize(ctx, callerID, conversationID, policies.ActionRenameConversation); err != nil {
+			return err
+		}
+	} else {
+		isParticipant, err := s.participants.IsParticipant(ctx, conversationID, callerID)
+		if err != nil {
+			return fmt.Errorf("check participant: %w", err)
+		}
+		if !isParticipant {
+			return errors.New("not a participant in this conversation")
+		}
+	}
+	return s.conversations.SetRetention(ctx, conversationID, retentionSeconds, keepLastN)
+}
+
+// RenameConversation changes a group conversation's display name; only an
+// owner/admin may do so.
+func (s *ConversationService) RenameConversation(ctx context.Context, conversationID, callerID int64, name string) error {
+	conv, err := s.conversations.GetByID(ctx, conversationID)
+	if err != nil {
+		return fmt.Errorf("get conversation: %w", err)
+	}
+	if conv == nil {
+		return errors.New("conversation not found")
+	}
+	if !conv.IsGroup {
+		return errors.New("only group conversations can be renamed")
+	}
+	if err := s.policies.Authorize(ctx, callerID, conversationID, policies.ActionRenameConversation); err != nil {
+		return err
+	}
+	return s.conversations.Rename(ctx, conversationID, name)
+}
+
+// AddParticipant joins userID to conversationID as a RoleMember; only an
+// owner/admin may add participants. A user banned from the conversation
+// (see BanParticipant) is refused regardless of who is adding them.
+func (s *ConversationService) AddParticipant(ctx context.Context, conversationID, callerID, userID int64) error {
+	if err := s.policies.Authorize(ctx, callerID, conversationID, policies.ActionManageParticipants); err != nil {
+		return err
+	}
+	banned, err := s.participants.IsBanned(ctx, conversationID, userID)
+	if err != nil {
+		return fmt.Errorf("check ban: %w", err)
+	}
+	if banned {
+		return ErrBanned
+	}
+	return s.participants.AddParticipant(ctx, conversationID, userID, domain.RoleMember)
+}
+
+// RemoveParticipant removes userID's membership in conversationID; only an
+// owner/admin may remove participants.
+func (s *ConversationService) RemoveParticipant(ctx context.Context, conversationID, callerID, userID int64) error {
+	if err := s.policies.Authorize(ctx, callerID, conversationID, policies.ActionManageParticipants); err != nil {
+		return err
+	}
+	return s.participants.RemoveParticipant(ctx, conversationID, userID)
+}
+
+// KickParticipant removes userID's membership in conversationID without a
+// ban, so they may be re-added later. A global moderator/admin (see
+// internal/authz) may kick from any conversation, even one they aren't a
+// member of; otherwise the caller needs owner/admin standing in it.
+func (s *ConversationService) KickParticipant(ctx context.Context, conversationID, callerID, userID int64) error {
+	if err := s.requireModeratorOr(ctx, callerID, conversationID, policies.ActionManageParticipants); err != nil {
+		return err
+	}
+	return s.participants.RemoveParticipant(ctx, conversationID, userID)
+}
+
+// BanParticipant removes userID's membership in conversationID and records
+// the ban so AddParticipant refuses to re-seat them. Same authorization as
+// KickParticipant.
+func (s *ConversationService) BanParticipant(ctx context.Context, conversationID, callerID, userID int64) error {
+	if err := s.requireModeratorOr(ctx, callerID, conversationID, policies.ActionManageParticipants); err != nil {
+		return err
+	}
+	return s.participants.BanParticipant(ctx, conversationID, userID)
+}
+
+// SetLocked marks conversationID read-only (or reopens it): while locked,
+// MessageService.CreateMessage refuses new messages from anyone, including a
+// global moderator/admin. Same authorization as KickParticipant.
+func (s *ConversationService) SetLocked(ctx context.Context, conversationID, callerID int64, locked bool) error {
+	if err := s.requireModeratorOr(ctx, callerID, conversationID, policies.ActionLockConversation); err != nil {
+		return err
+	}
+	return s.conversations.SetLocked(ctx, conversationID, locked)
+}
+
+// requireModeratorOr allows the call through if callerID holds at least
+// domain.GlobalRoleModerator site-wide (internal/authz), and otherwise falls
+// back to the usual per-conversation policies.Authorizer check for action.
+func (s *ConversationService) requireModeratorOr(ctx context.Context, callerID, conversationID int64, action policies.Action) error {
+	caller, err := s.users.GetByID(ctx, callerID)
+	if err != nil {
+		return fmt.Errorf("get caller: %w", err)
+	}
+	if caller != nil && authz.Atleast(caller.Role, domain.GlobalRoleModerator) {
+		return nil
+	}
+	return s.policies.Authorize(ctx, callerID, conversationID, action)
+}
+
+// ChangeRole promotes or demotes an existing participant; only an
+// owner/admin may change roles.
+func (s *ConversationService) ChangeRole(ctx context.Context, conversationID, callerID, userID int64, role domain.ConversationRole) error {
+	switch role {
+	case domain.RoleOwner, domain.RoleAdmin, domain.RoleMember:
+	default:
+		return errors.New("role must be 'owner', 'admin' or 'member'")
+	}
+	if err := s.policies.Authorize(ctx, callerID, conversationID, policies.ActionChangeRole); err != nil {
+		return err
+	}
+	return s.participants.SetRole(ctx, conversationID, userID, role)
+}
+
+// toResponse enriches a bare Conversation with participants, last message and unread count.
+func (s *ConversationService) toResponse(ctx context.Context, conv *domain.Conversation, userID int64) (*ConversationResponse, error) {
+	users, err := s.participants.ListParticipants(ctx, conv.ID)
+	if err != nil {
+		return nil, fmt.Errorf("list participants: %w", err)
+	}
+	participants := make([]domain.User, len(users))
+	for i, u := range users {
+		participants[i] = *u
+	}
+
+	unread, err := s.conversations.GetUnreadCount(ctx, conv.ID, userID)
+	if err != nil {
+		unread = 0 // non-fatal
+	}
+
+	var lastMsg *MessageResponse
+	if s.msgSvc != nil {
+		msgs, err := s.messages.ListForConversationForUser(ctx, conv.ID, userID, 1)
+		if err == nil && len(msgs) > 0 {
+			lastMsg, _ = s.msgSvc.ToResponse(ctx, msgs[0])
+		}
+	}
+
+	return &ConversationResponse{
+		Conversation: conv,
+		Participants: participants,
+		LastMessage:  lastMsg,
+		UnreadCount:  unread,
+	}, nil
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"backend_go/internal/domain"
+)
+
+// Messages is the interface MessageService satisfies. Everything outside
+// this package — handlers, the ws.Handler, and the logging/metrics/tracing
+// middleware in internal/service/middleware — depends on this rather than
+// *MessageService, so those middleware can wrap the real implementation
+// without touching any call site.
+type Messages interface {
+	CreateMessage(ctx context.Context, in MessageCreateInput, senderID int64) (*domain.Message, error)
+	EditMessage(ctx context.Context, callerID, messageID int64, newContent string) (*domain.Message, error)
+	DeleteMessage(ctx context.Context, callerID, messageID int64, deleteType string) (*domain.Message, error)
+	ForceDeleteMessage(ctx context.Context, moderatorID, messageID int64) (*domain.Message, error)
+	ListMessagesPage(ctx context.Context, conversationID, userID int64, cur domain.Cursor, dir domain.Direction, limit int) (*MessagePage, error)
+	ChangesSince(ctx context.Context, conversationID, userID int64, since time.Time) (*MessageChanges, error)
+	MarkAllReadInConversation(ctx context.Context, conversationID, callerID int64) error
+	GetParticipantIDs(ctx context.Context, conversationID int64) ([]int64, error)
+	ToResponse(ctx context.Context, m *domain.Message) (*MessageResponse, error)
+	ToResponses(ctx context.Context, msgs []*domain.Message) ([]*MessageResponse, error)
+}
+
+var _ Messages = (*MessageService)(nil)
+
+// Auth is the interface AuthService satisfies; see Messages.
+type Auth interface {
+	Register(ctx context.Context, in RegisterInput) (*domain.User, error)
+	Login(ctx context.Context, in LoginInput) (*TokenResponse, error)
+	Refresh(ctx context.Context, rawToken, userAgent, ip string) (*TokenResponse, error)
+	Logout(ctx context.Context, userID int64, refreshToken string) error
+	VerifyEmail(ctx context.Context, token string) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+}
+
+var _ Auth = (*AuthService)(nil)
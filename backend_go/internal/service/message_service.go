@@ -4,16 +4,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"strconv"
 	"time"
 
+	"backend_go/internal/authz"
 	"backend_go/internal/domain"
-	"backend_go/internal/security"
+	"backend_go/internal/policies"
 )
 
-// Sentinel errors used by handlers to map to HTTP status codes.
+// Sentinel errors used by handlers to map to HTTP status codes. ErrForbidden
+// is policies.ErrForbidden itself (not just an equal value), so
+// errors.Is(err, service.ErrForbidden) still matches whatever the
+// policies.Authorizer below returns.
 var (
-	ErrForbidden      = errors.New("forbidden")
-	ErrMessageDeleted = errors.New("message is already deleted")
+	ErrForbidden          = policies.ErrForbidden
+	ErrMessageDeleted     = errors.New("message is already deleted")
+	ErrConversationLocked = errors.New("conversation is locked")
 )
 
 type MessageService struct {
@@ -22,7 +29,10 @@ type MessageService struct {
 	messages      domain.MessageRepository
 	deletedMsgs   domain.UserDeletedMessageRepository
 	users         domain.UserRepository
-	encryptor     *security.Encryptor
+	attachments   domain.AttachmentRepository
+	keys          *ConversationKeyService
+	events        domain.EventPublisher
+	policies      *policies.Authorizer
 
 	MaxMessagesPerConversation int
 }
@@ -33,7 +43,10 @@ func NewMessageService(
 	messages domain.MessageRepository,
 	deletedMsgs domain.UserDeletedMessageRepository,
 	users domain.UserRepository,
-	encryptor *security.Encryptor,
+	attachments domain.AttachmentRepository,
+	keys *ConversationKeyService,
+	events domain.EventPublisher,
+	authz *policies.Authorizer,
 	maxMessages int,
 ) *MessageService {
 	return &MessageService{
@@ -42,7 +55,10 @@ func NewMessageService(
 		messages:                   messages,
 		deletedMsgs:                deletedMsgs,
 		users:                      users,
-		encryptor:                  encryptor,
+		attachments:                attachments,
+		keys:                       keys,
+		events:                     events,
+		policies:                   authz,
 		MaxMessagesPerConversation: maxMessages,
 	}
 }
@@ -50,8 +66,19 @@ func NewMessageService(
 type MessageCreateInput struct {
 	ConversationID int64
 	Content        string
-	FilePath       *string
-	FileType       *string
+	AttachmentID   *int64
+
+	// TTLSeconds, when positive, sets the message's ExpiresAt to now+TTL;
+	// the conversation's retention_seconds (if any) still applies on top.
+	TTLSeconds int
+	// ViewOnce marks the message for immediate deletion once it is read by
+	// anyone other than the sender (see MessageService.MarkAllReadInConversation).
+	ViewOnce bool
+
+	// OriginServer, when set, marks this message as relayed in from another
+	// zchat server by internal/federation rather than authored locally; it
+	// is copied verbatim onto domain.Message.OriginServer.
+	OriginServer *string
 }
 
 func (s *MessageService) CreateMessage(
@@ -70,6 +97,9 @@ func (s *MessageService) CreateMessage(
 	if conv == nil {
 		return nil, errors.New("conversation not found")
 	}
+	if conv.IsLocked {
+		return nil, ErrConversationLocked
+	}
 	isParticipant, err := s.participants.IsParticipant(ctx, in.ConversationID, senderID)
 	if err != nil {
 		return nil, fmt.Errorf("check participant: %w", err)
@@ -78,11 +108,28 @@ func (s *MessageService) CreateMessage(
 		return nil, errors.New("you are not a participant in this conversation")
 	}
 
-	if in.Content == "" && (in.FilePath == nil || *in.FilePath == "") {
+	if in.Content == "" && in.AttachmentID == nil {
 		return nil, errors.New("message content cannot be empty")
 	}
 
-	encrypted, err := s.encryptor.Encrypt(in.Content)
+	var filePath, fileType *string
+	if in.AttachmentID != nil {
+		att, err := s.attachments.GetByID(ctx, *in.AttachmentID)
+		if err != nil {
+			return nil, fmt.Errorf("get attachment: %w", err)
+		}
+		if att == nil {
+			return nil, errors.New("attachment not found")
+		}
+		if att.OwnerID != senderID {
+			return nil, ErrForbidden
+		}
+		idStr := strconv.FormatInt(att.ID, 10)
+		filePath = &idStr
+		fileType = &att.ContentType
+	}
+
+	encrypted, err := s.keys.Encrypt(ctx, in.ConversationID, in.Content)
 	if err != nil {
 		return nil, fmt.Errorf("encrypt content: %w", err)
 	}
@@ -91,9 +138,18 @@ func (s *MessageService) CreateMessage(
 		Content:        encrypted,
 		ConversationID: in.ConversationID,
 		SenderID:       senderID,
-		FilePath:       in.FilePath,
-		FileType:       in.FileType,
+		FilePath:       filePath,
+		FileType:       fileType,
 		IsDeleted:      false,
+		ViewOnce:       in.ViewOnce,
+		OriginServer:   in.OriginServer,
+	}
+	if in.Content != "" {
+		msg.SearchText = &in.Content
+	}
+	if in.TTLSeconds > 0 {
+		expiresAt := time.Now().Add(time.Duration(in.TTLSeconds) * time.Second)
+		msg.ExpiresAt = &expiresAt
 	}
 
 	if err := s.messages.Create(ctx, msg); err != nil {
@@ -101,14 +157,28 @@ func (s *MessageService) CreateMessage(
 	}
 
 	if s.MaxMessagesPerConversation > 0 {
-		if err := s.messages.PruneOld(ctx, in.ConversationID, s.MaxMessagesPerConversation); err != nil {
+		if _, err := s.messages.PruneOld(ctx, in.ConversationID, s.MaxMessagesPerConversation); err != nil {
 			return nil, fmt.Errorf("prune old messages: %w", err)
 		}
 	}
 
+	s.publish(ctx, domain.NewMessageCreatedEvent(senderID, msg.ID, msg.ConversationID))
+
 	return msg, nil
 }
 
+// publish fires event to s.events and logs, rather than returns, any
+// failure: a downstream consumer missing an event must never fail the
+// request that produced it.
+func (s *MessageService) publish(ctx context.Context, event interface{}) {
+	if s.events == nil {
+		return
+	}
+	if err := s.events.Publish(ctx, event); err != nil {
+		log.Printf("publish event: %v", err)
+	}
+}
+
 func (s *MessageService) EditMessage(
 	ctx context.Context,
 	callerID, messageID int64,
@@ -132,16 +202,22 @@ func (s *MessageService) EditMessage(
 		return nil, ErrForbidden
 	}
 
-	encrypted, err := s.encryptor.Encrypt(newContent)
+	encrypted, err := s.keys.Encrypt(ctx, msg.ConversationID, newContent)
 	if err != nil {
 		return nil, fmt.Errorf("encrypt content: %w", err)
 	}
 
 	msg.Content = encrypted
 	msg.IsEdited = true
+	if newContent != "" {
+		msg.SearchText = &newContent
+	} else {
+		msg.SearchText = nil
+	}
 	if err := s.messages.Update(ctx, msg); err != nil {
 		return nil, fmt.Errorf("update message: %w", err)
 	}
+	s.publish(ctx, domain.NewMessageEditedEvent(callerID, msg.ID, msg.ConversationID))
 	return msg, nil
 }
 
@@ -160,8 +236,12 @@ func (s *MessageService) DeleteMessage(
 
 	switch deleteType {
 	case "for_everyone":
+		// Senders may always delete their own message; anyone else needs
+		// an owner/admin role in the conversation.
 		if msg.SenderID != callerID {
-			return nil, ErrForbidden
+			if err := s.policies.Authorize(ctx, callerID, msg.ConversationID, policies.ActionDeleteAnyMessage); err != nil {
+				return nil, err
+			}
 		}
 		if err := s.messages.SoftDeleteForEveryone(ctx, messageID); err != nil {
 			return nil, fmt.Errorf("soft delete: %w", err)
@@ -175,56 +255,144 @@ func (s *MessageService) DeleteMessage(
 		return nil, errors.New("delete_type must be 'for_me' or 'for_everyone'")
 	}
 
+	s.publish(ctx, domain.NewMessageDeletedEvent(callerID, msg.ID, msg.ConversationID, deleteType))
+
 	return msg, nil
 }
 
-func (s *MessageService) ListMessages(
-	ctx context.Context,
-	conversationID int64,
-	userID int64,
-	limit int,
-) ([]*domain.Message, error) {
-	conv, err := s.conversations.GetByID(ctx, conversationID)
+// ForceDeleteMessage soft-deletes messageID for everyone on behalf of a
+// global moderator/admin (internal/authz), bypassing both the sender check
+// and the conversation's policies.Authorizer entirely — the caller need not
+// even be a participant. httpserver.RequireRole gates the REST route, but
+// this method re-checks moderatorID's role itself since the WS handler
+// reaches it directly.
+func (s *MessageService) ForceDeleteMessage(ctx context.Context, moderatorID, messageID int64) (*domain.Message, error) {
+	moderator, err := s.users.GetByID(ctx, moderatorID)
 	if err != nil {
-		return nil, fmt.Errorf("get conversation: %w", err)
+		return nil, fmt.Errorf("get moderator: %w", err)
 	}
-	if conv == nil {
-		return nil, errors.New("conversation not found")
+	if moderator == nil || !authz.Atleast(moderator.Role, domain.GlobalRoleModerator) {
+		return nil, ErrForbidden
 	}
-	isParticipant, err := s.participants.IsParticipant(ctx, conversationID, userID)
+
+	msg, err := s.messages.GetByID(ctx, messageID)
 	if err != nil {
-		return nil, fmt.Errorf("check participant: %w", err)
+		return nil, fmt.Errorf("get message: %w", err)
 	}
-	if !isParticipant {
-		return nil, errors.New("you are not a participant in this conversation")
+	if msg == nil {
+		return nil, errors.New("message not found")
+	}
+	if err := s.messages.SoftDeleteForEveryone(ctx, messageID); err != nil {
+		return nil, fmt.Errorf("soft delete: %w", err)
 	}
+	msg.IsDeleted = true
 
+	s.publish(ctx, domain.NewMessageDeletedEvent(moderatorID, msg.ID, msg.ConversationID, "for_everyone"))
+
+	return msg, nil
+}
+
+// MessagePage is a cursor-paginated window into a conversation's history.
+// NextCursor/PrevCursor are set from the first and last message whenever the
+// page is non-empty and are empty only for an empty page; HasMore, not an
+// empty cursor, says whether more rows exist in the queried direction.
+// Messages is always chronological (oldest first) regardless of which
+// direction was queried.
+type MessagePage struct {
+	Messages   []*domain.Message
+	NextCursor string
+	PrevCursor string
+	HasMore    bool
+}
+
+// ListMessagesPage performs keyset pagination, given a cursor decoded from
+// the caller's before_id/after_id query param (a zero Cursor starts from the
+// most recent message). It over-fetches by one row to compute HasMore
+// without a separate count query.
+func (s *MessageService) ListMessagesPage(
+	ctx context.Context,
+	conversationID, userID int64,
+	cur domain.Cursor,
+	dir domain.Direction,
+	limit int,
+) (*MessagePage, error) {
+	if err := s.checkParticipant(ctx, conversationID, userID); err != nil {
+		return nil, err
+	}
 	if limit <= 0 || limit > s.MaxMessagesPerConversation {
 		limit = s.MaxMessagesPerConversation
 	}
 
-	msgs, err := s.messages.ListForConversationForUser(ctx, conversationID, userID, limit)
+	msgs, err := s.messages.ListPage(ctx, conversationID, userID, cur, dir, limit+1)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("list message page: %w", err)
+	}
+
+	hasMore := len(msgs) > limit
+	if hasMore {
+		msgs = msgs[:limit]
 	}
 
-	// Reverse to chronological order (DB returns DESC)
-	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
-		msgs[i], msgs[j] = msgs[j], msgs[i]
+	// ListPage orders DESC for Backward and ASC for Forward; both need to
+	// come back chronological (oldest first) for the response.
+	if dir == domain.Backward {
+		for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+			msgs[i], msgs[j] = msgs[j], msgs[i]
+		}
+	}
+
+	page := &MessagePage{Messages: msgs, HasMore: hasMore}
+	if len(msgs) > 0 {
+		first, last := msgs[0], msgs[len(msgs)-1]
+		page.NextCursor = domain.Cursor{CreatedAt: first.CreatedAt, ID: first.ID}.String()
+		page.PrevCursor = domain.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.String()
 	}
-	return msgs, nil
+	return page, nil
 }
 
-func (s *MessageService) MarkAllReadInConversation(ctx context.Context, conversationID, callerID int64) error {
-	isParticipant, err := s.participants.IsParticipant(ctx, conversationID, callerID)
+// MessageChanges is what ChangesSince returns: everything a reconnecting
+// client needs to reconcile its local cache without refetching a full
+// window.
+type MessageChanges struct {
+	Upserts    []*domain.Message
+	DeletedIDs []int64
+}
+
+// ChangesSince returns every message created or edited in conversationID
+// since the given time, plus the ids of any the caller's copy should now
+// drop, so a reconnecting client can catch up in one round trip.
+func (s *MessageService) ChangesSince(ctx context.Context, conversationID, userID int64, since time.Time) (*MessageChanges, error) {
+	if err := s.checkParticipant(ctx, conversationID, userID); err != nil {
+		return nil, err
+	}
+	upserts, deletedIDs, err := s.messages.ChangesSince(ctx, conversationID, userID, since)
 	if err != nil {
-		return fmt.Errorf("check participant: %w", err)
+		return nil, fmt.Errorf("list changes: %w", err)
 	}
-	if !isParticipant {
-		return ErrForbidden
+	return &MessageChanges{Upserts: upserts, DeletedIDs: deletedIDs}, nil
+}
+
+func (s *MessageService) checkParticipant(ctx context.Context, conversationID, userID int64) error {
+	conv, err := s.conversations.GetByID(ctx, conversationID)
+	if err != nil {
+		return fmt.Errorf("get conversation: %w", err)
+	}
+	if conv == nil {
+		return errors.New("conversation not found")
+	}
+	return s.policies.Authorize(ctx, userID, conversationID, policies.ActionReadMessages)
+}
+
+func (s *MessageService) MarkAllReadInConversation(ctx context.Context, conversationID, callerID int64) error {
+	if err := s.policies.Authorize(ctx, callerID, conversationID, policies.ActionMarkRead); err != nil {
+		return err
 	}
 
-	return s.messages.MarkAllReadInConversation(ctx, conversationID, callerID)
+	if err := s.messages.MarkAllReadInConversation(ctx, conversationID, callerID); err != nil {
+		return err
+	}
+	s.publish(ctx, domain.NewMessageReadEvent(callerID, conversationID))
+	return nil
 }
 
 // GetParticipantIDs returns user IDs of all conversation participants (for WS broadcasts).
@@ -242,24 +410,26 @@ func (s *MessageService) GetParticipantIDs(ctx context.Context, conversationID i
 
 // MessageResponse mirrors the API response expected by the frontend.
 type MessageResponse struct {
-	ID             int64     `json:"id"`
-	Content        string    `json:"content"`
-	ConversationID int64     `json:"conversation_id"`
-	SenderID       int64     `json:"sender_id"`
-	SenderUsername string    `json:"sender_username"`
-	CreatedAt      time.Time `json:"created_at"`
-	FilePath       *string   `json:"file_path,omitempty"`
-	FileType       *string   `json:"file_type,omitempty"`
-	IsDeleted      bool      `json:"is_deleted"`
-	IsEdited       bool      `json:"is_edited"`
-	IsRead         bool      `json:"is_read"`
+	ID             int64      `json:"id"`
+	Content        string     `json:"content"`
+	ConversationID int64      `json:"conversation_id"`
+	SenderID       int64      `json:"sender_id"`
+	SenderUsername string     `json:"sender_username"`
+	CreatedAt      time.Time  `json:"created_at"`
+	FilePath       *string    `json:"file_path,omitempty"`
+	FileType       *string    `json:"file_type,omitempty"`
+	IsDeleted      bool       `json:"is_deleted"`
+	IsEdited       bool       `json:"is_edited"`
+	IsRead         bool       `json:"is_read"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	ViewOnce       bool       `json:"view_once,omitempty"`
 }
 
 // ToResponse converts a domain message into a decrypted response DTO.
 func (s *MessageService) ToResponse(ctx context.Context, m *domain.Message) (*MessageResponse, error) {
 	content := m.Content
 	if !m.IsDeleted {
-		dec, err := s.encryptor.Decrypt(m.Content)
+		dec, err := s.keys.Decrypt(ctx, m.Content)
 		if err == nil {
 			content = dec
 		}
@@ -281,6 +451,8 @@ func (s *MessageService) ToResponse(ctx context.Context, m *domain.Message) (*Me
 		IsDeleted:      m.IsDeleted,
 		IsEdited:       m.IsEdited,
 		IsRead:         m.IsRead,
+		ExpiresAt:      m.ExpiresAt,
+		ViewOnce:       m.ViewOnce,
 	}, nil
 }
 
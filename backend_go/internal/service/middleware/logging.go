@@ -0,0 +1,184 @@
+// Package middleware provides cross-cutting decorators — logging, metrics,
+// tracing — for the service.Messages and service.Auth interfaces. Each
+// decorator wraps another implementation of the same interface (usually the
+// real MessageService/AuthService, or another decorator), so they compose:
+// NewTracingMessages(NewMetricsMessages(NewLoggingMessages(real, nil))).
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"backend_go/internal/domain"
+	"backend_go/internal/service"
+)
+
+// loggingMessages logs every Messages call's method, duration and outcome
+// via slog, so request-level visibility into MessageService doesn't require
+// ad-hoc log.Printf calls inside the service body itself.
+type loggingMessages struct {
+	next   service.Messages
+	logger *slog.Logger
+}
+
+// NewLoggingMessages wraps next with request logging. A nil logger falls
+// back to slog.Default().
+func NewLoggingMessages(next service.Messages, logger *slog.Logger) service.Messages {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &loggingMessages{next: next, logger: logger}
+}
+
+func (m *loggingMessages) log(ctx context.Context, method string, start time.Time, err error, attrs ...any) {
+	attrs = append(attrs, "method", method, "duration_ms", time.Since(start).Milliseconds())
+	if err != nil {
+		m.logger.ErrorContext(ctx, "messages call failed", append(attrs, "error", err)...)
+		return
+	}
+	m.logger.DebugContext(ctx, "messages call", attrs...)
+}
+
+func (m *loggingMessages) CreateMessage(ctx context.Context, in service.MessageCreateInput, senderID int64) (*domain.Message, error) {
+	start := time.Now()
+	msg, err := m.next.CreateMessage(ctx, in, senderID)
+	m.log(ctx, "CreateMessage", start, err, "conversation_id", in.ConversationID, "sender_id", senderID)
+	return msg, err
+}
+
+func (m *loggingMessages) EditMessage(ctx context.Context, callerID, messageID int64, newContent string) (*domain.Message, error) {
+	start := time.Now()
+	msg, err := m.next.EditMessage(ctx, callerID, messageID, newContent)
+	m.log(ctx, "EditMessage", start, err, "message_id", messageID, "caller_id", callerID)
+	return msg, err
+}
+
+func (m *loggingMessages) DeleteMessage(ctx context.Context, callerID, messageID int64, deleteType string) (*domain.Message, error) {
+	start := time.Now()
+	msg, err := m.next.DeleteMessage(ctx, callerID, messageID, deleteType)
+	m.log(ctx, "DeleteMessage", start, err, "message_id", messageID, "caller_id", callerID, "delete_type", deleteType)
+	return msg, err
+}
+
+func (m *loggingMessages) ForceDeleteMessage(ctx context.Context, moderatorID, messageID int64) (*domain.Message, error) {
+	start := time.Now()
+	msg, err := m.next.ForceDeleteMessage(ctx, moderatorID, messageID)
+	m.log(ctx, "ForceDeleteMessage", start, err, "message_id", messageID, "moderator_id", moderatorID)
+	return msg, err
+}
+
+func (m *loggingMessages) ListMessagesPage(ctx context.Context, conversationID, userID int64, cur domain.Cursor, dir domain.Direction, limit int) (*service.MessagePage, error) {
+	start := time.Now()
+	page, err := m.next.ListMessagesPage(ctx, conversationID, userID, cur, dir, limit)
+	m.log(ctx, "ListMessagesPage", start, err, "conversation_id", conversationID, "caller_id", userID)
+	return page, err
+}
+
+func (m *loggingMessages) ChangesSince(ctx context.Context, conversationID, userID int64, since time.Time) (*service.MessageChanges, error) {
+	start := time.Now()
+	changes, err := m.next.ChangesSince(ctx, conversationID, userID, since)
+	m.log(ctx, "ChangesSince", start, err, "conversation_id", conversationID, "caller_id", userID)
+	return changes, err
+}
+
+func (m *loggingMessages) MarkAllReadInConversation(ctx context.Context, conversationID, callerID int64) error {
+	start := time.Now()
+	err := m.next.MarkAllReadInConversation(ctx, conversationID, callerID)
+	m.log(ctx, "MarkAllReadInConversation", start, err, "conversation_id", conversationID, "caller_id", callerID)
+	return err
+}
+
+func (m *loggingMessages) GetParticipantIDs(ctx context.Context, conversationID int64) ([]int64, error) {
+	start := time.Now()
+	ids, err := m.next.GetParticipantIDs(ctx, conversationID)
+	m.log(ctx, "GetParticipantIDs", start, err, "conversation_id", conversationID)
+	return ids, err
+}
+
+func (m *loggingMessages) ToResponse(ctx context.Context, msg *domain.Message) (*service.MessageResponse, error) {
+	start := time.Now()
+	resp, err := m.next.ToResponse(ctx, msg)
+	m.log(ctx, "ToResponse", start, err)
+	return resp, err
+}
+
+func (m *loggingMessages) ToResponses(ctx context.Context, msgs []*domain.Message) ([]*service.MessageResponse, error) {
+	start := time.Now()
+	resp, err := m.next.ToResponses(ctx, msgs)
+	m.log(ctx, "ToResponses", start, err, "count", len(msgs))
+	return resp, err
+}
+
+// loggingAuth mirrors loggingMessages for the Auth interface.
+type loggingAuth struct {
+	next   service.Auth
+	logger *slog.Logger
+}
+
+// NewLoggingAuth wraps next with request logging. A nil logger falls back to
+// slog.Default().
+func NewLoggingAuth(next service.Auth, logger *slog.Logger) service.Auth {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &loggingAuth{next: next, logger: logger}
+}
+
+func (a *loggingAuth) log(ctx context.Context, method string, start time.Time, err error, attrs ...any) {
+	attrs = append(attrs, "method", method, "duration_ms", time.Since(start).Milliseconds())
+	if err != nil {
+		a.logger.ErrorContext(ctx, "auth call failed", append(attrs, "error", err)...)
+		return
+	}
+	a.logger.DebugContext(ctx, "auth call", attrs...)
+}
+
+func (a *loggingAuth) Register(ctx context.Context, in service.RegisterInput) (*domain.User, error) {
+	start := time.Now()
+	user, err := a.next.Register(ctx, in)
+	a.log(ctx, "Register", start, err, "username", in.Username)
+	return user, err
+}
+
+func (a *loggingAuth) Login(ctx context.Context, in service.LoginInput) (*service.TokenResponse, error) {
+	start := time.Now()
+	resp, err := a.next.Login(ctx, in)
+	a.log(ctx, "Login", start, err, "username", in.Username)
+	return resp, err
+}
+
+func (a *loggingAuth) Refresh(ctx context.Context, rawToken, userAgent, ip string) (*service.TokenResponse, error) {
+	start := time.Now()
+	resp, err := a.next.Refresh(ctx, rawToken, userAgent, ip)
+	a.log(ctx, "Refresh", start, err)
+	return resp, err
+}
+
+func (a *loggingAuth) Logout(ctx context.Context, userID int64, refreshToken string) error {
+	start := time.Now()
+	err := a.next.Logout(ctx, userID, refreshToken)
+	a.log(ctx, "Logout", start, err, "caller_id", userID)
+	return err
+}
+
+func (a *loggingAuth) VerifyEmail(ctx context.Context, token string) error {
+	start := time.Now()
+	err := a.next.VerifyEmail(ctx, token)
+	a.log(ctx, "VerifyEmail", start, err)
+	return err
+}
+
+func (a *loggingAuth) RequestPasswordReset(ctx context.Context, email string) error {
+	start := time.Now()
+	err := a.next.RequestPasswordReset(ctx, email)
+	a.log(ctx, "RequestPasswordReset", start, err)
+	return err
+}
+
+func (a *loggingAuth) ResetPassword(ctx context.Context, token, newPassword string) error {
+	start := time.Now()
+	err := a.next.ResetPassword(ctx, token, newPassword)
+	a.log(ctx, "ResetPassword", start, err)
+	return err
+}
@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"backend_go/internal/domain"
+	"backend_go/internal/service"
+)
+
+// serviceCallsTotal/serviceCallDuration are shared across every decorator in
+// this package (Messages and Auth alike) and labeled by service/method/
+// outcome, rather than one pair of metrics per method, so adding a new
+// wrapped method never requires registering new series.
+var (
+	serviceCallsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "zchat_service_calls_total",
+			Help: "Total service interface calls, labeled by service, method and outcome.",
+		},
+		[]string{"service", "method", "outcome"},
+	)
+	serviceCallDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "zchat_service_call_duration_seconds",
+			Help:    "Service interface call latency in seconds, labeled by service and method.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"service", "method"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(serviceCallsTotal, serviceCallDuration)
+}
+
+func observe(serviceName, method string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	serviceCallsTotal.WithLabelValues(serviceName, method, outcome).Inc()
+	serviceCallDuration.WithLabelValues(serviceName, method).Observe(time.Since(start).Seconds())
+}
+
+// metricsMessages records a call counter and latency histogram for every
+// Messages call.
+type metricsMessages struct {
+	next service.Messages
+}
+
+func NewMetricsMessages(next service.Messages) service.Messages {
+	return &metricsMessages{next: next}
+}
+
+func (m *metricsMessages) CreateMessage(ctx context.Context, in service.MessageCreateInput, senderID int64) (*domain.Message, error) {
+	start := time.Now()
+	msg, err := m.next.CreateMessage(ctx, in, senderID)
+	observe("messages", "CreateMessage", start, err)
+	return msg, err
+}
+
+func (m *metricsMessages) EditMessage(ctx context.Context, callerID, messageID int64, newContent string) (*domain.Message, error) {
+	start := time.Now()
+	msg, err := m.next.EditMessage(ctx, callerID, messageID, newContent)
+	observe("messages", "EditMessage", start, err)
+	return msg, err
+}
+
+func (m *metricsMessages) DeleteMessage(ctx context.Context, callerID, messageID int64, deleteType string) (*domain.Message, error) {
+	start := time.Now()
+	msg, err := m.next.DeleteMessage(ctx, callerID, messageID, deleteType)
+	observe("messages", "DeleteMessage", start, err)
+	return msg, err
+}
+
+func (m *metricsMessages) ForceDeleteMessage(ctx context.Context, moderatorID, messageID int64) (*domain.Message, error) {
+	start := time.Now()
+	msg, err := m.next.ForceDeleteMessage(ctx, moderatorID, messageID)
+	observe("messages", "ForceDeleteMessage", start, err)
+	return msg, err
+}
+
+func (m *metricsMessages) ListMessagesPage(ctx context.Context, conversationID, userID int64, cur domain.Cursor, dir domain.Direction, limit int) (*service.MessagePage, error) {
+	start := time.Now()
+	page, err := m.next.ListMessagesPage(ctx, conversationID, userID, cur, dir, limit)
+	observe("messages", "ListMessagesPage", start, err)
+	return page, err
+}
+
+func (m *metricsMessages) ChangesSince(ctx context.Context, conversationID, userID int64, since time.Time) (*service.MessageChanges, error) {
+	start := time.Now()
+	changes, err := m.next.ChangesSince(ctx, conversationID, userID, since)
+	observe("messages", "ChangesSince", start, err)
+	return changes, err
+}
+
+func (m *metricsMessages) MarkAllReadInConversation(ctx context.Context, conversationID, callerID int64) error {
+	start := time.Now()
+	err := m.next.MarkAllReadInConversation(ctx, conversationID, callerID)
+	observe("messages", "MarkAllReadInConversation", start, err)
+	return err
+}
+
+func (m *metricsMessages) GetParticipantIDs(ctx context.Context, conversationID int64) ([]int64, error) {
+	start := time.Now()
+	ids, err := m.next.GetParticipantIDs(ctx, conversationID)
+	observe("messages", "GetParticipantIDs", start, err)
+	return ids, err
+}
+
+func (m *metricsMessages) ToResponse(ctx context.Context, msg *domain.Message) (*service.MessageResponse, error) {
+	start := time.Now()
+	resp, err := m.next.ToResponse(ctx, msg)
+	observe("messages", "ToResponse", start, err)
+	return resp, err
+}
+
+func (m *metricsMessages) ToResponses(ctx context.Context, msgs []*domain.Message) ([]*service.MessageResponse, error) {
+	start := time.Now()
+	resp, err := m.next.ToResponses(ctx, msgs)
+	observe("messages", "ToResponses", start, err)
+	return resp, err
+}
+
+// metricsAuth mirrors metricsMessages for the Auth interface.
+type metricsAuth struct {
+	next service.Auth
+}
+
+func NewMetricsAuth(next service.Auth) service.Auth {
+	return &metricsAuth{next: next}
+}
+
+func (a *metricsAuth) Register(ctx context.Context, in service.RegisterInput) (*domain.User, error) {
+	start := time.Now()
+	user, err := a.next.Register(ctx, in)
+	observe("auth", "Register", start, err)
+	return user, err
+}
+
+func (a *metricsAuth) Login(ctx context.Context, in service.LoginInput) (*service.TokenResponse, error) {
+	start := time.Now()
+	resp, err := a.next.Login(ctx, in)
+	observe("auth", "Login", start, err)
+	return resp, err
+}
+
+func (a *metricsAuth) Refresh(ctx context.Context, rawToken, userAgent, ip string) (*service.TokenResponse, error) {
+	start := time.Now()
+	resp, err := a.next.Refresh(ctx, rawToken, userAgent, ip)
+	observe("auth", "Refresh", start, err)
+	return resp, err
+}
+
+func (a *metricsAuth) Logout(ctx context.Context, userID int64, refreshToken string) error {
+	start := time.Now()
+	err := a.next.Logout(ctx, userID, refreshToken)
+	observe("auth", "Logout", start, err)
+	return err
+}
+
+func (a *metricsAuth) VerifyEmail(ctx context.Context, token string) error {
+	start := time.Now()
+	err := a.next.VerifyEmail(ctx, token)
+	observe("auth", "VerifyEmail", start, err)
+	return err
+}
+
+func (a *metricsAuth) RequestPasswordReset(ctx context.Context, email string) error {
+	start := time.Now()
+	err := a.next.RequestPasswordReset(ctx, email)
+	observe("auth", "RequestPasswordReset", start, err)
+	return err
+}
+
+func (a *metricsAuth) ResetPassword(ctx context.Context, token, newPassword string) error {
+	start := time.Now()
+	err := a.next.ResetPassword(ctx, token, newPassword)
+	observe("auth", "ResetPassword", start, err)
+	return err
+}
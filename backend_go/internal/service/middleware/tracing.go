@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"backend_go/internal/domain"
+	"backend_go/internal/service"
+)
+
+var tracer = otel.Tracer("backend_go/internal/service")
+
+// finishSpan records err on span, if any, before ending it.
+func finishSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracingMessages opens a span per Messages call, tagged with whichever of
+// conversation_id/message_id/sender_id the call carries.
+type tracingMessages struct {
+	next service.Messages
+}
+
+func NewTracingMessages(next service.Messages) service.Messages {
+	return &tracingMessages{next: next}
+}
+
+func (m *tracingMessages) CreateMessage(ctx context.Context, in service.MessageCreateInput, senderID int64) (*domain.Message, error) {
+	ctx, span := tracer.Start(ctx, "Messages.CreateMessage", trace.WithAttributes(
+		attribute.Int64("conversation_id", in.ConversationID),
+		attribute.Int64("sender_id", senderID),
+	))
+	msg, err := m.next.CreateMessage(ctx, in, senderID)
+	if msg != nil {
+		span.SetAttributes(attribute.Int64("message_id", msg.ID))
+	}
+	finishSpan(span, err)
+	return msg, err
+}
+
+func (m *tracingMessages) EditMessage(ctx context.Context, callerID, messageID int64, newContent string) (*domain.Message, error) {
+	ctx, span := tracer.Start(ctx, "Messages.EditMessage", trace.WithAttributes(
+		attribute.Int64("message_id", messageID),
+		attribute.Int64("sender_id", callerID),
+	))
+	msg, err := m.next.EditMessage(ctx, callerID, messageID, newContent)
+	finishSpan(span, err)
+	return msg, err
+}
+
+func (m *tracingMessages) DeleteMessage(ctx context.Context, callerID, messageID int64, deleteType string) (*domain.Message, error) {
+	ctx, span := tracer.Start(ctx, "Messages.DeleteMessage", trace.WithAttributes(
+		attribute.Int64("message_id", messageID),
+		attribute.Int64("sender_id", callerID),
+		attribute.String("delete_type", deleteType),
+	))
+	msg, err := m.next.DeleteMessage(ctx, callerID, messageID, deleteType)
+	finishSpan(span, err)
+	return msg, err
+}
+
+func (m *tracingMessages) ForceDeleteMessage(ctx context.Context, moderatorID, messageID int64) (*domain.Message, error) {
+	ctx, span := tracer.Start(ctx, "Messages.ForceDeleteMessage", trace.WithAttributes(
+		attribute.Int64("message_id", messageID),
+		attribute.Int64("moderator_id", moderatorID),
+	))
+	msg, err := m.next.ForceDeleteMessage(ctx, moderatorID, messageID)
+	finishSpan(span, err)
+	return msg, err
+}
+
+func (m *tracingMessages) ListMessagesPage(ctx context.Context, conversationID, userID int64, cur domain.Cursor, dir domain.Direction, limit int) (*service.MessagePage, error) {
+	ctx, span := tracer.Start(ctx, "Messages.ListMessagesPage", trace.WithAttributes(
+		attribute.Int64("conversation_id", conversationID),
+		attribute.Int64("sender_id", userID),
+	))
+	page, err := m.next.ListMessagesPage(ctx, conversationID, userID, cur, dir, limit)
+	finishSpan(span, err)
+	return page, err
+}
+
+func (m *tracingMessages) ChangesSince(ctx context.Context, conversationID, userID int64, since time.Time) (*service.MessageChanges, error) {
+	ctx, span := tracer.Start(ctx, "Messages.ChangesSince", trace.WithAttributes(
+		attribute.Int64("conversation_id", conversationID),
+		attribute.Int64("sender_id", userID),
+	))
+	changes, err := m.next.ChangesSince(ctx, conversationID, userID, since)
+	finishSpan(span, err)
+	return changes, err
+}
+
+func (m *tracingMessages) MarkAllReadInConversation(ctx context.Context, conversationID, callerID int64) error {
+	ctx, span := tracer.Start(ctx, "Messages.MarkAllReadInConversation", trace.WithAttributes(
+		attribute.Int64("conversation_id", conversationID),
+		attribute.Int64("sender_id", callerID),
+	))
+	err := m.next.MarkAllReadInConversation(ctx, conversationID, callerID)
+	finishSpan(span, err)
+	return err
+}
+
+func (m *tracingMessages) GetParticipantIDs(ctx context.Context, conversationID int64) ([]int64, error) {
+	ctx, span := tracer.Start(ctx, "Messages.GetParticipantIDs", trace.WithAttributes(
+		attribute.Int64("conversation_id", conversationID),
+	))
+	ids, err := m.next.GetParticipantIDs(ctx, conversationID)
+	finishSpan(span, err)
+	return ids, err
+}
+
+func (m *tracingMessages) ToResponse(ctx context.Context, msg *domain.Message) (*service.MessageResponse, error) {
+	ctx, span := tracer.Start(ctx, "Messages.ToResponse")
+	if msg != nil {
+		span.SetAttributes(attribute.Int64("message_id", msg.ID))
+	}
+	resp, err := m.next.ToResponse(ctx, msg)
+	finishSpan(span, err)
+	return resp, err
+}
+
+func (m *tracingMessages) ToResponses(ctx context.Context, msgs []*domain.Message) ([]*service.MessageResponse, error) {
+	ctx, span := tracer.Start(ctx, "Messages.ToResponses", trace.WithAttributes(
+		attribute.Int("count", len(msgs)),
+	))
+	resp, err := m.next.ToResponses(ctx, msgs)
+	finishSpan(span, err)
+	return resp, err
+}
+
+// tracingAuth mirrors tracingMessages for the Auth interface.
+type tracingAuth struct {
+	next service.Auth
+}
+
+func NewTracingAuth(next service.Auth) service.Auth {
+	return &tracingAuth{next: next}
+}
+
+func (a *tracingAuth) Register(ctx context.Context, in service.RegisterInput) (*domain.User, error) {
+	ctx, span := tracer.Start(ctx, "Auth.Register", trace.WithAttributes(
+		attribute.String("username", in.Username),
+	))
+	user, err := a.next.Register(ctx, in)
+	if user != nil {
+		span.SetAttributes(attribute.Int64("sender_id", user.ID))
+	}
+	finishSpan(span, err)
+	return user, err
+}
+
+func (a *tracingAuth) Login(ctx context.Context, in service.LoginInput) (*service.TokenResponse, error) {
+	ctx, span := tracer.Start(ctx, "Auth.Login", trace.WithAttributes(
+		attribute.String("username", in.Username),
+	))
+	resp, err := a.next.Login(ctx, in)
+	if resp != nil && resp.User != nil {
+		span.SetAttributes(attribute.Int64("sender_id", resp.User.ID))
+	}
+	finishSpan(span, err)
+	return resp, err
+}
+
+func (a *tracingAuth) Refresh(ctx context.Context, rawToken, userAgent, ip string) (*service.TokenResponse, error) {
+	ctx, span := tracer.Start(ctx, "Auth.Refresh")
+	resp, err := a.next.Refresh(ctx, rawToken, userAgent, ip)
+	if resp != nil && resp.User != nil {
+		span.SetAttributes(attribute.Int64("sender_id", resp.User.ID))
+	}
+	finishSpan(span, err)
+	return resp, err
+}
+
+func (a *tracingAuth) Logout(ctx context.Context, userID int64, refreshToken string) error {
+	ctx, span := tracer.Start(ctx, "Auth.Logout", trace.WithAttributes(
+		attribute.Int64("sender_id", userID),
+	))
+	err := a.next.Logout(ctx, userID, refreshToken)
+	finishSpan(span, err)
+	return err
+}
+
+func (a *tracingAuth) VerifyEmail(ctx context.Context, token string) error {
+	ctx, span := tracer.Start(ctx, "Auth.VerifyEmail")
+	err := a.next.VerifyEmail(ctx, token)
+	finishSpan(span, err)
+	return err
+}
+
+func (a *tracingAuth) RequestPasswordReset(ctx context.Context, email string) error {
+	ctx, span := tracer.Start(ctx, "Auth.RequestPasswordReset")
+	err := a.next.RequestPasswordReset(ctx, email)
+	finishSpan(span, err)
+	return err
+}
+
+func (a *tracingAuth) ResetPassword(ctx context.Context, token, newPassword string) error {
+	ctx, span := tracer.Start(ctx, "Auth.ResetPassword")
+	err := a.next.ResetPassword(ctx, token, newPassword)
+	finishSpan(span, err)
+	return err
+}
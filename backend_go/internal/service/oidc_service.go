@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"backend_go/internal/auth/oidc"
+	"backend_go/internal/domain"
+	"backend_go/internal/security"
+)
+
+var usernameSanitizeRe = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// OIDCService resolves an external provider's userinfo into a local session,
+// upserting a user on first login from a given provider.
+type OIDCService struct {
+	users         domain.UserRepository
+	otps          domain.OTPRepository
+	refreshTokens domain.RefreshTokenRepository
+	hash          *security.PasswordWrapper
+	tokens        *security.TokenService
+	defaultTTL    time.Duration
+}
+
+func NewOIDCService(
+	users domain.UserRepository,
+	otps domain.OTPRepository,
+	refreshTokens domain.RefreshTokenRepository,
+	hash *security.PasswordWrapper,
+	tokens *security.TokenService,
+	defaultTTL time.Duration,
+) *OIDCService {
+	return &OIDCService{
+		users:         users,
+		otps:          otps,
+		refreshTokens: refreshTokens,
+		hash:          hash,
+		tokens:        tokens,
+		defaultTTL:    defaultTTL,
+	}
+}
+
+// LoginWithIdentity resolves info to a local user — linking an existing
+// account on a verified email match, or creating one otherwise — and issues
+// the same kind of session (access + refresh token) AuthService.Login does.
+// userAgent and ip are recorded on the issued refresh token for the user's
+// own audit trail.
+func (s *OIDCService) LoginWithIdentity(ctx context.Context, provider string, info *oidc.UserInfo, userAgent, ip string) (*TokenResponse, error) {
+	user, err := s.users.GetByIdentity(ctx, provider, info.Subject)
+	if err != nil && err != domain.ErrNotFound {
+		return nil, fmt.Errorf("lookup identity: %w", err)
+	}
+
+	if user == nil {
+		if info.Email != "" && info.EmailVerified {
+			user, err = s.users.GetByEmail(ctx, info.Email)
+			if err != nil && err != domain.ErrNotFound {
+				return nil, fmt.Errorf("lookup email: %w", err)
+			}
+		}
+		if user == nil {
+			user, err = s.createUserForIdentity(ctx, provider, info)
+			if err != nil {
+				return nil, err
+			}
+		}
+		var email *string
+		if info.Email != "" {
+			email = &info.Email
+		}
+		if err := s.users.LinkIdentity(ctx, user.ID, provider, info.Subject, email); err != nil {
+			return nil, fmt.Errorf("link identity: %w", err)
+		}
+	}
+
+	if !user.IsActive {
+		return nil, errors.New("user account is inactive")
+	}
+
+	// Gate OIDC logins through the same TOTP second factor AuthService.Login
+	// enforces for password logins: without this, a confirmed-TOTP user's
+	// OIDC-minted token carries no "otp" amr entry and is rejected by every
+	// subsequent AuthenticateBearer call, locking them out with no error at
+	// login time.
+	otp, err := s.otps.Get(ctx, user.ID)
+	if err != nil && err != domain.ErrNotFound {
+		return nil, fmt.Errorf("get otp enrollment: %w", err)
+	}
+	if otp != nil && otp.Confirmed {
+		preAuthToken, err := s.tokens.CreatePreAuth(user.Username, otpPreAuthTTL, false)
+		if err != nil {
+			return nil, fmt.Errorf("create pre-auth token: %w", err)
+		}
+		return &TokenResponse{
+			PreAuthToken: preAuthToken,
+			RequiresOTP:  true,
+			TokenType:    "bearer",
+			User:         user,
+		}, nil
+	}
+
+	if err := s.users.SetOnlineStatus(ctx, user.ID, true); err != nil {
+		return nil, fmt.Errorf("set online: %w", err)
+	}
+
+	token, err := s.tokens.CreateWithAMR(user.Username, s.defaultTTL, []string{"oidc"}, []string{string(user.Role)})
+	if err != nil {
+		return nil, fmt.Errorf("create token: %w", err)
+	}
+
+	// OIDC login has no remember_me concept of its own, so the refresh
+	// token always gets the shorter, non-remember-me TTL.
+	refreshToken, err := issueRefreshToken(ctx, s.refreshTokens, user.ID, false, userAgent, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		AccessToken:  token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.defaultTTL.Seconds()),
+		TokenType:    "bearer",
+		User:         user,
+	}, nil
+}
+
+// LinkIdentity attaches info's external identity to an already-authenticated
+// userID, so future logins from provider resolve directly to that user.
+func (s *OIDCService) LinkIdentity(ctx context.Context, userID int64, provider string, info *oidc.UserInfo) error {
+	existing, err := s.users.GetByIdentity(ctx, provider, info.Subject)
+	if err != nil && err != domain.ErrNotFound {
+		return fmt.Errorf("lookup identity: %w", err)
+	}
+	if existing != nil && existing.ID != userID {
+		return errors.New("this identity is already linked to a different account")
+	}
+
+	var email *string
+	if info.Email != "" {
+		email = &info.Email
+	}
+	if err := s.users.LinkIdentity(ctx, userID, provider, info.Subject, email); err != nil {
+		return fmt.Errorf("link identity: %w", err)
+	}
+	return nil
+}
+
+// UnlinkIdentity detaches provider from userID, refusing when userID is an
+// SSOOnly account (its password is a random value it was never shown) and
+// this is its last linked identity — removing it would leave the account
+// with no way to authenticate at all.
+func (s *OIDCService) UnlinkIdentity(ctx context.Context, userID int64, provider string) error {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+	if user == nil {
+		return domain.ErrNotFound
+	}
+
+	if user.SSOOnly {
+		count, err := s.users.CountIdentities(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("count identities: %w", err)
+		}
+		if count <= 1 {
+			return errors.New("cannot unlink the only way to authenticate into this account")
+		}
+	}
+
+	if err := s.users.UnlinkIdentity(ctx, userID, provider); err != nil {
+		return fmt.Errorf("unlink identity: %w", err)
+	}
+	return nil
+}
+
+func (s *OIDCService) createUserForIdentity(ctx context.Context, provider string, info *oidc.UserInfo) (*domain.User, error) {
+	username, err := s.uniqueUsername(ctx, provider, info)
+	if err != nil {
+		return nil, err
+	}
+
+	randomPassword, err := randomToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("generate random password: %w", err)
+	}
+	hashed, err := s.hash.Hash(randomPassword)
+	if err != nil {
+		return nil, fmt.Errorf("hash random password: %w", err)
+	}
+
+	var email *string
+	if info.Email != "" {
+		email = &info.Email
+	}
+
+	user := &domain.User{
+		Username:       username,
+		Email:          email,
+		HashedPassword: hashed,
+		IsActive:       true,
+		SSOOnly:        true,
+	}
+	if err := s.users.Create(ctx, user); err != nil {
+		return nil, fmt.Errorf("create user: %w", err)
+	}
+	return user, nil
+}
+
+// uniqueUsername derives a username candidate from the identity (preferring
+// the email local-part) and appends a random suffix until it's free.
+func (s *OIDCService) uniqueUsername(ctx context.Context, provider string, info *oidc.UserInfo) (string, error) {
+	base := strings.ToLower(provider + "_" + info.Subject)
+	if at := strings.Index(info.Email, "@"); at > 0 {
+		base = strings.ToLower(info.Email[:at])
+	}
+	base = usernameSanitizeRe.ReplaceAllString(base, "")
+	if len(base) < 3 {
+		base = provider + "_" + base
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+
+	candidate := base
+	for i := 0; i < 5; i++ {
+		existing, err := s.users.GetByUsername(ctx, candidate)
+		if err != nil && err != domain.ErrNotFound {
+			return "", fmt.Errorf("check username: %w", err)
+		}
+		if existing == nil {
+			return candidate, nil
+		}
+		suffix, err := randomToken(4)
+		if err != nil {
+			return "", err
+		}
+		candidate = base + "_" + suffix
+	}
+	return "", errors.New("could not allocate a unique username")
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate random token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+
+	"backend_go/internal/domain"
+	"backend_go/internal/security"
+)
+
+// recoveryCodeCount is how many single-use recovery codes are minted each
+// time a user confirms (or reconfirms) TOTP enrollment.
+const recoveryCodeCount = 10
+
+// OTPService manages TOTP enrollment and the second factor of the login flow.
+type OTPService struct {
+	users         domain.UserRepository
+	otps          domain.OTPRepository
+	refreshTokens domain.RefreshTokenRepository
+	totp          *security.TOTP
+	enc           *security.Encryptor
+	hash          *security.PasswordWrapper
+	tokens        *security.TokenService
+	defaultTTL    time.Duration
+	rememberMeTTL time.Duration
+}
+
+func NewOTPService(
+	users domain.UserRepository,
+	otps domain.OTPRepository,
+	refreshTokens domain.RefreshTokenRepository,
+	totp *security.TOTP,
+	enc *security.Encryptor,
+	hash *security.PasswordWrapper,
+	tokens *security.TokenService,
+	defaultTTL time.Duration,
+	rememberMeTTL time.Duration,
+) *OTPService {
+	return &OTPService{
+		users:         users,
+		otps:          otps,
+		refreshTokens: refreshTokens,
+		totp:          totp,
+		enc:           enc,
+		hash:          hash,
+		tokens:        tokens,
+		defaultTTL:    defaultTTL,
+		rememberMeTTL: rememberMeTTL,
+	}
+}
+
+// EnrollResult carries the data an authenticator app needs to add an account.
+type EnrollResult struct {
+	OTPAuthURI string `json:"otpauth_uri"`
+	QRCodePNG  string `json:"qr_code_png"` // base64-encoded PNG
+}
+
+// Enroll generates a new, unconfirmed TOTP secret for the user, replacing any
+// prior unconfirmed enrollment.
+func (s *OTPService) Enroll(ctx context.Context, userID int64, username string) (*EnrollResult, error) {
+	secret, err := s.totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("generate secret: %w", err)
+	}
+	encSecret, err := s.encryptSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.otps.Upsert(ctx, &domain.UserOTP{UserID: userID, Secret: encSecret, Confirmed: false}); err != nil {
+		return nil, fmt.Errorf("store otp enrollment: %w", err)
+	}
+
+	uri := s.totp.URI(secret, username)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return nil, fmt.Errorf("render qr code: %w", err)
+	}
+
+	return &EnrollResult{
+		OTPAuthURI: uri,
+		QRCodePNG:  base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+// ConfirmResult carries the one-time view of freshly minted recovery codes.
+type ConfirmResult struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// Confirm verifies the first TOTP code against a pending enrollment, flips it
+// to confirmed, and mints a fresh batch of recovery codes.
+func (s *OTPService) Confirm(ctx context.Context, userID int64, code string) (*ConfirmResult, error) {
+	otp, err := s.getEnrollment(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if otp == nil {
+		return nil, errors.New("no pending otp enrollment")
+	}
+
+	secret, err := s.decryptSecret(otp.Secret)
+	if err != nil {
+		return nil, err
+	}
+	ok, counter := s.totp.Verify(secret, code, time.Now())
+	if !ok {
+		return nil, errors.New("invalid otp code")
+	}
+
+	if err := s.otps.Confirm(ctx, userID); err != nil {
+		return nil, fmt.Errorf("confirm otp enrollment: %w", err)
+	}
+	if err := s.otps.SetLastCounter(ctx, userID, counter); err != nil {
+		return nil, fmt.Errorf("record otp counter: %w", err)
+	}
+
+	codes, hashes, err := s.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.otps.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, fmt.Errorf("store recovery codes: %w", err)
+	}
+
+	return &ConfirmResult{RecoveryCodes: codes}, nil
+}
+
+// Disable removes a user's TOTP enrollment and recovery codes, after
+// re-verifying their password.
+func (s *OTPService) Disable(ctx context.Context, userID int64, password string) error {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+	if user == nil {
+		return domain.ErrNotFound
+	}
+	if _, err := s.hash.Verify(password, user.HashedPassword); err != nil {
+		return errors.New("incorrect password")
+	}
+	return s.otps.Delete(ctx, userID)
+}
+
+// VerifyLogin exchanges a pre-auth token and a TOTP (or recovery) code for a
+// normal session token whose amr includes "otp", plus a refresh token —
+// honoring the remember_me the pre-auth token carries over from the
+// password-login leg, same as AuthService.Login. userAgent and ip are
+// recorded on the issued refresh token for the user's own audit trail.
+func (s *OTPService) VerifyLogin(ctx context.Context, preAuthToken, code, userAgent, ip string) (*TokenResponse, error) {
+	claims, err := s.tokens.Parse(preAuthToken)
+	if err != nil || !security.IsPreAuth(claims) {
+		return nil, errors.New("invalid or expired pre-auth token")
+	}
+	username, _ := claims["sub"].(string)
+	rememberMe, _ := claims["remember_me"].(bool)
+
+	user, err := s.users.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	if user == nil || !user.IsActive {
+		return nil, errors.New("user not found")
+	}
+
+	otp, err := s.getEnrollment(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if otp == nil || !otp.Confirmed {
+		return nil, errors.New("otp is not enrolled for this user")
+	}
+
+	secret, err := s.decryptSecret(otp.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, counter := s.totp.Verify(secret, code, time.Now())
+	if ok {
+		// Compare-and-set atomically, so two concurrent requests presenting
+		// the same code can't both pass a stale read of LastCounter before
+		// either persists the new one.
+		accepted, err := s.otps.CompareAndSetLastCounter(ctx, user.ID, counter)
+		if err != nil {
+			return nil, fmt.Errorf("record otp counter: %w", err)
+		}
+		if !accepted {
+			// Already-consumed code (or one older than the last accepted):
+			// reject it as a replay rather than granting a second login.
+			ok = false
+		}
+	}
+	if !ok && !s.tryConsumeRecoveryCode(ctx, user.ID, code) {
+		return nil, errors.New("invalid otp code")
+	}
+
+	if err := s.users.SetOnlineStatus(ctx, user.ID, true); err != nil {
+		return nil, fmt.Errorf("set online: %w", err)
+	}
+
+	ttl := s.defaultTTL
+	if rememberMe {
+		ttl = s.rememberMeTTL
+	}
+	token, err := s.tokens.CreateWithAMR(user.Username, ttl, []string{"pwd", "otp"}, []string{string(user.Role)})
+	if err != nil {
+		return nil, fmt.Errorf("create token: %w", err)
+	}
+
+	refreshToken, err := issueRefreshToken(ctx, s.refreshTokens, user.ID, rememberMe, userAgent, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		AccessToken:  token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(ttl.Seconds()),
+		TokenType:    "bearer",
+		User:         user,
+	}, nil
+}
+
+func (s *OTPService) tryConsumeRecoveryCode(ctx context.Context, userID int64, code string) bool {
+	codes, err := s.otps.ListUnusedRecoveryCodes(ctx, userID)
+	if err != nil {
+		return false
+	}
+	for _, c := range codes {
+		if _, err := s.hash.Verify(code, c.CodeHash); err == nil {
+			_ = s.otps.MarkRecoveryCodeUsed(ctx, userID, c.CodeHash)
+			return true
+		}
+	}
+	return false
+}
+
+func (s *OTPService) getEnrollment(ctx context.Context, userID int64) (*domain.UserOTP, error) {
+	otp, err := s.otps.Get(ctx, userID)
+	if err == domain.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get otp enrollment: %w", err)
+	}
+	return otp, nil
+}
+
+func (s *OTPService) encryptSecret(secret []byte) ([]byte, error) {
+	enc, err := s.enc.Encrypt(string(secret))
+	if err != nil {
+		return nil, fmt.Errorf("encrypt otp secret: %w", err)
+	}
+	return []byte(enc), nil
+}
+
+func (s *OTPService) decryptSecret(enc []byte) ([]byte, error) {
+	plain, err := s.enc.Decrypt(string(enc))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt otp secret: %w", err)
+	}
+	return []byte(plain), nil
+}
+
+func (s *OTPService) generateRecoveryCodes() (codes []string, hashes []string, err error) {
+	codes = make([]string, 0, recoveryCodeCount)
+	hashes = make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, fmt.Errorf("generate recovery code: %w", err)
+		}
+		code := hex.EncodeToString(raw)
+		hash, err := s.hash.Hash(code)
+		if err != nil {
+			return nil, nil, fmt.Errorf("hash recovery code: %w", err)
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hash)
+	}
+	return codes, hashes, nil
+}
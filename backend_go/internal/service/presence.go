@@ -0,0 +1,15 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// Presence abstracts the cluster-wide online-user tracker behind ws.Hub.
+// It is defined here rather than imported from ws so the two packages don't
+// import each other; ws.Broker satisfies it structurally.
+type Presence interface {
+	TouchPresence(ctx context.Context, userID int64, instanceID string, ttl time.Duration) error
+	EndPresence(ctx context.Context, userID int64, instanceID string) error
+	OnlineUserIDs(ctx context.Context) ([]int64, error)
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"backend_go/internal/domain"
+)
+
+// ProgressService is the per-device counterpart to
+// MessageService.MarkAllReadInConversation: it lets each of a user's
+// devices report its own furthest-read message instead of the whole
+// account sharing one is_read flag.
+type ProgressService struct {
+	participants domain.ParticipantRepository
+	progress     domain.ProgressRepository
+}
+
+func NewProgressService(participants domain.ParticipantRepository, progress domain.ProgressRepository) *ProgressService {
+	return &ProgressService{participants: participants, progress: progress}
+}
+
+// RecordProgress marks deviceID, on behalf of userID, as having read every
+// message in conversationID up to and including lastReadMessageID.
+func (s *ProgressService) RecordProgress(ctx context.Context, conversationID, userID int64, deviceID string, lastReadMessageID int64, readAt time.Time) error {
+	isParticipant, err := s.participants.IsParticipant(ctx, conversationID, userID)
+	if err != nil {
+		return fmt.Errorf("check participant: %w", err)
+	}
+	if !isParticipant {
+		return ErrForbidden
+	}
+	return s.progress.MarkReadUpTo(ctx, conversationID, userID, deviceID, lastReadMessageID, readAt)
+}
+
+// ListProgress returns the aggregated per-device read state of
+// conversationID, for a participant to inspect who has seen what.
+func (s *ProgressService) ListProgress(ctx context.Context, conversationID, userID int64) ([]*domain.DeviceProgress, error) {
+	isParticipant, err := s.participants.IsParticipant(ctx, conversationID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("check participant: %w", err)
+	}
+	if !isParticipant {
+		return nil, ErrForbidden
+	}
+	return s.progress.ListForConversation(ctx, conversationID)
+}
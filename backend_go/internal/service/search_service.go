@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"backend_go/internal/domain"
+)
+
+// SearchService performs full-text search over a user's own messages. It
+// relies on MessageRepository.Create/Update having mirrored each message's
+// plaintext into Message.SearchText (see that field's doc comment): the
+// store-specific search index is built from that mirror, never from the
+// encrypted Content column, since the index can't hold the encryption key.
+type SearchService struct {
+	conversations domain.ConversationRepository
+	participants  domain.ParticipantRepository
+	search        domain.MessageSearchRepository
+	messages      Messages // reused only to decrypt+enrich results
+}
+
+func NewSearchService(
+	conversations domain.ConversationRepository,
+	participants domain.ParticipantRepository,
+	search domain.MessageSearchRepository,
+	messages Messages,
+) *SearchService {
+	return &SearchService{
+		conversations: conversations,
+		participants:  participants,
+		search:        search,
+		messages:      messages,
+	}
+}
+
+// Search looks up messages matching query, restricted to conversations the
+// caller participates in. When conversationID is non-nil, it searches only
+// that conversation (and the caller must be a participant of it); otherwise
+// it searches every conversation the caller is in.
+func (s *SearchService) Search(
+	ctx context.Context,
+	callerID int64,
+	query string,
+	conversationID *int64,
+	limit int,
+) ([]*MessageResponse, error) {
+	if query == "" {
+		return nil, errors.New("q is required")
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+
+	var conversationIDs []int64
+	if conversationID != nil {
+		isParticipant, err := s.participants.IsParticipant(ctx, *conversationID, callerID)
+		if err != nil {
+			return nil, fmt.Errorf("check participant: %w", err)
+		}
+		if !isParticipant {
+			return nil, ErrForbidden
+		}
+		conversationIDs = []int64{*conversationID}
+	} else {
+		convs, err := s.conversations.ListForUser(ctx, callerID)
+		if err != nil {
+			return nil, fmt.Errorf("list conversations: %w", err)
+		}
+		conversationIDs = make([]int64, len(convs))
+		for i, c := range convs {
+			conversationIDs[i] = c.ID
+		}
+	}
+	if len(conversationIDs) == 0 {
+		return nil, nil
+	}
+
+	msgs, err := s.search.Search(ctx, conversationIDs, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("search messages: %w", err)
+	}
+	return s.messages.ToResponses(ctx, msgs)
+}
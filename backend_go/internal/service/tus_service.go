@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+
+	"backend_go/internal/domain"
+	"backend_go/internal/scan"
+	"backend_go/internal/storage"
+)
+
+// defaultTusGCInterval is how often RunGC sweeps for abandoned uploads.
+const defaultTusGCInterval = 10 * time.Minute
+
+// defaultTusUploadTTL is how long an abandoned resumable upload is kept
+// before the background GC worker reclaims it.
+const defaultTusUploadTTL = 24 * time.Hour
+
+// TusService implements the server side of a tus.io-1.0.0-compatible
+// resumable upload: chunks are appended to a local staging file, fsync'd
+// after every write, and — once the final byte arrives — validated,
+// scanned, and handed to the configured storage.Backend. Only then does the
+// object become visible to anything reading it by key.
+type TusService struct {
+	uploads    domain.TusUploadRepository
+	backend    storage.Backend
+	scanner    scan.Scanner
+	stagingDir string
+	ttl        time.Duration
+}
+
+func NewTusService(uploads domain.TusUploadRepository, backend storage.Backend, scanner scan.Scanner, stagingDir string, ttl time.Duration) (*TusService, error) {
+	if ttl <= 0 {
+		ttl = defaultTusUploadTTL
+	}
+	if err := os.MkdirAll(stagingDir, 0o755); err != nil {
+		return nil, fmt.Errorf("tus service: create staging dir: %w", err)
+	}
+	return &TusService{uploads: uploads, backend: backend, scanner: scanner, stagingDir: stagingDir, ttl: ttl}, nil
+}
+
+func (s *TusService) stagingPath(id string) string {
+	return filepath.Join(s.stagingDir, id+".part")
+}
+
+// CreateUpload registers a new resumable upload and its empty staging file.
+// key is the eventual storage.Backend object key the bytes will be moved to
+// on completion.
+func (s *TusService) CreateUpload(ctx context.Context, ownerID int64, key, contentType string, size int64, metadata string) (*domain.TusUpload, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("upload length must be positive")
+	}
+
+	u := &domain.TusUpload{
+		ID:          uuid.New().String(),
+		OwnerID:     ownerID,
+		Key:         key,
+		ContentType: contentType,
+		Size:        size,
+		Offset:      0,
+		Metadata:    metadata,
+		ExpiresAt:   time.Now().Add(s.ttl),
+	}
+
+	staging, err := os.Create(s.stagingPath(u.ID))
+	if err != nil {
+		return nil, fmt.Errorf("create staging file: %w", err)
+	}
+	staging.Close()
+
+	if err := s.uploads.Create(ctx, u); err != nil {
+		os.Remove(s.stagingPath(u.ID))
+		return nil, fmt.Errorf("create upload record: %w", err)
+	}
+	return u, nil
+}
+
+// GetUpload returns the current state of a resumable upload, for HEAD
+// requests and ownership checks.
+func (s *TusService) GetUpload(ctx context.Context, id string) (*domain.TusUpload, error) {
+	return s.uploads.GetByID(ctx, id)
+}
+
+// AppendChunk writes body to the upload's staging file starting at
+// expectedOffset, fsyncs it, and persists the new offset. If the upload is
+// now complete it is validated, scanned, and moved into the storage backend
+// before AppendChunk returns completed=true.
+func (s *TusService) AppendChunk(ctx context.Context, id string, expectedOffset int64, body io.Reader) (newOffset int64, completed bool, err error) {
+	u, err := s.uploads.GetByID(ctx, id)
+	if err != nil {
+		return 0, false, err
+	}
+	if expectedOffset != u.Offset {
+		return 0, false, domain.ErrConflict
+	}
+
+	f, err := os.OpenFile(s.stagingPath(id), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return 0, false, fmt.Errorf("open staging file: %w", err)
+	}
+
+	n, copyErr := io.Copy(f, io.LimitReader(body, u.Size-u.Offset))
+	if copyErr == nil {
+		copyErr = f.Sync()
+	}
+	if closeErr := f.Close(); copyErr == nil {
+		copyErr = closeErr
+	}
+	if copyErr != nil {
+		return 0, false, fmt.Errorf("append chunk: %w", copyErr)
+	}
+
+	newOffset = u.Offset + n
+	if err := s.uploads.UpdateOffset(ctx, id, newOffset); err != nil {
+		return 0, false, fmt.Errorf("persist upload offset: %w", err)
+	}
+	u.Offset = newOffset
+
+	if u.Offset < u.Size {
+		return newOffset, false, nil
+	}
+	if err := s.finalize(ctx, u); err != nil {
+		return newOffset, false, err
+	}
+	return newOffset, true, nil
+}
+
+// finalize scans the completed staging file and, if clean, hands it to the
+// storage backend under the upload's key before deleting the in-progress
+// record. A failed scan quarantines (deletes) the staging file instead of
+// ever letting it become reachable by key.
+func (s *TusService) finalize(ctx context.Context, u *domain.TusUpload) error {
+	path := s.stagingPath(u.ID)
+
+	scanResult, err := func() (scan.Result, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return scan.Result{}, fmt.Errorf("open staging file for scan: %w", err)
+		}
+		defer f.Close()
+		return s.scanner.Scan(ctx, f)
+	}()
+	if err != nil {
+		return fmt.Errorf("scan upload: %w", err)
+	}
+	if !scanResult.Clean {
+		os.Remove(path)
+		_ = s.uploads.Delete(ctx, u.ID)
+		return fmt.Errorf("upload quarantined: %s", scanResult.Signature)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open staging file for upload: %w", err)
+	}
+	putErr := s.backend.Put(ctx, u.Key, u.ContentType, u.Size, f)
+	f.Close()
+	if putErr != nil {
+		return fmt.Errorf("store completed upload: %w", putErr)
+	}
+
+	os.Remove(path)
+	return s.uploads.Delete(ctx, u.ID)
+}
+
+// CleanupExpired deletes every upload (and its staging file) whose
+// ExpiresAt has passed without completing. It is the core of the
+// background GC worker.
+func (s *TusService) CleanupExpired(ctx context.Context, before time.Time) (int, error) {
+	expired, err := s.uploads.ListExpired(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("list expired uploads: %w", err)
+	}
+	for _, u := range expired {
+		os.Remove(s.stagingPath(u.ID))
+		if err := s.uploads.Delete(ctx, u.ID); err != nil {
+			return 0, fmt.Errorf("delete expired upload %s: %w", u.ID, err)
+		}
+	}
+	return len(expired), nil
+}
+
+// RunGC periodically reclaims uploads abandoned before completion, until ctx
+// is cancelled. Each tick deletes every uploads_in_progress row (and its
+// staging file) whose ExpiresAt has already passed.
+func (s *TusService) RunGC(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultTusGCInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := s.CleanupExpired(ctx, time.Now()); err != nil {
+				log.Printf("tus: gc sweep: %v", err)
+			} else if n > 0 {
+				log.Printf("tus: gc reclaimed %d abandoned upload(s)", n)
+			}
+		}
+	}
+}
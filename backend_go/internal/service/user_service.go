@@ -3,17 +3,28 @@ package service
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"backend_go/internal/domain"
 )
 
-// UserService provides user-related operations.
+// UserService provides user-related operations. presence, when non-nil,
+// backs SetOnlineStatus/ListOnline with cluster-wide presence from ws.Hub's
+// Broker instead of only this instance's view of the database.
 type UserService struct {
-	users domain.UserRepository
+	users       domain.UserRepository
+	presence    Presence
+	instanceID  string
+	presenceTTL time.Duration
 }
 
-func NewUserService(users domain.UserRepository) *UserService {
-	return &UserService{users: users}
+func NewUserService(users domain.UserRepository, presence Presence, instanceID string, presenceTTL time.Duration) *UserService {
+	return &UserService{
+		users:       users,
+		presence:    presence,
+		instanceID:  instanceID,
+		presenceTTL: presenceTTL,
+	}
 }
 
 func (s *UserService) GetByID(ctx context.Context, id int64) (*domain.User, error) {
@@ -24,16 +35,48 @@ func (s *UserService) ListActive(ctx context.Context, offset, limit int) ([]*dom
 	return s.users.ListActive(ctx, offset, limit)
 }
 
+// ListOnline returns every user currently online anywhere in the cluster.
+// Without a Presence tracker wired in, it falls back to this instance's
+// single-node is_online column.
 func (s *UserService) ListOnline(ctx context.Context) ([]*domain.User, error) {
-	return s.users.ListOnline(ctx)
+	if s.presence == nil {
+		return s.users.ListOnline(ctx)
+	}
+	ids, err := s.presence.OnlineUserIDs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list online user ids: %w", err)
+	}
+	users := make([]*domain.User, 0, len(ids))
+	for _, id := range ids {
+		u, err := s.users.GetByID(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("get user %d: %w", id, err)
+		}
+		if u != nil {
+			users = append(users, u)
+		}
+	}
+	return users, nil
 }
 
 func (s *UserService) SoftDelete(ctx context.Context, id int64) error {
 	return s.users.SoftDelete(ctx, id)
 }
 
+// SetOnlineStatus persists isOnline on the user row and, when a cluster
+// Presence tracker is wired in, refreshes or clears this instance's
+// presence entry alongside it.
 func (s *UserService) SetOnlineStatus(ctx context.Context, id int64, isOnline bool) error {
-	return s.users.SetOnlineStatus(ctx, id, isOnline)
+	if err := s.users.SetOnlineStatus(ctx, id, isOnline); err != nil {
+		return err
+	}
+	if s.presence == nil {
+		return nil
+	}
+	if isOnline {
+		return s.presence.TouchPresence(ctx, id, s.instanceID, s.presenceTTL)
+	}
+	return s.presence.EndPresence(ctx, id, s.instanceID)
 }
 
 // UserStats is a simplified version of the Python service stats.
@@ -0,0 +1,50 @@
+// Package storage abstracts the object-storage backend used for message
+// attachments, so the HTTP layer never has to know whether bytes end up on
+// local disk, S3, or a MinIO/S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotConfigured is returned by a backend constructor when the required
+// configuration for that backend is missing.
+var ErrNotConfigured = errors.New("storage backend not configured")
+
+// PresignedUpload is the result of requesting a direct-upload URL.
+type PresignedUpload struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Backend is implemented by every object-storage driver. Keys are opaque
+// strings chosen by the caller (the attachments table stores them); callers
+// should not assume any particular layout.
+type Backend interface {
+	// PresignPut returns a URL the client can PUT the object bytes to
+	// directly, scoped to the given key, content type and size.
+	PresignPut(ctx context.Context, key, contentType string, size int64) (PresignedUpload, error)
+
+	// PresignGet returns a short-lived URL the client can GET the object
+	// from directly.
+	PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error)
+
+	// Put uploads an object on the server's behalf instead of handing the
+	// caller a presigned URL. Used by flows where bytes necessarily pass
+	// through this process already, e.g. completing a resumable upload
+	// assembled on local disk.
+	Put(ctx context.Context, key, contentType string, size int64, r io.Reader) error
+
+	// Get opens the object at key for reading on the server's behalf,
+	// instead of handing the caller a presigned URL. Used where this
+	// process needs the raw bytes itself, e.g. decrypting an encrypted
+	// attachment on the way out (see AttachmentService.OpenDecrypted).
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Delete removes the object at key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// Settings carries the subset of config.Config needed to select and build
+// a Backend. Kept as its own struct (rather than importing config directly)
+// to avoid an import cycle between storage and config.
+type Settings struct {
+	Backend      string // "local" | "s3" | "minio" | "s3_compatible"
+	LocalDir     string
+	PublicURL    string
+	SignSecret   []byte
+	Bucket       string
+	Region       string
+	Endpoint     string
+	AccessKey    string
+	SecretKey    string
+	UseSSL       bool
+	UsePathStyle bool
+}
+
+// New selects and constructs a Backend according to Settings.Backend.
+func New(ctx context.Context, s Settings) (Backend, error) {
+	switch s.Backend {
+	case "", "local":
+		return NewLocalBackend(s.LocalDir, s.PublicURL, s.SignSecret)
+	case "s3":
+		return NewS3Backend(ctx, S3Config{
+			Bucket:       s.Bucket,
+			Region:       s.Region,
+			Endpoint:     s.Endpoint,
+			AccessKey:    s.AccessKey,
+			SecretKey:    s.SecretKey,
+			UsePathStyle: s.UsePathStyle,
+		})
+	case "minio":
+		return NewMinIOBackend(ctx, MinIOConfig{
+			Endpoint:  s.Endpoint,
+			Bucket:    s.Bucket,
+			AccessKey: s.AccessKey,
+			SecretKey: s.SecretKey,
+			UseSSL:    s.UseSSL,
+		})
+	case "s3_compatible":
+		return NewGenericS3CompatibleBackend(ctx, S3Config{
+			Bucket:    s.Bucket,
+			Region:    s.Region,
+			Endpoint:  s.Endpoint,
+			AccessKey: s.AccessKey,
+			SecretKey: s.SecretKey,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", s.Backend)
+	}
+}
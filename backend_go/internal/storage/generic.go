@@ -0,0 +1,16 @@
+package storage
+
+import "context"
+
+// NewGenericS3CompatibleBackend builds a Backend against any S3-compatible
+// provider (Backblaze B2, DigitalOcean Spaces, Wasabi, etc.) that is not
+// MinIO itself. It reuses the AWS SDK presign client with path-style
+// addressing and a caller-supplied endpoint, since most such providers only
+// differ from AWS S3 in endpoint and bucket addressing style.
+func NewGenericS3CompatibleBackend(ctx context.Context, cfg S3Config) (*S3Backend, error) {
+	if cfg.Endpoint == "" {
+		return nil, ErrNotConfigured
+	}
+	cfg.UsePathStyle = true
+	return NewS3Backend(ctx, cfg)
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalBackend stores objects on local disk under baseDir. Since plain
+// files have no native presigned-URL concept, PresignPut/PresignGet mint a
+// short-lived HMAC-signed URL against the local "/api/attachments/local/"
+// endpoint registered in httpserver, which verifies the signature before
+// allowing the PUT or GET through.
+type LocalBackend struct {
+	baseDir   string
+	publicURL string // e.g. "http://localhost:8000/api/attachments/local"
+	secret    []byte
+}
+
+// NewLocalBackend constructs a disk-backed Backend. publicURL is the
+// externally reachable prefix clients should PUT/GET against.
+func NewLocalBackend(baseDir, publicURL string, secret []byte) (*LocalBackend, error) {
+	if baseDir == "" {
+		return nil, fmt.Errorf("local storage: base dir must not be empty")
+	}
+	if err := os.MkdirAll(baseDir, 0o755); err != nil {
+		return nil, fmt.Errorf("local storage: create base dir: %w", err)
+	}
+	return &LocalBackend{baseDir: baseDir, publicURL: strings.TrimRight(publicURL, "/"), secret: secret}, nil
+}
+
+var _ Backend = (*LocalBackend)(nil)
+
+// Path returns the on-disk path for a given object key.
+func (b *LocalBackend) Path(key string) string {
+	return filepath.Join(b.baseDir, filepath.FromSlash(key))
+}
+
+func (b *LocalBackend) sign(key, method string, exp int64) string {
+	mac := hmac.New(sha256.New, b.secret)
+	fmt.Fprintf(mac, "%s:%s:%d", method, key, exp)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks a (method, key, exp, sig) tuple produced by
+// PresignPut/PresignGet. Used by the local-upload HTTP handler.
+func (b *LocalBackend) VerifySignature(key, method, sig string, exp int64) bool {
+	if time.Now().Unix() > exp {
+		return false
+	}
+	want := b.sign(key, method, exp)
+	return hmac.Equal([]byte(want), []byte(sig))
+}
+
+func (b *LocalBackend) signedURL(key, method string, ttl time.Duration) string {
+	exp := time.Now().Add(ttl).Unix()
+	sig := b.sign(key, method, exp)
+	return fmt.Sprintf("%s/%s?exp=%d&sig=%s", b.publicURL, base64.RawURLEncoding.EncodeToString([]byte(key)), exp, sig)
+}
+
+func (b *LocalBackend) PresignPut(ctx context.Context, key, contentType string, size int64) (PresignedUpload, error) {
+	return PresignedUpload{
+		URL:    b.signedURL(key, "PUT", 15*time.Minute),
+		Method: "PUT",
+		Headers: map[string]string{
+			"Content-Type": contentType,
+		},
+	}, nil
+}
+
+func (b *LocalBackend) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return b.signedURL(key, "GET", ttl), nil
+}
+
+func (b *LocalBackend) Put(ctx context.Context, key, contentType string, size int64, r io.Reader) error {
+	dst := b.Path(key)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("local storage: create parent dir: %w", err)
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("local storage: create %s: %w", key, err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		os.Remove(dst)
+		return fmt.Errorf("local storage: write %s: %w", key, err)
+	}
+	return nil
+}
+
+func (b *LocalBackend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(b.Path(key))
+	if err != nil {
+		return nil, fmt.Errorf("local storage: open %s: %w", key, err)
+	}
+	return f, nil
+}
+
+func (b *LocalBackend) Delete(ctx context.Context, key string) error {
+	err := os.Remove(b.Path(key))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("local storage: delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// DecodeKey reverses the base64 key segment embedded in a signed local URL path.
+func DecodeKey(encoded string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("decode key: %w", err)
+	}
+	return string(raw), nil
+}
+
+// ParseExpiry parses the "exp" query parameter used by signed local URLs.
+func ParseExpiry(s string) (int64, error) {
+	return strconv.ParseInt(s, 10, 64)
+}
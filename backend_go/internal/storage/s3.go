@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Config configures an S3 or S3-compatible backend. Endpoint is left
+// empty for real AWS S3; setting it (plus UsePathStyle) targets MinIO or
+// any other S3-compatible provider.
+type S3Config struct {
+	Bucket       string
+	Region       string
+	Endpoint     string // optional, for S3-compatible providers
+	AccessKey    string
+	SecretKey    string
+	UsePathStyle bool
+}
+
+// S3Backend presigns PUT/GET URLs against an S3 (or S3-compatible) bucket
+// using the AWS SDK's presign client.
+type S3Backend struct {
+	bucket  string
+	client  *s3.Client
+	presign *s3.PresignClient
+}
+
+// NewS3Backend builds a Backend backed by AWS S3 or any S3-compatible
+// endpoint configured via S3Config.
+func NewS3Backend(ctx context.Context, cfg S3Config) (*S3Backend, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("%w: s3 bucket is required", ErrNotConfigured)
+	}
+
+	var optFns []func(*config.LoadOptions) error
+	if cfg.Region != "" {
+		optFns = append(optFns, config.WithRegion(cfg.Region))
+	}
+	if cfg.AccessKey != "" && cfg.SecretKey != "" {
+		optFns = append(optFns, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKey, cfg.SecretKey, ""),
+		))
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return nil, fmt.Errorf("load aws config: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.UsePathStyle
+	})
+
+	return &S3Backend{
+		bucket:  cfg.Bucket,
+		client:  client,
+		presign: s3.NewPresignClient(client),
+	}, nil
+}
+
+var _ Backend = (*S3Backend)(nil)
+
+func (b *S3Backend) PresignPut(ctx context.Context, key, contentType string, size int64) (PresignedUpload, error) {
+	req, err := b.presign.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(b.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	}, s3.WithPresignExpires(15*time.Minute))
+	if err != nil {
+		return PresignedUpload{}, fmt.Errorf("presign put: %w", err)
+	}
+	return PresignedUpload{
+		URL:     req.URL,
+		Method:  req.Method,
+		Headers: flattenSignedHeaders(req.SignedHeader),
+	}, nil
+}
+
+func (b *S3Backend) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	req, err := b.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("presign get: %w", err)
+	}
+	return req.URL, nil
+}
+
+func (b *S3Backend) Put(ctx context.Context, key, contentType string, size int64, r io.Reader) error {
+	_, err := b.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(b.bucket),
+		Key:           aws.String(key),
+		Body:          r,
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+	})
+	if err != nil {
+		return fmt.Errorf("s3 put %s: %w", key, err)
+	}
+	return nil
+}
+
+func (b *S3Backend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := b.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("s3 get %s: %w", key, err)
+	}
+	return out.Body, nil
+}
+
+func (b *S3Backend) Delete(ctx context.Context, key string) error {
+	_, err := b.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("s3 delete %s: %w", key, err)
+	}
+	return nil
+}
+
+func flattenSignedHeaders(h map[string][]string) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		if len(v) > 0 {
+			out[k] = v[0]
+		}
+	}
+	return out
+}
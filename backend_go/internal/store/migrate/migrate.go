@@ -0,0 +1,148 @@
+// Package migrate implements a small versioned, ledger-backed SQL migration
+// runner shared by the postgres and sqlite stores. Each backend supplies its
+// own dialect-specific ledger DDL and list of migrations; this package only
+// knows how to order them, track which have run, and apply/revert them.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// Migration is a single versioned schema change with its rollback.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Engine applies Migrations against a *sql.DB using a dialect-specific ledger.
+type Engine struct {
+	db               *sql.DB
+	createLedgerSQL  string
+	insertLedgerSQL  string // takes (version, name)
+	deleteLedgerSQL  string // takes (version)
+	selectAppliedSQL string
+}
+
+// New builds an Engine. The SQL strings are provided by the caller because
+// placeholder syntax ($1 vs ?) and timestamp defaults differ between
+// postgres and sqlite.
+func New(db *sql.DB, createLedgerSQL, insertLedgerSQL, deleteLedgerSQL, selectAppliedSQL string) *Engine {
+	return &Engine{
+		db:               db,
+		createLedgerSQL:  createLedgerSQL,
+		insertLedgerSQL:  insertLedgerSQL,
+		deleteLedgerSQL:  deleteLedgerSQL,
+		selectAppliedSQL: selectAppliedSQL,
+	}
+}
+
+func sortedByVersion(migrations []Migration) []Migration {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return sorted
+}
+
+func (e *Engine) appliedVersions(ctx context.Context) (map[int]bool, error) {
+	rows, err := e.db.QueryContext(ctx, e.selectAppliedSQL)
+	if err != nil {
+		return nil, fmt.Errorf("select applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, fmt.Errorf("scan applied migration: %w", err)
+		}
+		applied[v] = true
+	}
+	return applied, rows.Err()
+}
+
+// Up applies every migration whose version is not yet recorded in the
+// ledger, in ascending version order, each inside its own transaction.
+func (e *Engine) Up(ctx context.Context, migrations []Migration) error {
+	if _, err := e.db.ExecContext(ctx, e.createLedgerSQL); err != nil {
+		return fmt.Errorf("create migration ledger: %w", err)
+	}
+
+	applied, err := e.appliedVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range sortedByVersion(migrations) {
+		if applied[m.Version] {
+			continue
+		}
+
+		tx, err := e.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("begin migration %d tx: %w", m.Version, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("apply migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx, e.insertLedgerSQL, m.Version, m.Name); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("record migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+// Down reverts the `steps` most recently applied migrations, in descending
+// version order.
+func (e *Engine) Down(ctx context.Context, migrations []Migration, steps int) error {
+	if steps <= 0 {
+		return nil
+	}
+
+	applied, err := e.appliedVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	byVersion := make(map[int]Migration, len(migrations))
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+
+	ordered := sortedByVersion(migrations)
+	for i := len(ordered) - 1; i >= 0 && steps > 0; i-- {
+		m := ordered[i]
+		if !applied[m.Version] {
+			continue
+		}
+
+		tx, err := e.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("begin rollback %d tx: %w", m.Version, err)
+		}
+		if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("revert migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx, e.deleteLedgerSQL, m.Version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("unrecord migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit rollback %d (%s): %w", m.Version, m.Name, err)
+		}
+		steps--
+	}
+	return nil
+}
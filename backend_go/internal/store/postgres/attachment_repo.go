@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"backend_go/internal/domain"
+)
+
+type AttachmentRepo struct {
+	db *sql.DB
+}
+
+func NewAttachmentRepo(db *sql.DB) *AttachmentRepo {
+	return &AttachmentRepo{db: db}
+}
+
+var _ domain.AttachmentRepository = (*AttachmentRepo)(nil)
+
+func (r *AttachmentRepo) Create(ctx context.Context, a *domain.Attachment) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO attachments (owner_id, key, content_type, size, sha256, wrapped_key, kek_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		RETURNING id, created_at
+	`, a.OwnerID, a.Key, a.ContentType, a.Size, a.SHA256, a.WrappedKey, a.KEKID,
+	).Scan(&a.ID, &a.CreatedAt)
+}
+
+func (r *AttachmentRepo) GetByID(ctx context.Context, id int64) (*domain.Attachment, error) {
+	a := &domain.Attachment{}
+	var kekID sql.NullInt64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, owner_id, key, content_type, size, sha256, wrapped_key, kek_id, created_at
+		FROM attachments WHERE id = $1
+	`, id).Scan(&a.ID, &a.OwnerID, &a.Key, &a.ContentType, &a.Size, &a.SHA256, &a.WrappedKey, &kekID, &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get attachment: %w", err)
+	}
+	a.KEKID = uint32(kekID.Int64)
+	return a, nil
+}
+
+// FindBySHA256 looks up an attachment already stored under sum, for
+// content-addressed dedup on upload.
+func (r *AttachmentRepo) FindBySHA256(ctx context.Context, sum string) (*domain.Attachment, error) {
+	a := &domain.Attachment{}
+	var kekID sql.NullInt64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, owner_id, key, content_type, size, sha256, wrapped_key, kek_id, created_at
+		FROM attachments WHERE sha256 = $1
+		LIMIT 1
+	`, sum).Scan(&a.ID, &a.OwnerID, &a.Key, &a.ContentType, &a.Size, &a.SHA256, &a.WrappedKey, &kekID, &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find attachment by sha256: %w", err)
+	}
+	a.KEKID = uint32(kekID.Int64)
+	return a, nil
+}
+
+func (r *AttachmentRepo) Delete(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM attachments WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete attachment: %w", err)
+	}
+	return nil
+}
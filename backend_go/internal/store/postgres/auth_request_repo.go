@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"backend_go/internal/domain"
+)
+
+type AuthRequestRepo struct {
+	db *sql.DB
+}
+
+func NewAuthRequestRepo(db *sql.DB) *AuthRequestRepo {
+	return &AuthRequestRepo{db: db}
+}
+
+var _ domain.AuthRequestRepository = (*AuthRequestRepo)(nil)
+
+func (r *AuthRequestRepo) Create(ctx context.Context, req *domain.AuthRequest) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO oauth_auth_requests
+			(code, client_id, user_id, redirect_uri, scope, state, code_challenge, code_challenge_method, expires_at, used)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, req.Code, req.ClientID, req.UserID, req.RedirectURI, req.Scope, req.State,
+		req.CodeChallenge, req.CodeChallengeMethod, req.ExpiresAt, req.Used)
+	if err != nil {
+		return fmt.Errorf("create auth request: %w", err)
+	}
+	return nil
+}
+
+func (r *AuthRequestRepo) GetByCode(ctx context.Context, code string) (*domain.AuthRequest, error) {
+	req := &domain.AuthRequest{}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT code, client_id, user_id, redirect_uri, scope, state, code_challenge, code_challenge_method, expires_at, used
+		FROM oauth_auth_requests WHERE code = $1
+	`, code).Scan(&req.Code, &req.ClientID, &req.UserID, &req.RedirectURI, &req.Scope, &req.State,
+		&req.CodeChallenge, &req.CodeChallengeMethod, &req.ExpiresAt, &req.Used)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get auth request: %w", err)
+	}
+	return req, nil
+}
+
+func (r *AuthRequestRepo) MarkUsed(ctx context.Context, code string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE oauth_auth_requests SET used = TRUE WHERE code = $1`, code)
+	if err != nil {
+		return fmt.Errorf("mark auth request used: %w", err)
+	}
+	return nil
+}
+
+func (r *AuthRequestRepo) DeleteExpired(ctx context.Context, cutoff time.Time) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM oauth_auth_requests WHERE expires_at < $1`, cutoff)
+	if err != nil {
+		return fmt.Errorf("delete expired auth requests: %w", err)
+	}
+	return nil
+}
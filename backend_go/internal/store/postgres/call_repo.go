@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"backend_go/internal/domain"
+)
+
+type CallRepo struct {
+	db *sql.DB
+}
+
+func NewCallRepo(db *sql.DB) *CallRepo {
+	return &CallRepo{db: db}
+}
+
+var _ domain.CallRepository = (*CallRepo)(nil)
+
+func (r *CallRepo) Create(ctx context.Context, c *domain.Call) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO calls (id, conversation_id, caller_id, callee_id, outcome)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING started_at
+	`, c.ID, c.ConversationID, c.CallerID, c.CalleeID, c.Outcome,
+	).Scan(&c.StartedAt)
+}
+
+func (r *CallRepo) GetByID(ctx context.Context, id string) (*domain.Call, error) {
+	c := &domain.Call{}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, conversation_id, caller_id, callee_id, started_at, ended_at, outcome
+		FROM calls WHERE id = $1
+	`, id).Scan(&c.ID, &c.ConversationID, &c.CallerID, &c.CalleeID, &c.StartedAt, &c.EndedAt, &c.Outcome)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get call: %w", err)
+	}
+	return c, nil
+}
+
+func (r *CallRepo) End(ctx context.Context, id string, endedAt time.Time, outcome string) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE calls SET ended_at = $2, outcome = $3 WHERE id = $1
+	`, id, endedAt, outcome)
+	if err != nil {
+		return fmt.Errorf("end call: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("end call rows affected: %w", err)
+	}
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *CallRepo) ListForConversation(ctx context.Context, conversationID int64, limit int) ([]*domain.Call, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, conversation_id, caller_id, callee_id, started_at, ended_at, outcome
+		FROM calls WHERE conversation_id = $1
+		ORDER BY started_at DESC LIMIT $2
+	`, conversationID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list calls: %w", err)
+	}
+	defer rows.Close()
+
+	var calls []*domain.Call
+	for rows.Next() {
+		c := &domain.Call{}
+		if err := rows.Scan(&c.ID, &c.ConversationID, &c.CallerID, &c.CalleeID, &c.StartedAt, &c.EndedAt, &c.Outcome); err != nil {
+			return nil, fmt.Errorf("scan call: %w", err)
+		}
+		calls = append(calls, c)
+	}
+	return calls, rows.Err()
+}
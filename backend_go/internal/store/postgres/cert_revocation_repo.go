@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"backend_go/internal/domain"
+)
+
+type CertRevocationRepo struct {
+	db *sql.DB
+}
+
+func NewCertRevocationRepo(db *sql.DB) *CertRevocationRepo {
+	return &CertRevocationRepo{db: db}
+}
+
+var _ domain.CertRevocationRepository = (*CertRevocationRepo)(nil)
+
+func (r *CertRevocationRepo) IsRevoked(ctx context.Context, serialHex string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM revoked_certificates WHERE serial_hex = $1)`,
+		serialHex,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("check certificate revocation: %w", err)
+	}
+	return exists, nil
+}
+
+func (r *CertRevocationRepo) Revoke(ctx context.Context, serialHex, subject, reason string) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO revoked_certificates (serial_hex, subject, reason, revoked_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (serial_hex) DO NOTHING
+	`, serialHex, subject, reason)
+	if err != nil {
+		return fmt.Errorf("revoke certificate: %w", err)
+	}
+	return nil
+}
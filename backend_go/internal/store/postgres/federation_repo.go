@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"backend_go/internal/domain"
+)
+
+type FederationRepo struct {
+	db *sql.DB
+}
+
+func NewFederationRepo(db *sql.DB) *FederationRepo {
+	return &FederationRepo{db: db}
+}
+
+var _ domain.FederationRepository = (*FederationRepo)(nil)
+
+func (r *FederationRepo) RemoteParticipants(ctx context.Context, conversationID int64) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT handle FROM federation_remote_participants WHERE conversation_id = $1
+	`, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("list remote participants: %w", err)
+	}
+	defer rows.Close()
+
+	var handles []string
+	for rows.Next() {
+		var h string
+		if err := rows.Scan(&h); err != nil {
+			return nil, fmt.Errorf("scan remote participant: %w", err)
+		}
+		handles = append(handles, h)
+	}
+	return handles, rows.Err()
+}
+
+func (r *FederationRepo) AddRemoteParticipant(ctx context.Context, conversationID int64, handle string) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO federation_remote_participants (conversation_id, handle)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, conversationID, handle)
+	if err != nil {
+		return fmt.Errorf("add remote participant: %w", err)
+	}
+	return nil
+}
+
+func (r *FederationRepo) RemoveRemoteParticipant(ctx context.Context, conversationID int64, handle string) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM federation_remote_participants WHERE conversation_id = $1 AND handle = $2
+	`, conversationID, handle)
+	if err != nil {
+		return fmt.Errorf("remove remote participant: %w", err)
+	}
+	return nil
+}
+
+func (r *FederationRepo) ListFederatedConversations(ctx context.Context) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT DISTINCT conversation_id FROM federation_remote_participants
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list federated conversations: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan federated conversation: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
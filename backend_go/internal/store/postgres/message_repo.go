@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"backend_go/internal/domain"
 )
@@ -18,14 +19,23 @@ func NewMessageRepo(db *sql.DB) *MessageRepo {
 
 var _ domain.MessageRepository = (*MessageRepo)(nil)
 
+// isReadExpr derives Message.IsRead as "read on at least one device" from
+// message_progress, so it reflects per-device read state instead of the
+// single stored flag alone. The OR keeps it true for clients still on the
+// legacy MarkAllReadInConversation path (the WS mark_read event), which
+// never writes a message_progress row.
+const isReadExpr = `(m.is_read OR EXISTS (
+	SELECT 1 FROM message_progress mp WHERE mp.message_id = m.id AND mp.read_at IS NOT NULL
+)) AS is_read`
+
 func (r *MessageRepo) Create(ctx context.Context, m *domain.Message) error {
 	return r.db.QueryRowContext(ctx, `
 		INSERT INTO messages
-			(content, conversation_id, sender_id, created_at, file_path, file_type, fully_read_at, is_deleted, is_edited, is_read)
-		VALUES ($1, $2, $3, NOW(), $4, $5, $6, FALSE, FALSE, FALSE)
+			(content, conversation_id, sender_id, created_at, file_path, file_type, fully_read_at, is_deleted, is_edited, is_read, expires_at, view_once, search_text, origin_server)
+		VALUES ($1, $2, $3, NOW(), $4, $5, $6, FALSE, FALSE, FALSE, $7, $8, $9, $10)
 		RETURNING id, created_at
 	`, m.Content, m.ConversationID, m.SenderID,
-		m.FilePath, m.FileType, m.FullyReadAt,
+		m.FilePath, m.FileType, m.FullyReadAt, m.ExpiresAt, m.ViewOnce, m.SearchText, m.OriginServer,
 	).Scan(&m.ID, &m.CreatedAt)
 }
 
@@ -33,11 +43,12 @@ func (r *MessageRepo) GetByID(ctx context.Context, id int64) (*domain.Message, e
 	m := &domain.Message{}
 	err := r.db.QueryRowContext(ctx, `
 		SELECT id, content, conversation_id, sender_id, created_at, file_path, file_type,
-		       fully_read_at, is_deleted, is_edited, is_read
-		FROM messages WHERE id = $1
+		       fully_read_at, is_deleted, is_edited, edited_at, `+isReadExpr+`, expires_at, view_once, origin_server
+		FROM messages m WHERE m.id = $1
 	`, id).Scan(
 		&m.ID, &m.Content, &m.ConversationID, &m.SenderID, &m.CreatedAt,
-		&m.FilePath, &m.FileType, &m.FullyReadAt, &m.IsDeleted, &m.IsEdited, &m.IsRead,
+		&m.FilePath, &m.FileType, &m.FullyReadAt, &m.IsDeleted, &m.IsEdited, &m.EditedAt, &m.IsRead,
+		&m.ExpiresAt, &m.ViewOnce, &m.OriginServer,
 	)
 	if err == sql.ErrNoRows {
 		return nil, domain.ErrNotFound
@@ -50,8 +61,8 @@ func (r *MessageRepo) GetByID(ctx context.Context, id int64) (*domain.Message, e
 
 func (r *MessageRepo) Update(ctx context.Context, m *domain.Message) error {
 	_, err := r.db.ExecContext(ctx, `
-		UPDATE messages SET content=$1, is_edited=$2 WHERE id=$3
-	`, m.Content, m.IsEdited, m.ID)
+		UPDATE messages SET content=$1, is_edited=$2, edited_at=NOW(), search_text=$3 WHERE id=$4
+	`, m.Content, m.IsEdited, m.SearchText, m.ID)
 	return err
 }
 
@@ -63,8 +74,8 @@ func (r *MessageRepo) SoftDeleteForEveryone(ctx context.Context, id int64) error
 func (r *MessageRepo) ListForConversation(ctx context.Context, conversationID int64, limit int) ([]*domain.Message, error) {
 	rows, err := r.db.QueryContext(ctx, `
 		SELECT id, content, conversation_id, sender_id, created_at, file_path, file_type,
-		       fully_read_at, is_deleted, is_edited, is_read
-		FROM messages
+		       fully_read_at, is_deleted, is_edited, edited_at, `+isReadExpr+`, expires_at, view_once, origin_server
+		FROM messages m
 		WHERE conversation_id = $1
 		ORDER BY created_at DESC
 		LIMIT $2
@@ -80,7 +91,8 @@ func (r *MessageRepo) ListForConversation(ctx context.Context, conversationID in
 func (r *MessageRepo) ListForConversationForUser(ctx context.Context, conversationID, userID int64, limit int) ([]*domain.Message, error) {
 	rows, err := r.db.QueryContext(ctx, `
 		SELECT m.id, m.content, m.conversation_id, m.sender_id, m.created_at,
-		       m.file_path, m.file_type, m.fully_read_at, m.is_deleted, m.is_edited, m.is_read
+		       m.file_path, m.file_type, m.fully_read_at, m.is_deleted, m.is_edited, m.edited_at, `+isReadExpr+`,
+		       m.expires_at, m.view_once, m.origin_server
 		FROM messages m
 		LEFT JOIN user_deleted_messages udm
 		       ON udm.message_id = m.id AND udm.user_id = $2
@@ -95,18 +107,128 @@ func (r *MessageRepo) ListForConversationForUser(ctx context.Context, conversati
 	return r.scanMessages(rows)
 }
 
+// ListPage performs keyset pagination over a conversation's messages,
+// excluding ones the user has soft-deleted via "delete for me". A zero
+// Cursor starts from the most recent message in either direction.
+func (r *MessageRepo) ListPage(ctx context.Context, conversationID, userID int64, cur domain.Cursor, dir domain.Direction, limit int) ([]*domain.Message, error) {
+	var rows *sql.Rows
+	var err error
+	if dir == domain.Forward {
+		if cur.ID == 0 {
+			rows, err = r.db.QueryContext(ctx, `
+				SELECT m.id, m.content, m.conversation_id, m.sender_id, m.created_at,
+				       m.file_path, m.file_type, m.fully_read_at, m.is_deleted, m.is_edited, m.edited_at, `+isReadExpr+`,
+				       m.expires_at, m.view_once, m.origin_server
+				FROM messages m
+				LEFT JOIN user_deleted_messages udm ON udm.message_id = m.id AND udm.user_id = $2
+				WHERE m.conversation_id = $1 AND udm.user_id IS NULL
+				ORDER BY m.created_at ASC, m.id ASC
+				LIMIT $3
+			`, conversationID, userID, limit)
+		} else {
+			rows, err = r.db.QueryContext(ctx, `
+				SELECT m.id, m.content, m.conversation_id, m.sender_id, m.created_at,
+				       m.file_path, m.file_type, m.fully_read_at, m.is_deleted, m.is_edited, m.edited_at, `+isReadExpr+`,
+				       m.expires_at, m.view_once, m.origin_server
+				FROM messages m
+				LEFT JOIN user_deleted_messages udm ON udm.message_id = m.id AND udm.user_id = $2
+				WHERE m.conversation_id = $1 AND udm.user_id IS NULL
+				  AND (m.created_at, m.id) > ($4, $5)
+				ORDER BY m.created_at ASC, m.id ASC
+				LIMIT $3
+			`, conversationID, userID, limit, cur.CreatedAt, cur.ID)
+		}
+	} else {
+		if cur.ID == 0 {
+			rows, err = r.db.QueryContext(ctx, `
+				SELECT m.id, m.content, m.conversation_id, m.sender_id, m.created_at,
+				       m.file_path, m.file_type, m.fully_read_at, m.is_deleted, m.is_edited, m.edited_at, `+isReadExpr+`,
+				       m.expires_at, m.view_once, m.origin_server
+				FROM messages m
+				LEFT JOIN user_deleted_messages udm ON udm.message_id = m.id AND udm.user_id = $2
+				WHERE m.conversation_id = $1 AND udm.user_id IS NULL
+				ORDER BY m.created_at DESC, m.id DESC
+				LIMIT $3
+			`, conversationID, userID, limit)
+		} else {
+			rows, err = r.db.QueryContext(ctx, `
+				SELECT m.id, m.content, m.conversation_id, m.sender_id, m.created_at,
+				       m.file_path, m.file_type, m.fully_read_at, m.is_deleted, m.is_edited, m.edited_at, `+isReadExpr+`,
+				       m.expires_at, m.view_once, m.origin_server
+				FROM messages m
+				LEFT JOIN user_deleted_messages udm ON udm.message_id = m.id AND udm.user_id = $2
+				WHERE m.conversation_id = $1 AND udm.user_id IS NULL
+				  AND (m.created_at, m.id) < ($4, $5)
+				ORDER BY m.created_at DESC, m.id DESC
+				LIMIT $3
+			`, conversationID, userID, limit, cur.CreatedAt, cur.ID)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list message page: %w", err)
+	}
+	return r.scanMessages(rows)
+}
+
+// ChangesSince returns messages created or edited in conversationID after
+// since (upserts, visible to userID), plus the ids of messages that user has
+// had soft-deleted from under them since then — either by the sender
+// deleting "for everyone" or by the janitor expiring the message.
+func (r *MessageRepo) ChangesSince(ctx context.Context, conversationID, userID int64, since time.Time) ([]*domain.Message, []int64, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT m.id, m.content, m.conversation_id, m.sender_id, m.created_at,
+		       m.file_path, m.file_type, m.fully_read_at, m.is_deleted, m.is_edited, m.edited_at, `+isReadExpr+`,
+		       m.expires_at, m.view_once, m.origin_server
+		FROM messages m
+		LEFT JOIN user_deleted_messages udm ON udm.message_id = m.id AND udm.user_id = $2
+		WHERE m.conversation_id = $1 AND udm.user_id IS NULL
+		  AND (m.created_at > $3 OR m.edited_at > $3)
+		ORDER BY m.created_at ASC, m.id ASC
+	`, conversationID, userID, since)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list changed messages: %w", err)
+	}
+	upserts, err := r.scanMessages(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	deletedRows, err := r.db.QueryContext(ctx, `
+		SELECT id FROM messages
+		WHERE conversation_id = $1 AND is_deleted = TRUE AND created_at <= $2
+	`, conversationID, since)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list deleted messages: %w", err)
+	}
+	defer deletedRows.Close()
+	var deletedIDs []int64
+	for deletedRows.Next() {
+		var id int64
+		if err := deletedRows.Scan(&id); err != nil {
+			return nil, nil, fmt.Errorf("scan deleted message id: %w", err)
+		}
+		deletedIDs = append(deletedIDs, id)
+	}
+	return upserts, deletedIDs, deletedRows.Err()
+}
+
+// MarkAllReadInConversation marks every unread message from other senders as
+// read. A view-once message also gets fully_read_at stamped here, which the
+// janitor treats as an immediate deletion signal.
 func (r *MessageRepo) MarkAllReadInConversation(ctx context.Context, conversationID, senderExcludeID int64) error {
 	_, err := r.db.ExecContext(ctx, `
-		UPDATE messages SET is_read=TRUE
+		UPDATE messages
+		SET is_read = TRUE,
+		    fully_read_at = CASE WHEN view_once THEN NOW() ELSE fully_read_at END
 		WHERE conversation_id=$1 AND sender_id!=$2 AND is_read=FALSE AND is_deleted=FALSE
 	`, conversationID, senderExcludeID)
 	return err
 }
 
-func (r *MessageRepo) PruneOld(ctx context.Context, conversationID int64, keepLimit int) error {
+func (r *MessageRepo) PruneOld(ctx context.Context, conversationID int64, keepLimit int) ([]domain.PrunedMessage, error) {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("begin prune tx: %w", err)
+		return nil, fmt.Errorf("begin prune tx: %w", err)
 	}
 	defer tx.Rollback()
 
@@ -122,10 +244,10 @@ func (r *MessageRepo) PruneOld(ctx context.Context, conversationID int64, keepLi
 			  LIMIT $2
 		  )
 	`, conversationID, keepLimit); err != nil {
-		return fmt.Errorf("delete dependent user_deleted_messages: %w", err)
+		return nil, fmt.Errorf("delete dependent user_deleted_messages: %w", err)
 	}
 
-	if _, err := tx.ExecContext(ctx, `
+	rows, err := tx.QueryContext(ctx, `
 		DELETE FROM messages
 		WHERE conversation_id = $1
 		  AND id NOT IN (
@@ -134,15 +256,70 @@ func (r *MessageRepo) PruneOld(ctx context.Context, conversationID int64, keepLi
 			  ORDER BY created_at DESC
 			  LIMIT $2
 		  )
-	`, conversationID, keepLimit); err != nil {
-		return fmt.Errorf("delete old messages: %w", err)
+		RETURNING id, file_path
+	`, conversationID, keepLimit)
+	if err != nil {
+		return nil, fmt.Errorf("delete old messages: %w", err)
+	}
+	pruned, err := scanPrunedMessages(rows)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("commit prune tx: %w", err)
+		return nil, fmt.Errorf("commit prune tx: %w", err)
+	}
+
+	return pruned, nil
+}
+
+// PruneOlderThan deletes every message in conversationID created before
+// cutoff, the age-based counterpart to PruneOld's count-based cap.
+func (r *MessageRepo) PruneOlderThan(ctx context.Context, conversationID int64, cutoff time.Time) ([]domain.PrunedMessage, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin prune tx: %w", err)
 	}
+	defer tx.Rollback()
 
-	return nil
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM user_deleted_messages udm
+		USING messages m
+		WHERE udm.message_id = m.id AND m.conversation_id = $1 AND m.created_at < $2
+	`, conversationID, cutoff); err != nil {
+		return nil, fmt.Errorf("delete dependent user_deleted_messages: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		DELETE FROM messages WHERE conversation_id = $1 AND created_at < $2
+		RETURNING id, file_path
+	`, conversationID, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("delete expired messages: %w", err)
+	}
+	pruned, err := scanPrunedMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit prune tx: %w", err)
+	}
+
+	return pruned, nil
+}
+
+func scanPrunedMessages(rows *sql.Rows) ([]domain.PrunedMessage, error) {
+	defer rows.Close()
+	var pruned []domain.PrunedMessage
+	for rows.Next() {
+		var pm domain.PrunedMessage
+		if err := rows.Scan(&pm.ID, &pm.FilePath); err != nil {
+			return nil, fmt.Errorf("scan pruned message: %w", err)
+		}
+		pruned = append(pruned, pm)
+	}
+	return pruned, rows.Err()
 }
 
 // ── helpers ──────────────────────────────────────────────────────────────────
@@ -154,7 +331,8 @@ func (r *MessageRepo) scanMessages(rows *sql.Rows) ([]*domain.Message, error) {
 		m := &domain.Message{}
 		if err := rows.Scan(
 			&m.ID, &m.Content, &m.ConversationID, &m.SenderID, &m.CreatedAt,
-			&m.FilePath, &m.FileType, &m.FullyReadAt, &m.IsDeleted, &m.IsEdited, &m.IsRead,
+			&m.FilePath, &m.FileType, &m.FullyReadAt, &m.IsDeleted, &m.IsEdited, &m.EditedAt, &m.IsRead,
+			&m.ExpiresAt, &m.ViewOnce, &m.OriginServer,
 		); err != nil {
 			return nil, fmt.Errorf("scan message: %w", err)
 		}
@@ -0,0 +1,496 @@
+package postgres
+
+import "backend_go/internal/store/migrate"
+
+// migrations is the ordered, versioned schema history for the postgres
+// store. Each entry's Up/Down must be safe to run standalone inside its own
+// transaction; once a version has shipped, its SQL should not be edited —
+// ship a new version instead.
+var migrations = []migrate.Migration{
+	{
+		Version: 1,
+		Name:    "create_core_tables",
+		Up: `
+			CREATE TABLE IF NOT EXISTS users (
+				id               BIGSERIAL PRIMARY KEY,
+				username         VARCHAR(50)  UNIQUE NOT NULL,
+				email            VARCHAR(100) UNIQUE,
+				hashed_password  VARCHAR(255) NOT NULL,
+				is_active        BOOLEAN      NOT NULL DEFAULT TRUE,
+				is_online        BOOLEAN      NOT NULL DEFAULT FALSE,
+				created_at       TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+				last_seen        TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+			);
+
+			CREATE TABLE IF NOT EXISTS conversations (
+				id         BIGSERIAL    PRIMARY KEY,
+				name       VARCHAR(100),
+				is_group   BOOLEAN      NOT NULL DEFAULT FALSE,
+				created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+				updated_at TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+			);
+
+			CREATE TABLE IF NOT EXISTS conversation_participants (
+				user_id         BIGINT       NOT NULL REFERENCES users(id),
+				conversation_id BIGINT       NOT NULL REFERENCES conversations(id),
+				last_read_at    TIMESTAMPTZ,
+				joined_at       TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+				PRIMARY KEY (user_id, conversation_id)
+			);
+
+			CREATE TABLE IF NOT EXISTS messages (
+				id              BIGSERIAL    PRIMARY KEY,
+				content         TEXT         NOT NULL,
+				conversation_id BIGINT       NOT NULL REFERENCES conversations(id),
+				sender_id       BIGINT       NOT NULL REFERENCES users(id),
+				created_at      TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+				file_path       TEXT,
+				file_type       TEXT,
+				fully_read_at   TIMESTAMPTZ,
+				is_deleted      BOOLEAN      NOT NULL DEFAULT FALSE,
+				is_edited       BOOLEAN      NOT NULL DEFAULT FALSE,
+				is_read         BOOLEAN      NOT NULL DEFAULT FALSE
+			);
+
+			CREATE TABLE IF NOT EXISTS user_deleted_messages (
+				user_id    BIGINT      NOT NULL REFERENCES users(id),
+				message_id BIGINT      NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+				deleted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				PRIMARY KEY (user_id, message_id)
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_users_username ON users(username);
+			CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
+			CREATE INDEX IF NOT EXISTS idx_users_is_online ON users(is_online);
+			CREATE INDEX IF NOT EXISTS idx_conversations_is_group ON conversations(is_group);
+			CREATE INDEX IF NOT EXISTS idx_conversations_updated_at ON conversations(updated_at DESC);
+			CREATE INDEX IF NOT EXISTS idx_conv_participants_user ON conversation_participants(user_id);
+			CREATE INDEX IF NOT EXISTS idx_conv_participants_conv ON conversation_participants(conversation_id);
+			CREATE INDEX IF NOT EXISTS idx_messages_conversation ON messages(conversation_id);
+			CREATE INDEX IF NOT EXISTS idx_messages_sender ON messages(sender_id);
+			CREATE INDEX IF NOT EXISTS idx_messages_created_at ON messages(created_at);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS user_deleted_messages;
+			DROP TABLE IF EXISTS messages;
+			DROP TABLE IF EXISTS conversation_participants;
+			DROP TABLE IF EXISTS conversations;
+			DROP TABLE IF EXISTS users;
+		`,
+	},
+	{
+		Version: 2,
+		Name:    "message_edit_and_read_flags",
+		Up: `
+			ALTER TABLE messages ADD COLUMN IF NOT EXISTS is_edited BOOLEAN NOT NULL DEFAULT FALSE;
+			ALTER TABLE messages ADD COLUMN IF NOT EXISTS is_read   BOOLEAN NOT NULL DEFAULT FALSE;
+		`,
+		Down: `
+			ALTER TABLE messages DROP COLUMN IF EXISTS is_edited;
+			ALTER TABLE messages DROP COLUMN IF EXISTS is_read;
+		`,
+	},
+	{
+		Version: 3,
+		Name:    "attachments",
+		Up: `
+			CREATE TABLE IF NOT EXISTS attachments (
+				id           BIGSERIAL    PRIMARY KEY,
+				owner_id     BIGINT       NOT NULL REFERENCES users(id),
+				key          TEXT         NOT NULL UNIQUE,
+				content_type TEXT         NOT NULL,
+				size         BIGINT       NOT NULL,
+				sha256       TEXT         NOT NULL,
+				created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+			);
+			CREATE INDEX IF NOT EXISTS idx_attachments_owner ON attachments(owner_id);
+			CREATE INDEX IF NOT EXISTS idx_attachments_sha256 ON attachments(sha256);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS attachments;
+		`,
+	},
+	{
+		Version: 4,
+		Name:    "user_otp",
+		Up: `
+			CREATE TABLE IF NOT EXISTS user_otp (
+				user_id    BIGINT      PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+				secret     BYTEA       NOT NULL,
+				confirmed  BOOLEAN     NOT NULL DEFAULT FALSE,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			);
+
+			CREATE TABLE IF NOT EXISTS user_recovery_codes (
+				user_id    BIGINT      NOT NULL REFERENCES user_otp(user_id) ON DELETE CASCADE,
+				code_hash  TEXT        NOT NULL,
+				used_at    TIMESTAMPTZ,
+				PRIMARY KEY (user_id, code_hash)
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_user_recovery_codes_user ON user_recovery_codes(user_id);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS user_recovery_codes;
+			DROP TABLE IF EXISTS user_otp;
+		`,
+	},
+	{
+		Version: 5,
+		Name:    "user_identities",
+		Up: `
+			CREATE TABLE IF NOT EXISTS user_identities (
+				user_id    BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				provider   TEXT        NOT NULL,
+				subject    TEXT        NOT NULL,
+				email      TEXT,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				PRIMARY KEY (provider, subject)
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities(user_id);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS user_identities;
+		`,
+	},
+	{
+		Version: 6,
+		Name:    "message_expiry_and_conversation_retention",
+		Up: `
+			ALTER TABLE messages ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+			ALTER TABLE messages ADD COLUMN IF NOT EXISTS view_once  BOOLEAN NOT NULL DEFAULT FALSE;
+			ALTER TABLE conversations ADD COLUMN IF NOT EXISTS retention_seconds INTEGER;
+
+			CREATE INDEX IF NOT EXISTS idx_messages_expires_at ON messages(expires_at) WHERE expires_at IS NOT NULL;
+		`,
+		Down: `
+			DROP INDEX IF EXISTS idx_messages_expires_at;
+			ALTER TABLE conversations DROP COLUMN IF EXISTS retention_seconds;
+			ALTER TABLE messages DROP COLUMN IF EXISTS view_once;
+			ALTER TABLE messages DROP COLUMN IF EXISTS expires_at;
+		`,
+	},
+	{
+		Version: 7,
+		Name:    "uploads_in_progress",
+		Up: `
+			CREATE TABLE IF NOT EXISTS uploads_in_progress (
+				id           TEXT         PRIMARY KEY,
+				owner_id     BIGINT       NOT NULL REFERENCES users(id),
+				key          TEXT         NOT NULL UNIQUE,
+				content_type TEXT         NOT NULL,
+				size         BIGINT       NOT NULL,
+				"offset"     BIGINT       NOT NULL DEFAULT 0,
+				metadata     TEXT         NOT NULL DEFAULT '',
+				created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+				expires_at   TIMESTAMPTZ  NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS idx_uploads_in_progress_owner ON uploads_in_progress(owner_id);
+			CREATE INDEX IF NOT EXISTS idx_uploads_in_progress_expires_at ON uploads_in_progress(expires_at);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS uploads_in_progress;
+		`,
+	},
+	{
+		Version: 8,
+		Name:    "calls",
+		Up: `
+			CREATE TABLE IF NOT EXISTS calls (
+				id              TEXT        PRIMARY KEY,
+				conversation_id BIGINT      NOT NULL REFERENCES conversations(id),
+				caller_id       BIGINT      NOT NULL REFERENCES users(id),
+				callee_id       BIGINT      NOT NULL REFERENCES users(id),
+				started_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				ended_at        TIMESTAMPTZ,
+				outcome         TEXT        NOT NULL DEFAULT 'ongoing'
+			);
+			CREATE INDEX IF NOT EXISTS idx_calls_conversation ON calls(conversation_id);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS calls;
+		`,
+	},
+	{
+		Version: 9,
+		Name:    "conversation_keep_last_n",
+		Up: `
+			ALTER TABLE conversations ADD COLUMN keep_last_n INTEGER;
+		`,
+		Down: `
+			ALTER TABLE conversations DROP COLUMN keep_last_n;
+		`,
+	},
+	{
+		Version: 10,
+		Name:    "message_search_index",
+		Up: `
+			ALTER TABLE messages ADD COLUMN search_text TEXT;
+			ALTER TABLE messages ADD COLUMN search_vector tsvector;
+
+			CREATE INDEX IF NOT EXISTS idx_messages_search_vector ON messages USING GIN(search_vector);
+
+			CREATE OR REPLACE FUNCTION messages_search_vector_update() RETURNS trigger AS $$
+			BEGIN
+				NEW.search_vector := to_tsvector('english', coalesce(NEW.search_text, ''));
+				RETURN NEW;
+			END;
+			$$ LANGUAGE plpgsql;
+
+			CREATE TRIGGER messages_search_vector_trigger
+				BEFORE INSERT OR UPDATE OF search_text ON messages
+				FOR EACH ROW EXECUTE FUNCTION messages_search_vector_update();
+		`,
+		Down: `
+			DROP TRIGGER IF EXISTS messages_search_vector_trigger ON messages;
+			DROP FUNCTION IF EXISTS messages_search_vector_update();
+			DROP INDEX IF EXISTS idx_messages_search_vector;
+			ALTER TABLE messages DROP COLUMN search_vector;
+			ALTER TABLE messages DROP COLUMN search_text;
+		`,
+	},
+	{
+		Version: 11,
+		Name:    "mtls_service_accounts",
+		Up: `
+			ALTER TABLE users ADD COLUMN is_service_account BOOLEAN NOT NULL DEFAULT FALSE;
+
+			CREATE TABLE IF NOT EXISTS revoked_certificates (
+				serial_hex TEXT PRIMARY KEY,
+				subject    TEXT NOT NULL,
+				reason     TEXT NOT NULL DEFAULT '',
+				revoked_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS revoked_certificates;
+			ALTER TABLE users DROP COLUMN is_service_account;
+		`,
+	},
+	{
+		Version: 12,
+		Name:    "conversation_keys",
+		Up: `
+			CREATE TABLE IF NOT EXISTS conversation_keys (
+				id              BIGSERIAL   PRIMARY KEY,
+				conversation_id BIGINT      NOT NULL REFERENCES conversations(id),
+				kek_id          BIGINT      NOT NULL,
+				wrapped_dek     BYTEA       NOT NULL,
+				status          TEXT        NOT NULL DEFAULT 'active',
+				created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			);
+			CREATE INDEX IF NOT EXISTS idx_conversation_keys_conversation ON conversation_keys(conversation_id, status);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS conversation_keys;
+		`,
+	},
+	{
+		Version: 13,
+		Name:    "message_cursor_pagination",
+		Up: `
+			ALTER TABLE messages ADD COLUMN IF NOT EXISTS edited_at TIMESTAMPTZ;
+
+			-- Keyset pagination walks (conversation_id, created_at, id), in
+			-- both directions, so the composite index needs to cover it;
+			-- idx_messages_conversation/idx_messages_created_at alone force a
+			-- sort on every page fetch.
+			CREATE INDEX IF NOT EXISTS idx_messages_conv_created_id ON messages(conversation_id, created_at DESC, id DESC);
+		`,
+		Down: `
+			DROP INDEX IF EXISTS idx_messages_conv_created_id;
+			ALTER TABLE messages DROP COLUMN IF EXISTS edited_at;
+		`,
+	},
+	{
+		Version: 14,
+		Name:    "message_progress",
+		Up: `
+			CREATE TABLE IF NOT EXISTS message_progress (
+				user_id      BIGINT      NOT NULL REFERENCES users(id),
+				device_id    TEXT        NOT NULL,
+				message_id   BIGINT      NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+				delivered_at TIMESTAMPTZ,
+				read_at      TIMESTAMPTZ,
+				percentage   INTEGER     NOT NULL DEFAULT 0,
+				PRIMARY KEY (user_id, device_id, message_id)
+			);
+			CREATE INDEX IF NOT EXISTS idx_message_progress_message ON message_progress(message_id);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS message_progress;
+		`,
+	},
+	{
+		Version: 15,
+		Name:    "attachment_encryption",
+		Up: `
+			ALTER TABLE attachments ADD COLUMN IF NOT EXISTS wrapped_key BYTEA;
+			ALTER TABLE attachments ADD COLUMN IF NOT EXISTS kek_id BIGINT;
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_attachments_sha256_unique ON attachments(sha256) WHERE sha256 != '';
+		`,
+		Down: `
+			DROP INDEX IF EXISTS idx_attachments_sha256_unique;
+			ALTER TABLE attachments DROP COLUMN IF EXISTS kek_id;
+			ALTER TABLE attachments DROP COLUMN IF EXISTS wrapped_key;
+		`,
+	},
+	{
+		Version: 16,
+		Name:    "oauth2_authserver",
+		Up: `
+			CREATE TABLE IF NOT EXISTS oauth_clients (
+				client_id      TEXT        PRIMARY KEY,
+				client_secret  TEXT        NOT NULL DEFAULT '',
+				name           TEXT        NOT NULL,
+				redirect_uris  TEXT        NOT NULL,
+				allowed_scopes TEXT        NOT NULL DEFAULT 'openid,profile,email',
+				created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			);
+
+			CREATE TABLE IF NOT EXISTS oauth_auth_requests (
+				code                  TEXT        PRIMARY KEY,
+				client_id             TEXT        NOT NULL REFERENCES oauth_clients(client_id),
+				user_id               BIGINT      NOT NULL REFERENCES users(id),
+				redirect_uri          TEXT        NOT NULL,
+				scope                 TEXT        NOT NULL,
+				state                 TEXT        NOT NULL DEFAULT '',
+				code_challenge        TEXT        NOT NULL DEFAULT '',
+				code_challenge_method TEXT        NOT NULL DEFAULT '',
+				expires_at            TIMESTAMPTZ NOT NULL,
+				used                  BOOLEAN     NOT NULL DEFAULT FALSE
+			);
+			CREATE INDEX IF NOT EXISTS idx_oauth_auth_requests_expires ON oauth_auth_requests(expires_at);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS oauth_auth_requests;
+			DROP TABLE IF EXISTS oauth_clients;
+		`,
+	},
+	{
+		Version: 17,
+		Name:    "verification_tokens",
+		Up: `
+			CREATE TABLE IF NOT EXISTS verification_tokens (
+				token      TEXT        PRIMARY KEY,
+				user_id    BIGINT      NOT NULL REFERENCES users(id),
+				purpose    TEXT        NOT NULL,
+				expires_at TIMESTAMPTZ NOT NULL,
+				used       BOOLEAN     NOT NULL DEFAULT FALSE,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			);
+			CREATE INDEX IF NOT EXISTS idx_verification_tokens_expires ON verification_tokens(expires_at);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS verification_tokens;
+		`,
+	},
+	{
+		Version: 18,
+		Name:    "conversation_roles",
+		Up: `
+			ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'member';
+		`,
+		Down: `
+			ALTER TABLE conversation_participants DROP COLUMN IF EXISTS role;
+		`,
+	},
+	{
+		Version: 19,
+		Name:    "federation",
+		Up: `
+			ALTER TABLE messages ADD COLUMN IF NOT EXISTS origin_server TEXT;
+			CREATE TABLE IF NOT EXISTS federation_remote_participants (
+				conversation_id BIGINT NOT NULL REFERENCES conversations(id),
+				handle          TEXT   NOT NULL,
+				PRIMARY KEY (conversation_id, handle)
+			);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS federation_remote_participants;
+			ALTER TABLE messages DROP COLUMN IF EXISTS origin_server;
+		`,
+	},
+	{
+		Version: 20,
+		Name:    "federation_ghost_users",
+		Up: `
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS remote_handle TEXT;
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_users_remote_handle ON users(remote_handle) WHERE remote_handle IS NOT NULL;
+		`,
+		Down: `
+			DROP INDEX IF EXISTS idx_users_remote_handle;
+			ALTER TABLE users DROP COLUMN IF EXISTS remote_handle;
+		`,
+	},
+	{
+		Version: 21,
+		Name:    "authz_roles",
+		Up: `
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user';
+			ALTER TABLE conversations ADD COLUMN IF NOT EXISTS is_locked BOOLEAN NOT NULL DEFAULT FALSE;
+			CREATE TABLE IF NOT EXISTS conversation_bans (
+				conversation_id BIGINT NOT NULL REFERENCES conversations(id),
+				user_id         BIGINT NOT NULL REFERENCES users(id),
+				banned_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				PRIMARY KEY (conversation_id, user_id)
+			);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS conversation_bans;
+			ALTER TABLE conversations DROP COLUMN IF EXISTS is_locked;
+			ALTER TABLE users DROP COLUMN IF EXISTS role;
+		`,
+	},
+	{
+		Version: 22,
+		Name:    "otp_replay_protection",
+		Up: `
+			ALTER TABLE user_otp ADD COLUMN IF NOT EXISTS last_counter BIGINT NOT NULL DEFAULT 0;
+		`,
+		Down: `
+			ALTER TABLE user_otp DROP COLUMN IF EXISTS last_counter;
+		`,
+	},
+	{
+		Version: 23,
+		Name:    "password_changed_at",
+		Up: `
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+		`,
+		Down: `
+			ALTER TABLE users DROP COLUMN IF EXISTS password_changed_at;
+		`,
+	},
+	{
+		Version: 24,
+		Name:    "sso_only_users",
+		Up: `
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS sso_only BOOLEAN NOT NULL DEFAULT false;
+		`,
+		Down: `
+			ALTER TABLE users DROP COLUMN IF EXISTS sso_only;
+		`,
+	},
+	{
+		Version: 25,
+		Name:    "refresh_tokens",
+		Up: `
+			CREATE TABLE IF NOT EXISTS refresh_tokens (
+				id         BIGSERIAL PRIMARY KEY,
+				user_id    BIGINT NOT NULL REFERENCES users(id),
+				token_hash TEXT NOT NULL UNIQUE,
+				expires_at TIMESTAMPTZ NOT NULL,
+				revoked_at TIMESTAMPTZ,
+				user_agent TEXT NOT NULL DEFAULT '',
+				ip         TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			);
+			CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON refresh_tokens(user_id);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS refresh_tokens;
+		`,
+	},
+}
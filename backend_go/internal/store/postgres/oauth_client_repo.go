@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"backend_go/internal/domain"
+)
+
+type OAuthClientRepo struct {
+	db *sql.DB
+}
+
+func NewOAuthClientRepo(db *sql.DB) *OAuthClientRepo {
+	return &OAuthClientRepo{db: db}
+}
+
+var _ domain.OAuthClientRepository = (*OAuthClientRepo)(nil)
+
+func (r *OAuthClientRepo) GetByID(ctx context.Context, clientID string) (*domain.OAuthClient, error) {
+	c := &domain.OAuthClient{}
+	var redirectURIs, allowedScopes string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT client_id, client_secret, name, redirect_uris, allowed_scopes, created_at
+		FROM oauth_clients WHERE client_id = $1
+	`, clientID).Scan(&c.ClientID, &c.ClientSecret, &c.Name, &redirectURIs, &allowedScopes, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get oauth client: %w", err)
+	}
+	c.RedirectURIs = strings.Split(redirectURIs, ",")
+	c.AllowedScopes = strings.Split(allowedScopes, ",")
+	return c, nil
+}
+
+func (r *OAuthClientRepo) Create(ctx context.Context, c *domain.OAuthClient) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO oauth_clients (client_id, client_secret, name, redirect_uris, allowed_scopes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`, c.ClientID, c.ClientSecret, c.Name, strings.Join(c.RedirectURIs, ","), strings.Join(c.AllowedScopes, ","),
+	).Scan(&c.CreatedAt)
+}
@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"backend_go/internal/domain"
+)
+
+type OTPRepo struct {
+	db *sql.DB
+}
+
+func NewOTPRepo(db *sql.DB) *OTPRepo {
+	return &OTPRepo{db: db}
+}
+
+var _ domain.OTPRepository = (*OTPRepo)(nil)
+
+func (r *OTPRepo) Get(ctx context.Context, userID int64) (*domain.UserOTP, error) {
+	o := &domain.UserOTP{}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT user_id, secret, confirmed, created_at, last_counter FROM user_otp WHERE user_id = $1
+	`, userID).Scan(&o.UserID, &o.Secret, &o.Confirmed, &o.CreatedAt, &o.LastCounter)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get user otp: %w", err)
+	}
+	return o, nil
+}
+
+func (r *OTPRepo) Upsert(ctx context.Context, o *domain.UserOTP) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO user_otp (user_id, secret, confirmed, created_at, last_counter)
+		VALUES ($1, $2, $3, NOW(), 0)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, confirmed = EXCLUDED.confirmed, last_counter = 0
+		RETURNING created_at
+	`, o.UserID, o.Secret, o.Confirmed).Scan(&o.CreatedAt)
+}
+
+func (r *OTPRepo) Confirm(ctx context.Context, userID int64) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE user_otp SET confirmed = TRUE WHERE user_id = $1`, userID)
+	return err
+}
+
+func (r *OTPRepo) Delete(ctx context.Context, userID int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM user_otp WHERE user_id = $1`, userID)
+	return err
+}
+
+// SetLastCounter records the HOTP counter of the most recently accepted
+// code, so a later Verify of the same (or an older) counter is rejected as a
+// replay.
+func (r *OTPRepo) SetLastCounter(ctx context.Context, userID int64, counter int64) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE user_otp SET last_counter = $1 WHERE user_id = $2`, counter, userID)
+	return err
+}
+
+// CompareAndSetLastCounter atomically records counter as the last accepted
+// HOTP counter iff it is still greater than the one on file, in a single
+// statement, so two concurrent requests presenting the same code can't both
+// read the old counter before either writes the new one.
+func (r *OTPRepo) CompareAndSetLastCounter(ctx context.Context, userID int64, counter int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE user_otp SET last_counter = $1 WHERE user_id = $2 AND last_counter < $1
+	`, counter, userID)
+	if err != nil {
+		return false, fmt.Errorf("compare-and-set otp counter: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("compare-and-set otp counter: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (r *OTPRepo) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("clear recovery codes: %w", err)
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)
+		`, userID, hash); err != nil {
+			return fmt.Errorf("insert recovery code: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *OTPRepo) ListUnusedRecoveryCodes(ctx context.Context, userID int64) ([]domain.RecoveryCode, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT user_id, code_hash, used_at FROM user_recovery_codes
+		WHERE user_id = $1 AND used_at IS NULL
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list recovery codes: %w", err)
+	}
+	defer rows.Close()
+
+	var codes []domain.RecoveryCode
+	for rows.Next() {
+		var c domain.RecoveryCode
+		if err := rows.Scan(&c.UserID, &c.CodeHash, &c.UsedAt); err != nil {
+			return nil, fmt.Errorf("scan recovery code: %w", err)
+		}
+		codes = append(codes, c)
+	}
+	return codes, rows.Err()
+}
+
+func (r *OTPRepo) MarkRecoveryCodeUsed(ctx context.Context, userID int64, codeHash string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE user_recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2
+	`, userID, codeHash)
+	return err
+}
@@ -49,6 +49,92 @@ func (r *ParticipantRepo) IsParticipant(ctx context.Context, conversationID, use
 	return exists, nil
 }
 
+func (r *ParticipantRepo) GetRole(ctx context.Context, conversationID, userID int64) (domain.ConversationRole, error) {
+	var role domain.ConversationRole
+	err := r.db.QueryRowContext(ctx, `
+		SELECT role FROM conversation_participants
+		WHERE conversation_id = $1 AND user_id = $2
+	`, conversationID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("get participant role: %w", err)
+	}
+	return role, nil
+}
+
+func (r *ParticipantRepo) SetRole(ctx context.Context, conversationID, userID int64, role domain.ConversationRole) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE conversation_participants SET role = $1
+		WHERE conversation_id = $2 AND user_id = $3
+	`, role, conversationID, userID)
+	if err != nil {
+		return fmt.Errorf("set participant role: %w", err)
+	}
+	return nil
+}
+
+func (r *ParticipantRepo) AddParticipant(ctx context.Context, conversationID, userID int64, role domain.ConversationRole) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO conversation_participants (user_id, conversation_id, role, joined_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT DO NOTHING
+	`, userID, conversationID, role)
+	if err != nil {
+		return fmt.Errorf("add participant: %w", err)
+	}
+	return nil
+}
+
+func (r *ParticipantRepo) RemoveParticipant(ctx context.Context, conversationID, userID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2
+	`, conversationID, userID)
+	if err != nil {
+		return fmt.Errorf("remove participant: %w", err)
+	}
+	return nil
+}
+
+// BanParticipant removes a user from the conversation and records the ban so
+// AddParticipant (and the moderator re-invite path) refuse to re-seat them.
+func (r *ParticipantRepo) BanParticipant(ctx context.Context, conversationID, userID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2
+	`, conversationID, userID); err != nil {
+		return fmt.Errorf("remove participant: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO conversation_bans (conversation_id, user_id, banned_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT DO NOTHING
+	`, conversationID, userID); err != nil {
+		return fmt.Errorf("insert ban: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (r *ParticipantRepo) IsBanned(ctx context.Context, conversationID, userID int64) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM conversation_bans
+			WHERE conversation_id = $1 AND user_id = $2
+		)
+	`, conversationID, userID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("check ban: %w", err)
+	}
+	return exists, nil
+}
+
 // UserDeletedMessageRepo implements domain.UserDeletedMessageRepository.
 type UserDeletedMessageRepo struct {
 	db *sql.DB
@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"backend_go/internal/domain"
+)
+
+type ProgressRepo struct {
+	db *sql.DB
+}
+
+func NewProgressRepo(db *sql.DB) *ProgressRepo {
+	return &ProgressRepo{db: db}
+}
+
+var _ domain.ProgressRepository = (*ProgressRepo)(nil)
+
+// MarkReadUpTo upserts a message_progress row for every message the device
+// hasn't already recorded a later read for, excluding the reader's own
+// messages the same way MarkAllReadInConversation does.
+func (r *ProgressRepo) MarkReadUpTo(ctx context.Context, conversationID, userID int64, deviceID string, lastReadMessageID int64, readAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO message_progress (user_id, device_id, message_id, delivered_at, read_at, percentage)
+		SELECT $1, $2, m.id, $5, $5, 100
+		FROM messages m
+		WHERE m.conversation_id = $3 AND m.id <= $4 AND m.sender_id != $1
+		ON CONFLICT (user_id, device_id, message_id) DO UPDATE
+		SET read_at      = EXCLUDED.read_at,
+		    delivered_at = COALESCE(message_progress.delivered_at, EXCLUDED.delivered_at),
+		    percentage   = 100
+		WHERE message_progress.read_at IS NULL OR EXCLUDED.read_at > message_progress.read_at
+	`, userID, deviceID, conversationID, lastReadMessageID, readAt)
+	if err != nil {
+		return fmt.Errorf("mark read up to: %w", err)
+	}
+	return nil
+}
+
+func (r *ProgressRepo) ListForConversation(ctx context.Context, conversationID int64) ([]*domain.DeviceProgress, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT mp.user_id, mp.device_id, MAX(mp.message_id) AS last_read_message_id,
+		       MAX(mp.read_at) AS read_at, MAX(mp.delivered_at) AS delivered_at
+		FROM message_progress mp
+		JOIN messages m ON m.id = mp.message_id
+		WHERE m.conversation_id = $1
+		GROUP BY mp.user_id, mp.device_id
+		ORDER BY mp.user_id, mp.device_id
+	`, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("list progress for conversation: %w", err)
+	}
+	defer rows.Close()
+
+	var res []*domain.DeviceProgress
+	for rows.Next() {
+		p := &domain.DeviceProgress{}
+		if err := rows.Scan(&p.UserID, &p.DeviceID, &p.LastReadMessageID, &p.ReadAt, &p.DeliveredAt); err != nil {
+			return nil, fmt.Errorf("scan device progress: %w", err)
+		}
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,181 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"backend_go/internal/domain"
+	"backend_go/internal/storage"
+)
+
+// Broadcaster delivers a pruning notification to a conversation's
+// participants, so clients can drop the messages it removed from their
+// local copy. *ws.Hub satisfies this.
+type Broadcaster interface {
+	BroadcastToUsers(userIDs []int64, payload any)
+}
+
+// RetentionWorker periodically enforces each conversation's keep_last_n and
+// retention_seconds policy, falling back to the configured global defaults
+// for conversations that haven't set their own. Unlike sqlite's Janitor it
+// doesn't yet cover per-message ExpiresAt/ViewOnce, since nothing currently
+// schedules that enforcement for this store either; this worker is scoped
+// to the count- and age-based caps the retention subsystem was added for.
+type RetentionWorker struct {
+	db               *sql.DB
+	messages         domain.MessageRepository
+	storage          storage.Backend
+	notify           Broadcaster
+	interval         time.Duration
+	defaultKeepLastN int
+	defaultMaxAge    time.Duration
+}
+
+// NewRetentionWorker builds a RetentionWorker. backend may be nil if
+// attachment cleanup is not needed; notify may be nil to prune without
+// broadcasting. defaultKeepLastN/defaultMaxAge apply to conversations that
+// haven't set their own keep_last_n/retention_seconds; zero disables that
+// default policy.
+func NewRetentionWorker(db *sql.DB, messages domain.MessageRepository, backend storage.Backend, notify Broadcaster, interval time.Duration, defaultKeepLastN int, defaultMaxAge time.Duration) *RetentionWorker {
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	return &RetentionWorker{
+		db:               db,
+		messages:         messages,
+		storage:          backend,
+		notify:           notify,
+		interval:         interval,
+		defaultKeepLastN: defaultKeepLastN,
+		defaultMaxAge:    defaultMaxAge,
+	}
+}
+
+// Run sweeps on a ticker until ctx is cancelled.
+func (w *RetentionWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.Sweep(ctx); err != nil {
+				log.Printf("retention worker: sweep: %v", err)
+			}
+		}
+	}
+}
+
+type conversationPolicy struct {
+	id               int64
+	retentionSeconds sql.NullInt64
+	keepLastN        sql.NullInt64
+}
+
+// Sweep applies every conversation's effective keep_last_n and
+// retention_seconds policy, deleting the attachment blob for each pruned
+// message and broadcasting a "messages_pruned" event per conversation.
+func (w *RetentionWorker) Sweep(ctx context.Context) error {
+	rows, err := w.db.QueryContext(ctx, `SELECT id, retention_seconds, keep_last_n FROM conversations`)
+	if err != nil {
+		return fmt.Errorf("list conversations: %w", err)
+	}
+	var policies []conversationPolicy
+	for rows.Next() {
+		var p conversationPolicy
+		if err := rows.Scan(&p.id, &p.retentionSeconds, &p.keepLastN); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan conversation policy: %w", err)
+		}
+		policies = append(policies, p)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	for _, p := range policies {
+		keepLastN := w.defaultKeepLastN
+		if p.keepLastN.Valid {
+			keepLastN = int(p.keepLastN.Int64)
+		}
+		if keepLastN > 0 {
+			pruned, err := w.messages.PruneOld(ctx, p.id, keepLastN)
+			if err != nil {
+				log.Printf("retention worker: prune old for conversation %d: %v", p.id, err)
+			} else {
+				w.cleanup(ctx, p.id, pruned)
+			}
+		}
+
+		maxAge := w.defaultMaxAge
+		if p.retentionSeconds.Valid {
+			maxAge = time.Duration(p.retentionSeconds.Int64) * time.Second
+		}
+		if maxAge > 0 {
+			pruned, err := w.messages.PruneOlderThan(ctx, p.id, time.Now().Add(-maxAge))
+			if err != nil {
+				log.Printf("retention worker: prune older than for conversation %d: %v", p.id, err)
+			} else {
+				w.cleanup(ctx, p.id, pruned)
+			}
+		}
+	}
+	return nil
+}
+
+func (w *RetentionWorker) cleanup(ctx context.Context, conversationID int64, pruned []domain.PrunedMessage) {
+	if len(pruned) == 0 {
+		return
+	}
+
+	messageIDs := make([]int64, len(pruned))
+	for i, pm := range pruned {
+		messageIDs[i] = pm.ID
+		if w.storage != nil && pm.FilePath != nil && *pm.FilePath != "" {
+			if err := w.storage.Delete(ctx, *pm.FilePath); err != nil {
+				log.Printf("retention worker: delete attachment %q for message %d: %v", *pm.FilePath, pm.ID, err)
+			}
+		}
+	}
+
+	if w.notify == nil {
+		return
+	}
+	participantIDs, err := w.participantIDs(ctx, conversationID)
+	if err != nil {
+		log.Printf("retention worker: list participants for conversation %d: %v", conversationID, err)
+		return
+	}
+	w.notify.BroadcastToUsers(participantIDs, map[string]any{
+		"type":            "messages_pruned",
+		"conversation_id": conversationID,
+		"message_ids":     messageIDs,
+	})
+}
+
+func (w *RetentionWorker) participantIDs(ctx context.Context, conversationID int64) ([]int64, error) {
+	rows, err := w.db.QueryContext(ctx, `
+		SELECT user_id FROM conversation_participants WHERE conversation_id = $1
+	`, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
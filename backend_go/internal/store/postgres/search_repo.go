@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"backend_go/internal/domain"
+)
+
+type SearchRepo struct {
+	db *sql.DB
+}
+
+func NewSearchRepo(db *sql.DB) *SearchRepo {
+	return &SearchRepo{db: db}
+}
+
+var _ domain.MessageSearchRepository = (*SearchRepo)(nil)
+
+// Search queries the tsvector column migrations.go keeps in sync via a
+// trigger on search_text, ranking hits by relevance and then recency.
+func (r *SearchRepo) Search(ctx context.Context, conversationIDs []int64, query string, limit int) ([]*domain.Message, error) {
+	if len(conversationIDs) == 0 || query == "" {
+		return nil, nil
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, content, conversation_id, sender_id, created_at, file_path, file_type,
+		       fully_read_at, is_deleted, is_edited, is_read, expires_at, view_once
+		FROM messages
+		WHERE conversation_id = ANY($1::bigint[])
+		  AND is_deleted = FALSE
+		  AND search_vector @@ plainto_tsquery('english', $2)
+		ORDER BY ts_rank(search_vector, plainto_tsquery('english', $2)) DESC, created_at DESC
+		LIMIT $3
+	`, conversationIDs, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("search messages: %w", err)
+	}
+	defer rows.Close()
+
+	var res []*domain.Message
+	for rows.Next() {
+		m := &domain.Message{}
+		if err := rows.Scan(
+			&m.ID, &m.Content, &m.ConversationID, &m.SenderID, &m.CreatedAt,
+			&m.FilePath, &m.FileType, &m.FullyReadAt, &m.IsDeleted, &m.IsEdited, &m.IsRead,
+			&m.ExpiresAt, &m.ViewOnce,
+		); err != nil {
+			return nil, fmt.Errorf("scan search result: %w", err)
+		}
+		res = append(res, m)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"backend_go/internal/domain"
+)
+
+type TusUploadRepo struct {
+	db *sql.DB
+}
+
+func NewTusUploadRepo(db *sql.DB) *TusUploadRepo {
+	return &TusUploadRepo{db: db}
+}
+
+var _ domain.TusUploadRepository = (*TusUploadRepo)(nil)
+
+func (r *TusUploadRepo) Create(ctx context.Context, u *domain.TusUpload) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO uploads_in_progress (id, owner_id, key, content_type, size, "offset", metadata, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING created_at
+	`, u.ID, u.OwnerID, u.Key, u.ContentType, u.Size, u.Offset, u.Metadata, u.ExpiresAt,
+	).Scan(&u.CreatedAt)
+}
+
+func (r *TusUploadRepo) GetByID(ctx context.Context, id string) (*domain.TusUpload, error) {
+	u := &domain.TusUpload{}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, owner_id, key, content_type, size, "offset", metadata, created_at, expires_at
+		FROM uploads_in_progress WHERE id = $1
+	`, id).Scan(&u.ID, &u.OwnerID, &u.Key, &u.ContentType, &u.Size, &u.Offset, &u.Metadata, &u.CreatedAt, &u.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get upload in progress: %w", err)
+	}
+	return u, nil
+}
+
+func (r *TusUploadRepo) UpdateOffset(ctx context.Context, id string, offset int64) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE uploads_in_progress SET "offset" = $1 WHERE id = $2`, offset, id)
+	if err != nil {
+		return fmt.Errorf("update upload offset: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *TusUploadRepo) Delete(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM uploads_in_progress WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete upload in progress: %w", err)
+	}
+	return nil
+}
+
+func (r *TusUploadRepo) ListExpired(ctx context.Context, before time.Time) ([]*domain.TusUpload, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, owner_id, key, content_type, size, "offset", metadata, created_at, expires_at
+		FROM uploads_in_progress WHERE expires_at < $1
+	`, before)
+	if err != nil {
+		return nil, fmt.Errorf("list expired uploads: %w", err)
+	}
+	defer rows.Close()
+
+	var uploads []*domain.TusUpload
+	for rows.Next() {
+		u := &domain.TusUpload{}
+		if err := rows.Scan(&u.ID, &u.OwnerID, &u.Key, &u.ContentType, &u.Size, &u.Offset, &u.Metadata, &u.CreatedAt, &u.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("scan upload in progress: %w", err)
+		}
+		uploads = append(uploads, u)
+	}
+	return uploads, rows.Err()
+}
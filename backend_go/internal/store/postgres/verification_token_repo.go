@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"backend_go/internal/domain"
+)
+
+type VerificationTokenRepo struct {
+	db *sql.DB
+}
+
+func NewVerificationTokenRepo(db *sql.DB) *VerificationTokenRepo {
+	return &VerificationTokenRepo{db: db}
+}
+
+var _ domain.VerificationTokenRepository = (*VerificationTokenRepo)(nil)
+
+func (r *VerificationTokenRepo) Create(ctx context.Context, t *domain.VerificationToken) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO verification_tokens (token, user_id, purpose, expires_at, used)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`, t.Token, t.UserID, t.Purpose, t.ExpiresAt, t.Used).Scan(&t.CreatedAt)
+}
+
+func (r *VerificationTokenRepo) GetByToken(ctx context.Context, token string) (*domain.VerificationToken, error) {
+	t := &domain.VerificationToken{}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT token, user_id, purpose, expires_at, used, created_at
+		FROM verification_tokens WHERE token = $1
+	`, token).Scan(&t.Token, &t.UserID, &t.Purpose, &t.ExpiresAt, &t.Used, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get verification token: %w", err)
+	}
+	return t, nil
+}
+
+func (r *VerificationTokenRepo) MarkUsed(ctx context.Context, token string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE verification_tokens SET used = TRUE WHERE token = $1`, token)
+	if err != nil {
+		return fmt.Errorf("mark verification token used: %w", err)
+	}
+	return nil
+}
+
+func (r *VerificationTokenRepo) DeleteExpired(ctx context.Context, cutoff time.Time) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM verification_tokens WHERE expires_at < $1`, cutoff)
+	if err != nil {
+		return fmt.Errorf("delete expired verification tokens: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"backend_go/internal/domain"
+)
+
+type CallRepo struct {
+	db *sql.DB
+}
+
+func NewCallRepo(db *sql.DB) *CallRepo {
+	return &CallRepo{db: db}
+}
+
+var _ domain.CallRepository = (*CallRepo)(nil)
+
+func (r *CallRepo) Create(ctx context.Context, c *domain.Call) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO calls (id, conversation_id, caller_id, callee_id, outcome, started_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, c.ID, c.ConversationID, c.CallerID, c.CalleeID, c.Outcome)
+	if err != nil {
+		return fmt.Errorf("insert call: %w", err)
+	}
+	return r.db.QueryRowContext(ctx, `SELECT started_at FROM calls WHERE id = ?`, c.ID).Scan(&c.StartedAt)
+}
+
+func (r *CallRepo) GetByID(ctx context.Context, id string) (*domain.Call, error) {
+	c := &domain.Call{}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, conversation_id, caller_id, callee_id, started_at, ended_at, outcome
+		FROM calls WHERE id = ?
+	`, id).Scan(&c.ID, &c.ConversationID, &c.CallerID, &c.CalleeID, &c.StartedAt, &c.EndedAt, &c.Outcome)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get call: %w", err)
+	}
+	return c, nil
+}
+
+func (r *CallRepo) End(ctx context.Context, id string, endedAt time.Time, outcome string) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE calls SET ended_at = ?, outcome = ? WHERE id = ?`, endedAt, outcome, id)
+	if err != nil {
+		return fmt.Errorf("end call: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("end call rows affected: %w", err)
+	}
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *CallRepo) ListForConversation(ctx context.Context, conversationID int64, limit int) ([]*domain.Call, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, conversation_id, caller_id, callee_id, started_at, ended_at, outcome
+		FROM calls WHERE conversation_id = ?
+		ORDER BY started_at DESC LIMIT ?
+	`, conversationID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list calls: %w", err)
+	}
+	defer rows.Close()
+
+	var calls []*domain.Call
+	for rows.Next() {
+		c := &domain.Call{}
+		if err := rows.Scan(&c.ID, &c.ConversationID, &c.CallerID, &c.CalleeID, &c.StartedAt, &c.EndedAt, &c.Outcome); err != nil {
+			return nil, fmt.Errorf("scan call: %w", err)
+		}
+		calls = append(calls, c)
+	}
+	return calls, rows.Err()
+}
@@ -0,0 +1,119 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"backend_go/internal/domain"
+)
+
+type ConversationKeyRepo struct {
+	db *sql.DB
+}
+
+func NewConversationKeyRepo(db *sql.DB) *ConversationKeyRepo {
+	return &ConversationKeyRepo{db: db}
+}
+
+var _ domain.ConversationKeyRepository = (*ConversationKeyRepo)(nil)
+
+func (r *ConversationKeyRepo) GetActive(ctx context.Context, conversationID int64) (*domain.ConversationKey, error) {
+	k := &domain.ConversationKey{}
+	var kekID int64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, conversation_id, kek_id, wrapped_dek, status, created_at
+		FROM conversation_keys
+		WHERE conversation_id = ? AND status = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`, conversationID, domain.ConversationKeyActive,
+	).Scan(&k.ID, &k.ConversationID, &kekID, &k.WrappedDEK, &k.Status, &k.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get active conversation key: %w", err)
+	}
+	k.KEKID = uint32(kekID)
+	return k, nil
+}
+
+func (r *ConversationKeyRepo) GetByID(ctx context.Context, id int64) (*domain.ConversationKey, error) {
+	k := &domain.ConversationKey{}
+	var kekID int64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, conversation_id, kek_id, wrapped_dek, status, created_at
+		FROM conversation_keys WHERE id = ?
+	`, id).Scan(&k.ID, &k.ConversationID, &kekID, &k.WrappedDEK, &k.Status, &k.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get conversation key: %w", err)
+	}
+	k.KEKID = uint32(kekID)
+	return k, nil
+}
+
+func (r *ConversationKeyRepo) Create(ctx context.Context, key *domain.ConversationKey) error {
+	if key.Status == "" {
+		key.Status = domain.ConversationKeyActive
+	}
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO conversation_keys (conversation_id, kek_id, wrapped_dek, status)
+		VALUES (?, ?, ?, ?)
+	`, key.ConversationID, int64(key.KEKID), key.WrappedDEK, key.Status)
+	if err != nil {
+		return fmt.Errorf("create conversation key: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("create conversation key: %w", err)
+	}
+	key.ID = id
+	return nil
+}
+
+func (r *ConversationKeyRepo) Retire(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE conversation_keys SET status = ? WHERE id = ?
+	`, domain.ConversationKeyRetired, id)
+	if err != nil {
+		return fmt.Errorf("retire conversation key: %w", err)
+	}
+	return nil
+}
+
+func (r *ConversationKeyRepo) Rewrap(ctx context.Context, id int64, kekID uint32, wrappedDEK []byte) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE conversation_keys SET kek_id = ?, wrapped_dek = ? WHERE id = ?
+	`, int64(kekID), wrappedDEK, id)
+	if err != nil {
+		return fmt.Errorf("rewrap conversation key: %w", err)
+	}
+	return nil
+}
+
+func (r *ConversationKeyRepo) ListAll(ctx context.Context) ([]*domain.ConversationKey, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, conversation_id, kek_id, wrapped_dek, status, created_at
+		FROM conversation_keys
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list conversation keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*domain.ConversationKey
+	for rows.Next() {
+		k := &domain.ConversationKey{}
+		var kekID int64
+		if err := rows.Scan(&k.ID, &k.ConversationID, &kekID, &k.WrappedDEK, &k.Status, &k.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan conversation key: %w", err)
+		}
+		k.KEKID = uint32(kekID)
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
@@ -0,0 +1,150 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"backend_go/internal/storage"
+)
+
+// Broadcaster delivers a deletion notification to a conversation's
+// participants, so clients can purge their local copy of a message the
+// janitor removed server-side. *ws.Hub satisfies this.
+type Broadcaster interface {
+	BroadcastToUsers(userIDs []int64, payload any)
+}
+
+// Janitor periodically deletes messages whose per-message TTL has passed,
+// whose conversation retention window has lapsed, or that are view-once and
+// have just been fully read, and tells connected clients to drop them too.
+// Retention is enforced here, server-side, not just advertised to clients.
+type Janitor struct {
+	db       *sql.DB
+	storage  storage.Backend
+	notify   Broadcaster
+	interval time.Duration
+}
+
+// NewJanitor builds a Janitor. storage may be nil if attachment cleanup is
+// not needed (e.g. in tests); notify may be nil to run deletions without
+// broadcasting, which is occasionally useful offline.
+func NewJanitor(db *sql.DB, backend storage.Backend, notify Broadcaster, interval time.Duration) *Janitor {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &Janitor{db: db, storage: backend, notify: notify, interval: interval}
+}
+
+// Run sweeps on a ticker until ctx is cancelled.
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := j.Sweep(ctx); err != nil {
+				log.Printf("janitor: sweep: %v", err)
+			}
+		}
+	}
+}
+
+type lapsedMessage struct {
+	id             int64
+	conversationID int64
+	filePath       sql.NullString
+}
+
+// Sweep deletes every message that is past its ExpiresAt, past its
+// conversation's retention_seconds, or view-once and fully read, removing
+// any attachment object alongside it and broadcasting a "message_expired"
+// event per conversation so clients purge their local copies.
+func (j *Janitor) Sweep(ctx context.Context) error {
+	rows, err := j.db.QueryContext(ctx, `
+		SELECT m.id, m.conversation_id, m.file_path
+		FROM messages m
+		JOIN conversations c ON c.id = m.conversation_id
+		WHERE (m.expires_at IS NOT NULL AND m.expires_at < CURRENT_TIMESTAMP)
+		   OR (m.view_once = 1 AND m.fully_read_at IS NOT NULL)
+		   OR (c.retention_seconds IS NOT NULL
+		       AND datetime(m.created_at, '+' || c.retention_seconds || ' seconds') < CURRENT_TIMESTAMP)
+	`)
+	if err != nil {
+		return fmt.Errorf("select lapsed messages: %w", err)
+	}
+
+	var lapsed []lapsedMessage
+	for rows.Next() {
+		var lm lapsedMessage
+		if err := rows.Scan(&lm.id, &lm.conversationID, &lm.filePath); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan lapsed message: %w", err)
+		}
+		lapsed = append(lapsed, lm)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	if len(lapsed) == 0 {
+		return nil
+	}
+
+	byConversation := make(map[int64][]int64)
+	for _, lm := range lapsed {
+		if _, err := j.db.ExecContext(ctx, `DELETE FROM messages WHERE id = ?`, lm.id); err != nil {
+			return fmt.Errorf("delete lapsed message %d: %w", lm.id, err)
+		}
+		if j.storage != nil && lm.filePath.Valid && lm.filePath.String != "" {
+			if err := j.storage.Delete(ctx, lm.filePath.String); err != nil {
+				log.Printf("janitor: delete attachment %q for message %d: %v", lm.filePath.String, lm.id, err)
+			}
+		}
+		byConversation[lm.conversationID] = append(byConversation[lm.conversationID], lm.id)
+	}
+
+	if j.notify == nil {
+		return nil
+	}
+	for conversationID, messageIDs := range byConversation {
+		participantIDs, err := j.participantIDs(ctx, conversationID)
+		if err != nil {
+			log.Printf("janitor: list participants for conversation %d: %v", conversationID, err)
+			continue
+		}
+		j.notify.BroadcastToUsers(participantIDs, map[string]any{
+			"type":            "message_expired",
+			"conversation_id": conversationID,
+			"message_ids":     messageIDs,
+		})
+	}
+	return nil
+}
+
+func (j *Janitor) participantIDs(ctx context.Context, conversationID int64) ([]int64, error) {
+	rows, err := j.db.QueryContext(ctx, `
+		SELECT user_id FROM conversation_participants WHERE conversation_id = ?
+	`, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"backend_go/internal/domain"
 )
@@ -19,10 +20,18 @@ func NewMessageRepo(db *sql.DB) *MessageRepo {
 
 var _ domain.MessageRepository = (*MessageRepo)(nil)
 
+// isReadExpr derives Message.IsRead as "read on at least one device" from
+// message_progress, mirroring the postgres store. The OR keeps it true for
+// clients still on the legacy MarkAllReadInConversation path (the WS
+// mark_read event), which never writes a message_progress row.
+const isReadExpr = `(m.is_read OR EXISTS (
+	SELECT 1 FROM message_progress mp WHERE mp.message_id = m.id AND mp.read_at IS NOT NULL
+)) AS is_read`
+
 func (r *MessageRepo) Create(ctx context.Context, m *domain.Message) error {
 	query := `
-		INSERT INTO messages (content, conversation_id, sender_id, created_at, file_path, file_type, fully_read_at, is_deleted)
-		VALUES (?, ?, ?, CURRENT_TIMESTAMP, ?, ?, ?, ?)
+		INSERT INTO messages (content, conversation_id, sender_id, created_at, file_path, file_type, fully_read_at, is_deleted, expires_at, view_once, search_text, origin_server)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	res, err := r.db.ExecContext(ctx, query,
 		m.Content,
@@ -32,6 +41,10 @@ func (r *MessageRepo) Create(ctx context.Context, m *domain.Message) error {
 		m.FileType,
 		m.FullyReadAt,
 		m.IsDeleted,
+		m.ExpiresAt,
+		m.ViewOnce,
+		m.SearchText,
+		m.OriginServer,
 	)
 	if err != nil {
 		return fmt.Errorf("insert message: %w", err)
@@ -44,10 +57,43 @@ func (r *MessageRepo) Create(ctx context.Context, m *domain.Message) error {
 	return nil
 }
 
+func (r *MessageRepo) GetByID(ctx context.Context, id int64) (*domain.Message, error) {
+	m := &domain.Message{}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, content, conversation_id, sender_id, created_at, file_path, file_type,
+		       fully_read_at, is_deleted, is_edited, edited_at, `+isReadExpr+`, expires_at, view_once, origin_server
+		FROM messages m WHERE m.id = ?
+	`, id).Scan(
+		&m.ID, &m.Content, &m.ConversationID, &m.SenderID, &m.CreatedAt,
+		&m.FilePath, &m.FileType, &m.FullyReadAt, &m.IsDeleted, &m.IsEdited, &m.EditedAt, &m.IsRead,
+		&m.ExpiresAt, &m.ViewOnce, &m.OriginServer,
+	)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get message: %w", err)
+	}
+	return m, nil
+}
+
+func (r *MessageRepo) Update(ctx context.Context, m *domain.Message) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE messages SET content=?, is_edited=?, edited_at=CURRENT_TIMESTAMP, search_text=? WHERE id=?
+	`, m.Content, m.IsEdited, m.SearchText, m.ID)
+	return err
+}
+
+func (r *MessageRepo) SoftDeleteForEveryone(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE messages SET is_deleted=1 WHERE id=?`, id)
+	return err
+}
+
 func (r *MessageRepo) ListForConversation(ctx context.Context, conversationID int64, limit int) ([]*domain.Message, error) {
 	query := `
-		SELECT id, content, conversation_id, sender_id, created_at, file_path, file_type, fully_read_at, is_deleted
-		FROM messages
+		SELECT id, content, conversation_id, sender_id, created_at, file_path, file_type,
+		       fully_read_at, is_deleted, is_edited, edited_at, `+isReadExpr+`, expires_at, view_once, origin_server
+		FROM messages m
 		WHERE conversation_id = ?
 		ORDER BY created_at DESC
 		LIMIT ?
@@ -56,72 +102,221 @@ func (r *MessageRepo) ListForConversation(ctx context.Context, conversationID in
 	if err != nil {
 		return nil, fmt.Errorf("list messages: %w", err)
 	}
-	defer rows.Close()
+	return r.scanMessages(rows)
+}
 
-	var res []*domain.Message
-	for rows.Next() {
-		m := &domain.Message{}
-		if err := rows.Scan(
-			&m.ID,
-			&m.Content,
-			&m.ConversationID,
-			&m.SenderID,
-			&m.CreatedAt,
-			&m.FilePath,
-			&m.FileType,
-			&m.FullyReadAt,
-			&m.IsDeleted,
-		); err != nil {
-			return nil, fmt.Errorf("scan message: %w", err)
+// ListForConversationForUser is like ListForConversation but excludes messages
+// the given user has soft-deleted via "delete for me".
+func (r *MessageRepo) ListForConversationForUser(ctx context.Context, conversationID, userID int64, limit int) ([]*domain.Message, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT m.id, m.content, m.conversation_id, m.sender_id, m.created_at,
+		       m.file_path, m.file_type, m.fully_read_at, m.is_deleted, m.is_edited, m.edited_at, `+isReadExpr+`,
+		       m.expires_at, m.view_once, m.origin_server
+		FROM messages m
+		LEFT JOIN user_deleted_messages udm
+		       ON udm.message_id = m.id AND udm.user_id = ?
+		WHERE m.conversation_id = ?
+		  AND udm.user_id IS NULL
+		ORDER BY m.created_at DESC
+		LIMIT ?
+	`, userID, conversationID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list messages for user: %w", err)
+	}
+	return r.scanMessages(rows)
+}
+
+// ListPage performs keyset pagination over a conversation's messages,
+// excluding ones the user has soft-deleted via "delete for me". A zero
+// Cursor starts from the most recent message in either direction.
+func (r *MessageRepo) ListPage(ctx context.Context, conversationID, userID int64, cur domain.Cursor, dir domain.Direction, limit int) ([]*domain.Message, error) {
+	cols := `m.id, m.content, m.conversation_id, m.sender_id, m.created_at,
+		       m.file_path, m.file_type, m.fully_read_at, m.is_deleted, m.is_edited, m.edited_at, `+isReadExpr+`,
+		       m.expires_at, m.view_once, m.origin_server`
+
+	var rows *sql.Rows
+	var err error
+	switch {
+	case dir == domain.Forward && cur.ID == 0:
+		rows, err = r.db.QueryContext(ctx, `
+			SELECT `+cols+`
+			FROM messages m
+			LEFT JOIN user_deleted_messages udm ON udm.message_id = m.id AND udm.user_id = ?
+			WHERE m.conversation_id = ? AND udm.user_id IS NULL
+			ORDER BY m.created_at ASC, m.id ASC
+			LIMIT ?
+		`, userID, conversationID, limit)
+	case dir == domain.Forward:
+		rows, err = r.db.QueryContext(ctx, `
+			SELECT `+cols+`
+			FROM messages m
+			LEFT JOIN user_deleted_messages udm ON udm.message_id = m.id AND udm.user_id = ?
+			WHERE m.conversation_id = ? AND udm.user_id IS NULL
+			  AND (m.created_at > ? OR (m.created_at = ? AND m.id > ?))
+			ORDER BY m.created_at ASC, m.id ASC
+			LIMIT ?
+		`, userID, conversationID, cur.CreatedAt, cur.CreatedAt, cur.ID, limit)
+	case cur.ID == 0:
+		rows, err = r.db.QueryContext(ctx, `
+			SELECT `+cols+`
+			FROM messages m
+			LEFT JOIN user_deleted_messages udm ON udm.message_id = m.id AND udm.user_id = ?
+			WHERE m.conversation_id = ? AND udm.user_id IS NULL
+			ORDER BY m.created_at DESC, m.id DESC
+			LIMIT ?
+		`, userID, conversationID, limit)
+	default:
+		rows, err = r.db.QueryContext(ctx, `
+			SELECT `+cols+`
+			FROM messages m
+			LEFT JOIN user_deleted_messages udm ON udm.message_id = m.id AND udm.user_id = ?
+			WHERE m.conversation_id = ? AND udm.user_id IS NULL
+			  AND (m.created_at < ? OR (m.created_at = ? AND m.id < ?))
+			ORDER BY m.created_at DESC, m.id DESC
+			LIMIT ?
+		`, userID, conversationID, cur.CreatedAt, cur.CreatedAt, cur.ID, limit)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list message page: %w", err)
+	}
+	return r.scanMessages(rows)
+}
+
+// ChangesSince returns messages created or edited in conversationID after
+// since (upserts, visible to userID), plus the ids of messages that user has
+// had soft-deleted from under them since then — either by the sender
+// deleting "for everyone" or by the janitor expiring the message.
+func (r *MessageRepo) ChangesSince(ctx context.Context, conversationID, userID int64, since time.Time) ([]*domain.Message, []int64, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT m.id, m.content, m.conversation_id, m.sender_id, m.created_at,
+		       m.file_path, m.file_type, m.fully_read_at, m.is_deleted, m.is_edited, m.edited_at, `+isReadExpr+`,
+		       m.expires_at, m.view_once, m.origin_server
+		FROM messages m
+		LEFT JOIN user_deleted_messages udm ON udm.message_id = m.id AND udm.user_id = ?
+		WHERE m.conversation_id = ? AND udm.user_id IS NULL
+		  AND (m.created_at > ? OR m.edited_at > ?)
+		ORDER BY m.created_at ASC, m.id ASC
+	`, userID, conversationID, since, since)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list changed messages: %w", err)
+	}
+	upserts, err := r.scanMessages(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	deletedRows, err := r.db.QueryContext(ctx, `
+		SELECT id FROM messages
+		WHERE conversation_id = ? AND is_deleted = 1 AND created_at <= ?
+	`, conversationID, since)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list deleted messages: %w", err)
+	}
+	defer deletedRows.Close()
+	var deletedIDs []int64
+	for deletedRows.Next() {
+		var id int64
+		if err := deletedRows.Scan(&id); err != nil {
+			return nil, nil, fmt.Errorf("scan deleted message id: %w", err)
 		}
-		res = append(res, m)
+		deletedIDs = append(deletedIDs, id)
 	}
-	return res, nil
+	return upserts, deletedIDs, deletedRows.Err()
 }
 
-func (r *MessageRepo) PruneOld(ctx context.Context, conversationID int64, keepLimit int) error {
+// MarkAllReadInConversation marks every unread message from other senders as
+// read. A view-once message also gets fully_read_at stamped here, which the
+// janitor treats as an immediate deletion signal.
+func (r *MessageRepo) MarkAllReadInConversation(ctx context.Context, conversationID, senderExcludeID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE messages
+		SET is_read = 1,
+		    fully_read_at = CASE WHEN view_once THEN CURRENT_TIMESTAMP ELSE fully_read_at END
+		WHERE conversation_id=? AND sender_id!=? AND is_read=0 AND is_deleted=0
+	`, conversationID, senderExcludeID)
+	return err
+}
+
+func (r *MessageRepo) PruneOld(ctx context.Context, conversationID int64, keepLimit int) ([]domain.PrunedMessage, error) {
 	// Count messages
 	var count int
 	if err := r.db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM messages WHERE conversation_id = ?
 	`, conversationID).Scan(&count); err != nil {
-		return fmt.Errorf("count messages: %w", err)
+		return nil, fmt.Errorf("count messages: %w", err)
 	}
 
 	if count <= keepLimit {
-		return nil
+		return nil, nil
 	}
 
-	// Get IDs of messages to delete (oldest first)
+	// Get the oldest messages beyond keepLimit, along with their attachment
+	// path so the caller can clean up the blob.
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT id FROM messages
+		SELECT id, file_path FROM messages
 		WHERE conversation_id = ?
 		ORDER BY created_at ASC
 		LIMIT ?
 	`, conversationID, count-keepLimit)
 	if err != nil {
-		return fmt.Errorf("select old messages: %w", err)
+		return nil, fmt.Errorf("select old messages: %w", err)
+	}
+	pruned, err := scanPrunedMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(pruned) == 0 {
+		return nil, nil
 	}
-	defer rows.Close()
 
-	var ids []int64
-	for rows.Next() {
-		var id int64
-		if err := rows.Scan(&id); err != nil {
-			return fmt.Errorf("scan id: %w", err)
-		}
-		ids = append(ids, id)
+	if err := r.deletePrunedMessages(ctx, pruned); err != nil {
+		return nil, err
 	}
+	return pruned, nil
+}
 
-	if len(ids) == 0 {
-		return nil
+// PruneOlderThan deletes every message in conversationID created before
+// cutoff, the age-based counterpart to PruneOld's count-based cap.
+func (r *MessageRepo) PruneOlderThan(ctx context.Context, conversationID int64, cutoff time.Time) ([]domain.PrunedMessage, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, file_path FROM messages
+		WHERE conversation_id = ? AND created_at < ?
+	`, conversationID, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("select expired messages: %w", err)
+	}
+	pruned, err := scanPrunedMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(pruned) == 0 {
+		return nil, nil
 	}
 
-	// Delete messages
-	query := `DELETE FROM messages WHERE id IN (?` + strings.Repeat(",?", len(ids)-1) + `)`
-	args := make([]any, len(ids))
-	for i, id := range ids {
-		args[i] = id
+	if err := r.deletePrunedMessages(ctx, pruned); err != nil {
+		return nil, err
+	}
+	return pruned, nil
+}
+
+func scanPrunedMessages(rows *sql.Rows) ([]domain.PrunedMessage, error) {
+	defer rows.Close()
+	var pruned []domain.PrunedMessage
+	for rows.Next() {
+		var pm domain.PrunedMessage
+		if err := rows.Scan(&pm.ID, &pm.FilePath); err != nil {
+			return nil, fmt.Errorf("scan pruned message: %w", err)
+		}
+		pruned = append(pruned, pm)
+	}
+	return pruned, rows.Err()
+}
+
+func (r *MessageRepo) deletePrunedMessages(ctx context.Context, pruned []domain.PrunedMessage) error {
+	query := `DELETE FROM messages WHERE id IN (?` + strings.Repeat(",?", len(pruned)-1) + `)`
+	args := make([]any, len(pruned))
+	for i, pm := range pruned {
+		args[i] = pm.ID
 	}
 	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
 		return fmt.Errorf("delete old messages: %w", err)
@@ -129,3 +324,21 @@ func (r *MessageRepo) PruneOld(ctx context.Context, conversationID int64, keepLi
 	return nil
 }
 
+// ── helpers ──────────────────────────────────────────────────────────────────
+
+func (r *MessageRepo) scanMessages(rows *sql.Rows) ([]*domain.Message, error) {
+	defer rows.Close()
+	var res []*domain.Message
+	for rows.Next() {
+		m := &domain.Message{}
+		if err := rows.Scan(
+			&m.ID, &m.Content, &m.ConversationID, &m.SenderID, &m.CreatedAt,
+			&m.FilePath, &m.FileType, &m.FullyReadAt, &m.IsDeleted, &m.IsEdited, &m.EditedAt, &m.IsRead,
+			&m.ExpiresAt, &m.ViewOnce, &m.OriginServer,
+		); err != nil {
+			return nil, fmt.Errorf("scan message: %w", err)
+		}
+		res = append(res, m)
+	}
+	return res, rows.Err()
+}
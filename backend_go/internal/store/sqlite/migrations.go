@@ -0,0 +1,509 @@
+package sqlite
+
+import "backend_go/internal/store/migrate"
+
+// migrations is the ordered, versioned schema history for the sqlite store.
+// Each entry's Up/Down must be safe to run standalone inside its own
+// transaction; once a version has shipped, its SQL should not be edited —
+// ship a new version instead.
+var migrations = []migrate.Migration{
+	{
+		Version: 1,
+		Name:    "create_core_tables",
+		Up: `
+			CREATE TABLE IF NOT EXISTS users (
+				id INTEGER PRIMARY KEY,
+				username VARCHAR(50) UNIQUE NOT NULL,
+				email VARCHAR(100) UNIQUE,
+				hashed_password VARCHAR(255) NOT NULL,
+				is_active BOOLEAN DEFAULT TRUE,
+				is_online BOOLEAN DEFAULT FALSE,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				last_seen DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE TABLE IF NOT EXISTS conversations (
+				id INTEGER PRIMARY KEY,
+				name VARCHAR(100),
+				is_group BOOLEAN DEFAULT FALSE,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE TABLE IF NOT EXISTS conversation_participants (
+				user_id INTEGER NOT NULL,
+				conversation_id INTEGER NOT NULL,
+				last_read_at DATETIME DEFAULT NULL,
+				joined_at DATETIME DEFAULT NULL,
+				PRIMARY KEY (user_id, conversation_id),
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				FOREIGN KEY (conversation_id) REFERENCES conversations(id)
+			);
+
+			CREATE TABLE IF NOT EXISTS messages (
+				id INTEGER PRIMARY KEY,
+				content TEXT NOT NULL,
+				conversation_id INTEGER NOT NULL,
+				sender_id INTEGER NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				file_path TEXT DEFAULT NULL,
+				file_type TEXT DEFAULT NULL,
+				fully_read_at DATETIME DEFAULT NULL,
+				is_deleted BOOLEAN DEFAULT 0,
+				FOREIGN KEY (conversation_id) REFERENCES conversations(id),
+				FOREIGN KEY (sender_id) REFERENCES users(id)
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_users_username ON users(username);
+			CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
+			CREATE INDEX IF NOT EXISTS idx_users_is_online ON users(is_online);
+			CREATE INDEX IF NOT EXISTS idx_conversations_is_group ON conversations(is_group);
+			CREATE INDEX IF NOT EXISTS idx_conversations_updated_at ON conversations(updated_at DESC);
+			CREATE INDEX IF NOT EXISTS idx_conv_participants_user ON conversation_participants(user_id);
+			CREATE INDEX IF NOT EXISTS idx_conv_participants_conv ON conversation_participants(conversation_id);
+			CREATE INDEX IF NOT EXISTS idx_messages_conversation ON messages(conversation_id);
+			CREATE INDEX IF NOT EXISTS idx_messages_sender ON messages(sender_id);
+			CREATE INDEX IF NOT EXISTS idx_messages_created_at ON messages(created_at DESC);
+			CREATE INDEX IF NOT EXISTS idx_messages_conv_created ON messages(conversation_id, created_at DESC);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS messages;
+			DROP TABLE IF EXISTS conversation_participants;
+			DROP TABLE IF EXISTS conversations;
+			DROP TABLE IF EXISTS users;
+		`,
+	},
+	{
+		Version: 2,
+		Name:    "attachments",
+		Up: `
+			CREATE TABLE IF NOT EXISTS attachments (
+				id INTEGER PRIMARY KEY,
+				owner_id INTEGER NOT NULL,
+				key TEXT NOT NULL UNIQUE,
+				content_type TEXT NOT NULL,
+				size INTEGER NOT NULL,
+				sha256 TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (owner_id) REFERENCES users(id)
+			);
+			CREATE INDEX IF NOT EXISTS idx_attachments_owner ON attachments(owner_id);
+			CREATE INDEX IF NOT EXISTS idx_attachments_sha256 ON attachments(sha256);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS attachments;
+		`,
+	},
+	{
+		Version: 3,
+		Name:    "user_otp",
+		Up: `
+			CREATE TABLE IF NOT EXISTS user_otp (
+				user_id INTEGER PRIMARY KEY,
+				secret BLOB NOT NULL,
+				confirmed BOOLEAN DEFAULT FALSE,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+
+			CREATE TABLE IF NOT EXISTS user_recovery_codes (
+				user_id INTEGER NOT NULL,
+				code_hash TEXT NOT NULL,
+				used_at DATETIME DEFAULT NULL,
+				PRIMARY KEY (user_id, code_hash),
+				FOREIGN KEY (user_id) REFERENCES user_otp(user_id) ON DELETE CASCADE
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_user_recovery_codes_user ON user_recovery_codes(user_id);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS user_recovery_codes;
+			DROP TABLE IF EXISTS user_otp;
+		`,
+	},
+	{
+		Version: 4,
+		Name:    "user_identities",
+		Up: `
+			CREATE TABLE IF NOT EXISTS user_identities (
+				user_id INTEGER NOT NULL,
+				provider TEXT NOT NULL,
+				subject TEXT NOT NULL,
+				email TEXT,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (provider, subject),
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities(user_id);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS user_identities;
+		`,
+	},
+	{
+		Version: 5,
+		Name:    "message_expiry_and_conversation_retention",
+		Up: `
+			ALTER TABLE messages ADD COLUMN expires_at DATETIME DEFAULT NULL;
+			ALTER TABLE messages ADD COLUMN view_once BOOLEAN DEFAULT FALSE;
+			ALTER TABLE conversations ADD COLUMN retention_seconds INTEGER DEFAULT NULL;
+
+			CREATE INDEX IF NOT EXISTS idx_messages_expires_at ON messages(expires_at);
+		`,
+		Down: `
+			DROP INDEX IF EXISTS idx_messages_expires_at;
+			ALTER TABLE conversations DROP COLUMN retention_seconds;
+			ALTER TABLE messages DROP COLUMN view_once;
+			ALTER TABLE messages DROP COLUMN expires_at;
+		`,
+	},
+	{
+		Version: 6,
+		Name:    "uploads_in_progress",
+		Up: `
+			CREATE TABLE IF NOT EXISTS uploads_in_progress (
+				id TEXT PRIMARY KEY,
+				owner_id INTEGER NOT NULL,
+				key TEXT NOT NULL UNIQUE,
+				content_type TEXT NOT NULL,
+				size INTEGER NOT NULL,
+				offset INTEGER NOT NULL DEFAULT 0,
+				metadata TEXT NOT NULL DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				expires_at DATETIME NOT NULL,
+				FOREIGN KEY (owner_id) REFERENCES users(id)
+			);
+			CREATE INDEX IF NOT EXISTS idx_uploads_in_progress_owner ON uploads_in_progress(owner_id);
+			CREATE INDEX IF NOT EXISTS idx_uploads_in_progress_expires_at ON uploads_in_progress(expires_at);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS uploads_in_progress;
+		`,
+	},
+	{
+		Version: 7,
+		Name:    "calls",
+		Up: `
+			CREATE TABLE IF NOT EXISTS calls (
+				id              TEXT PRIMARY KEY,
+				conversation_id INTEGER NOT NULL,
+				caller_id       INTEGER NOT NULL,
+				callee_id       INTEGER NOT NULL,
+				started_at      DATETIME DEFAULT CURRENT_TIMESTAMP,
+				ended_at        DATETIME,
+				outcome         TEXT NOT NULL DEFAULT 'ongoing',
+				FOREIGN KEY (conversation_id) REFERENCES conversations(id),
+				FOREIGN KEY (caller_id) REFERENCES users(id),
+				FOREIGN KEY (callee_id) REFERENCES users(id)
+			);
+			CREATE INDEX IF NOT EXISTS idx_calls_conversation ON calls(conversation_id);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS calls;
+		`,
+	},
+	{
+		Version: 8,
+		Name:    "conversation_keep_last_n",
+		Up: `
+			ALTER TABLE conversations ADD COLUMN keep_last_n INTEGER DEFAULT NULL;
+		`,
+		Down: `
+			ALTER TABLE conversations DROP COLUMN keep_last_n;
+		`,
+	},
+	{
+		Version: 9,
+		Name:    "message_search_index",
+		Up: `
+			ALTER TABLE messages ADD COLUMN search_text TEXT DEFAULT NULL;
+
+			CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(
+				search_text,
+				content='messages',
+				content_rowid='id'
+			);
+
+			CREATE TRIGGER IF NOT EXISTS messages_fts_ai AFTER INSERT ON messages BEGIN
+				INSERT INTO messages_fts(rowid, search_text) VALUES (new.id, new.search_text);
+			END;
+			CREATE TRIGGER IF NOT EXISTS messages_fts_ad AFTER DELETE ON messages BEGIN
+				INSERT INTO messages_fts(messages_fts, rowid, search_text) VALUES('delete', old.id, old.search_text);
+			END;
+			CREATE TRIGGER IF NOT EXISTS messages_fts_au AFTER UPDATE ON messages BEGIN
+				INSERT INTO messages_fts(messages_fts, rowid, search_text) VALUES('delete', old.id, old.search_text);
+				INSERT INTO messages_fts(rowid, search_text) VALUES (new.id, new.search_text);
+			END;
+		`,
+		Down: `
+			DROP TRIGGER IF EXISTS messages_fts_au;
+			DROP TRIGGER IF EXISTS messages_fts_ad;
+			DROP TRIGGER IF EXISTS messages_fts_ai;
+			DROP TABLE IF EXISTS messages_fts;
+			ALTER TABLE messages DROP COLUMN search_text;
+		`,
+	},
+	{
+		Version: 10,
+		Name:    "mtls_service_accounts",
+		Up: `
+			ALTER TABLE users ADD COLUMN is_service_account INTEGER NOT NULL DEFAULT 0;
+
+			CREATE TABLE IF NOT EXISTS revoked_certificates (
+				serial_hex TEXT PRIMARY KEY,
+				subject    TEXT NOT NULL,
+				reason     TEXT NOT NULL DEFAULT '',
+				revoked_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS revoked_certificates;
+			ALTER TABLE users DROP COLUMN is_service_account;
+		`,
+	},
+	{
+		Version: 11,
+		Name:    "conversation_keys",
+		Up: `
+			CREATE TABLE IF NOT EXISTS conversation_keys (
+				id              INTEGER PRIMARY KEY,
+				conversation_id INTEGER NOT NULL,
+				kek_id          INTEGER NOT NULL,
+				wrapped_dek     BLOB NOT NULL,
+				status          TEXT NOT NULL DEFAULT 'active',
+				created_at      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (conversation_id) REFERENCES conversations(id)
+			);
+			CREATE INDEX IF NOT EXISTS idx_conversation_keys_conversation ON conversation_keys(conversation_id, status);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS conversation_keys;
+		`,
+	},
+	{
+		// The postgres store picked up is_edited/is_read and
+		// user_deleted_messages back in its own "message_edit_and_read_flags"
+		// and baseline migrations; this store never caught up. Bringing it
+		// in line here, plus edited_at and the keyset pagination index, so
+		// MessageRepository's cursor pagination and ChangesSince work
+		// against either store.
+		Version: 12,
+		Name:    "message_cursor_pagination",
+		Up: `
+			ALTER TABLE messages ADD COLUMN is_edited BOOLEAN NOT NULL DEFAULT 0;
+			ALTER TABLE messages ADD COLUMN is_read BOOLEAN NOT NULL DEFAULT 0;
+			ALTER TABLE messages ADD COLUMN edited_at DATETIME DEFAULT NULL;
+
+			CREATE TABLE IF NOT EXISTS user_deleted_messages (
+				user_id    INTEGER NOT NULL,
+				message_id INTEGER NOT NULL,
+				deleted_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (user_id, message_id),
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_messages_conv_created_id ON messages(conversation_id, created_at, id);
+		`,
+		Down: `
+			DROP INDEX IF EXISTS idx_messages_conv_created_id;
+			DROP TABLE IF EXISTS user_deleted_messages;
+			ALTER TABLE messages DROP COLUMN edited_at;
+			ALTER TABLE messages DROP COLUMN is_read;
+			ALTER TABLE messages DROP COLUMN is_edited;
+		`,
+	},
+	{
+		Version: 13,
+		Name:    "message_progress",
+		Up: `
+			CREATE TABLE IF NOT EXISTS message_progress (
+				user_id      INTEGER  NOT NULL,
+				device_id    TEXT     NOT NULL,
+				message_id   INTEGER  NOT NULL,
+				delivered_at DATETIME,
+				read_at      DATETIME,
+				percentage   INTEGER  NOT NULL DEFAULT 0,
+				PRIMARY KEY (user_id, device_id, message_id),
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+			);
+			CREATE INDEX IF NOT EXISTS idx_message_progress_message ON message_progress(message_id);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS message_progress;
+		`,
+	},
+	{
+		Version: 14,
+		Name:    "attachment_encryption",
+		Up: `
+			ALTER TABLE attachments ADD COLUMN wrapped_key BLOB DEFAULT NULL;
+			ALTER TABLE attachments ADD COLUMN kek_id INTEGER DEFAULT NULL;
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_attachments_sha256_unique ON attachments(sha256) WHERE sha256 != '';
+		`,
+		Down: `
+			DROP INDEX IF EXISTS idx_attachments_sha256_unique;
+			ALTER TABLE attachments DROP COLUMN kek_id;
+			ALTER TABLE attachments DROP COLUMN wrapped_key;
+		`,
+	},
+	{
+		Version: 15,
+		Name:    "oauth2_authserver",
+		Up: `
+			CREATE TABLE IF NOT EXISTS oauth_clients (
+				client_id      TEXT PRIMARY KEY,
+				client_secret  TEXT NOT NULL DEFAULT '',
+				name           TEXT NOT NULL,
+				redirect_uris  TEXT NOT NULL,
+				allowed_scopes TEXT NOT NULL DEFAULT 'openid,profile,email',
+				created_at     DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE TABLE IF NOT EXISTS oauth_auth_requests (
+				code                  TEXT PRIMARY KEY,
+				client_id             TEXT NOT NULL REFERENCES oauth_clients(client_id),
+				user_id               INTEGER NOT NULL REFERENCES users(id),
+				redirect_uri          TEXT NOT NULL,
+				scope                 TEXT NOT NULL,
+				state                 TEXT NOT NULL DEFAULT '',
+				code_challenge        TEXT NOT NULL DEFAULT '',
+				code_challenge_method TEXT NOT NULL DEFAULT '',
+				expires_at            DATETIME NOT NULL,
+				used                  BOOLEAN NOT NULL DEFAULT FALSE
+			);
+			CREATE INDEX IF NOT EXISTS idx_oauth_auth_requests_expires ON oauth_auth_requests(expires_at);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS oauth_auth_requests;
+			DROP TABLE IF EXISTS oauth_clients;
+		`,
+	},
+	{
+		Version: 16,
+		Name:    "verification_tokens",
+		Up: `
+			CREATE TABLE IF NOT EXISTS verification_tokens (
+				token      TEXT PRIMARY KEY,
+				user_id    INTEGER NOT NULL REFERENCES users(id),
+				purpose    TEXT NOT NULL,
+				expires_at DATETIME NOT NULL,
+				used       BOOLEAN NOT NULL DEFAULT FALSE,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_verification_tokens_expires ON verification_tokens(expires_at);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS verification_tokens;
+		`,
+	},
+	{
+		Version: 17,
+		Name:    "conversation_roles",
+		Up: `
+			ALTER TABLE conversation_participants ADD COLUMN role TEXT NOT NULL DEFAULT 'member';
+		`,
+		Down: `
+			ALTER TABLE conversation_participants DROP COLUMN role;
+		`,
+	},
+	{
+		Version: 18,
+		Name:    "federation",
+		Up: `
+			ALTER TABLE messages ADD COLUMN origin_server TEXT;
+			CREATE TABLE IF NOT EXISTS federation_remote_participants (
+				conversation_id INTEGER NOT NULL REFERENCES conversations(id),
+				handle          TEXT    NOT NULL,
+				PRIMARY KEY (conversation_id, handle)
+			);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS federation_remote_participants;
+			ALTER TABLE messages DROP COLUMN origin_server;
+		`,
+	},
+	{
+		Version: 19,
+		Name:    "federation_ghost_users",
+		Up: `
+			ALTER TABLE users ADD COLUMN remote_handle TEXT;
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_users_remote_handle ON users(remote_handle) WHERE remote_handle IS NOT NULL;
+		`,
+		Down: `
+			DROP INDEX IF EXISTS idx_users_remote_handle;
+			ALTER TABLE users DROP COLUMN remote_handle;
+		`,
+	},
+	{
+		Version: 20,
+		Name:    "authz_roles",
+		Up: `
+			ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user';
+			ALTER TABLE conversations ADD COLUMN is_locked BOOLEAN NOT NULL DEFAULT FALSE;
+			CREATE TABLE IF NOT EXISTS conversation_bans (
+				conversation_id INTEGER NOT NULL REFERENCES conversations(id),
+				user_id         INTEGER NOT NULL REFERENCES users(id),
+				banned_at       DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (conversation_id, user_id)
+			);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS conversation_bans;
+			ALTER TABLE conversations DROP COLUMN is_locked;
+			ALTER TABLE users DROP COLUMN role;
+		`,
+	},
+	{
+		Version: 21,
+		Name:    "otp_replay_protection",
+		Up: `
+			ALTER TABLE user_otp ADD COLUMN last_counter INTEGER NOT NULL DEFAULT 0;
+		`,
+		Down: `
+			ALTER TABLE user_otp DROP COLUMN last_counter;
+		`,
+	},
+	{
+		Version: 22,
+		Name:    "password_changed_at",
+		Up: `
+			ALTER TABLE users ADD COLUMN password_changed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP;
+		`,
+		Down: `
+			ALTER TABLE users DROP COLUMN password_changed_at;
+		`,
+	},
+	{
+		Version: 23,
+		Name:    "sso_only_users",
+		Up: `
+			ALTER TABLE users ADD COLUMN sso_only BOOLEAN NOT NULL DEFAULT 0;
+		`,
+		Down: `
+			ALTER TABLE users DROP COLUMN sso_only;
+		`,
+	},
+	{
+		Version: 24,
+		Name:    "refresh_tokens",
+		Up: `
+			CREATE TABLE IF NOT EXISTS refresh_tokens (
+				id         INTEGER PRIMARY KEY,
+				user_id    INTEGER NOT NULL REFERENCES users(id),
+				token_hash TEXT NOT NULL UNIQUE,
+				expires_at DATETIME NOT NULL,
+				revoked_at DATETIME,
+				user_agent TEXT NOT NULL DEFAULT '',
+				ip         TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON refresh_tokens(user_id);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS refresh_tokens;
+		`,
+	},
+}
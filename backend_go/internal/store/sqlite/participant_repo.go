@@ -1,70 +1,178 @@
-package sqlite
-
-import (
-	"context"
-	"database/sql"
-	"fmt"
-
-	"backend_go/internal/domain"
-)
-
-type ParticipantRepo struct {
-	db *sql.DB
-}
-
-func NewParticipantRepo(db *sql.DB) *ParticipantRepo {
-	return &ParticipantRepo{db: db}
-}
-
-var _ domain.ParticipantRepository = (*ParticipantRepo)(nil)
-
-func (r *ParticipantRepo) ListParticipants(ctx context.Context, conversationID int64) ([]*domain.User, error) {
-	query := `
-		SELECT u.id, u.username, u.email, u.hashed_password, u.is_active, u.is_online, u.created_at, u.last_seen
-		FROM users u
-		JOIN conversation_participants cp ON cp.user_id = u.id
-		WHERE cp.conversation_id = ?
-		ORDER BY u.username ASC
-	`
-	rows, err := r.db.QueryContext(ctx, query, conversationID)
-	if err != nil {
-		return nil, fmt.Errorf("list participants: %w", err)
-	}
-	defer rows.Close()
-
-	var users []*domain.User
-	for rows.Next() {
-		u := &domain.User{}
-		if err := rows.Scan(
-			&u.ID,
-			&u.Username,
-			&u.Email,
-			&u.HashedPassword,
-			&u.IsActive,
-			&u.IsOnline,
-			&u.CreatedAt,
-			&u.LastSeen,
-		); err != nil {
-			return nil, fmt.Errorf("scan participant: %w", err)
-		}
-		users = append(users, u)
-	}
-	return users, nil
-}
-
-func (r *ParticipantRepo) IsParticipant(ctx context.Context, conversationID, userID int64) (bool, error) {
-	var exists int
-	err := r.db.QueryRowContext(ctx, `
-		SELECT 1
-		FROM conversation_participants
-		WHERE conversation_id = ? AND user_id = ?
-	`, conversationID, userID).Scan(&exists)
-	if err == sql.ErrNoRows {
-		return false, nil
-	}
-	if err != nil {
-		return false, fmt.Errorf("is participant: %w", err)
-	}
-	return true, nil
-}
-
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"backend_go/internal/domain"
+)
+
+type ParticipantRepo struct {
+	db *sql.DB
+}
+
+func NewParticipantRepo(db *sql.DB) *ParticipantRepo {
+	return &ParticipantRepo{db: db}
+}
+
+var _ domain.ParticipantRepository = (*ParticipantRepo)(nil)
+
+func (r *ParticipantRepo) ListParticipants(ctx context.Context, conversationID int64) ([]*domain.User, error) {
+	query := `
+		SELECT u.id, u.username, u.email, u.hashed_password, u.is_active, u.is_online, u.created_at, u.last_seen
+		FROM users u
+		JOIN conversation_participants cp ON cp.user_id = u.id
+		WHERE cp.conversation_id = ?
+		ORDER BY u.username ASC
+	`
+	rows, err := r.db.QueryContext(ctx, query, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("list participants: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*domain.User
+	for rows.Next() {
+		u := &domain.User{}
+		if err := rows.Scan(
+			&u.ID,
+			&u.Username,
+			&u.Email,
+			&u.HashedPassword,
+			&u.IsActive,
+			&u.IsOnline,
+			&u.CreatedAt,
+			&u.LastSeen,
+		); err != nil {
+			return nil, fmt.Errorf("scan participant: %w", err)
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func (r *ParticipantRepo) IsParticipant(ctx context.Context, conversationID, userID int64) (bool, error) {
+	var exists int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT 1
+		FROM conversation_participants
+		WHERE conversation_id = ? AND user_id = ?
+	`, conversationID, userID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("is participant: %w", err)
+	}
+	return true, nil
+}
+
+func (r *ParticipantRepo) GetRole(ctx context.Context, conversationID, userID int64) (domain.ConversationRole, error) {
+	var role domain.ConversationRole
+	err := r.db.QueryRowContext(ctx, `
+		SELECT role FROM conversation_participants
+		WHERE conversation_id = ? AND user_id = ?
+	`, conversationID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("get participant role: %w", err)
+	}
+	return role, nil
+}
+
+func (r *ParticipantRepo) SetRole(ctx context.Context, conversationID, userID int64, role domain.ConversationRole) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE conversation_participants SET role = ?
+		WHERE conversation_id = ? AND user_id = ?
+	`, role, conversationID, userID)
+	if err != nil {
+		return fmt.Errorf("set participant role: %w", err)
+	}
+	return nil
+}
+
+func (r *ParticipantRepo) AddParticipant(ctx context.Context, conversationID, userID int64, role domain.ConversationRole) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO conversation_participants (user_id, conversation_id, role, joined_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+	`, userID, conversationID, role)
+	if err != nil {
+		return fmt.Errorf("add participant: %w", err)
+	}
+	return nil
+}
+
+func (r *ParticipantRepo) RemoveParticipant(ctx context.Context, conversationID, userID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM conversation_participants WHERE conversation_id = ? AND user_id = ?
+	`, conversationID, userID)
+	if err != nil {
+		return fmt.Errorf("remove participant: %w", err)
+	}
+	return nil
+}
+
+// BanParticipant removes a user from the conversation and records the ban so
+// AddParticipant (and the moderator re-invite path) refuse to re-seat them.
+func (r *ParticipantRepo) BanParticipant(ctx context.Context, conversationID, userID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM conversation_participants WHERE conversation_id = ? AND user_id = ?
+	`, conversationID, userID); err != nil {
+		return fmt.Errorf("remove participant: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT OR IGNORE INTO conversation_bans (conversation_id, user_id, banned_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, conversationID, userID); err != nil {
+		return fmt.Errorf("insert ban: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (r *ParticipantRepo) IsBanned(ctx context.Context, conversationID, userID int64) (bool, error) {
+	var exists int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT 1
+		FROM conversation_bans
+		WHERE conversation_id = ? AND user_id = ?
+	`, conversationID, userID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("is banned: %w", err)
+	}
+	return true, nil
+}
+
+// UserDeletedMessageRepo implements domain.UserDeletedMessageRepository.
+type UserDeletedMessageRepo struct {
+	db *sql.DB
+}
+
+func NewUserDeletedMessageRepo(db *sql.DB) *UserDeletedMessageRepo {
+	return &UserDeletedMessageRepo{db: db}
+}
+
+var _ domain.UserDeletedMessageRepository = (*UserDeletedMessageRepo)(nil)
+
+func (r *UserDeletedMessageRepo) Create(ctx context.Context, userID, messageID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO user_deleted_messages (user_id, message_id, deleted_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT DO NOTHING
+	`, userID, messageID)
+	if err != nil {
+		return fmt.Errorf("insert user_deleted_message: %w", err)
+	}
+	return nil
+}
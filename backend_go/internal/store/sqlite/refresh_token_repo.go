@@ -0,0 +1,77 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"backend_go/internal/domain"
+)
+
+type RefreshTokenRepo struct {
+	db *sql.DB
+}
+
+func NewRefreshTokenRepo(db *sql.DB) *RefreshTokenRepo {
+	return &RefreshTokenRepo{db: db}
+}
+
+var _ domain.RefreshTokenRepository = (*RefreshTokenRepo)(nil)
+
+func (r *RefreshTokenRepo) Create(ctx context.Context, t *domain.RefreshToken) error {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (user_id, token_hash, expires_at, user_agent, ip, created_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, t.UserID, t.TokenHash, t.ExpiresAt, t.UserAgent, t.IP)
+	if err != nil {
+		return fmt.Errorf("create refresh token: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("last insert id: %w", err)
+	}
+	t.ID = id
+	return nil
+}
+
+func (r *RefreshTokenRepo) GetByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	t := &domain.RefreshToken{}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, user_id, token_hash, expires_at, revoked_at, user_agent, ip, created_at
+		FROM refresh_tokens WHERE token_hash = ?
+	`, tokenHash).Scan(&t.ID, &t.UserID, &t.TokenHash, &t.ExpiresAt, &t.RevokedAt, &t.UserAgent, &t.IP, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get refresh token: %w", err)
+	}
+	return t, nil
+}
+
+func (r *RefreshTokenRepo) Revoke(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("revoke refresh token: %w", err)
+	}
+	return nil
+}
+
+func (r *RefreshTokenRepo) RevokeAllForUser(ctx context.Context, userID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = ? AND revoked_at IS NULL
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("revoke all refresh tokens for user: %w", err)
+	}
+	return nil
+}
+
+func (r *RefreshTokenRepo) DeleteExpired(ctx context.Context, cutoff time.Time) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE expires_at < ?`, cutoff)
+	if err != nil {
+		return fmt.Errorf("delete expired refresh tokens: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"backend_go/internal/domain"
+)
+
+type SearchRepo struct {
+	db *sql.DB
+}
+
+func NewSearchRepo(db *sql.DB) *SearchRepo {
+	return &SearchRepo{db: db}
+}
+
+var _ domain.MessageSearchRepository = (*SearchRepo)(nil)
+
+// Search queries the messages_fts FTS5 virtual table migrations.go keeps in
+// sync via insert/update/delete triggers on messages.
+func (r *SearchRepo) Search(ctx context.Context, conversationIDs []int64, query string, limit int) ([]*domain.Message, error) {
+	if len(conversationIDs) == 0 || query == "" {
+		return nil, nil
+	}
+
+	placeholders := strings.Repeat(",?", len(conversationIDs)-1)
+	q := fmt.Sprintf(`
+		SELECT m.id, m.content, m.conversation_id, m.sender_id, m.created_at,
+		       m.file_path, m.file_type, m.fully_read_at, m.is_deleted, m.expires_at, m.view_once
+		FROM messages_fts f
+		JOIN messages m ON m.id = f.rowid
+		WHERE m.conversation_id IN (?%s)
+		  AND m.is_deleted = 0
+		  AND messages_fts MATCH ?
+		ORDER BY rank
+		LIMIT ?
+	`, placeholders)
+
+	args := make([]any, 0, len(conversationIDs)+2)
+	for _, id := range conversationIDs {
+		args = append(args, id)
+	}
+	args = append(args, query, limit)
+
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search messages: %w", err)
+	}
+	defer rows.Close()
+
+	var res []*domain.Message
+	for rows.Next() {
+		m := &domain.Message{}
+		if err := rows.Scan(
+			&m.ID, &m.Content, &m.ConversationID, &m.SenderID, &m.CreatedAt,
+			&m.FilePath, &m.FileType, &m.FullyReadAt, &m.IsDeleted, &m.ExpiresAt, &m.ViewOnce,
+		); err != nil {
+			return nil, fmt.Errorf("scan search result: %w", err)
+		}
+		res = append(res, m)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,86 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"backend_go/internal/domain"
+)
+
+type TusUploadRepo struct {
+	db *sql.DB
+}
+
+func NewTusUploadRepo(db *sql.DB) *TusUploadRepo {
+	return &TusUploadRepo{db: db}
+}
+
+var _ domain.TusUploadRepository = (*TusUploadRepo)(nil)
+
+func (r *TusUploadRepo) Create(ctx context.Context, u *domain.TusUpload) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO uploads_in_progress (id, owner_id, key, content_type, size, offset, metadata, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?)
+	`, u.ID, u.OwnerID, u.Key, u.ContentType, u.Size, u.Offset, u.Metadata, u.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("insert upload in progress: %w", err)
+	}
+	return r.db.QueryRowContext(ctx, `SELECT created_at FROM uploads_in_progress WHERE id = ?`, u.ID).Scan(&u.CreatedAt)
+}
+
+func (r *TusUploadRepo) GetByID(ctx context.Context, id string) (*domain.TusUpload, error) {
+	u := &domain.TusUpload{}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, owner_id, key, content_type, size, offset, metadata, created_at, expires_at
+		FROM uploads_in_progress WHERE id = ?
+	`, id).Scan(&u.ID, &u.OwnerID, &u.Key, &u.ContentType, &u.Size, &u.Offset, &u.Metadata, &u.CreatedAt, &u.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get upload in progress: %w", err)
+	}
+	return u, nil
+}
+
+func (r *TusUploadRepo) UpdateOffset(ctx context.Context, id string, offset int64) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE uploads_in_progress SET offset = ? WHERE id = ?`, offset, id)
+	if err != nil {
+		return fmt.Errorf("update upload offset: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *TusUploadRepo) Delete(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM uploads_in_progress WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete upload in progress: %w", err)
+	}
+	return nil
+}
+
+func (r *TusUploadRepo) ListExpired(ctx context.Context, before time.Time) ([]*domain.TusUpload, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, owner_id, key, content_type, size, offset, metadata, created_at, expires_at
+		FROM uploads_in_progress WHERE expires_at < ?
+	`, before)
+	if err != nil {
+		return nil, fmt.Errorf("list expired uploads: %w", err)
+	}
+	defer rows.Close()
+
+	var uploads []*domain.TusUpload
+	for rows.Next() {
+		u := &domain.TusUpload{}
+		if err := rows.Scan(&u.ID, &u.OwnerID, &u.Key, &u.ContentType, &u.Size, &u.Offset, &u.Metadata, &u.CreatedAt, &u.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("scan upload in progress: %w", err)
+		}
+		uploads = append(uploads, u)
+	}
+	return uploads, rows.Err()
+}
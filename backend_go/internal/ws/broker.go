@@ -0,0 +1,74 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Broker decouples the Hub from how a broadcast reaches other instances in
+// a horizontally-scaled deployment: Publish fans a message out to every
+// subscriber of a channel, local or remote, and each instance's Hub
+// subscribes once and delivers a message only to its own locally
+// registered sockets. Channels follow the "zchat.events.user.<id>" /
+// "zchat.events.global" naming scheme.
+type Broker interface {
+	Publish(ctx context.Context, channel string, payload []byte) error
+	// Subscribe delivers every payload published to a channel matching one
+	// of patterns (which may contain "*" globs) until ctx is cancelled.
+	Subscribe(ctx context.Context, patterns ...string) (<-chan BrokerMessage, error)
+
+	// NextSeq returns the next monotonic sequence number for userID's event
+	// stream, so clients can detect gaps and request a catch-up.
+	NextSeq(ctx context.Context, userID int64) (int64, error)
+
+	// TouchPresence (re)registers userID as online on instanceID for ttl;
+	// callers must call it again before ttl elapses to stay marked online.
+	TouchPresence(ctx context.Context, userID int64, instanceID string, ttl time.Duration) error
+	// EndPresence clears userID's presence entry for instanceID.
+	EndPresence(ctx context.Context, userID int64, instanceID string) error
+	// OnlineUserIDs returns every user with a live presence entry anywhere
+	// in the cluster.
+	OnlineUserIDs(ctx context.Context) ([]int64, error)
+}
+
+// BrokerMessage is a single delivery handed back from Subscribe.
+type BrokerMessage struct {
+	Channel string
+	Payload []byte
+}
+
+// Envelope wraps a broadcast payload with a per-user monotonic sequence
+// number. Clients track the last Seq they saw and, on a gap, request a
+// catch-up instead of assuming nothing was missed.
+type Envelope struct {
+	Seq   int64 `json:"seq"`
+	Event any   `json:"event"`
+}
+
+// globalPresenceUserID sequences BroadcastAll events; real user IDs start
+// at 1, so 0 never collides with one.
+const globalPresenceUserID int64 = 0
+
+const (
+	userChannelPrefix  = "zchat.events.user."
+	userChannelPattern = userChannelPrefix + "*"
+	globalChannel      = "zchat.events.global"
+)
+
+func userChannel(userID int64) string {
+	return fmt.Sprintf("%s%d", userChannelPrefix, userID)
+}
+
+func parseUserChannel(channel string) (int64, bool) {
+	if !strings.HasPrefix(channel, userChannelPrefix) {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(channel, userChannelPrefix), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
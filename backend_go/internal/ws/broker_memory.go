@@ -0,0 +1,114 @@
+package ws
+
+import (
+	"context"
+	"path"
+	"sync"
+	"time"
+)
+
+// MemoryBroker is the single-instance Broker: Publish delivers straight to
+// this process's own subscribers, and presence entries never expire since
+// there is no other instance for one to outlive. It is the default when no
+// Redis connection is configured.
+type MemoryBroker struct {
+	mu   sync.Mutex
+	subs []memorySub
+	seqs map[int64]int64
+
+	presenceMu sync.Mutex
+	presence   map[int64]map[string]struct{} // userID -> set of instanceIDs
+}
+
+type memorySub struct {
+	pattern string
+	ch      chan BrokerMessage
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		seqs:     make(map[int64]int64),
+		presence: make(map[int64]map[string]struct{}),
+	}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, channel string, payload []byte) error {
+	b.mu.Lock()
+	subs := make([]memorySub, len(b.subs))
+	copy(subs, b.subs)
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		if ok, _ := path.Match(s.pattern, channel); ok {
+			select {
+			case s.ch <- BrokerMessage{Channel: channel, Payload: payload}:
+			case <-ctx.Done():
+			}
+		}
+	}
+	return nil
+}
+
+// Subscribe registers out against every pattern and drops it once ctx is
+// cancelled. The channel is left open rather than closed on unsubscribe,
+// since Publish may still hold a reference to it concurrently; the consumer
+// is expected to stop reading once its own ctx is done, not range over it.
+func (b *MemoryBroker) Subscribe(ctx context.Context, patterns ...string) (<-chan BrokerMessage, error) {
+	out := make(chan BrokerMessage, 16)
+	b.mu.Lock()
+	for _, p := range patterns {
+		b.subs = append(b.subs, memorySub{pattern: p, ch: out})
+	}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		filtered := b.subs[:0]
+		for _, s := range b.subs {
+			if s.ch != out {
+				filtered = append(filtered, s)
+			}
+		}
+		b.subs = filtered
+	}()
+	return out, nil
+}
+
+func (b *MemoryBroker) NextSeq(ctx context.Context, userID int64) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seqs[userID]++
+	return b.seqs[userID], nil
+}
+
+func (b *MemoryBroker) TouchPresence(ctx context.Context, userID int64, instanceID string, ttl time.Duration) error {
+	b.presenceMu.Lock()
+	defer b.presenceMu.Unlock()
+	if b.presence[userID] == nil {
+		b.presence[userID] = make(map[string]struct{})
+	}
+	b.presence[userID][instanceID] = struct{}{}
+	return nil
+}
+
+func (b *MemoryBroker) EndPresence(ctx context.Context, userID int64, instanceID string) error {
+	b.presenceMu.Lock()
+	defer b.presenceMu.Unlock()
+	delete(b.presence[userID], instanceID)
+	if len(b.presence[userID]) == 0 {
+		delete(b.presence, userID)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) OnlineUserIDs(ctx context.Context) ([]int64, error) {
+	b.presenceMu.Lock()
+	defer b.presenceMu.Unlock()
+	ids := make([]int64, 0, len(b.presence))
+	for uid := range b.presence {
+		ids = append(ids, uid)
+	}
+	return ids, nil
+}
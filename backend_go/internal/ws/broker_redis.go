@@ -0,0 +1,106 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisBroker fans broadcasts out across every backend replica via Redis
+// Pub/Sub and tracks cluster-wide presence as short-lived keys, so a
+// crashed instance's connections age out instead of leaving a user stuck
+// "online".
+type RedisBroker struct {
+	client *redis.Client
+}
+
+func NewRedisBroker(client *redis.Client) *RedisBroker {
+	return &RedisBroker{client: client}
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, channel string, payload []byte) error {
+	return b.client.Publish(ctx, channel, payload).Err()
+}
+
+// Subscribe pattern-subscribes once for every caller rather than opening a
+// connection per user channel, which would not scale with the number of
+// online users.
+func (b *RedisBroker) Subscribe(ctx context.Context, patterns ...string) (<-chan BrokerMessage, error) {
+	pubsub := b.client.PSubscribe(ctx, patterns...)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return nil, fmt.Errorf("psubscribe %v: %w", patterns, err)
+	}
+
+	out := make(chan BrokerMessage, 16)
+	go func() {
+		defer pubsub.Close()
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				select {
+				case out <- BrokerMessage{Channel: msg.Channel, Payload: []byte(msg.Payload)}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+const seqKeyPrefix = "zchat:seq:user:"
+
+func (b *RedisBroker) NextSeq(ctx context.Context, userID int64) (int64, error) {
+	return b.client.Incr(ctx, seqKeyPrefix+strconv.FormatInt(userID, 10)).Result()
+}
+
+const presenceKeyPrefix = "zchat:presence:"
+
+func presenceKey(userID int64, instanceID string) string {
+	return fmt.Sprintf("%s%d:%s", presenceKeyPrefix, userID, instanceID)
+}
+
+func (b *RedisBroker) TouchPresence(ctx context.Context, userID int64, instanceID string, ttl time.Duration) error {
+	return b.client.Set(ctx, presenceKey(userID, instanceID), "1", ttl).Err()
+}
+
+func (b *RedisBroker) EndPresence(ctx context.Context, userID int64, instanceID string) error {
+	return b.client.Del(ctx, presenceKey(userID, instanceID)).Err()
+}
+
+// OnlineUserIDs scans every live presence key and dedupes by user ID, since
+// a user connected to several instances holds one entry per instance.
+func (b *RedisBroker) OnlineUserIDs(ctx context.Context) ([]int64, error) {
+	seen := make(map[int64]struct{})
+	iter := b.client.Scan(ctx, 0, presenceKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		rest := strings.TrimPrefix(iter.Val(), presenceKeyPrefix)
+		idStr, _, ok := strings.Cut(rest, ":")
+		if !ok {
+			continue
+		}
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			continue
+		}
+		seen[id] = struct{}{}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("scan presence keys: %w", err)
+	}
+	ids := make([]int64, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
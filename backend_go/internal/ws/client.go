@@ -0,0 +1,189 @@
+package ws
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// sendQueueSize bounds how many not-yet-written events a single
+	// connection can have buffered before WriteEvent starts failing with
+	// ErrSendQueueFull instead of blocking the writer (the Hub's single
+	// Run goroutine) on one slow client.
+	sendQueueSize = 256
+
+	// writeWait bounds how long a single WriteMessage/WriteControl call may
+	// block the writer goroutine.
+	writeWait = 10 * time.Second
+
+	// pongWait is how long a connection may go without a pong before it's
+	// considered dead; pingPeriod (comfortably under pongWait) is how often
+	// writePump sends a ping to keep that deadline refreshed.
+	pongWait   = 60 * time.Second
+	pingPeriod = (pongWait * 9) / 10
+)
+
+// ErrSendQueueFull is returned by Client.WriteEvent when the connection's
+// send queue is already full — the caller (Hub.deliverLocally) treats this
+// as "this client is too slow" and drops it rather than blocking everyone
+// else's delivery on it.
+var ErrSendQueueFull = errors.New("ws: send queue full")
+
+// Client is a single registered WebSocket connection together with the wire
+// codec negotiated for it. Every write goes through a bounded send queue
+// drained by one dedicated writePump goroutine, so concurrent callers (the
+// Hub's broadcast path and the connection's own read loop via SendError)
+// never race on the same *websocket.Conn, and one slow reader can't stall
+// delivery to everyone else.
+type Client struct {
+	conn  *websocket.Conn
+	codec Codec
+
+	send   chan []byte
+	closed chan struct{}
+
+	closeOnce   sync.Once
+	closeCode   int
+	closeReason string
+}
+
+// NewClient wraps conn with its negotiated codec, applies maxMessageSize and
+// the read-deadline/pong-handler pair that detects a dead connection, and
+// starts the writer goroutine that owns every write to conn from here on.
+func NewClient(conn *websocket.Conn, codec Codec, maxMessageSize int64) *Client {
+	c := &Client{
+		conn:   conn,
+		codec:  codec,
+		send:   make(chan []byte, sendQueueSize),
+		closed: make(chan struct{}),
+	}
+
+	conn.SetReadLimit(maxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	go c.writePump()
+	return c
+}
+
+// writePump is the only goroutine that ever calls conn.WriteMessage/
+// WriteControl; it drains the send queue, pings on pingPeriod to keep the
+// client's read deadline alive, and tears the connection down once closed
+// fires (from Close/CloseSlow) or a write fails.
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case data := <-c.send:
+			wsSendQueueDepth.Dec()
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(c.codec.FrameType(), data); err != nil {
+				return
+			}
+
+		case <-c.closed:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, c.closeReason), time.Now().Add(writeWait))
+			return
+
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// WriteEvent marshals v with the client's codec and enqueues it for
+// writePump. It never blocks: a full queue (a client that isn't reading
+// fast enough) returns ErrSendQueueFull, and a closed connection returns
+// ErrClientClosed, both of which Hub.deliverLocally treats as "drop this
+// client" rather than stalling delivery to everyone else.
+func (c *Client) WriteEvent(v any) error {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case c.send <- data:
+		wsSendQueueDepth.Inc()
+		wsMessagesSentTotal.WithLabelValues(eventTypeLabel(v)).Inc()
+		return nil
+	case <-c.closed:
+		return ErrClientClosed
+	default:
+		return ErrSendQueueFull
+	}
+}
+
+// ErrClientClosed is returned by WriteEvent once the client has been closed.
+var ErrClientClosed = errors.New("ws: client closed")
+
+// ReadEvent reads the next frame from the client and unmarshals it into v
+// using the client's negotiated codec.
+func (c *Client) ReadEvent(v any) error {
+	_, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return c.codec.Unmarshal(data, v)
+}
+
+// SendError writes an {"type":"error"} event to the client in its codec.
+func (c *Client) SendError(msg string) {
+	_ = c.WriteEvent(map[string]any{
+		"type":    "error",
+		"message": msg,
+	})
+}
+
+// Close tears the connection down with a normal closure. Safe to call more
+// than once or concurrently with WriteEvent/CloseSlow.
+func (c *Client) Close() error {
+	c.closeWith(websocket.CloseNormalClosure, "")
+	return nil
+}
+
+// CloseSlow tears the connection down with code 1013 ("try again later"),
+// the close code Hub.deliverLocally uses when it drops this client for
+// falling behind rather than for a network error or normal disconnect.
+func (c *Client) CloseSlow() error {
+	c.closeWith(websocket.CloseTryAgainLater, "slow consumer")
+	return nil
+}
+
+func (c *Client) closeWith(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeCode = code
+		c.closeReason = reason
+		close(c.closed)
+	})
+}
+
+// eventTypeLabel extracts the "type" field from v for the
+// zchat_ws_messages_sent_total label, covering the shapes WriteEvent
+// actually receives: an Envelope wrapping a map[string]any (the normal
+// broadcast path) or a bare map[string]any (SendError's own error event).
+func eventTypeLabel(v any) string {
+	switch val := v.(type) {
+	case Envelope:
+		return eventTypeLabel(val.Event)
+	case map[string]any:
+		if t, ok := val["type"].(string); ok && t != "" {
+			return t
+		}
+	}
+	return "unknown"
+}
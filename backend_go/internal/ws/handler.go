@@ -2,6 +2,7 @@ package ws
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,7 +11,9 @@ import (
 
 	"github.com/gorilla/websocket"
 
+	"backend_go/internal/call"
 	"backend_go/internal/domain"
+	"backend_go/internal/federation"
 	"backend_go/internal/security"
 	"backend_go/internal/service"
 )
@@ -104,22 +107,29 @@ func userInParticipants(userID int64, participantIDs []int64) bool {
 //   - typing           -> forward typing indicator to other participants
 //   - edit_message     -> edit + broadcast message_edited
 //   - delete_message   -> delete for_me / for_everyone + broadcast
-//   - call_offer / call_answer / ice_candidate / call_end / call_rejected -> forward to target
+//   - call.accept / call.reject / call.sdp / call.ice / call.hangup -> relay to the other call participant
+//
+// bridge may be nil (federation disabled), in which case messages and
+// typing indicators never leave this server.
 func MakeHandler(
 	hub *Hub,
 	tokens *security.TokenService,
 	users domain.UserRepository,
 	convs domain.ConversationRepository,
-	msgSvc *service.MessageService,
+	msgSvc service.Messages,
 	encryptor *security.Encryptor,
+	callSvc *call.Service,
+	bridge *federation.Bridge,
 	allowedOrigins []string,
+	maxMessageBytes int64,
 ) http.HandlerFunc {
 	checkOrigin := makeCheckOrigin(allowedOrigins)
 	upgrader := websocket.Upgrader{
 		CheckOrigin: checkOrigin,
-		Subprotocols: []string{
-			"bearer",
-		},
+		// "bearer" carries the auth token (see extractTokenFromWSRequest);
+		// the zchat.*.v1 entries are wire codecs negotiated in negotiateCodec.
+		Subprotocols:      append([]string{"bearer"}, codecSubprotocols...),
+		EnableCompression: true,
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -161,13 +171,16 @@ func MakeHandler(
 			return
 		}
 		defer conn.Close()
+		conn.EnableWriteCompression(true)
+
+		client := NewClient(conn, negotiateCodec(r.Header.Get("Sec-WebSocket-Protocol")), maxMessageBytes)
 
 		if err := users.SetOnlineStatus(ctx, user.ID, true); err != nil {
 			log.Printf("ws: set online for %d: %v", user.ID, err)
 		}
-		hub.Register(user.ID, conn)
+		hub.Register(user.ID, client)
 		defer func() {
-			hub.Unregister(user.ID, conn)
+			hub.Unregister(user.ID, client)
 			if err := users.SetOnlineStatus(context.Background(), user.ID, false); err != nil {
 				log.Printf("ws: set offline for %d: %v", user.ID, err)
 			}
@@ -185,7 +198,7 @@ func MakeHandler(
 
 		for {
 			var payload map[string]any
-			if err := conn.ReadJSON(&payload); err != nil {
+			if err := client.ReadEvent(&payload); err != nil {
 				break
 			}
 			msgType, _ := payload["type"].(string)
@@ -197,8 +210,10 @@ func MakeHandler(
 				content, _ := payload["content"].(string)
 				filePath, _ := payload["file_path"].(string)
 				fileType, _ := payload["file_type"].(string)
+				ttlSecondsF, _ := payload["ttl_seconds"].(float64)
+				viewOnce, _ := payload["view_once"].(bool)
 				if convIDf == 0 || (content == "" && filePath == "") {
-					sendError(conn, "message requires conversation_id and non-empty content or file")
+					client.SendError("message requires conversation_id and non-empty content or file")
 					continue
 				}
 				var fpPtr, ftPtr *string
@@ -213,10 +228,12 @@ func MakeHandler(
 					Content:        content,
 					FilePath:       fpPtr,
 					FileType:       ftPtr,
+					TTLSeconds:     int(ttlSecondsF),
+					ViewOnce:       viewOnce,
 				}, user.ID)
 				if err != nil {
 					log.Printf("ws: create message: %v", err)
-					sendError(conn, "failed to send message")
+					client.SendError("failed to send message")
 					continue
 				}
 				resp, err := msgSvc.ToResponse(ctx, msg)
@@ -241,7 +258,14 @@ func MakeHandler(
 					"file_type":       resp.FileType,
 					"is_deleted":      resp.IsDeleted,
 					"is_read":         false,
+					"expires_at":      resp.ExpiresAt,
+					"view_once":       resp.ViewOnce,
 				})
+				if bridge != nil {
+					if err := bridge.PublishMessage(ctx, resp.ConversationID, user.ID, user.Username, resp.CreatedAt, content, viewOnce); err != nil {
+						log.Printf("ws: federation publish message: %v", err)
+					}
+				}
 
 			// ── mark read ────────────────────────────────────────────────────
 			case "mark_read":
@@ -252,7 +276,7 @@ func MakeHandler(
 				convID := int64(convIDf)
 				if err := msgSvc.MarkAllReadInConversation(ctx, convID, user.ID); err != nil {
 					log.Printf("ws: mark_read: %v", err)
-					sendError(conn, "failed to mark messages as read")
+					client.SendError("failed to mark messages as read")
 					continue
 				}
 				participantIDs, _ := msgSvc.GetParticipantIDs(ctx, convID)
@@ -271,7 +295,7 @@ func MakeHandler(
 				convID := int64(convIDf)
 				participantIDs, err := msgSvc.GetParticipantIDs(ctx, convID)
 				if err != nil || !userInParticipants(user.ID, participantIDs) {
-					sendError(conn, "not allowed for this conversation")
+					client.SendError("not allowed for this conversation")
 					continue
 				}
 				var others []int64
@@ -286,6 +310,11 @@ func MakeHandler(
 					"user_id":         user.ID,
 					"username":        user.Username,
 				})
+				if bridge != nil {
+					if err := bridge.PublishTyping(ctx, convID, user.ID, user.Username); err != nil {
+						log.Printf("ws: federation publish typing: %v", err)
+					}
+				}
 
 			// ── edit message ─────────────────────────────────────────────────
 			case "edit_message":
@@ -297,7 +326,7 @@ func MakeHandler(
 				updated, err := msgSvc.EditMessage(ctx, user.ID, int64(msgIDf), content)
 				if err != nil {
 					log.Printf("ws: edit_message: %v", err)
-					sendError(conn, "failed to edit message")
+					client.SendError("failed to edit message")
 					continue
 				}
 				resp, _ := msgSvc.ToResponse(ctx, updated)
@@ -325,9 +354,17 @@ func MakeHandler(
 					continue
 				}
 				result, err := msgSvc.DeleteMessage(ctx, user.ID, int64(msgIDf), deleteType)
+				moderated := false
+				if err != nil && deleteType == "for_everyone" && errors.Is(err, service.ErrForbidden) {
+					// Not the sender and not an owner/admin of the
+					// conversation: let ForceDeleteMessage re-check the
+					// caller's site-wide role before giving up.
+					result, err = msgSvc.ForceDeleteMessage(ctx, user.ID, int64(msgIDf))
+					moderated = err == nil
+				}
 				if err != nil {
 					log.Printf("ws: delete_message: %v", err)
-					sendError(conn, "failed to delete message")
+					client.SendError("failed to delete message")
 					continue
 				}
 				if deleteType == "for_everyone" {
@@ -338,6 +375,15 @@ func MakeHandler(
 						"conversation_id": result.ConversationID,
 						"delete_type":     "for_everyone",
 					})
+					if moderated {
+						hub.BroadcastToUsers(participantIDs, map[string]any{
+							"type":            "moderation_action",
+							"action":          "force_delete_message",
+							"message_id":      int64(msgIDf),
+							"conversation_id": result.ConversationID,
+							"moderator_id":    user.ID,
+						})
+					}
 				} else {
 					hub.BroadcastToUsers([]int64{user.ID}, map[string]any{
 						"type":            "message_deleted",
@@ -348,34 +394,32 @@ func MakeHandler(
 				}
 
 			// ── WebRTC signaling ─────────────────────────────────────────────
-			case "call_offer", "call_answer", "ice_candidate", "call_end", "call_rejected":
-				targetIDf, _ := payload["target_user_id"].(float64)
+			// call.invite is sent by POST /api/conversations/{id}/calls, not
+			// over the socket; every other frame is relayed here once both
+			// sides hold the call_id that creation returned.
+			case call.FrameAccept, call.FrameReject, call.FrameSDP, call.FrameICE, call.FrameHangup:
+				callID, _ := payload["call_id"].(string)
+				targetIDf, _ := payload["to_user"].(float64)
 				convIDf, _ := payload["conversation_id"].(float64)
-				if targetIDf == 0 || convIDf == 0 {
-					sendError(conn, "call signaling requires target_user_id and conversation_id")
+				if callID == "" || targetIDf == 0 || convIDf == 0 {
+					client.SendError("call signaling requires call_id, to_user and conversation_id")
 					continue
 				}
 				convID := int64(convIDf)
 				targetID := int64(targetIDf)
 				participantIDs, err := msgSvc.GetParticipantIDs(ctx, convID)
 				if err != nil || !userInParticipants(user.ID, participantIDs) || !userInParticipants(targetID, participantIDs) {
-					sendError(conn, "not allowed for this conversation")
+					client.SendError("not allowed for this conversation")
 					continue
 				}
-				fwd := map[string]any{
-					"type":            msgType,
-					"conversation_id": convID,
-					"sender_id":       user.ID,
-					"sender_username": user.Username,
-					"target_user_id":  targetID,
-				}
-				if sdp, ok := payload["sdp"]; ok {
-					fwd["sdp"] = sdp
-				}
-				if candidate, ok := payload["candidate"]; ok {
-					fwd["candidate"] = candidate
-				}
-				hub.BroadcastToUsers([]int64{targetID}, fwd)
+				callSvc.Relay(call.Frame{
+					Type:           msgType,
+					ConversationID: convID,
+					FromUser:       user.ID,
+					ToUser:         targetID,
+					CallID:         callID,
+					Payload:        payload["payload"],
+				})
 
 			default:
 				log.Printf("ws: unknown event type %q from user %d", msgType, user.ID)
@@ -383,10 +427,3 @@ func MakeHandler(
 		}
 	}
 }
-
-func sendError(conn *websocket.Conn, msg string) {
-	_ = conn.WriteJSON(map[string]any{
-		"type":    "error",
-		"message": msg,
-	})
-}
@@ -1,121 +1,247 @@
-package ws
-
-import (
-	"github.com/gorilla/websocket"
-)
-
-// Hub maintains the set of active clients and broadcasts messages to the
-// clients.
-type Hub struct {
-	// Registered clients.
-	clients map[int64]map[*websocket.Conn]bool
-
-	// Inbound messages from the clients.
-	broadcast chan broadcastMessage
-
-	// Register requests from the clients.
-	register chan registerRequest
-
-	// Unregister requests from clients.
-	unregister chan unregisterRequest
-}
-
-type registerRequest struct {
-	userID int64
-	conn   *websocket.Conn
-}
-
-type unregisterRequest struct {
-	userID int64
-	conn   *websocket.Conn
-}
-
-type broadcastMessage struct {
-	targetUserIDs []int64 // if nil, broadcast to all
-	payload       any
-}
-
-func NewHub() *Hub {
-	return &Hub{
-		broadcast:  make(chan broadcastMessage),
-		register:   make(chan registerRequest),
-		unregister: make(chan unregisterRequest),
-		clients:    make(map[int64]map[*websocket.Conn]bool),
-	}
-}
-
-func (h *Hub) Run() {
-	for {
-		select {
-		case req := <-h.register:
-			if h.clients[req.userID] == nil {
-				h.clients[req.userID] = make(map[*websocket.Conn]bool)
-			}
-			h.clients[req.userID][req.conn] = true
-
-		case req := <-h.unregister:
-			if conns, ok := h.clients[req.userID]; ok {
-				if _, ok := conns[req.conn]; ok {
-					delete(conns, req.conn)
-					req.conn.Close()
-					if len(conns) == 0 {
-						delete(h.clients, req.userID)
-					}
-				}
-			}
-
-		case msg := <-h.broadcast:
-			if msg.targetUserIDs == nil {
-				// Broadcast to all
-				for uid, conns := range h.clients {
-					for conn := range conns {
-						if err := conn.WriteJSON(msg.payload); err != nil {
-							conn.Close()
-							delete(conns, conn)
-						}
-					}
-					if len(conns) == 0 {
-						delete(h.clients, uid)
-					}
-				}
-			} else {
-				// Broadcast to specific users
-				for _, uid := range msg.targetUserIDs {
-					if conns, ok := h.clients[uid]; ok {
-						for conn := range conns {
-							if err := conn.WriteJSON(msg.payload); err != nil {
-								conn.Close()
-								delete(conns, conn)
-							}
-						}
-						// If all connections for a user are dead, remove the user map
-						if len(conns) == 0 {
-							delete(h.clients, uid)
-						}
-					}
-				}
-			}
-		}
-	}
-}
-
-// Register adds a connection for the given user.
-func (h *Hub) Register(userID int64, conn *websocket.Conn) {
-	h.register <- registerRequest{userID: userID, conn: conn}
-}
-
-// Unregister removes a connection for the given user.
-func (h *Hub) Unregister(userID int64, conn *websocket.Conn) {
-	h.unregister <- unregisterRequest{userID: userID, conn: conn}
-}
-
-// BroadcastToUsers sends the given payload to all active connections of the
-// provided user IDs.
-func (h *Hub) BroadcastToUsers(userIDs []int64, payload any) {
-	h.broadcast <- broadcastMessage{targetUserIDs: userIDs, payload: payload}
-}
-
-// BroadcastAll sends the payload to all connected users.
-func (h *Hub) BroadcastAll(payload any) {
-	h.broadcast <- broadcastMessage{targetUserIDs: nil, payload: payload}
-}
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+)
+
+// Sink is anything Hub can deliver an event to: a registered WebSocket
+// connection (Client), or — since internal/grpcserver's Subscribe RPC has
+// no socket at all — a gRPC server-stream adapter. Hub only ever needs to
+// push an event and, on failure, tear the registration down, so that's all
+// this interface asks for.
+type Sink interface {
+	WriteEvent(v any) error
+	Close() error
+}
+
+// slowCloser is a Sink that can distinguish "closed because it fell behind"
+// from an ordinary close — currently only *Client, whose CloseSlow sends
+// code 1013 instead of a normal closure. Sinks without a real socket (the
+// gRPC stream adapter) just fall back to Close.
+type slowCloser interface {
+	CloseSlow() error
+}
+
+// Hub maintains the set of connections registered on this instance and
+// fans broadcasts out through a Broker, so a message reaches every instance
+// in a horizontally-scaled deployment rather than only the one a given
+// socket happens to be attached to.
+type Hub struct {
+	// Registered clients, local to this instance.
+	clients map[int64]map[Sink]bool
+
+	broker      Broker
+	instanceID  string
+	presenceTTL time.Duration
+
+	register   chan registerRequest
+	unregister chan unregisterRequest
+}
+
+type registerRequest struct {
+	userID int64
+	client Sink
+}
+
+type unregisterRequest struct {
+	userID int64
+	client Sink
+}
+
+// NewHub builds a Hub backed by broker. instanceID identifies this process
+// in the broker's cluster-wide presence set; presenceTTL is how long a
+// presence entry survives without a refresh, so an unclean shutdown ages
+// out instead of leaving a user stuck "online".
+func NewHub(broker Broker, instanceID string, presenceTTL time.Duration) *Hub {
+	return &Hub{
+		clients:     make(map[int64]map[Sink]bool),
+		broker:      broker,
+		instanceID:  instanceID,
+		presenceTTL: presenceTTL,
+		register:    make(chan registerRequest),
+		unregister:  make(chan unregisterRequest),
+	}
+}
+
+// Broker exposes the Hub's Broker so other services (e.g. UserService) can
+// consult the same cluster-wide presence set without either package
+// importing the other.
+func (h *Hub) Broker() Broker {
+	return h.broker
+}
+
+// InstanceID identifies this process in the broker's presence set.
+func (h *Hub) InstanceID() string {
+	return h.instanceID
+}
+
+// Run subscribes to the broker, processes register/unregister requests,
+// periodically refreshes this instance's presence entries, and delivers
+// broker events to locally registered sockets. It blocks until ctx is
+// cancelled.
+func (h *Hub) Run(ctx context.Context) {
+	incoming, err := h.broker.Subscribe(ctx, userChannelPattern, globalChannel)
+	if err != nil {
+		log.Printf("ws: subscribe to broker: %v", err)
+		incoming = make(chan BrokerMessage)
+	}
+
+	refresh := time.NewTicker(h.presenceTTL / 2)
+	defer refresh.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case req := <-h.register:
+			if h.clients[req.userID] == nil {
+				h.clients[req.userID] = make(map[Sink]bool)
+			}
+			h.clients[req.userID][req.client] = true
+			wsConnections.Inc()
+			if err := h.broker.TouchPresence(ctx, req.userID, h.instanceID, h.presenceTTL); err != nil {
+				log.Printf("ws: touch presence for %d: %v", req.userID, err)
+			}
+
+		case req := <-h.unregister:
+			if clients, ok := h.clients[req.userID]; ok {
+				if _, ok := clients[req.client]; ok {
+					delete(clients, req.client)
+					req.client.Close()
+					wsConnections.Dec()
+					if len(clients) == 0 {
+						delete(h.clients, req.userID)
+						if err := h.broker.EndPresence(ctx, req.userID, h.instanceID); err != nil {
+							log.Printf("ws: end presence for %d: %v", req.userID, err)
+						}
+					}
+				}
+			}
+
+		case <-refresh.C:
+			for uid := range h.clients {
+				if err := h.broker.TouchPresence(ctx, uid, h.instanceID, h.presenceTTL); err != nil {
+					log.Printf("ws: refresh presence for %d: %v", uid, err)
+				}
+			}
+
+		case msg := <-incoming:
+			h.deliverLocally(msg)
+		}
+	}
+}
+
+// deliverLocally writes a broker-relayed message to every socket this
+// instance holds for the message's target: every locally registered
+// connection for the global channel, or only the target user's locally
+// registered connections for a per-user channel.
+func (h *Hub) deliverLocally(msg BrokerMessage) {
+	var env Envelope
+	if err := json.Unmarshal(msg.Payload, &env); err != nil {
+		log.Printf("ws: unmarshal envelope: %v", err)
+		return
+	}
+
+	if msg.Channel == globalChannel {
+		for uid, clients := range h.clients {
+			h.deliverToClients(clients, env)
+			if len(clients) == 0 {
+				delete(h.clients, uid)
+			}
+		}
+		return
+	}
+
+	userID, ok := parseUserChannel(msg.Channel)
+	if !ok {
+		return
+	}
+	clients, ok := h.clients[userID]
+	if !ok {
+		return
+	}
+	h.deliverToClients(clients, env)
+	if len(clients) == 0 {
+		delete(h.clients, userID)
+	}
+}
+
+// deliverToClients writes env to every client in clients, dropping (and
+// removing from clients) any that errors: a client whose send queue is
+// already full is closed with code 1013 rather than a network error, since
+// it's falling behind rather than gone.
+func (h *Hub) deliverToClients(clients map[Sink]bool, env Envelope) {
+	for client := range clients {
+		err := client.WriteEvent(env)
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, ErrSendQueueFull) {
+			wsDroppedSlowClientsTotal.Inc()
+			if sc, ok := client.(slowCloser); ok {
+				sc.CloseSlow()
+			} else {
+				client.Close()
+			}
+		} else {
+			client.Close()
+		}
+		delete(clients, client)
+		wsConnections.Dec()
+	}
+}
+
+// Register adds a connection for the given user.
+func (h *Hub) Register(userID int64, client Sink) {
+	h.register <- registerRequest{userID: userID, client: client}
+}
+
+// Unregister removes a connection for the given user.
+func (h *Hub) Unregister(userID int64, client Sink) {
+	h.unregister <- unregisterRequest{userID: userID, client: client}
+}
+
+// BroadcastToUsers publishes payload, wrapped in a per-user sequenced
+// envelope, to each user's channel. Every instance (including this one)
+// receives it back through the broker subscription and delivers it only to
+// its own locally registered sockets for that user.
+func (h *Hub) BroadcastToUsers(userIDs []int64, payload any) {
+	ctx := context.Background()
+	for _, uid := range userIDs {
+		seq, err := h.broker.NextSeq(ctx, uid)
+		if err != nil {
+			log.Printf("ws: next seq for %d: %v", uid, err)
+		}
+		data, err := json.Marshal(Envelope{Seq: seq, Event: payload})
+		if err != nil {
+			log.Printf("ws: marshal envelope: %v", err)
+			continue
+		}
+		if err := h.broker.Publish(ctx, userChannel(uid), data); err != nil {
+			log.Printf("ws: publish to user %d: %v", uid, err)
+		}
+	}
+}
+
+// BroadcastAll publishes payload to the global channel; every instance
+// delivers it to whatever sockets it actually holds locally.
+func (h *Hub) BroadcastAll(payload any) {
+	ctx := context.Background()
+	seq, err := h.broker.NextSeq(ctx, globalPresenceUserID)
+	if err != nil {
+		log.Printf("ws: next seq for global: %v", err)
+	}
+	data, err := json.Marshal(Envelope{Seq: seq, Event: payload})
+	if err != nil {
+		log.Printf("ws: marshal envelope: %v", err)
+		return
+	}
+	if err := h.broker.Publish(ctx, globalChannel, data); err != nil {
+		log.Printf("ws: publish to global: %v", err)
+	}
+}
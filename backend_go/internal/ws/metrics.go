@@ -0,0 +1,32 @@
+package ws
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// These mirror internal/service/middleware's metrics.go: package-level
+// collectors registered once in init(), updated inline by Hub/Client rather
+// than through a decorator (there's no interface to wrap here).
+var (
+	wsConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "zchat_ws_connections",
+		Help: "Number of WebSocket connections currently registered on this instance.",
+	})
+	wsMessagesSentTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "zchat_ws_messages_sent_total",
+			Help: "Total events written to a WebSocket connection, labeled by event type.",
+		},
+		[]string{"type"},
+	)
+	wsSendQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "zchat_ws_send_queue_depth",
+		Help: "Total queued-but-not-yet-written events summed across every connection's send queue.",
+	})
+	wsDroppedSlowClientsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "zchat_ws_dropped_slow_clients_total",
+		Help: "Total connections closed with code 1013 for falling behind on their send queue.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(wsConnections, wsMessagesSentTotal, wsSendQueueDepth, wsDroppedSlowClientsTotal)
+}